@@ -45,8 +45,27 @@ const (
 	// RuntimeContainerMetaKey is a key in pod annotations. Kruise-daemon should report the
 	// states of runtime containers into its value, which is a structure JSON of RuntimeContainerMetaSet type.
 	RuntimeContainerMetaKey = "apps.kruise.io/runtime-containers-meta"
+
+	// InPlaceUpdateRecreateContainerKey is an annotation in pod template. If its value is "true", the changes of
+	// plain env values, command and args of containers can be in-place updated by recreating the changed containers.
+	// The values are projected into pod annotations when pods are created, and containers read them by env from metadata.
+	// It requires the InPlaceUpdateEnvFromMetadata feature-gate.
+	InPlaceUpdateRecreateContainerKey = "apps.kruise.io/inplace-update-recreate-container"
+)
+
+// Reasons for the InPlaceUpdateNotPossible condition of workloads, which is reported when pods can not be
+// updated in place to the update revision. The message of condition records the fields that prevent it.
+const (
+	// InPlaceUpdateFallbackToRecreateReason means pods will be recreated instead with InPlaceIfPossible strategy.
+	InPlaceUpdateFallbackToRecreateReason = "FallbackToRecreate"
+	// InPlaceUpdateBlockedReason means pods can not be updated with InPlaceOnly strategy.
+	InPlaceUpdateBlockedReason = "InPlaceOnlyBlocked"
 )
 
+// InPlaceUpdateResizeFailedReason is the reason of InPlaceUpdateReady condition and events when the
+// in-place resizing of resources stays Infeasible or Deferred past the ResizeTimeoutSeconds.
+const InPlaceUpdateResizeFailedReason = "ResizeFailed"
+
 // InPlaceUpdateState records latest inplace-update state, including old statuses of containers.
 type InPlaceUpdateState struct {
 	// Revision is the updated revision hash.
@@ -101,6 +120,12 @@ type InPlaceUpdateContainerBatch struct {
 // to determine whether the InPlaceUpdate is completed.
 type InPlaceUpdateContainerStatus struct {
 	ImageID string `json:"imageID,omitempty"`
+
+	// RestartCount is the restart count of container before it is resized in-place.
+	RestartCount int32 `json:"restartCount,omitempty"`
+	// ResizeRestart indicates the container will be restarted by kubelet to resize its resources,
+	// for the resizePolicy of the changed resources is RestartContainer.
+	ResizeRestart bool `json:"resizeRestart,omitempty"`
 }
 
 // InPlaceUpdateStrategy defines the strategies for in-place update.
@@ -108,8 +133,34 @@ type InPlaceUpdateStrategy struct {
 	// GracePeriodSeconds is the timespan between set Pod status to not-ready and update images in Pod spec
 	// when in-place update a Pod.
 	GracePeriodSeconds int32 `json:"gracePeriodSeconds,omitempty"`
+
+	// ResizeTimeoutSeconds is the timespan to wait for the in-place resizing of resources to be done,
+	// when kubelet reports the resize of Pod as Infeasible or Deferred.
+	// It only works when InPlaceWorkloadVerticalScaling is enabled.
+	// Defaults to 0, which means waiting for the resize forever.
+	// +optional
+	ResizeTimeoutSeconds int32 `json:"resizeTimeoutSeconds,omitempty"`
+
+	// ResizeFailurePolicy indicates how to handle the Pod whose resize has timed out.
+	// Fail marks the in-place update of Pod failed by setting InPlaceUpdateReady condition to False,
+	// and Recreate deletes the Pod to be recreated with the update revision, which does not work for
+	// the InPlaceOnly strategy.
+	// Defaults to Fail.
+	// +optional
+	ResizeFailurePolicy InPlaceResizeFailurePolicyType `json:"resizeFailurePolicy,omitempty"`
 }
 
+// InPlaceResizeFailurePolicyType defines how to handle the Pod whose in-place resizing has failed.
+// +enum
+type InPlaceResizeFailurePolicyType string
+
+const (
+	// InPlaceResizeFailurePolicyFail marks the in-place update failed and keeps the Pod as it is.
+	InPlaceResizeFailurePolicyFail InPlaceResizeFailurePolicyType = "Fail"
+	// InPlaceResizeFailurePolicyRecreate deletes the Pod to be recreated with the update revision.
+	InPlaceResizeFailurePolicyRecreate InPlaceResizeFailurePolicyType = "Recreate"
+)
+
 func GetInPlaceUpdateState(obj metav1.Object) (string, bool) {
 	if v, ok := obj.GetAnnotations()[InPlaceUpdateStateKey]; ok {
 		return v, ok
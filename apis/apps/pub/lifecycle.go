@@ -16,6 +16,11 @@ limitations under the License.
 
 package pub
 
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 const (
 	LifecycleStateKey     = "lifecycle.apps.kruise.io/state"
 	LifecycleTimestampKey = "lifecycle.apps.kruise.io/timestamp"
@@ -49,6 +54,11 @@ type Lifecycle struct {
 	InPlaceUpdate *LifecycleHook `json:"inPlaceUpdate,omitempty"`
 	// PreNormal is the hook after Pod to be created and ready to be Normal.
 	PreNormal *LifecycleHook `json:"preNormal,omitempty"`
+	// PostInPlaceUpdate is the hook after the containers of Pod have been updated in-place and before Pod to be ready.
+	// The labels and finalizers in its handlers are added to Pod when the in-place update begins,
+	// and Pod is kept not ready until all of them have been removed.
+	// +optional
+	PostInPlaceUpdate *LifecycleHook `json:"postInPlaceUpdate,omitempty"`
 }
 
 type LifecycleHook struct {
@@ -60,4 +70,75 @@ type LifecycleHook struct {
 	// Currently, MarkPodNotReady only takes effect on InPlaceUpdate & PreDelete hook.
 	// Default to false.
 	MarkPodNotReady bool `json:"markPodNotReady,omitempty"`
+	// TimeoutSeconds is the maximum time for Pod to wait for this hook in the lifecycle state,
+	// after which the FailurePolicy takes effect.
+	// Default to 0, which means no timeout.
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// FailurePolicy defines what to do when the hook has timed out.
+	// - Proceed: Pod goes on to the next lifecycle state as if the hook has finished,
+	// and the labels and finalizers of a PreDelete hook are removed before Pod is deleted.
+	// - Block: Pod keeps waiting for the hook, and a warning event is recorded.
+	// Default to Block.
+	// +optional
+	FailurePolicy LifecycleHookFailurePolicyType `json:"failurePolicy,omitempty"`
+	// HTTPHandler calls an HTTP endpoint for Pod in the lifecycle state of this hook.
+	// If it is set, Pod waits in the lifecycle state until the endpoint responds with a 2xx status code,
+	// instead of waiting for the LabelsHandler and FinalizersHandler.
+	// +optional
+	HTTPHandler *LifecycleHTTPHandler `json:"httpHandler,omitempty"`
+}
+
+// LifecycleHTTPHandler describes an HTTP endpoint to call for a lifecycle hook.
+// The endpoint receives a POST request with the namespace, name, uid and lifecycle state of Pod in JSON.
+type LifecycleHTTPHandler struct {
+	// URL is the full URL of the endpoint, such as a Service URL like http://traffic-manager.default.svc/hooks.
+	// Exactly one of URL and Port should be set.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Port is the port of the endpoint on the Pod IP.
+	// Exactly one of URL and Port should be set.
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// Path is the path of the endpoint on the Pod IP.
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Scheme to connect to the endpoint on the Pod IP.
+	// Default to HTTP.
+	// +optional
+	Scheme v1.URIScheme `json:"scheme,omitempty"`
+	// TimeoutSeconds is the timeout of each request.
+	// Default to 3 seconds.
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// PeriodSeconds is how often to call the endpoint until it responds with a 2xx status code.
+	// Default to 10 seconds.
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+}
+
+// LifecycleHookFailurePolicyType defines what to do when a lifecycle hook has timed out.
+// +enum
+type LifecycleHookFailurePolicyType string
+
+const (
+	// LifecycleHookFailurePolicyProceed means Pod goes on as if the hook has finished.
+	LifecycleHookFailurePolicyProceed LifecycleHookFailurePolicyType = "Proceed"
+	// LifecycleHookFailurePolicyBlock means Pod keeps waiting for the hook.
+	LifecycleHookFailurePolicyBlock LifecycleHookFailurePolicyType = "Block"
+)
+
+// LifecycleStateStatus is the status of Pods waiting for hooks in a lifecycle state.
+type LifecycleStateStatus struct {
+	// State is the lifecycle state.
+	State LifecycleStateType `json:"state"`
+	// Replicas is the number of Pods in this state.
+	Replicas int32 `json:"replicas"`
+	// TimedOutReplicas is the number of Pods whose hook has timed out in this state.
+	// +optional
+	TimedOutReplicas int32 `json:"timedOutReplicas,omitempty"`
+	// OldestTimestamp is the earliest time when the Pods entered this state,
+	// which tells the longest time spent in it.
+	// +optional
+	OldestTimestamp *metav1.Time `json:"oldestTimestamp,omitempty"`
 }
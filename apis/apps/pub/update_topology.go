@@ -0,0 +1,105 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pub
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// UpdateTopologyStrategy is the strategy to update pods domain by domain.
+// Pods are grouped into topology domains by the value of topologyKey in their labels.
+// Only pods in one domain can be updated at a time, and the next domain will not begin to update
+// until all pods in the current domain have been updated and available for soakSeconds.
+type UpdateTopologyStrategy struct {
+	// TopologyKey is the key of pod labels to group pods into domains, which is usually set in the pod template.
+	// It is only looked up in pod labels, so node labels such as topology.kubernetes.io/zone are not allowed.
+	TopologyKey string `json:"topologyKey"`
+	// DomainOrder is the order of domains to update.
+	// Domains not in this list will be updated after the listed ones in alphabetical order,
+	// and pods without the topologyKey label will be updated at last.
+	// +optional
+	DomainOrder []string `json:"domainOrder,omitempty"`
+	// SoakSeconds is the minimum seconds that all pods in a domain should keep available
+	// after they have been updated, before the next domain begins to update. Defaults to 0.
+	// +optional
+	SoakSeconds int32 `json:"soakSeconds,omitempty"`
+}
+
+// UpdateTopologyPhase is the phase of the topology-batched update.
+type UpdateTopologyPhase string
+
+const (
+	// UpdateTopologyPhaseUpdating means pods in the current domain are updating.
+	UpdateTopologyPhaseUpdating UpdateTopologyPhase = "Updating"
+	// UpdateTopologyPhaseSoaking means pods in the current domain have been updated and available,
+	// and it is waiting for soakSeconds before the next domain.
+	UpdateTopologyPhaseSoaking UpdateTopologyPhase = "Soaking"
+	// UpdateTopologyPhaseCompleted means pods in all domains have been updated.
+	UpdateTopologyPhaseCompleted UpdateTopologyPhase = "Completed"
+)
+
+// UpdateTopologyStatus is the status of the topology-batched update.
+type UpdateTopologyStatus struct {
+	// Revision is the update revision of this topology-batched update.
+	Revision string `json:"revision,omitempty"`
+	// CurrentDomain is the domain that is updating or soaking.
+	// Empty value with Updating or Soaking phase means the pods without topologyKey label.
+	// +optional
+	CurrentDomain string `json:"currentDomain,omitempty"`
+	// Phase is the phase of the current domain.
+	Phase UpdateTopologyPhase `json:"phase,omitempty"`
+	// SoakStartTime is the time when the current domain began to soak.
+	// +optional
+	SoakStartTime *metav1.Time `json:"soakStartTime,omitempty"`
+	// CompletedDomains contains the domains that have been updated and soaked.
+	// +optional
+	CompletedDomains []string `json:"completedDomains,omitempty"`
+}
+
+// FieldsValidation checks invalid fields in UpdateTopologyStrategy.
+func (strategy *UpdateTopologyStrategy) FieldsValidation() error {
+	if strategy == nil {
+		return nil
+	}
+
+	if strategy.TopologyKey == "" {
+		return fmt.Errorf("topologyKey should not be empty")
+	}
+	if errs := validation.IsQualifiedName(strategy.TopologyKey); len(errs) > 0 {
+		return fmt.Errorf("invalid topologyKey %v: %v", strategy.TopologyKey, strings.Join(errs, ", "))
+	}
+	switch strategy.TopologyKey {
+	case v1.LabelTopologyZone, v1.LabelTopologyRegion, v1.LabelFailureDomainBetaZone, v1.LabelFailureDomainBetaRegion, v1.LabelHostname:
+		return fmt.Errorf("topologyKey %v is a node label, which is not looked up in pod labels", strategy.TopologyKey)
+	}
+	if strategy.SoakSeconds < 0 {
+		return fmt.Errorf("soakSeconds should not be negative")
+	}
+	m := make(map[string]struct{}, len(strategy.DomainOrder))
+	for _, domain := range strategy.DomainOrder {
+		if _, ok := m[domain]; ok {
+			return fmt.Errorf("duplicated domain %v in domainOrder", domain)
+		}
+		m[domain] = struct{}{}
+	}
+	return nil
+}
@@ -170,6 +170,11 @@ func (in *Lifecycle) DeepCopyInto(out *Lifecycle) {
 		*out = new(LifecycleHook)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PostInPlaceUpdate != nil {
+		in, out := &in.PostInPlaceUpdate, &out.PostInPlaceUpdate
+		*out = new(LifecycleHook)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Lifecycle.
@@ -182,6 +187,21 @@ func (in *Lifecycle) DeepCopy() *Lifecycle {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LifecycleHTTPHandler) DeepCopyInto(out *LifecycleHTTPHandler) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleHTTPHandler.
+func (in *LifecycleHTTPHandler) DeepCopy() *LifecycleHTTPHandler {
+	if in == nil {
+		return nil
+	}
+	out := new(LifecycleHTTPHandler)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LifecycleHook) DeepCopyInto(out *LifecycleHook) {
 	*out = *in
@@ -197,6 +217,11 @@ func (in *LifecycleHook) DeepCopyInto(out *LifecycleHook) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.HTTPHandler != nil {
+		in, out := &in.HTTPHandler, &out.HTTPHandler
+		*out = new(LifecycleHTTPHandler)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleHook.
@@ -209,6 +234,25 @@ func (in *LifecycleHook) DeepCopy() *LifecycleHook {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LifecycleStateStatus) DeepCopyInto(out *LifecycleStateStatus) {
+	*out = *in
+	if in.OldestTimestamp != nil {
+		in, out := &in.OldestTimestamp, &out.OldestTimestamp
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LifecycleStateStatus.
+func (in *LifecycleStateStatus) DeepCopy() *LifecycleStateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LifecycleStateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RuntimeContainerHashes) DeepCopyInto(out *RuntimeContainerHashes) {
 	*out = *in
@@ -317,3 +361,47 @@ func (in *UpdatePriorityWeightTerm) DeepCopy() *UpdatePriorityWeightTerm {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateTopologyStatus) DeepCopyInto(out *UpdateTopologyStatus) {
+	*out = *in
+	if in.SoakStartTime != nil {
+		in, out := &in.SoakStartTime, &out.SoakStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedDomains != nil {
+		in, out := &in.CompletedDomains, &out.CompletedDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateTopologyStatus.
+func (in *UpdateTopologyStatus) DeepCopy() *UpdateTopologyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateTopologyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdateTopologyStrategy) DeepCopyInto(out *UpdateTopologyStrategy) {
+	*out = *in
+	if in.DomainOrder != nil {
+		in, out := &in.DomainOrder, &out.DomainOrder
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdateTopologyStrategy.
+func (in *UpdateTopologyStrategy) DeepCopy() *UpdateTopologyStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdateTopologyStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -22,6 +22,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/apis/apps/v1beta1"
 )
 
 const (
@@ -35,6 +36,23 @@ const (
 	// CloneSetScalingExcludePreparingDeleteKey is the label key that enables scalingExcludePreparingDelete
 	// only for this CloneSet, which means it will calculate scale number excluding Pods in PreparingDelete state.
 	CloneSetScalingExcludePreparingDeleteKey = "apps.kruise.io/cloneset-scaling-exclude-preparing-delete"
+
+	// CloneSetResumeStepKey is the annotation key to resume a CloneSet rollout that is paused at a step without duration.
+	// Its value should be `<updateRevision>/<index>` of the paused step, so that it only resumes the step of
+	// the current rollout, and the steps of the following rollouts will not be resumed by it.
+	CloneSetResumeStepKey = "apps.kruise.io/cloneset-resume-step"
+
+	// CloneSetPVCRetentionFinalizer is the finalizer added to CloneSet whose persistentVolumeClaimRetentionPolicy.whenDeleted
+	// is Retain, so that the controller can release its PVCs before the CloneSet is deleted.
+	CloneSetPVCRetentionFinalizer = "apps.kruise.io/cloneset-pvc-retention"
+
+	// CloneSetReplaceOriginInstanceIDKey is the label key on a replacement pod created for scaleStrategy.podsToReplace,
+	// whose value is the instance-id of the original pod being replaced.
+	CloneSetReplaceOriginInstanceIDKey = "apps.kruise.io/cloneset-replace-origin-instance-id"
+
+	// CloneSetStandbyKey is the label key of standby pods created for scaleStrategy.standbyReplicas.
+	// It will be removed when the pod is promoted for scaling out.
+	CloneSetStandbyKey = "apps.kruise.io/cloneset-standby"
 )
 
 // CloneSetSpec defines the desired state of CloneSet
@@ -56,11 +74,16 @@ type CloneSetSpec struct {
 	Template v1.PodTemplateSpec `json:"template"`
 
 	// VolumeClaimTemplates is a list of claims that pods are allowed to reference.
-	// Note that PVC will be deleted when its pod has been deleted.
+	// Note that PVC will be deleted when its pod has been deleted, unless it is retained by persistentVolumeClaimRetentionPolicy.
 	// +kubebuilder:pruning:PreserveUnknownFields
 	// +kubebuilder:validation:Schemaless
 	VolumeClaimTemplates []v1.PersistentVolumeClaim `json:"volumeClaimTemplates,omitempty"`
 
+	// PersistentVolumeClaimRetentionPolicy describes the policy used for PVCs created from
+	// the CloneSet VolumeClaimTemplates. By default, PVCs are deleted together with their pods
+	// when the CloneSet is scaled in, and with the CloneSet when it is deleted.
+	PersistentVolumeClaimRetentionPolicy *CloneSetPersistentVolumeClaimRetentionPolicy `json:"persistentVolumeClaimRetentionPolicy,omitempty"`
+
 	// ScaleStrategy indicates the ScaleStrategy that will be employed to
 	// create and delete Pods in the CloneSet.
 	ScaleStrategy CloneSetScaleStrategy `json:"scaleStrategy,omitempty"`
@@ -82,6 +105,45 @@ type CloneSetSpec struct {
 
 	// Lifecycle defines the lifecycle hooks for Pods pre-available(pre-normal), pre-delete, in-place update.
 	Lifecycle *appspub.Lifecycle `json:"lifecycle,omitempty"`
+
+	// ProgressDeadlineSeconds is the maximum time in seconds for a CloneSet to make progress before it
+	// is considered to be failed. The CloneSet controller will continue to process failed CloneSets and
+	// a condition with a ProgressDeadlineExceeded reason will be surfaced in the CloneSet status.
+	// Note that progress will not be estimated during the time a CloneSet is paused.
+	// Not set by default, which means no Progressing condition will be reported.
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// RollbackPolicy indicates what to do when the CloneSet exceeds its progressDeadlineSeconds.
+	// Defaults to None, which means the CloneSet only reports the failure in its Progressing condition.
+	// Auto means the template will be reverted to the one of status.currentRevision.
+	RollbackPolicy CloneSetRollbackPolicyType `json:"rollbackPolicy,omitempty"`
+}
+
+// CloneSetRollbackPolicyType is the policy to roll back a CloneSet whose update is failed.
+type CloneSetRollbackPolicyType string
+
+const (
+	// CloneSetRollbackPolicyNone means the CloneSet will not be rolled back automatically.
+	CloneSetRollbackPolicyNone CloneSetRollbackPolicyType = "None"
+	// CloneSetRollbackPolicyAuto means the template of CloneSet will be reverted to the current revision
+	// once the update revision fails to progress within progressDeadlineSeconds.
+	CloneSetRollbackPolicyAuto CloneSetRollbackPolicyType = "Auto"
+)
+
+// CloneSetPersistentVolumeClaimRetentionPolicy describes the policy used for PVCs
+// created from the CloneSet VolumeClaimTemplates.
+type CloneSetPersistentVolumeClaimRetentionPolicy struct {
+	// WhenDeleted specifies what happens to PVCs created from CloneSet
+	// VolumeClaimTemplates when the CloneSet is deleted. The default policy
+	// of `Delete` causes those PVCs to be deleted with the CloneSet. The
+	// `Retain` policy causes PVCs to be released from the CloneSet and kept.
+	WhenDeleted v1beta1.PersistentVolumeClaimRetentionPolicyType `json:"whenDeleted,omitempty"`
+	// WhenScaled specifies what happens to PVCs created from CloneSet
+	// VolumeClaimTemplates when the CloneSet is scaled in. The default policy
+	// of `Delete` causes the PVCs of the deleted pods to be deleted. The
+	// `Retain` policy keeps them to be reused by the pods scaled out later,
+	// which can not work with scaleStrategy.disablePVCReuse.
+	WhenScaled v1beta1.PersistentVolumeClaimRetentionPolicyType `json:"whenScaled,omitempty"`
 }
 
 // CloneSetScaleStrategy defines strategies for pods scale.
@@ -89,6 +151,12 @@ type CloneSetScaleStrategy struct {
 	// PodsToDelete is the names of Pod should be deleted.
 	// Note that this list will be truncated for non-existing pod names.
 	PodsToDelete []string `json:"podsToDelete,omitempty"`
+	// PodsToReplace is the names of Pod should be replaced.
+	// Different from PodsToDelete, a replacement pod will be created first, and the original pod
+	// will not be deleted until the replacement is available. The number of pods replacing at the same time
+	// is limited by updateStrategy.maxSurge, so no pod will be replaced if maxSurge is 0.
+	// Note that this list will be truncated for non-existing pod names.
+	PodsToReplace []string `json:"podsToReplace,omitempty"`
 	// The maximum number of pods that can be unavailable for scaled pods.
 	// This field can control the changes rate of replicas for CloneSet so as to minimize the impact for users' service.
 	// The scale will fail if the number of unavailable pods were greater than this MaxUnavailable at scaling up.
@@ -98,6 +166,12 @@ type CloneSetScaleStrategy struct {
 	// Indicate if cloneSet will reuse already existed pvc to
 	// rebuild a new pod
 	DisablePVCReuse bool `json:"disablePVCReuse,omitempty"`
+
+	// StandbyReplicas is the number of warm standby pods to keep besides the replicas.
+	// Standby pods are created from the update revision and kept not ready by the KruisePodReady readiness gate,
+	// so they are excluded from Service endpoints and status.replicas.
+	// When scaling out, standby pods will be promoted instead of creating new pods from scratch.
+	StandbyReplicas int32 `json:"standbyReplicas,omitempty"`
 }
 
 // CloneSetUpdateStrategy defines strategies for pods update.
@@ -133,8 +207,42 @@ type CloneSetUpdateStrategy struct {
 	// - Note that pods will be scattered after priority sort. So, although priority strategy and scatter strategy can be applied together, we suggest to use either one of them.
 	// - If scatterStrategy is used, we suggest to just use one term. Otherwise, the update order can be hard to understand.
 	ScatterStrategy UpdateScatterStrategy `json:"scatterStrategy,omitempty"`
+	// TopologyStrategy makes pods updated domain by domain, such as zone by zone.
+	// Pods in the next domain will not be updated until all pods in the current domain
+	// have been updated and kept available for the soak seconds.
+	// - Note that pods in the same domain will still be sorted by priority and scatter strategies.
+	TopologyStrategy *appspub.UpdateTopologyStrategy `json:"topologyStrategy,omitempty"`
 	// InPlaceUpdateStrategy contains strategies for in-place update.
 	InPlaceUpdateStrategy *appspub.InPlaceUpdateStrategy `json:"inPlaceUpdateStrategy,omitempty"`
+	// Steps define the order of stages to roll out the update revision.
+	// Controller will go through these steps one by one, each of them sets the partition of its stage
+	// and may pause for a duration or until resumed manually after the stage has been finished.
+	// If steps are defined, the partition of current step overrides the partition above unless the latter is larger,
+	// and the partition above should not be larger than the partition of the last step.
+	// Steps will start over from the first one once the update revision has changed.
+	Steps []CloneSetUpdateStep `json:"steps,omitempty"`
+}
+
+// CloneSetUpdateStep defines a stage of CloneSet rollout.
+type CloneSetUpdateStep struct {
+	// Partition is the desired number of pods in old revisions at this step.
+	// Value can be an absolute number (ex: 5) or a percentage of desired pods (ex: 10%).
+	// Only one of partition and replicas can be set.
+	Partition *intstr.IntOrString `json:"partition,omitempty"`
+	// Replicas is the desired number of pods in update revision at this step.
+	// Value can be an absolute number (ex: 5) or a percentage of desired pods (ex: 10%).
+	// Only one of partition and replicas can be set.
+	Replicas *intstr.IntOrString `json:"replicas,omitempty"`
+	// Pause defines whether to pause after the pods of this step are all updated and available.
+	// If it is nil, the rollout goes on to the next step directly.
+	Pause *CloneSetUpdateStepPause `json:"pause,omitempty"`
+}
+
+// CloneSetUpdateStepPause defines how long a step pauses.
+type CloneSetUpdateStepPause struct {
+	// Duration is the seconds to pause before going on to the next step.
+	// If it is nil, the step will pause until it is resumed by the annotation apps.kruise.io/cloneset-resume-step.
+	Duration *int32 `json:"duration,omitempty"`
 }
 
 // CloneSetUpdateStrategyType defines strategies for pods in-place update.
@@ -187,6 +295,10 @@ type CloneSetStatus struct {
 	// This field is calculated via Replicas - Partition.
 	ExpectedUpdatedReplicas int32 `json:"expectedUpdatedReplicas,omitempty"`
 
+	// StandbyReplicas is the number of standby Pods created for scaleStrategy.standbyReplicas,
+	// which are not counted in replicas.
+	StandbyReplicas int32 `json:"standbyReplicas,omitempty"`
+
 	// UpdateRevision, if not empty, indicates the latest revision of the CloneSet.
 	UpdateRevision string `json:"updateRevision,omitempty"`
 
@@ -203,6 +315,98 @@ type CloneSetStatus struct {
 
 	// LabelSelector is label selectors for query over pods that should match the replica count used by HPA.
 	LabelSelector string `json:"labelSelector,omitempty"`
+
+	// CurrentStepIndex is the index of the step in updateStrategy.steps that the rollout is currently in.
+	// It is only set when steps are defined and the CloneSet is rolling out the update revision.
+	CurrentStepIndex *int32 `json:"currentStepIndex,omitempty"`
+
+	// StepStatuses are the states of each step in updateStrategy.steps for the current rollout.
+	StepStatuses []CloneSetUpdateStepStatus `json:"stepStatuses,omitempty"`
+
+	// VolumeClaims represents the status of compatibility between existing PVCs
+	// and their respective templates. It tracks whether the PersistentVolumeClaims have been updated
+	// to match any changes made to the volumeClaimTemplates, ensuring synchronization
+	// between the defined templates and the actual PersistentVolumeClaims in use.
+	// It is only reported if the CloneSetAutoResizePVCGate is enabled.
+	VolumeClaims []v1beta1.VolumeClaimStatus `json:"volumeClaims,omitempty"`
+
+	// RevisionReplicas is the breakdown of replicas by the revisions that pods are running on,
+	// keyed by the revision name.
+	RevisionReplicas map[string]CloneSetRevisionReplicas `json:"revisionReplicas,omitempty"`
+
+	// RolloutHistory records the recent rollouts of the CloneSet, the latest one comes last.
+	// At most MaxCloneSetRolloutHistory records are kept.
+	RolloutHistory []CloneSetRolloutRecord `json:"rolloutHistory,omitempty"`
+
+	// LifecycleStates records the Pods waiting for lifecycle hooks in each state.
+	LifecycleStates []appspub.LifecycleStateStatus `json:"lifecycleStates,omitempty"`
+
+	// UpdateTopology records the progress of the update when updateStrategy.topologyStrategy is set.
+	UpdateTopology *appspub.UpdateTopologyStatus `json:"updateTopology,omitempty"`
+}
+
+// CloneSetRevisionReplicas describes the replicas running on a revision.
+type CloneSetRevisionReplicas struct {
+	// Replicas is the number of Pods running on this revision.
+	Replicas int32 `json:"replicas"`
+	// ReadyReplicas is the number of Pods running on this revision and in ready state.
+	ReadyReplicas int32 `json:"readyReplicas"`
+	// AvailableReplicas is the number of Pods running on this revision and available for minReadySeconds.
+	AvailableReplicas int32 `json:"availableReplicas"`
+}
+
+// MaxCloneSetRolloutHistory is the max number of records kept in status.rolloutHistory.
+const MaxCloneSetRolloutHistory = 10
+
+// CloneSetRolloutOutcome is the outcome of a CloneSet rollout.
+type CloneSetRolloutOutcome string
+
+const (
+	// CloneSetRolloutProgressing means the rollout has not finished yet.
+	CloneSetRolloutProgressing CloneSetRolloutOutcome = "Progressing"
+	// CloneSetRolloutSucceeded means all Pods have been updated to the revision of the rollout.
+	CloneSetRolloutSucceeded CloneSetRolloutOutcome = "Succeeded"
+	// CloneSetRolloutRolledBack means the rollout has been rolled back to the current revision before it finished.
+	CloneSetRolloutRolledBack CloneSetRolloutOutcome = "RolledBack"
+	// CloneSetRolloutSuperseded means the rollout has been re-targeted to another new revision before it finished.
+	CloneSetRolloutSuperseded CloneSetRolloutOutcome = "Superseded"
+)
+
+// CloneSetRolloutRecord describes a rollout of CloneSet.
+type CloneSetRolloutRecord struct {
+	// Revision is the update revision of this rollout.
+	Revision string `json:"revision"`
+	// StartTime is the time when this rollout started.
+	StartTime metav1.Time `json:"startTime"`
+	// FinishTime is the time when this rollout finished, which is empty if the rollout is still progressing.
+	FinishTime *metav1.Time `json:"finishTime,omitempty"`
+	// Outcome is the outcome of this rollout.
+	Outcome CloneSetRolloutOutcome `json:"outcome"`
+}
+
+// CloneSetUpdateStepState is the state of a step during CloneSet rollout.
+type CloneSetUpdateStepState string
+
+const (
+	// CloneSetUpdateStepStatePending means the step has not started yet.
+	CloneSetUpdateStepStatePending CloneSetUpdateStepState = "Pending"
+	// CloneSetUpdateStepStateUpdating means the pods of the step are being updated.
+	CloneSetUpdateStepStateUpdating CloneSetUpdateStepState = "Updating"
+	// CloneSetUpdateStepStatePaused means the pods of the step have been updated and the step is paused.
+	CloneSetUpdateStepStatePaused CloneSetUpdateStepState = "Paused"
+	// CloneSetUpdateStepStateCompleted means the step has been finished.
+	CloneSetUpdateStepStateCompleted CloneSetUpdateStepState = "Completed"
+)
+
+// CloneSetUpdateStepStatus describes the state of a step during CloneSet rollout.
+type CloneSetUpdateStepStatus struct {
+	// State is the state of this step.
+	State CloneSetUpdateStepState `json:"state"`
+	// StartTime is the time when this step started updating.
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// FinishTime is the time when the pods of this step have been all updated and available.
+	// The pause of this step starts from this time.
+	FinishTime *metav1.Time `json:"finishTime,omitempty"`
 }
 
 // CloneSetConditionType is type for CloneSet conditions.
@@ -213,6 +417,26 @@ const (
 	CloneSetConditionFailedScale CloneSetConditionType = "FailedScale"
 	// CloneSetConditionFailedUpdate indicates cloneset controller failed to update pods.
 	CloneSetConditionFailedUpdate CloneSetConditionType = "FailedUpdate"
+	// CloneSetConditionProgressing indicates whether the update revision of cloneset is progressing,
+	// only reported when spec.progressDeadlineSeconds is set.
+	CloneSetConditionProgressing CloneSetConditionType = "Progressing"
+	// CloneSetConditionInPlaceUpdateNotPossible indicates pods of current revision can not be updated in place
+	// to the update revision, only reported with InPlaceIfPossible or InPlaceOnly update strategy.
+	CloneSetConditionInPlaceUpdateNotPossible CloneSetConditionType = "InPlaceUpdateNotPossible"
+)
+
+// Reasons for CloneSet Progressing condition.
+const (
+	// CloneSetNewRevisionCreatedReason means a new update revision has been created.
+	CloneSetNewRevisionCreatedReason = "NewRevisionCreated"
+	// CloneSetUpdatedReason means more pods have been updated or become available in the update revision.
+	CloneSetUpdatedReason = "CloneSetUpdated"
+	// CloneSetNewRevisionAvailableReason means all expected pods of the update revision are available.
+	CloneSetNewRevisionAvailableReason = "NewRevisionAvailable"
+	// CloneSetPausedReason means the update of CloneSet is paused, so the progress is not estimated.
+	CloneSetPausedReason = "CloneSetPaused"
+	// CloneSetProgressDeadlineExceededReason means the update revision failed to progress within progressDeadlineSeconds.
+	CloneSetProgressDeadlineExceededReason = "ProgressDeadlineExceeded"
 )
 
 // CloneSetCondition describes the state of a CloneSet at a certain point.
@@ -221,6 +445,8 @@ type CloneSetCondition struct {
 	Type CloneSetConditionType `json:"type"`
 	// Status of the condition, one of True, False, Unknown.
 	Status v1.ConditionStatus `json:"status"`
+	// The last time this condition was updated.
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
 	// Last time the condition transitioned from one status to another.
 	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
 	// The reason for the condition's last transition.
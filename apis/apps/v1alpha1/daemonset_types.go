@@ -20,6 +20,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
@@ -57,6 +58,19 @@ const (
 	DeprecatedSurgingRollingUpdateType RollingUpdateType = "Surging"
 )
 
+// DaemonSetConditionInPlaceUpdateNotPossible indicates pods of the last revision can not be updated in place
+// to the current revision, only reported with InPlaceIfPossible rolling update.
+const DaemonSetConditionInPlaceUpdateNotPossible appsv1.DaemonSetConditionType = "InPlaceUpdateNotPossible"
+
+// DaemonSetNodePoolAnnotationKey is the annotation on pods recording the name of the node pool,
+// whose patch the pod is created from. Pods created from the template without any patch have no such annotation.
+const DaemonSetNodePoolAnnotationKey = "apps.kruise.io/daemonset-node-pool"
+
+// DaemonSetResumeStageKey is the annotation key to resume a DaemonSet rolling update that is paused at a stage.
+// Its value should be `<revision>/<index>` of the paused stage, where revision is status.updateStage.revision,
+// so that it only resumes the stage of the current rolling update, like apps.kruise.io/cloneset-resume-step of CloneSet.
+const DaemonSetResumeStageKey = "apps.kruise.io/daemonset-resume-stage"
+
 // Spec to control the desired behavior of daemon set rolling update.
 type RollingUpdateDaemonSet struct {
 	// Type is to specify which kind of rollingUpdate.
@@ -116,6 +130,79 @@ type RollingUpdateDaemonSet struct {
 	// daemon set controller.
 	// +optional
 	Paused *bool `json:"paused,omitempty"`
+
+	// InPlaceUpdateStrategy contains strategies for in-place update,
+	// which only works with InPlaceIfPossible rollingUpdateType.
+	// +optional
+	InPlaceUpdateStrategy *appspub.InPlaceUpdateStrategy `json:"inPlaceUpdateStrategy,omitempty"`
+
+	// Stages divide the rolling update into stages, which are processed one by one.
+	// Only the nodes selected by the current and previous stages can be updated, and the next stage
+	// will not begin until all pods on these nodes have been updated and available, soaked and resumed.
+	// The remaining nodes will be updated after all stages have been completed.
+	// It works together with selector and partition, which still limit the nodes to update.
+	// +optional
+	Stages []DaemonSetUpdateStage `json:"stages,omitempty"`
+}
+
+// DaemonSetUpdateStage is a stage of the rolling update, which updates pods on a part of nodes.
+type DaemonSetUpdateStage struct {
+	// Name of the stage, which is reported in status.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// NodeSelector is a label query over nodes to update in this stage.
+	// Defaults to all nodes that should run the daemon pod.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// Replicas is the number or percentage of nodes selected by nodeSelector to update in this stage.
+	// Absolute number is calculated from percentage by rounding up. Defaults to 100%.
+	// +optional
+	Replicas *intstr.IntOrString `json:"replicas,omitempty"`
+
+	// SoakSeconds is the minimum seconds that pods updated in this stage should keep available
+	// before the next stage begins. Defaults to 0.
+	// +optional
+	SoakSeconds int32 `json:"soakSeconds,omitempty"`
+
+	// Pause indicates the rolling update will be paused after this stage has been completed and soaked,
+	// until it is resumed by the annotation apps.kruise.io/daemonset-resume-stage.
+	// +optional
+	Pause bool `json:"pause,omitempty"`
+}
+
+// DaemonSetUpdateStagePhase is the phase of the current stage of rolling update.
+type DaemonSetUpdateStagePhase string
+
+const (
+	// DaemonSetUpdateStagePhaseUpdating means pods on nodes of the current stage are updating.
+	DaemonSetUpdateStagePhaseUpdating DaemonSetUpdateStagePhase = "Updating"
+	// DaemonSetUpdateStagePhaseSoaking means pods on nodes of the current stage have been updated and available,
+	// and it is waiting for soakSeconds before the next stage.
+	DaemonSetUpdateStagePhaseSoaking DaemonSetUpdateStagePhase = "Soaking"
+	// DaemonSetUpdateStagePhasePaused means the current stage has been completed and soaked,
+	// and it is waiting to be resumed by the annotation apps.kruise.io/daemonset-resume-stage.
+	DaemonSetUpdateStagePhasePaused DaemonSetUpdateStagePhase = "Paused"
+	// DaemonSetUpdateStagePhaseCompleted means all stages have been completed,
+	// and the remaining nodes can be updated.
+	DaemonSetUpdateStagePhaseCompleted DaemonSetUpdateStagePhase = "Completed"
+)
+
+// DaemonSetUpdateStageStatus is the status of the staged rolling update.
+type DaemonSetUpdateStageStatus struct {
+	// Revision is the update revision of this staged rolling update.
+	Revision string `json:"revision,omitempty"`
+	// CurrentStage is the index of the current stage in rollingUpdate.stages.
+	CurrentStage int32 `json:"currentStage"`
+	// Name is the name of the current stage.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Phase is the phase of the current stage.
+	Phase DaemonSetUpdateStagePhase `json:"phase,omitempty"`
+	// SoakStartTime is the time when the current stage began to soak.
+	// +optional
+	SoakStartTime *metav1.Time `json:"soakStartTime,omitempty"`
 }
 
 // DaemonSetSpec defines the desired state of DaemonSet
@@ -138,6 +225,17 @@ type DaemonSetSpec struct {
 	// +kubebuilder:validation:Schemaless
 	Template corev1.PodTemplateSpec `json:"template"`
 
+	// NodePoolPatches are patches to the template for pods on the nodes of specific node pools,
+	// so that pods in different node pools can have different images, resources and so on.
+	// For each node, only the first patch whose nodeSelector matches the node is applied.
+	// Pods on the nodes moved to another node pool are regarded as old pods and updated by the update strategy.
+	// +optional
+	// +patchMergeKey=name
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=name
+	NodePoolPatches []DaemonSetNodePoolPatch `json:"nodePoolPatches,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
+
 	// An update strategy to replace existing DaemonSet pods with new pods.
 	// +optional
 	UpdateStrategy DaemonSetUpdateStrategy `json:"updateStrategy,omitempty"`
@@ -160,11 +258,26 @@ type DaemonSetSpec struct {
 	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
 
 	// Lifecycle defines the lifecycle hooks for Pods pre-delete, in-place update.
-	// Currently, we only support pre-delete hook for Advanced DaemonSet.
+	// Currently, we only support pre-delete and post-in-place-update hooks for Advanced DaemonSet,
+	// so timeoutSeconds and failurePolicy only take effect on them.
 	// +optional
 	Lifecycle *appspub.Lifecycle `json:"lifecycle,omitempty"`
 }
 
+// DaemonSetNodePoolPatch defines a patch to the template for pods on the nodes of a node pool.
+type DaemonSetNodePoolPatch struct {
+	// Name is the unique name of the node pool.
+	Name string `json:"name"`
+
+	// NodeSelector is a label query over nodes in the node pool.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector"`
+
+	// Patch is a strategic merge patch to the template for pods on the nodes in the node pool.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Patch runtime.RawExtension `json:"patch"`
+}
+
 // DaemonSetStatus defines the observed state of DaemonSet
 type DaemonSetStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -222,6 +335,13 @@ type DaemonSetStatus struct {
 
 	// DaemonSetHash is the controller-revision-hash, which represents the latest version of the DaemonSet.
 	DaemonSetHash string `json:"daemonSetHash"`
+
+	// LifecycleStates records the Pods waiting for lifecycle hooks in each state.
+	LifecycleStates []appspub.LifecycleStateStatus `json:"lifecycleStates,omitempty"`
+
+	// UpdateStage is the status of the staged rolling update, only reported when rollingUpdate.stages is set.
+	// +optional
+	UpdateStage *DaemonSetUpdateStageStatus `json:"updateStage,omitempty"`
 }
 
 // +genclient
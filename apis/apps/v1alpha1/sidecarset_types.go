@@ -19,6 +19,7 @@ package v1alpha1
 import (
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -31,6 +32,11 @@ const (
 	// sidecarSet to "version-2", and they write the "version-2" to InjectionStrategy.Revision.CustomVersion
 	// when they decided to promote the "version-2", to avoid some risks about gray deployment of SidecarSet.
 	SidecarSetCustomVersionLabel = "apps.kruise.io/sidecarset-custom-version"
+
+	// SidecarSetResumeStageKey is the annotation key to resume a SidecarSet rolling update that is paused at a stage.
+	// Its value should be `<revision>/<index>` of the paused stage, where revision is status.updateStage.revision,
+	// so that it only resumes the stage of the current rolling update, like apps.kruise.io/daemonset-resume-stage of DaemonSet.
+	SidecarSetResumeStageKey = "apps.kruise.io/sidecarset-resume-stage"
 )
 
 // SidecarSetSpec defines the desired state of SidecarSet
@@ -138,6 +144,45 @@ type SidecarContainer struct {
 	// TransferEnv will transfer env info from other container
 	// SourceContainerName is pod.spec.container[x].name; EnvName is pod.spec.container[x].Env.name
 	TransferEnv []TransferEnvVar `json:"transferEnv,omitempty"`
+
+	// ResourcesPolicy derives the resources of the sidecar container from the app containers of pod,
+	// which is evaluated when the sidecar container is injected, and overrides the resources of the container.
+	// If InPlaceWorkloadVerticalScaling is enabled, it is re-evaluated after the resources of the app containers are changed,
+	// and the sidecar container is resized in-place.
+	// +optional
+	ResourcesPolicy *SidecarResourcesPolicy `json:"resourcesPolicy,omitempty"`
+}
+
+// SidecarResourcesPolicy defines the expressions to derive the resources of sidecar container from the app containers.
+type SidecarResourcesPolicy struct {
+	// TargetContainerNames are the names of the app containers whose resources are summed.
+	// Defaults to all the containers in pod.spec.containers that are not injected by SidecarSets.
+	// +optional
+	TargetContainerNames []string `json:"targetContainerNames,omitempty"`
+
+	// Requests are the expressions of resource requests, which are derived from the sum of requests of the app containers.
+	// +optional
+	Requests map[corev1.ResourceName]SidecarResourceExpression `json:"requests,omitempty"`
+
+	// Limits are the expressions of resource limits, which are derived from the sum of limits of the app containers.
+	// The limit is not set if any app container has no limit of the resource.
+	// +optional
+	Limits map[corev1.ResourceName]SidecarResourceExpression `json:"limits,omitempty"`
+}
+
+// SidecarResourceExpression derives a resource quantity as a percentage of the sum of the app containers,
+// bounded by Min and Max.
+type SidecarResourceExpression struct {
+	// Percent is the percentage of the sum of the app containers, which must be greater than 0.
+	Percent int32 `json:"percent"`
+
+	// Min is the lower bound of the derived quantity.
+	// +optional
+	Min *resource.Quantity `json:"min,omitempty"`
+
+	// Max is the upper bound of the derived quantity.
+	// +optional
+	Max *resource.Quantity `json:"max,omitempty"`
 }
 
 type ShareVolumePolicy struct {
@@ -200,6 +245,19 @@ type SidecarSetInjectionStrategy struct {
 	// default is false
 	Paused bool `json:"paused,omitempty"`
 
+	// NativeSidecar indicates that the containers of SidecarSet are injected into pod.spec.initContainers
+	// with restartPolicy Always, as the native sidecar containers of Kubernetes, instead of pod.spec.containers.
+	// Then their startup and shutdown are ordered by kubelet, which requires the SidecarContainers feature of Kubernetes.
+	// HotUpgrade is not supported for native sidecar containers.
+	// default is false
+	NativeSidecar bool `json:"nativeSidecar,omitempty"`
+
+	// Preview indicates that the SidecarSet controller previews the injection of SidecarSet into the existing pods
+	// matched with it, and reports the results in status.injectionPreview without touching any pod.
+	// It can be used together with Paused to check the SidecarSet before it is injected into pods.
+	// default is false
+	Preview bool `json:"preview,omitempty"`
+
 	// Revision can help users rolling update SidecarSet safely. If users set
 	// this filed, SidecarSet will try to inject specific revision according to
 	// different policies.
@@ -280,6 +338,105 @@ type SidecarSetUpdateStrategy struct {
 	// - Note that pods will be scattered after priority sort. So, although priority strategy and scatter strategy can be applied together, we suggest to use either one of them.
 	// - If scatterStrategy is used, we suggest to just use one term. Otherwise, the update order can be hard to understand.
 	ScatterStrategy UpdateScatterStrategy `json:"scatterStrategy,omitempty"`
+
+	// RollbackTo, if not nil, indicates the SidecarSet rolls back the injected pods to the specific history revision
+	// instead of the latest one, following the Selector, Partition, MaxUnavailable and PriorityStrategy above.
+	// The newly created pods are also injected with this revision, regardless of injectionStrategy.revision.
+	// Like the in-place update, only the pods whose sidecar containers differ from the revision in images can be
+	// rolled back, the others are marked with SidecarSetUpgradable=False condition and reported by event.
+	// +optional
+	RollbackTo *SidecarSetRollbackTo `json:"rollbackTo,omitempty"`
+
+	// Stages divide the rolling update into stages, which are processed one by one.
+	// Only the pods selected by the current and previous stages can be updated, and the next stage
+	// will not begin until all of these pods have been updated and ready, soaked and resumed.
+	// The remaining pods will be updated after all stages have been completed.
+	// It works together with Selector and Partition, which still limit the pods to update.
+	// +optional
+	Stages []SidecarSetUpdateStage `json:"stages,omitempty"`
+}
+
+// SidecarSetUpdateStage is a stage of the rolling update, which updates a part of the matched pods.
+type SidecarSetUpdateStage struct {
+	// Name of the stage, which is reported in status.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// NamespaceSelector is a label query over namespaces of the pods to update in this stage.
+	// Defaults to all namespaces.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Selector is a label query over the pods to update in this stage.
+	// Defaults to all matched pods.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// MaxUnavailable is the maximum number of pods that can be unavailable during this stage.
+	// Value can be an absolute number (ex: 5) or a percentage of the pods selected by the current
+	// and previous stages (ex: 10%). Defaults to updateStrategy.maxUnavailable.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// SoakSeconds is the minimum seconds that pods updated in this stage should keep ready
+	// before the next stage begins. Defaults to 0.
+	// +optional
+	SoakSeconds int32 `json:"soakSeconds,omitempty"`
+
+	// Pause indicates the rolling update will be paused after this stage has been completed and soaked,
+	// until it is resumed by the annotation apps.kruise.io/sidecarset-resume-stage.
+	// +optional
+	Pause bool `json:"pause,omitempty"`
+}
+
+// SidecarSetUpdateStagePhase is the phase of the current stage of rolling update.
+type SidecarSetUpdateStagePhase string
+
+const (
+	// SidecarSetUpdateStagePhaseUpdating means pods of the current stage are updating.
+	SidecarSetUpdateStagePhaseUpdating SidecarSetUpdateStagePhase = "Updating"
+	// SidecarSetUpdateStagePhaseSoaking means pods of the current stage have been updated and ready,
+	// and it is waiting for soakSeconds before the next stage.
+	SidecarSetUpdateStagePhaseSoaking SidecarSetUpdateStagePhase = "Soaking"
+	// SidecarSetUpdateStagePhasePaused means the current stage has been completed and soaked,
+	// and it is waiting to be resumed by the annotation apps.kruise.io/sidecarset-resume-stage.
+	SidecarSetUpdateStagePhasePaused SidecarSetUpdateStagePhase = "Paused"
+	// SidecarSetUpdateStagePhaseCompleted means all stages have been completed,
+	// and the remaining pods can be updated.
+	SidecarSetUpdateStagePhaseCompleted SidecarSetUpdateStagePhase = "Completed"
+)
+
+// SidecarSetUpdateStageStatus is the status of the staged rolling update.
+type SidecarSetUpdateStageStatus struct {
+	// Revision is the sidecarSet hash of this staged rolling update.
+	Revision string `json:"revision,omitempty"`
+	// CurrentStage is the index of the current stage in updateStrategy.stages.
+	CurrentStage int32 `json:"currentStage"`
+	// Name is the name of the current stage.
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Phase is the phase of the current stage.
+	Phase SidecarSetUpdateStagePhase `json:"phase,omitempty"`
+	// SoakStartTime is the time when the current stage began to soak.
+	// +optional
+	SoakStartTime *metav1.Time `json:"soakStartTime,omitempty"`
+	// MatchedPods is the number of matched pods selected by the current and previous stages.
+	MatchedPods int32 `json:"matchedPods"`
+	// UpdatedPods is the number of pods selected by the current and previous stages that have been updated.
+	UpdatedPods int32 `json:"updatedPods"`
+	// UpdatedReadyPods is the number of pods selected by the current and previous stages that have been updated and ready.
+	UpdatedReadyPods int32 `json:"updatedReadyPods"`
+}
+
+// SidecarSetRollbackTo describes the history revision of SidecarSet to roll back to.
+type SidecarSetRollbackTo struct {
+	// CustomVersion corresponds to label 'apps.kruise.io/sidecarset-custom-version' of (History) SidecarSet.
+	// The latest ControllerRevision with this CustomVersion is selected.
+	// + optional
+	CustomVersion *string `json:"customVersion,omitempty"`
+	// RevisionName corresponds to a specific ControllerRevision name of SidecarSet that you want to roll back to.
+	// + optional
+	RevisionName *string `json:"revisionName,omitempty"`
 }
 
 type SidecarSetUpdateStrategyType string
@@ -314,6 +471,77 @@ type SidecarSetStatus struct {
 	// uses this field as a collision avoidance mechanism when it needs to create the name for the
 	// newest ControllerRevision.
 	CollisionCount *int32 `json:"collisionCount,omitempty"`
+
+	// InjectionPreview is the result of previewing the injection of SidecarSet into the existing matched pods,
+	// which is reported only when injectionStrategy.preview is true.
+	// +optional
+	InjectionPreview *SidecarSetInjectionPreview `json:"injectionPreview,omitempty"`
+
+	// Revisions are the history revisions of the SidecarSet, sorted by increasing revision number,
+	// with the number of matched pods injected with each of them.
+	// +optional
+	Revisions []SidecarSetRevisionStatus `json:"revisions,omitempty"`
+
+	// UpdateStage is the status of the staged rolling update, only reported when updateStrategy.stages is set.
+	// +optional
+	UpdateStage *SidecarSetUpdateStageStatus `json:"updateStage,omitempty"`
+}
+
+// SidecarSetRevisionStatus is the status of a history revision of SidecarSet.
+type SidecarSetRevisionStatus struct {
+	// Name is the name of the ControllerRevision.
+	Name string `json:"name"`
+
+	// Revision is the revision number of the ControllerRevision.
+	Revision int64 `json:"revision"`
+
+	// CustomVersion is the label 'apps.kruise.io/sidecarset-custom-version' of the ControllerRevision.
+	// +optional
+	CustomVersion string `json:"customVersion,omitempty"`
+
+	// Pods is the number of matched pods injected with this revision.
+	Pods int32 `json:"pods"`
+}
+
+// SidecarSetInjectionPreview is the result of previewing the injection of SidecarSet into the existing matched pods.
+type SidecarSetInjectionPreview struct {
+	// MatchedPods is the number of active pods matched with the SidecarSet.
+	MatchedPods int32 `json:"matchedPods"`
+
+	// ConflictedPods is the number of matched pods that would be rejected for conflicts.
+	ConflictedPods int32 `json:"conflictedPods"`
+
+	// Pods are the injection results of the matched pods. At most 100 pods are recorded,
+	// and the conflicted pods are recorded first.
+	// +optional
+	Pods []SidecarSetPodInjectionPreview `json:"pods,omitempty"`
+}
+
+// SidecarSetPodInjectionPreview is the result of previewing the injection of SidecarSet into a pod.
+type SidecarSetPodInjectionPreview struct {
+	// Namespace is the namespace of the pod.
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the pod.
+	Name string `json:"name"`
+
+	// InitContainers are the names of the init containers of the pod after injection.
+	// +optional
+	InitContainers []string `json:"initContainers,omitempty"`
+
+	// Containers are the names of the containers of the pod after injection.
+	// +optional
+	Containers []string `json:"containers,omitempty"`
+
+	// Volumes are the names of the volumes of the pod after injection.
+	// +optional
+	Volumes []string `json:"volumes,omitempty"`
+
+	// Conflicts are the reasons why the injection into the pod would be rejected or broken,
+	// such as conflicts with other SidecarSets matched by the pod, duplicate container names,
+	// volume clashes or missing transferEnv sources.
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 // +genclient
@@ -10,6 +10,10 @@ const (
 	// SpecifiedDeleteKey indicates this object should be deleted, and the value could be the deletion option.
 	SpecifiedDeleteKey = "apps.kruise.io/specified-delete"
 
+	// SpecifiedReplaceKey indicates this object should be replaced, which means a replacement will be created
+	// before this object is deleted.
+	SpecifiedReplaceKey = "apps.kruise.io/specified-replace"
+
 	// ImagePreDownloadCreatedKey indicates the images of this revision have been pre-downloaded
 	ImagePreDownloadCreatedKey = "apps.kruise.io/pre-predownload-created"
 
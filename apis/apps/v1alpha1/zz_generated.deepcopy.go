@@ -22,6 +22,7 @@ package v1alpha1
 
 import (
 	"github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/apis/apps/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -375,6 +376,7 @@ func (in *CloneSet) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloneSetCondition) DeepCopyInto(out *CloneSetCondition) {
 	*out = *in
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
 	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
 }
 
@@ -420,6 +422,56 @@ func (in *CloneSetList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneSetPersistentVolumeClaimRetentionPolicy) DeepCopyInto(out *CloneSetPersistentVolumeClaimRetentionPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetPersistentVolumeClaimRetentionPolicy.
+func (in *CloneSetPersistentVolumeClaimRetentionPolicy) DeepCopy() *CloneSetPersistentVolumeClaimRetentionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneSetPersistentVolumeClaimRetentionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneSetRevisionReplicas) DeepCopyInto(out *CloneSetRevisionReplicas) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetRevisionReplicas.
+func (in *CloneSetRevisionReplicas) DeepCopy() *CloneSetRevisionReplicas {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneSetRevisionReplicas)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneSetRolloutRecord) DeepCopyInto(out *CloneSetRolloutRecord) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.FinishTime != nil {
+		in, out := &in.FinishTime, &out.FinishTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetRolloutRecord.
+func (in *CloneSetRolloutRecord) DeepCopy() *CloneSetRolloutRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneSetRolloutRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloneSetScaleStrategy) DeepCopyInto(out *CloneSetScaleStrategy) {
 	*out = *in
@@ -428,6 +480,11 @@ func (in *CloneSetScaleStrategy) DeepCopyInto(out *CloneSetScaleStrategy) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PodsToReplace != nil {
+		in, out := &in.PodsToReplace, &out.PodsToReplace
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.MaxUnavailable != nil {
 		in, out := &in.MaxUnavailable, &out.MaxUnavailable
 		*out = new(intstr.IntOrString)
@@ -466,6 +523,11 @@ func (in *CloneSetSpec) DeepCopyInto(out *CloneSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PersistentVolumeClaimRetentionPolicy != nil {
+		in, out := &in.PersistentVolumeClaimRetentionPolicy, &out.PersistentVolumeClaimRetentionPolicy
+		*out = new(CloneSetPersistentVolumeClaimRetentionPolicy)
+		**out = **in
+	}
 	in.ScaleStrategy.DeepCopyInto(&out.ScaleStrategy)
 	in.UpdateStrategy.DeepCopyInto(&out.UpdateStrategy)
 	if in.RevisionHistoryLimit != nil {
@@ -478,6 +540,11 @@ func (in *CloneSetSpec) DeepCopyInto(out *CloneSetSpec) {
 		*out = new(pub.Lifecycle)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		*out = new(int32)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetSpec.
@@ -505,6 +572,49 @@ func (in *CloneSetStatus) DeepCopyInto(out *CloneSetStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.CurrentStepIndex != nil {
+		in, out := &in.CurrentStepIndex, &out.CurrentStepIndex
+		*out = new(int32)
+		**out = **in
+	}
+	if in.StepStatuses != nil {
+		in, out := &in.StepStatuses, &out.StepStatuses
+		*out = make([]CloneSetUpdateStepStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeClaims != nil {
+		in, out := &in.VolumeClaims, &out.VolumeClaims
+		*out = make([]v1beta1.VolumeClaimStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.RevisionReplicas != nil {
+		in, out := &in.RevisionReplicas, &out.RevisionReplicas
+		*out = make(map[string]CloneSetRevisionReplicas, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RolloutHistory != nil {
+		in, out := &in.RolloutHistory, &out.RolloutHistory
+		*out = make([]CloneSetRolloutRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LifecycleStates != nil {
+		in, out := &in.LifecycleStates, &out.LifecycleStates
+		*out = make([]pub.LifecycleStateStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UpdateTopology != nil {
+		in, out := &in.UpdateTopology, &out.UpdateTopology
+		*out = new(pub.UpdateTopologyStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetStatus.
@@ -534,6 +644,79 @@ func (in *CloneSetTemplateSpec) DeepCopy() *CloneSetTemplateSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneSetUpdateStep) DeepCopyInto(out *CloneSetUpdateStep) {
+	*out = *in
+	if in.Partition != nil {
+		in, out := &in.Partition, &out.Partition
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.Pause != nil {
+		in, out := &in.Pause, &out.Pause
+		*out = new(CloneSetUpdateStepPause)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetUpdateStep.
+func (in *CloneSetUpdateStep) DeepCopy() *CloneSetUpdateStep {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneSetUpdateStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneSetUpdateStepPause) DeepCopyInto(out *CloneSetUpdateStepPause) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetUpdateStepPause.
+func (in *CloneSetUpdateStepPause) DeepCopy() *CloneSetUpdateStepPause {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneSetUpdateStepPause)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloneSetUpdateStepStatus) DeepCopyInto(out *CloneSetUpdateStepStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishTime != nil {
+		in, out := &in.FinishTime, &out.FinishTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetUpdateStepStatus.
+func (in *CloneSetUpdateStepStatus) DeepCopy() *CloneSetUpdateStepStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloneSetUpdateStepStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloneSetUpdateStrategy) DeepCopyInto(out *CloneSetUpdateStrategy) {
 	*out = *in
@@ -562,11 +745,23 @@ func (in *CloneSetUpdateStrategy) DeepCopyInto(out *CloneSetUpdateStrategy) {
 		*out = make(UpdateScatterStrategy, len(*in))
 		copy(*out, *in)
 	}
+	if in.TopologyStrategy != nil {
+		in, out := &in.TopologyStrategy, &out.TopologyStrategy
+		*out = new(pub.UpdateTopologyStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.InPlaceUpdateStrategy != nil {
 		in, out := &in.InPlaceUpdateStrategy, &out.InPlaceUpdateStrategy
 		*out = new(pub.InPlaceUpdateStrategy)
 		**out = **in
 	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]CloneSetUpdateStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloneSetUpdateStrategy.
@@ -957,6 +1152,27 @@ func (in *DaemonSetList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaemonSetNodePoolPatch) DeepCopyInto(out *DaemonSetNodePoolPatch) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Patch.DeepCopyInto(&out.Patch)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaemonSetNodePoolPatch.
+func (in *DaemonSetNodePoolPatch) DeepCopy() *DaemonSetNodePoolPatch {
+	if in == nil {
+		return nil
+	}
+	out := new(DaemonSetNodePoolPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaemonSetSpec) DeepCopyInto(out *DaemonSetSpec) {
 	*out = *in
@@ -966,6 +1182,13 @@ func (in *DaemonSetSpec) DeepCopyInto(out *DaemonSetSpec) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.Template.DeepCopyInto(&out.Template)
+	if in.NodePoolPatches != nil {
+		in, out := &in.NodePoolPatches, &out.NodePoolPatches
+		*out = make([]DaemonSetNodePoolPatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	in.UpdateStrategy.DeepCopyInto(&out.UpdateStrategy)
 	if in.BurstReplicas != nil {
 		in, out := &in.BurstReplicas, &out.BurstReplicas
@@ -1009,6 +1232,18 @@ func (in *DaemonSetStatus) DeepCopyInto(out *DaemonSetStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LifecycleStates != nil {
+		in, out := &in.LifecycleStates, &out.LifecycleStates
+		*out = make([]pub.LifecycleStateStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UpdateStage != nil {
+		in, out := &in.UpdateStage, &out.UpdateStage
+		*out = new(DaemonSetUpdateStageStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaemonSetStatus.
@@ -1021,6 +1256,50 @@ func (in *DaemonSetStatus) DeepCopy() *DaemonSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaemonSetUpdateStage) DeepCopyInto(out *DaemonSetUpdateStage) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaemonSetUpdateStage.
+func (in *DaemonSetUpdateStage) DeepCopy() *DaemonSetUpdateStage {
+	if in == nil {
+		return nil
+	}
+	out := new(DaemonSetUpdateStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DaemonSetUpdateStageStatus) DeepCopyInto(out *DaemonSetUpdateStageStatus) {
+	*out = *in
+	if in.SoakStartTime != nil {
+		in, out := &in.SoakStartTime, &out.SoakStartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DaemonSetUpdateStageStatus.
+func (in *DaemonSetUpdateStageStatus) DeepCopy() *DaemonSetUpdateStageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DaemonSetUpdateStageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DaemonSetUpdateStrategy) DeepCopyInto(out *DaemonSetUpdateStrategy) {
 	*out = *in
@@ -2609,6 +2888,18 @@ func (in *RollingUpdateDaemonSet) DeepCopyInto(out *RollingUpdateDaemonSet) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.InPlaceUpdateStrategy != nil {
+		in, out := &in.InPlaceUpdateStrategy, &out.InPlaceUpdateStrategy
+		*out = new(pub.InPlaceUpdateStrategy)
+		**out = **in
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]DaemonSetUpdateStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingUpdateDaemonSet.
@@ -2718,6 +3009,11 @@ func (in *SidecarContainer) DeepCopyInto(out *SidecarContainer) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ResourcesPolicy != nil {
+		in, out := &in.ResourcesPolicy, &out.ResourcesPolicy
+		*out = new(SidecarResourcesPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarContainer.
@@ -2745,6 +3041,65 @@ func (in *SidecarContainerUpgradeStrategy) DeepCopy() *SidecarContainerUpgradeSt
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarResourceExpression) DeepCopyInto(out *SidecarResourceExpression) {
+	*out = *in
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarResourceExpression.
+func (in *SidecarResourceExpression) DeepCopy() *SidecarResourceExpression {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarResourceExpression)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarResourcesPolicy) DeepCopyInto(out *SidecarResourcesPolicy) {
+	*out = *in
+	if in.TargetContainerNames != nil {
+		in, out := &in.TargetContainerNames, &out.TargetContainerNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Requests != nil {
+		in, out := &in.Requests, &out.Requests
+		*out = make(map[corev1.ResourceName]SidecarResourceExpression, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Limits != nil {
+		in, out := &in.Limits, &out.Limits
+		*out = make(map[corev1.ResourceName]SidecarResourceExpression, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarResourcesPolicy.
+func (in *SidecarResourcesPolicy) DeepCopy() *SidecarResourcesPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarResourcesPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SidecarSet) DeepCopyInto(out *SidecarSet) {
 	*out = *in
@@ -2797,6 +3152,28 @@ func (in *SidecarSetInjectRevision) DeepCopy() *SidecarSetInjectRevision {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarSetInjectionPreview) DeepCopyInto(out *SidecarSetInjectionPreview) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]SidecarSetPodInjectionPreview, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetInjectionPreview.
+func (in *SidecarSetInjectionPreview) DeepCopy() *SidecarSetInjectionPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarSetInjectionPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SidecarSetInjectionStrategy) DeepCopyInto(out *SidecarSetInjectionStrategy) {
 	*out = *in
@@ -2871,6 +3248,81 @@ func (in *SidecarSetPatchPodMetadata) DeepCopy() *SidecarSetPatchPodMetadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarSetPodInjectionPreview) DeepCopyInto(out *SidecarSetPodInjectionPreview) {
+	*out = *in
+	if in.InitContainers != nil {
+		in, out := &in.InitContainers, &out.InitContainers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conflicts != nil {
+		in, out := &in.Conflicts, &out.Conflicts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetPodInjectionPreview.
+func (in *SidecarSetPodInjectionPreview) DeepCopy() *SidecarSetPodInjectionPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarSetPodInjectionPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarSetRevisionStatus) DeepCopyInto(out *SidecarSetRevisionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetRevisionStatus.
+func (in *SidecarSetRevisionStatus) DeepCopy() *SidecarSetRevisionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarSetRevisionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarSetRollbackTo) DeepCopyInto(out *SidecarSetRollbackTo) {
+	*out = *in
+	if in.CustomVersion != nil {
+		in, out := &in.CustomVersion, &out.CustomVersion
+		*out = new(string)
+		**out = **in
+	}
+	if in.RevisionName != nil {
+		in, out := &in.RevisionName, &out.RevisionName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetRollbackTo.
+func (in *SidecarSetRollbackTo) DeepCopy() *SidecarSetRollbackTo {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarSetRollbackTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SidecarSetSpec) DeepCopyInto(out *SidecarSetSpec) {
 	*out = *in
@@ -2944,6 +3396,21 @@ func (in *SidecarSetStatus) DeepCopyInto(out *SidecarSetStatus) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.InjectionPreview != nil {
+		in, out := &in.InjectionPreview, &out.InjectionPreview
+		*out = new(SidecarSetInjectionPreview)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]SidecarSetRevisionStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.UpdateStage != nil {
+		in, out := &in.UpdateStage, &out.UpdateStage
+		*out = new(SidecarSetUpdateStageStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetStatus.
@@ -2956,6 +3423,55 @@ func (in *SidecarSetStatus) DeepCopy() *SidecarSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarSetUpdateStage) DeepCopyInto(out *SidecarSetUpdateStage) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetUpdateStage.
+func (in *SidecarSetUpdateStage) DeepCopy() *SidecarSetUpdateStage {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarSetUpdateStage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarSetUpdateStageStatus) DeepCopyInto(out *SidecarSetUpdateStageStatus) {
+	*out = *in
+	if in.SoakStartTime != nil {
+		in, out := &in.SoakStartTime, &out.SoakStartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetUpdateStageStatus.
+func (in *SidecarSetUpdateStageStatus) DeepCopy() *SidecarSetUpdateStageStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarSetUpdateStageStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SidecarSetUpdateStrategy) DeepCopyInto(out *SidecarSetUpdateStrategy) {
 	*out = *in
@@ -2984,6 +3500,18 @@ func (in *SidecarSetUpdateStrategy) DeepCopyInto(out *SidecarSetUpdateStrategy)
 		*out = make(UpdateScatterStrategy, len(*in))
 		copy(*out, *in)
 	}
+	if in.RollbackTo != nil {
+		in, out := &in.RollbackTo, &out.RollbackTo
+		*out = new(SidecarSetRollbackTo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]SidecarSetUpdateStage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarSetUpdateStrategy.
@@ -27,6 +27,16 @@ import (
 const (
 	// MaxMinReadySeconds is the max value of MinReadySeconds
 	MaxMinReadySeconds = 300
+
+	// StatefulSetSurgePodLabelKey is the label key of surge pods created for rollingUpdate.maxSurge.
+	StatefulSetSurgePodLabelKey = "apps.kruise.io/statefulset-surge"
+
+	// StatefulSetVolumeSnapshotsAnnotationKey is the annotation key of pods recording the names of VolumeSnapshots
+	// created before update, which is a json map from volumeClaimTemplate name to VolumeSnapshot name.
+	StatefulSetVolumeSnapshotsAnnotationKey = "apps.kruise.io/volume-snapshots"
+	// StatefulSetVolumeSnapshotRevisionLabelKey is the label key of VolumeSnapshots created before update,
+	// whose value is the update revision that the pod was going to update to.
+	StatefulSetVolumeSnapshotRevisionLabelKey = "apps.kruise.io/volume-snapshot-revision"
 )
 
 // VolumeClaimUpdateStrategyType defines the update strategy types for volume claims.
@@ -43,6 +53,17 @@ const (
 	// This strategy places full control of the update timing in the hands of the user, typically executed after ensuring data has been backed up or there are no data security concerns,
 	// allowing for storage resource management that aligns with specific user requirements and security policies.
 	OnPVCDeleteVolumeClaimUpdateStrategyType VolumeClaimUpdateStrategyType = "OnDelete"
+
+	// RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType indicates that volume claim updates are triggered when associated Pods undergo rolling updates,
+	// like OnPodRollingUpdate, and the claims whose changes cannot be applied in place, such as a new storage class or access modes,
+	// are deleted and recreated together with their Pods ordinal by ordinal. Data in the deleted claims will be lost unless it has been migrated.
+	RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType VolumeClaimUpdateStrategyType = "RecreateOnPodRollingUpdate"
+)
+
+const (
+	// StatefulSetRecreateVolumeClaimsAnnotationKey is the annotation key of pods whose volume claims are going to be recreated
+	// with RecreateOnPodRollingUpdate strategy. Its value is a json containing the names of the claims and the time when the pod was marked.
+	StatefulSetRecreateVolumeClaimsAnnotationKey = "apps.kruise.io/recreate-volume-claims"
 )
 
 // VolumeClaimStatus describes the status of a volume claim template.
@@ -81,7 +102,13 @@ type VolumeClaimUpdateStrategy struct {
 	// Type specifies the type of update strategy, possible values include:
 	// OnPodRollingUpdateVolumeClaimUpdateStrategyType: Apply the update strategy during pod rolling updates.
 	// OnPVCDeleteVolumeClaimUpdateStrategyType: Apply the update strategy when a PersistentVolumeClaim is deleted.
+	// RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType: Apply the update strategy during pod rolling updates, and recreate the claims that cannot be updated in place.
 	Type VolumeClaimUpdateStrategyType `json:"type,omitempty"`
+	// PreRecreateHook is the hook before the claims of a Pod are recreated with RecreateOnPodRollingUpdate strategy,
+	// which lets an external job migrate the data first. The Pod is annotated with apps.kruise.io/recreate-volume-claims,
+	// and its claims will not be deleted until the labels and finalizers in the hook have been removed from the Pod.
+	// +optional
+	PreRecreateHook *appspub.LifecycleHook `json:"preRecreateHook,omitempty"`
 }
 
 // RollingUpdateStatefulSetStrategy is used to communicate parameter for RollingUpdateStatefulSetStrategyType.
@@ -91,6 +118,13 @@ type RollingUpdateStatefulSetStrategy struct {
 	// Default value is 0.
 	// +optional
 	Partition *int32 `json:"partition,omitempty"`
+	// Ordinals is the list of pod ordinals to update, and partition will be ignored if it is set.
+	// Pods will be updated in the order of the list, so that you can update the followers first and the leader last,
+	// such as [1, 2, 0]. Pods with ordinals not in the list will be kept in their revisions.
+	// You can also use ranges along with numbers, such as [1, 3-5], which is a shortcut for [1, 3, 4, 5],
+	// and a range must not contain more than 10000 ordinals.
+	// +optional
+	Ordinals []intstr.IntOrString `json:"ordinals,omitempty"`
 	// The maximum number of pods that can be unavailable during the update.
 	// Value can be an absolute number (ex: 5) or a percentage of desired pods (ex: 10%).
 	// Absolute number is calculated from percentage by rounding down.
@@ -98,6 +132,16 @@ type RollingUpdateStatefulSetStrategy struct {
 	// Defaults to 1.
 	// +optional
 	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// The maximum number of pods that can be created above the desired replicas during the update.
+	// Surge pods are created in update revision with the ordinals after the last replica,
+	// and each available surge pod allows one more replica to be unavailable for update.
+	// They will be deleted once all the replicas have been updated and available.
+	// Value can be an absolute number (ex: 5) or a percentage of desired pods (ex: 10%).
+	// Absolute number is calculated from percentage by rounding up.
+	// Also, maxSurge can just be allowed to work with Parallel podManagementPolicy.
+	// Defaults to 0.
+	// +optional
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
 	// PodUpdatePolicy indicates how pods should be updated
 	// Default value is "ReCreate"
 	// +optional
@@ -121,14 +165,61 @@ type RollingUpdateStatefulSetStrategy struct {
 	// Default value is 0, max is 300.
 	// +optional
 	MinReadySeconds *int32 `json:"minReadySeconds,omitempty"`
+	// VolumeSnapshotBeforeUpdate makes StatefulSet create a VolumeSnapshot for each PVC of the pod from
+	// VolumeClaimTemplates and wait for them to be ready to use, before recreating or in-place updating the pod.
+	// It gives a rollback point for each ordinal. The snapshots are owned by the StatefulSet,
+	// and only the latest ones of each PVC are retained according to the RetentionLimit.
+	// +optional
+	VolumeSnapshotBeforeUpdate *VolumeSnapshotStrategy `json:"volumeSnapshotBeforeUpdate,omitempty"`
+}
+
+// VolumeSnapshotStrategy defines how to snapshot the volumes of pods before update.
+type VolumeSnapshotStrategy struct {
+	// VolumeSnapshotClassName is the name of VolumeSnapshotClass for the snapshots.
+	// The default VolumeSnapshotClass will be used if it is empty.
+	// +optional
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
+	// TimeoutSeconds is the maximum time to wait for the snapshots to be ready to use,
+	// after which the FailurePolicy takes effect.
+	// Default to 600.
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// FailurePolicy defines what to do when the snapshots are not ready to use in TimeoutSeconds.
+	// - Proceed: update the pod without waiting for the snapshots.
+	// - Block: keep waiting for the snapshots, and a warning event is recorded.
+	// Default to Block.
+	// +optional
+	FailurePolicy VolumeSnapshotFailurePolicyType `json:"failurePolicy,omitempty"`
+	// RetentionLimit is the maximum number of VolumeSnapshots retained for each PVC.
+	// Older snapshots are deleted once it is exceeded, so are the ones of revisions removed from the history,
+	// except for the snapshots recorded in existing pods.
+	// Default to 3.
+	// +optional
+	RetentionLimit int32 `json:"retentionLimit,omitempty"`
 }
 
+// VolumeSnapshotFailurePolicyType defines what to do when the snapshots are not ready to use in time.
+// +enum
+type VolumeSnapshotFailurePolicyType string
+
+const (
+	// VolumeSnapshotFailurePolicyProceed means updating the pod without waiting for the snapshots.
+	VolumeSnapshotFailurePolicyProceed VolumeSnapshotFailurePolicyType = "Proceed"
+	// VolumeSnapshotFailurePolicyBlock means keeping waiting for the snapshots.
+	VolumeSnapshotFailurePolicyBlock VolumeSnapshotFailurePolicyType = "Block"
+)
+
 // UnorderedUpdateStrategy defines strategies for non-ordered update.
 type UnorderedUpdateStrategy struct {
 	// Priorities are the rules for calculating the priority of updating pods.
 	// Each pod to be updated, will pass through these terms and get a sum of weights.
 	// +optional
 	PriorityStrategy *appspub.UpdatePriorityStrategy `json:"priorityStrategy,omitempty"`
+	// TopologyStrategy makes pods updated domain by domain, such as zone by zone.
+	// Pods in the next domain will not be updated until all pods in the current domain
+	// have been updated and kept available for the soak seconds.
+	// +optional
+	TopologyStrategy *appspub.UpdateTopologyStrategy `json:"topologyStrategy,omitempty"`
 }
 
 // PodUpdateStrategyType is a string enumeration type that enumerates
@@ -368,12 +459,30 @@ type StatefulSetStatus struct {
 	// to match any changes made to the volumeClaimTemplates, ensuring synchronization
 	// between the defined templates and the actual PersistentVolumeClaims in use.
 	VolumeClaims []VolumeClaimStatus `json:"volumeClaims,omitempty"`
+
+	// LifecycleStates records the Pods waiting for lifecycle hooks in each state.
+	LifecycleStates []appspub.LifecycleStateStatus `json:"lifecycleStates,omitempty"`
+
+	// UpdateTopology records the progress of the update when unorderedUpdate.topologyStrategy is set.
+	// +optional
+	UpdateTopology *appspub.UpdateTopologyStatus `json:"updateTopology,omitempty"`
+
+	// PendingOrdinals is the list of ordinals in rollingUpdate.ordinals whose pods have not been updated
+	// to the update revision, in the order to update.
+	// +optional
+	PendingOrdinals []int32 `json:"pendingOrdinals,omitempty"`
 }
 
 // These are valid conditions of a statefulset.
 const (
 	FailedCreatePod apps.StatefulSetConditionType = "FailedCreatePod"
 	FailedUpdatePod apps.StatefulSetConditionType = "FailedUpdatePod"
+	// InPlaceUpdateNotPossible indicates pods of current revision can not be updated in place to the update revision,
+	// only reported with InPlaceIfPossible or InPlaceOnly podUpdatePolicy.
+	InPlaceUpdateNotPossible apps.StatefulSetConditionType = "InPlaceUpdateNotPossible"
+	// VolumeSnapshotTimeout indicates the VolumeSnapshots created before update are not ready to use in time,
+	// and the message contains the names of pods.
+	VolumeSnapshotTimeout apps.StatefulSetConditionType = "VolumeSnapshotTimeout"
 )
 
 // +genclient
@@ -37,11 +37,21 @@ func (in *RollingUpdateStatefulSetStrategy) DeepCopyInto(out *RollingUpdateState
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Ordinals != nil {
+		in, out := &in.Ordinals, &out.Ordinals
+		*out = make([]intstr.IntOrString, len(*in))
+		copy(*out, *in)
+	}
 	if in.MaxUnavailable != nil {
 		in, out := &in.MaxUnavailable, &out.MaxUnavailable
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
 	if in.UnorderedUpdate != nil {
 		in, out := &in.UnorderedUpdate, &out.UnorderedUpdate
 		*out = new(UnorderedUpdateStrategy)
@@ -57,6 +67,11 @@ func (in *RollingUpdateStatefulSetStrategy) DeepCopyInto(out *RollingUpdateState
 		*out = new(int32)
 		**out = **in
 	}
+	if in.VolumeSnapshotBeforeUpdate != nil {
+		in, out := &in.VolumeSnapshotBeforeUpdate, &out.VolumeSnapshotBeforeUpdate
+		*out = new(VolumeSnapshotStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingUpdateStatefulSetStrategy.
@@ -199,7 +214,7 @@ func (in *StatefulSetSpec) DeepCopyInto(out *StatefulSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	out.VolumeClaimUpdateStrategy = in.VolumeClaimUpdateStrategy
+	in.VolumeClaimUpdateStrategy.DeepCopyInto(&out.VolumeClaimUpdateStrategy)
 	in.UpdateStrategy.DeepCopyInto(&out.UpdateStrategy)
 	if in.RevisionHistoryLimit != nil {
 		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
@@ -263,6 +278,23 @@ func (in *StatefulSetStatus) DeepCopyInto(out *StatefulSetStatus) {
 		*out = make([]VolumeClaimStatus, len(*in))
 		copy(*out, *in)
 	}
+	if in.LifecycleStates != nil {
+		in, out := &in.LifecycleStates, &out.LifecycleStates
+		*out = make([]pub.LifecycleStateStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UpdateTopology != nil {
+		in, out := &in.UpdateTopology, &out.UpdateTopology
+		*out = new(pub.UpdateTopologyStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingOrdinals != nil {
+		in, out := &in.PendingOrdinals, &out.PendingOrdinals
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StatefulSetStatus.
@@ -303,6 +335,11 @@ func (in *UnorderedUpdateStrategy) DeepCopyInto(out *UnorderedUpdateStrategy) {
 		*out = new(pub.UpdatePriorityStrategy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TopologyStrategy != nil {
+		in, out := &in.TopologyStrategy, &out.TopologyStrategy
+		*out = new(pub.UpdateTopologyStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnorderedUpdateStrategy.
@@ -333,6 +370,11 @@ func (in *VolumeClaimStatus) DeepCopy() *VolumeClaimStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeClaimUpdateStrategy) DeepCopyInto(out *VolumeClaimUpdateStrategy) {
 	*out = *in
+	if in.PreRecreateHook != nil {
+		in, out := &in.PreRecreateHook, &out.PreRecreateHook
+		*out = new(pub.LifecycleHook)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeClaimUpdateStrategy.
@@ -344,3 +386,23 @@ func (in *VolumeClaimUpdateStrategy) DeepCopy() *VolumeClaimUpdateStrategy {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotStrategy) DeepCopyInto(out *VolumeSnapshotStrategy) {
+	*out = *in
+	if in.VolumeSnapshotClassName != nil {
+		in, out := &in.VolumeSnapshotClassName, &out.VolumeSnapshotClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotStrategy.
+func (in *VolumeSnapshotStrategy) DeepCopy() *VolumeSnapshotStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -67,6 +67,10 @@ func encodeSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) (string, error) {
 	if len(initContainer) > 0 {
 		m["initContainers"] = sidecarSet.Spec.InitContainers
 	}
+	// the containers injected as native sidecar containers cannot be upgraded in place from regular containers, or vice versa.
+	if IsNativeSidecarSet(sidecarSet) {
+		m["nativeSidecar"] = true
+	}
 	data, err := json.Marshal(m)
 	if err != nil {
 		return "", err
@@ -50,6 +50,7 @@ type HistoryControl interface {
 	NextRevision(revisions []*apps.ControllerRevision) int64
 	GetRevisionSelector(s *appsv1alpha1.SidecarSet) labels.Selector
 	GetHistorySidecarSet(sidecarSet *appsv1alpha1.SidecarSet, revisionInfo *appsv1alpha1.SidecarSetInjectRevision) (*appsv1alpha1.SidecarSet, error)
+	GetRollbackSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) (*appsv1alpha1.SidecarSet, error)
 }
 
 type realControl struct {
@@ -212,6 +213,36 @@ func (r *realControl) GetHistorySidecarSet(sidecarSet *appsv1alpha1.SidecarSet,
 	return restoredSidecarSet, nil
 }
 
+// GetRollbackSidecarSet restores the history SidecarSet specified by updateStrategy.rollbackTo,
+// whose status.latestRevision is the name of the ControllerRevision rolled back to.
+// Only the fields recorded in revisions are rolled back, the others such as selector and
+// updateStrategy are kept the same as the current SidecarSet.
+func (r *realControl) GetRollbackSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) (*appsv1alpha1.SidecarSet, error) {
+	rollbackTo := sidecarSet.Spec.UpdateStrategy.RollbackTo
+	if rollbackTo == nil {
+		return nil, nil
+	}
+	revisionInfo := &appsv1alpha1.SidecarSetInjectRevision{
+		CustomVersion: rollbackTo.CustomVersion,
+		RevisionName:  rollbackTo.RevisionName,
+	}
+	if revisionInfo.RevisionName == nil && revisionInfo.CustomVersion == nil {
+		return nil, fmt.Errorf("revisionName and customVersion of rollbackTo cannot be empty simultaneously")
+	}
+	historySidecarSet, err := r.GetHistorySidecarSet(sidecarSet, revisionInfo)
+	if err != nil || historySidecarSet == nil {
+		return nil, err
+	}
+	spec := sidecarSet.Spec.DeepCopy()
+	spec.Containers = historySidecarSet.Spec.Containers
+	spec.InitContainers = historySidecarSet.Spec.InitContainers
+	spec.Volumes = historySidecarSet.Spec.Volumes
+	spec.ImagePullSecrets = historySidecarSet.Spec.ImagePullSecrets
+	spec.PatchPodMetadata = historySidecarSet.Spec.PatchPodMetadata
+	historySidecarSet.Spec = *spec
+	return historySidecarSet, nil
+}
+
 func (r *realControl) getControllerRevision(set *appsv1alpha1.SidecarSet, revisionInfo *appsv1alpha1.SidecarSetInjectRevision) (*apps.ControllerRevision, error) {
 	if revisionInfo == nil {
 		return nil, nil
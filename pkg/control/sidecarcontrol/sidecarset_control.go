@@ -120,9 +120,10 @@ func (c *commonControl) UpdatePodAnnotationsInUpgrade(changedContainers []string
 		inPlaceUpdateState.LastContainerStatuses = make(map[string]pub.InPlaceUpdateContainerStatus)
 	}
 
-	cStatus := make(map[string]string, len(pod.Status.ContainerStatuses))
-	for i := range pod.Status.ContainerStatuses {
-		c := &pod.Status.ContainerStatuses[i]
+	containerStatuses := getPodContainerStatuses(pod)
+	cStatus := make(map[string]string, len(containerStatuses))
+	for i := range containerStatuses {
+		c := &containerStatuses[i]
 		cStatus[c.Name] = c.ImageID
 	}
 	for _, cName := range changedContainers {
@@ -146,13 +147,17 @@ func (c *commonControl) IsPodStateConsistent(pod *v1.Pod, sidecarContainers sets
 	}
 
 	sidecarset := c.GetSidecarset()
+	// native sidecar containers are in pod.spec.initContainers
+	if IsNativeSidecarSet(sidecarset) && len(pod.Spec.InitContainers) != len(pod.Status.InitContainerStatuses) {
+		return false
+	}
 	if sidecarContainers.Len() == 0 {
 		sidecarContainers = GetSidecarContainersInPod(sidecarset)
 	}
 
 	allDigestImage := true
-	cImageIDs := util.GetPodContainerImageIDs(pod)
-	for _, container := range pod.Spec.Containers {
+	cImageIDs := util.GetPodContainerImageIDs(&v1.Pod{Status: v1.PodStatus{ContainerStatuses: getPodContainerStatuses(pod)}})
+	for _, container := range getPodContainers(pod) {
 		// only check whether sidecar container is consistent
 		if !sidecarContainers.Has(container.Name) {
 			continue
@@ -192,7 +197,7 @@ func (c *commonControl) IsSidecarSetUpgradable(pod *v1.Pod) (canUpgrade, consist
 
 	// cStatus: container.name -> containerStatus.Ready
 	cStatus := map[string]bool{}
-	for _, status := range pod.Status.ContainerStatuses {
+	for _, status := range getPodContainerStatuses(pod) {
 		cStatus[status.Name] = status.Ready
 	}
 	sidecarContainerList := GetSidecarContainersInPod(sidecarSet)
@@ -240,13 +245,14 @@ func IsSidecarContainerUpdateCompleted(pod *v1.Pod, sidecarSets, containers sets
 		}
 	}
 
-	containerImages := make(map[string]string, len(pod.Spec.Containers))
-	for i := range pod.Spec.Containers {
-		c := &pod.Spec.Containers[i]
+	podContainers := getPodContainers(pod)
+	containerImages := make(map[string]string, len(podContainers))
+	for i := range podContainers {
+		c := &podContainers[i]
 		containerImages[c.Name] = c.Image
 	}
 
-	for _, cs := range pod.Status.ContainerStatuses {
+	for _, cs := range getPodContainerStatuses(pod) {
 		// only check containers set
 		if !containers.Has(cs.Name) {
 			continue
@@ -569,6 +569,27 @@ func IsSidecarContainer(container corev1.Container) bool {
 	return false
 }
 
+// IsNativeSidecarSet checks whether the containers of SidecarSet are injected into pod.spec.initContainers
+// as native sidecar containers, instead of pod.spec.containers.
+func IsNativeSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) bool {
+	return sidecarSet.Spec.InjectionStrategy.NativeSidecar
+}
+
+// getPodContainers returns both init containers and containers of pod, since the sidecar containers
+// may be injected into pod.spec.initContainers as native sidecar containers.
+func getPodContainers(pod *corev1.Pod) []corev1.Container {
+	containers := make([]corev1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	containers = append(containers, pod.Spec.InitContainers...)
+	return append(containers, pod.Spec.Containers...)
+}
+
+// getPodContainerStatuses returns the statuses of both init containers and containers of pod.
+func getPodContainerStatuses(pod *corev1.Pod) []corev1.ContainerStatus {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	return append(statuses, pod.Status.ContainerStatuses...)
+}
+
 // listSidecarNameInSidecarSet list always init containers and sidecar containers
 func listSidecarNameInSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) sets.String {
 	sidecarList := sets.NewString()
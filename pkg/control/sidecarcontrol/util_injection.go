@@ -0,0 +1,304 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecarcontrol
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/util"
+)
+
+// InjectSidecars applies the sidecar containers, volumes, secrets and annotations built for the matched SidecarSets into pod.
+func InjectSidecars(pod *corev1.Pod, sidecarContainers, sidecarInitContainers []*appsv1alpha1.SidecarContainer,
+	sidecarSecrets []corev1.LocalObjectReference, volumesInSidecar []corev1.Volume, injectedAnnotations map[string]string) {
+	// 1. inject init containers, sort by their name, after the original init containers
+	sort.SliceStable(sidecarInitContainers, func(i, j int) bool {
+		return sidecarInitContainers[i].Name < sidecarInitContainers[j].Name
+	})
+	pod.Spec.InitContainers = MergeSidecarContainers(pod.Spec.InitContainers, sidecarInitContainers)
+	// 2. inject containers
+	pod.Spec.Containers = MergeSidecarContainers(pod.Spec.Containers, sidecarContainers)
+	// 3. inject volumes
+	pod.Spec.Volumes = util.MergeVolumes(pod.Spec.Volumes, volumesInSidecar)
+	// 4. inject imagePullSecrets
+	pod.Spec.ImagePullSecrets = MergeSidecarSecrets(pod.Spec.ImagePullSecrets, sidecarSecrets)
+	// 5. apply annotations
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	for k, v := range injectedAnnotations {
+		pod.Annotations[k] = v
+	}
+}
+
+// MergeSidecarSecrets merges the imagePullSecrets of SidecarSets into the ones of pod, without duplicates.
+func MergeSidecarSecrets(secretsInPod, secretsInSidecar []corev1.LocalObjectReference) (allSecrets []corev1.LocalObjectReference) {
+	secretFilter := make(map[string]bool)
+	for _, podSecret := range secretsInPod {
+		if _, ok := secretFilter[podSecret.Name]; !ok {
+			secretFilter[podSecret.Name] = true
+			allSecrets = append(allSecrets, podSecret)
+		}
+	}
+	for _, sidecarSecret := range secretsInSidecar {
+		if _, ok := secretFilter[sidecarSecret.Name]; !ok {
+			secretFilter[sidecarSecret.Name] = true
+			allSecrets = append(allSecrets, sidecarSecret)
+		}
+	}
+	return allSecrets
+}
+
+// MergeSidecarContainers merges the sidecar containers into the containers of pod, the existing ones are replaced in place,
+// and the new ones are put before or after the containers of pod by their podInjectPolicy.
+func MergeSidecarContainers(origins []corev1.Container, injected []*appsv1alpha1.SidecarContainer) []corev1.Container {
+	//format: pod.spec.containers[index].name -> index(the index of container in pod)
+	containersInPod := make(map[string]int)
+	for index, container := range origins {
+		containersInPod[container.Name] = index
+	}
+	var beforeAppContainers []corev1.Container
+	var afterAppContainers []corev1.Container
+	for _, sidecar := range injected {
+		//sidecar container already exist in pod
+		//keep the order of pod's original containers unchanged
+		if index, ok := containersInPod[sidecar.Name]; ok {
+			origins[index] = sidecar.Container
+			continue
+		}
+
+		switch sidecar.PodInjectPolicy {
+		case appsv1alpha1.BeforeAppContainerType:
+			beforeAppContainers = append(beforeAppContainers, sidecar.Container)
+		case appsv1alpha1.AfterAppContainerType:
+			afterAppContainers = append(afterAppContainers, sidecar.Container)
+		default:
+			// native sidecar containers should be started before the app init containers by default
+			if util.IsRestartableInitContainer(&sidecar.Container) {
+				beforeAppContainers = append(beforeAppContainers, sidecar.Container)
+			} else {
+				afterAppContainers = append(afterAppContainers, sidecar.Container)
+			}
+		}
+	}
+	origins = append(beforeAppContainers, origins...)
+	origins = append(origins, afterAppContainers...)
+	return origins
+}
+
+// ValidateSidecarSetConflict validates the initContainers, containers, volumes and patchPodMetadata of sidecarSet
+// conflict with the other SidecarSets, which may be injected into the same pods.
+func ValidateSidecarSetConflict(sidecarSet *appsv1alpha1.SidecarSet, others []*appsv1alpha1.SidecarSet, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	// record initContainer, container, volume name of other sidecarsets
+	// container name -> sidecarset
+	containerInOthers := make(map[string]*appsv1alpha1.SidecarSet)
+	// volume name -> sidecarset
+	volumeInOthers := make(map[string]*appsv1alpha1.SidecarSet)
+	// init container name -> sidecarset
+	initContainerInOthers := make(map[string]*appsv1alpha1.SidecarSet)
+	// patch pod annotation key -> sidecarset.Name#patchPolicy
+	annotationsInOthers := make(map[string]string)
+
+	for _, set := range others {
+		//ignore this sidecarset
+		if set.Name == sidecarSet.Name {
+			continue
+		}
+		for _, container := range set.Spec.InitContainers {
+			initContainerInOthers[container.Name] = set
+		}
+		for _, container := range set.Spec.Containers {
+			containerInOthers[container.Name] = set
+		}
+		for _, volume := range set.Spec.Volumes {
+			volumeInOthers[volume.Name] = set
+		}
+		for _, patch := range set.Spec.PatchPodMetadata {
+			if patch.PatchPolicy == appsv1alpha1.SidecarSetRetainPatchPolicy {
+				continue
+			}
+			for key := range patch.Annotations {
+				annotationsInOthers[key] = fmt.Sprintf("%s#%s", set.Name, patch.PatchPolicy)
+			}
+		}
+	}
+
+	// whether initContainers conflict
+	for _, container := range sidecarSet.Spec.InitContainers {
+		if other, ok := initContainerInOthers[container.Name]; ok {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("containers"), container.Name, fmt.Sprintf(
+				"container %v already exist in %v", container.Name, other.Name)))
+		}
+	}
+
+	// whether containers conflict
+	for _, container := range sidecarSet.Spec.Containers {
+		if other, ok := containerInOthers[container.Name]; ok {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("containers"), container.Name, fmt.Sprintf(
+				"container %v already exist in %v", container.Name, other.Name)))
+		}
+	}
+
+	// whether volumes conflict
+	for _, volume := range sidecarSet.Spec.Volumes {
+		if other, ok := volumeInOthers[volume.Name]; ok {
+			if !reflect.DeepEqual(&volume, getSidecarSetVolume(volume.Name, other)) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("volumes"), volume.Name, fmt.Sprintf(
+					"volume %s is in conflict with sidecarset %s", volume.Name, other.Name)))
+			}
+		}
+	}
+
+	// whether pod metadata conflict
+	for _, patch := range sidecarSet.Spec.PatchPodMetadata {
+		if patch.PatchPolicy == appsv1alpha1.SidecarSetRetainPatchPolicy {
+			continue
+		}
+		for key := range patch.Annotations {
+			other, ok := annotationsInOthers[key]
+			if !ok {
+				continue
+			}
+			slice := strings.Split(other, "#")
+			if patch.PatchPolicy == appsv1alpha1.SidecarSetOverwritePatchPolicy || appsv1alpha1.SidecarSetPatchPolicyType(slice[1]) == appsv1alpha1.SidecarSetOverwritePatchPolicy {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("patchPodMetadata"), key, fmt.Sprintf("annotation %s is in conflict with sidecarset %s", key, slice[0])))
+			}
+		}
+	}
+	return allErrs
+}
+
+func getSidecarSetVolume(volumeName string, sidecarset *appsv1alpha1.SidecarSet) *corev1.Volume {
+	for _, volume := range sidecarset.Spec.Volumes {
+		if volume.Name == volumeName {
+			return &volume
+		}
+	}
+	return nil
+}
+
+// GetSidecarSetInjectionConflicts returns the conflicts that would happen when sidecarSet is injected into pod, including:
+// 1. the conflicts with the other SidecarSets matched by pod, which are rejected by the SidecarSet validating webhook;
+// 2. the sidecar container has the same name with a container of pod, which is not injected by sidecarSet;
+// 3. the volume of SidecarSet has the same name with a volume of pod, but different volume source;
+// 4. the source container or env of transferEnv is not found in pod.
+func GetSidecarSetInjectionConflicts(sidecarSet *appsv1alpha1.SidecarSet, others []*appsv1alpha1.SidecarSet, pod *corev1.Pod) []string {
+	var conflicts []string
+	for _, err := range ValidateSidecarSetConflict(sidecarSet, others, field.NewPath("spec")) {
+		conflicts = append(conflicts, err.Detail)
+	}
+
+	appContainers := sets.NewString()
+	for i := range pod.Spec.InitContainers {
+		if !IsInjectedSidecarContainerInPod(&pod.Spec.InitContainers[i]) {
+			appContainers.Insert(pod.Spec.InitContainers[i].Name)
+		}
+	}
+	for i := range pod.Spec.Containers {
+		if !IsInjectedSidecarContainerInPod(&pod.Spec.Containers[i]) {
+			appContainers.Insert(pod.Spec.Containers[i].Name)
+		}
+	}
+
+	sidecars := make([]*appsv1alpha1.SidecarContainer, 0, len(sidecarSet.Spec.InitContainers)+len(sidecarSet.Spec.Containers))
+	for i := range sidecarSet.Spec.InitContainers {
+		sidecars = append(sidecars, &sidecarSet.Spec.InitContainers[i])
+	}
+	for i := range sidecarSet.Spec.Containers {
+		sidecars = append(sidecars, &sidecarSet.Spec.Containers[i])
+	}
+	for _, sidecar := range sidecars {
+		names := []string{sidecar.Name}
+		if IsHotUpgradeContainer(sidecar) {
+			name1, name2 := GetHotUpgradeContainerName(sidecar.Name)
+			names = []string{name1, name2}
+		}
+		for _, name := range names {
+			if appContainers.Has(name) {
+				conflicts = append(conflicts, fmt.Sprintf("container %s already exists in pod", name))
+			}
+		}
+		conflicts = append(conflicts, getTransferEnvConflicts(sidecar, pod)...)
+	}
+
+	for _, volume := range sidecarSet.Spec.Volumes {
+		for i := range pod.Spec.Volumes {
+			podVolume := &pod.Spec.Volumes[i]
+			if podVolume.Name == volume.Name && !reflect.DeepEqual(podVolume.VolumeSource, volume.VolumeSource) {
+				conflicts = append(conflicts, fmt.Sprintf("volume %s already exists in pod with a different source", volume.Name))
+			}
+		}
+	}
+	return conflicts
+}
+
+// getTransferEnvConflicts returns the transferEnv of the sidecar container whose source is not found in pod,
+// which is transferred from pod.spec.containers by GetSidecarTransferEnvs.
+func getTransferEnvConflicts(sidecar *appsv1alpha1.SidecarContainer, pod *corev1.Pod) []string {
+	var conflicts []string
+	for _, tEnv := range sidecar.TransferEnv {
+		sourceContainerName := tEnv.SourceContainerName
+		if tEnv.SourceContainerNameFrom != nil && tEnv.SourceContainerNameFrom.FieldRef != nil {
+			containerName, err := ExtractContainerNameFromFieldPath(tEnv.SourceContainerNameFrom.FieldRef, pod)
+			if err != nil {
+				conflicts = append(conflicts, fmt.Sprintf("failed to get transferEnv source container of container %s: %v", sidecar.Name, err))
+				continue
+			}
+			sourceContainerName = containerName
+		}
+
+		var source *corev1.Container
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == sourceContainerName {
+				source = &pod.Spec.Containers[i]
+				break
+			}
+		}
+		if source == nil {
+			conflicts = append(conflicts, fmt.Sprintf("transferEnv source container %q of container %s not found in pod", sourceContainerName, sidecar.Name))
+			continue
+		}
+
+		envs := sets.NewString(tEnv.EnvNames...)
+		if tEnv.EnvName != "" {
+			envs.Insert(tEnv.EnvName)
+		}
+		for _, envName := range envs.List() {
+			found := false
+			for _, env := range source.Env {
+				if env.Name == envName {
+					found = true
+					break
+				}
+			}
+			if !found {
+				conflicts = append(conflicts, fmt.Sprintf("transferEnv %s of container %s not found in source container %s", envName, sidecar.Name, sourceContainerName))
+			}
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecarcontrol
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/util"
+)
+
+// IsSidecarSetHasResourcesPolicy checks whether any container of SidecarSet derives its resources from the app containers.
+func IsSidecarSetHasResourcesPolicy(sidecarSet *appsv1alpha1.SidecarSet) bool {
+	for i := range sidecarSet.Spec.InitContainers {
+		if sidecarSet.Spec.InitContainers[i].ResourcesPolicy != nil {
+			return true
+		}
+	}
+	for i := range sidecarSet.Spec.Containers {
+		if sidecarSet.Spec.Containers[i].ResourcesPolicy != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSidecarResources returns the resources of the sidecar container, which are derived from the app containers of pod
+// by its resourcesPolicy, or the resources of the sidecar container itself if resourcesPolicy is nil.
+func GetSidecarResources(sidecarContainer *appsv1alpha1.SidecarContainer, pod *corev1.Pod) corev1.ResourceRequirements {
+	resources := *sidecarContainer.Resources.DeepCopy()
+	policy := sidecarContainer.ResourcesPolicy
+	if policy == nil {
+		return resources
+	}
+
+	targets := sets.NewString(policy.TargetContainerNames...)
+	var appContainers []*corev1.Container
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if targets.Len() > 0 && !targets.Has(container.Name) {
+			continue
+		}
+		if targets.Len() == 0 && IsInjectedSidecarContainerInPod(container) {
+			continue
+		}
+		appContainers = append(appContainers, container)
+	}
+
+	for name, expr := range policy.Requests {
+		if sum, ok := sumContainerResources(appContainers, name, func(c *corev1.Container) corev1.ResourceList { return c.Resources.Requests }); ok {
+			if resources.Requests == nil {
+				resources.Requests = corev1.ResourceList{}
+			}
+			resources.Requests[name] = evaluateResourceExpression(name, expr, sum)
+		}
+	}
+	for name, expr := range policy.Limits {
+		if sum, ok := sumContainerResources(appContainers, name, func(c *corev1.Container) corev1.ResourceList { return c.Resources.Limits }); ok {
+			if resources.Limits == nil {
+				resources.Limits = corev1.ResourceList{}
+			}
+			resources.Limits[name] = evaluateResourceExpression(name, expr, sum)
+		}
+	}
+	// requests must not be greater than limits, no matter whether they are derived or static
+	for name, request := range resources.Requests {
+		if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			resources.Requests[name] = limit.DeepCopy()
+		}
+	}
+	return resources
+}
+
+// UpdatePodSidecarResources updates the resources of the sidecar containers in pod which are derived from the app containers,
+// and returns the names of the changed containers. Only pod.spec.containers and native sidecar containers are resized,
+// for the regular init containers have already terminated.
+func UpdatePodSidecarResources(sidecarSet *appsv1alpha1.SidecarSet, pod *corev1.Pod) []string {
+	var changed []string
+	sidecars := make([]*appsv1alpha1.SidecarContainer, 0, len(sidecarSet.Spec.InitContainers)+len(sidecarSet.Spec.Containers))
+	for i := range sidecarSet.Spec.InitContainers {
+		sidecars = append(sidecars, &sidecarSet.Spec.InitContainers[i])
+	}
+	for i := range sidecarSet.Spec.Containers {
+		sidecars = append(sidecars, &sidecarSet.Spec.Containers[i])
+	}
+	for _, sidecar := range sidecars {
+		if sidecar.ResourcesPolicy == nil {
+			continue
+		}
+		resources := GetSidecarResources(sidecar, pod)
+		names := []string{sidecar.Name}
+		if IsHotUpgradeContainer(sidecar) {
+			name1, name2 := GetHotUpgradeContainerName(sidecar.Name)
+			names = []string{name1, name2}
+		}
+		for _, name := range names {
+			container := getResizableContainer(name, pod)
+			if container == nil || isResourceRequirementsEqual(container.Resources, resources) {
+				continue
+			}
+			container.Resources = *resources.DeepCopy()
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// getResizableContainer returns the container in pod.spec.containers or the native sidecar container with the name.
+func getResizableContainer(name string, pod *corev1.Pod) *corev1.Container {
+	for i := range pod.Spec.InitContainers {
+		if c := &pod.Spec.InitContainers[i]; c.Name == name && util.IsRestartableInitContainer(c) {
+			return c
+		}
+	}
+	for i := range pod.Spec.Containers {
+		if c := &pod.Spec.Containers[i]; c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// sumContainerResources returns the sum of the resource of containers. It returns false if any container has no such resource.
+func sumContainerResources(containers []*corev1.Container, name corev1.ResourceName, getResources func(*corev1.Container) corev1.ResourceList) (resource.Quantity, bool) {
+	var sum resource.Quantity
+	if len(containers) == 0 {
+		return sum, false
+	}
+	for _, c := range containers {
+		q, ok := getResources(c)[name]
+		if !ok {
+			return sum, false
+		}
+		sum.Add(q)
+	}
+	return sum, true
+}
+
+// evaluateResourceExpression returns the percentage of the sum, bounded by the min and max of the expression.
+func evaluateResourceExpression(name corev1.ResourceName, expr appsv1alpha1.SidecarResourceExpression, sum resource.Quantity) resource.Quantity {
+	var q *resource.Quantity
+	if name == corev1.ResourceCPU {
+		q = resource.NewMilliQuantity(sum.MilliValue()*int64(expr.Percent)/100, sum.Format)
+	} else {
+		q = resource.NewQuantity(sum.Value()*int64(expr.Percent)/100, sum.Format)
+	}
+	if expr.Min != nil && q.Cmp(*expr.Min) < 0 {
+		return expr.Min.DeepCopy()
+	}
+	if expr.Max != nil && q.Cmp(*expr.Max) > 0 {
+		return expr.Max.DeepCopy()
+	}
+	return *q
+}
+
+func isResourceRequirementsEqual(a, b corev1.ResourceRequirements) bool {
+	return isResourceListEqual(a.Requests, b.Requests) && isResourceListEqual(a.Limits, b.Limits)
+}
+
+func isResourceListEqual(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, qa := range a {
+		if qb, ok := b[name]; !ok || qa.Cmp(qb) != 0 {
+			return false
+		}
+	}
+	return true
+}
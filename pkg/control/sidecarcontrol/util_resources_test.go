@@ -0,0 +1,236 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecarcontrol
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+)
+
+func TestGetSidecarResources(t *testing.T) {
+	quantity := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "main",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+						Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+					},
+				},
+				{
+					Name: "worker",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("2Gi")},
+					},
+				},
+				{
+					Name: "log-agent",
+					Env:  []corev1.EnvVar{{Name: SidecarEnvKey, Value: "true"}},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name           string
+		policy         *appsv1alpha1.SidecarResourcesPolicy
+		staticLimits   corev1.ResourceList
+		expectRequests corev1.ResourceList
+		expectLimits   corev1.ResourceList
+	}{
+		{
+			name:           "without resourcesPolicy",
+			expectRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+		},
+		{
+			name: "percentage of all app containers",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceCPU:    {Percent: 10},
+					corev1.ResourceMemory: {Percent: 25},
+				},
+				// worker has no cpu limit
+				Limits: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceCPU: {Percent: 50},
+				},
+			},
+			expectRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("300m"), corev1.ResourceMemory: resource.MustParse("1536Mi")},
+		},
+		{
+			name: "percentage of target containers with bounds",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				TargetContainerNames: []string{"main"},
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceCPU:    {Percent: 10, Min: quantity("500m")},
+					corev1.ResourceMemory: {Percent: 50, Max: quantity("1Gi")},
+				},
+				Limits: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceCPU: {Percent: 50},
+				},
+			},
+			expectRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			expectLimits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		},
+		{
+			name: "requests clamped to derived limits",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				TargetContainerNames: []string{"main"},
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceCPU: {Percent: 10, Min: quantity("1")},
+				},
+				Limits: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceCPU: {Percent: 10},
+				},
+			},
+			expectRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("400m")},
+			expectLimits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("400m")},
+		},
+		{
+			name: "derived requests clamped to static limits",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				TargetContainerNames: []string{"main"},
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceMemory: {Percent: 50},
+				},
+			},
+			staticLimits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			expectRequests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			expectLimits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			sidecar := &appsv1alpha1.SidecarContainer{
+				Container: corev1.Container{
+					Name: "proxy",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+						Limits:   cs.staticLimits,
+					},
+				},
+				ResourcesPolicy: cs.policy,
+			}
+			resources := GetSidecarResources(sidecar, pod)
+			if !isResourceListEqual(resources.Requests, cs.expectRequests) {
+				t.Fatalf("expect requests %v, but got %v", cs.expectRequests, resources.Requests)
+			}
+			if !isResourceListEqual(resources.Limits, cs.expectLimits) {
+				t.Fatalf("expect limits %v, but got %v", cs.expectLimits, resources.Limits)
+			}
+		})
+	}
+}
+
+func TestUpdatePodSidecarResources(t *testing.T) {
+	sidecarSet := &appsv1alpha1.SidecarSet{
+		Spec: appsv1alpha1.SidecarSetSpec{
+			Containers: []appsv1alpha1.SidecarContainer{
+				{
+					Container: corev1.Container{Name: "proxy"},
+					ResourcesPolicy: &appsv1alpha1.SidecarResourcesPolicy{
+						TargetContainerNames: []string{"main"},
+						Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+							corev1.ResourceCPU: {Percent: 20},
+						},
+					},
+				},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "main",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					},
+				},
+				{
+					Name: "proxy",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+					},
+				},
+			},
+		},
+	}
+	if changed := UpdatePodSidecarResources(sidecarSet, pod); len(changed) != 0 {
+		t.Fatalf("expect no container changed, but got %v", changed)
+	}
+
+	// resize the app container
+	pod.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] = resource.MustParse("2")
+	if changed := UpdatePodSidecarResources(sidecarSet, pod); len(changed) != 1 || changed[0] != "proxy" {
+		t.Fatalf("expect container proxy changed, but got %v", changed)
+	}
+	if cpu := pod.Spec.Containers[1].Resources.Requests[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("400m")) != 0 {
+		t.Fatalf("expect cpu request 400m, but got %s", cpu.String())
+	}
+}
+
+func TestUpdatePodSidecarResourcesOfInitContainers(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	policy := &appsv1alpha1.SidecarResourcesPolicy{
+		Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+			corev1.ResourceCPU: {Percent: 10},
+		},
+	}
+	sidecarSet := &appsv1alpha1.SidecarSet{
+		Spec: appsv1alpha1.SidecarSetSpec{
+			InitContainers: []appsv1alpha1.SidecarContainer{
+				{Container: corev1.Container{Name: "init"}, ResourcesPolicy: policy},
+				{Container: corev1.Container{Name: "native-sidecar", RestartPolicy: &always}, ResourcesPolicy: policy},
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{Name: "init"},
+				{Name: "native-sidecar", RestartPolicy: &always},
+			},
+			Containers: []corev1.Container{
+				{
+					Name: "main",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+					},
+				},
+			},
+		},
+	}
+	if changed := UpdatePodSidecarResources(sidecarSet, pod); len(changed) != 1 || changed[0] != "native-sidecar" {
+		t.Fatalf("expect only native-sidecar changed, but got %v", changed)
+	}
+	if len(pod.Spec.InitContainers[0].Resources.Requests) != 0 {
+		t.Fatalf("expect regular init container not resized, but got %v", pod.Spec.InitContainers[0].Resources)
+	}
+}
@@ -116,6 +116,11 @@ func sortPodsForStatefulSet(set *appsv1beta1.StatefulSet, pods []*v1.Pod) []int
 		set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate.PriorityStrategy != nil {
 		indexes = updatesort.NewPrioritySorter(set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate.PriorityStrategy).Sort(pods, indexes)
 	}
+	if set.Spec.UpdateStrategy.RollingUpdate != nil &&
+		set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate != nil &&
+		set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate.TopologyStrategy != nil {
+		indexes = updatesort.NewTopologySorter(set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate.TopologyStrategy).Sort(pods, indexes)
+	}
 	return indexes
 }
 
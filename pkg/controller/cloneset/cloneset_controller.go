@@ -23,6 +23,7 @@ import (
 	"time"
 
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
 	kruiseclient "github.com/openkruise/kruise/pkg/client"
 	clonesetcore "github.com/openkruise/kruise/pkg/controller/cloneset/core"
 	revisioncontrol "github.com/openkruise/kruise/pkg/controller/cloneset/revision"
@@ -49,14 +50,17 @@ import (
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	klog "k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/controller/history"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -187,6 +191,7 @@ type ReconcileCloneSet struct {
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps.kruise.io,resources=clonesets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps.kruise.io,resources=clonesets/status,verbs=get;update;patch
@@ -241,6 +246,10 @@ func (r *ReconcileCloneSet) doReconcile(request reconcile.Request) (res reconcil
 		return reconcile.Result{}, nil
 	}
 
+	if modified, err := r.syncPVCRetentionOnDeletion(instance); err != nil || modified {
+		return reconcile.Result{}, err
+	}
+
 	// If scaling expectations have not satisfied yet, just skip this reconcile.
 	if scaleSatisfied, unsatisfiedDuration, scaleDirtyPods := clonesetutils.ScaleExpectations.SatisfiedExpectations(request.String()); !scaleSatisfied {
 		if unsatisfiedDuration >= expectations.ExpectationTimeout {
@@ -336,14 +345,20 @@ func (r *ReconcileCloneSet) doReconcile(request reconcile.Request) (res reconcil
 		}
 	}
 
+	// standby pods are neither scaled nor updated as the active ones, and not counted in status
+	filteredPods, standbyPods := clonesetutils.SplitStandbyPods(filteredPods)
+
 	newStatus := appsv1alpha1.CloneSetStatus{
 		ObservedGeneration: instance.Generation,
 		CurrentRevision:    currentRevision.Name,
 		UpdateRevision:     updateRevision.Name,
 		CollisionCount:     new(int32),
 		LabelSelector:      selector.String(),
+		StandbyReplicas:    int32(len(standbyPods)),
 	}
 	*newStatus.CollisionCount = collisionCount
+	calculateInPlaceUpdateCondition(instance, &newStatus, currentRevision, updateRevision,
+		synccontrol.GetInPlaceUpdateOptions(r.Client, instance, currentRevision, updateRevision, filteredPods, filteredPVCs), metav1.Now())
 
 	if !isPreDownloadDisabled {
 		if currentRevision.Name != updateRevision.Name {
@@ -374,22 +389,44 @@ func (r *ReconcileCloneSet) doReconcile(request reconcile.Request) (res reconcil
 		}
 	}
 
+	// rolling out with steps overrides the partition with the one of current step,
+	// so use a copy of instance to avoid writing it back into spec
+	syncInstance := instance
+	if len(instance.Spec.UpdateStrategy.Steps) > 0 {
+		syncInstance = instance.DeepCopy()
+		if pauseDuration := synccontrol.SyncUpdateSteps(syncInstance, &newStatus, filteredPods); pauseDuration > 0 {
+			clonesetutils.DurationStore.Push(request.String(), pauseDuration)
+		}
+	}
+
+	// expand pvcs online if the storage requests of volumeClaimTemplates grow
+	if utilfeature.DefaultFeatureGate.Enabled(features.CloneSetAutoResizePVCGate) {
+		if err = r.syncPVCResize(instance, &newStatus, filteredPods, filteredPVCs); err != nil {
+			klog.ErrorS(err, "Failed to resize pvcs for CloneSet", "cloneSet", request)
+		}
+	}
+
 	// scale and update pods
-	syncErr := r.syncCloneSet(instance, &newStatus, currentRevision, updateRevision, revisions, filteredPods, filteredPVCs)
+	syncErr := r.syncCloneSet(syncInstance, &newStatus, currentRevision, updateRevision, revisions, filteredPods, standbyPods, filteredPVCs)
 
 	// update new status
-	if err = r.statusUpdater.UpdateCloneSetStatus(instance, &newStatus, filteredPods); err != nil {
+	if err = r.statusUpdater.UpdateCloneSetStatus(syncInstance, &newStatus, filteredPods); err != nil {
 		return reconcile.Result{}, err
 	}
 
 	if err = r.truncatePodsToDelete(instance, filteredPods); err != nil {
-		klog.ErrorS(err, "Failed to truncate podsToDelete for CloneSet", "cloneSet", request)
+		klog.ErrorS(err, "Failed to truncate podsToDelete and podsToReplace for CloneSet", "cloneSet", request)
 	}
 
 	if err = r.truncateHistory(instance, filteredPods, revisions, currentRevision, updateRevision); err != nil {
 		klog.ErrorS(err, "Failed to truncate history for CloneSet", "cloneSet", request)
 	}
 
+	if err = r.rollbackIfProgressDeadlineExceeded(instance, &newStatus, currentRevision); err != nil {
+		klog.ErrorS(err, "Failed to roll back CloneSet", "cloneSet", request)
+		return reconcile.Result{}, err
+	}
+
 	if syncErr == nil && instance.Spec.MinReadySeconds > 0 && newStatus.AvailableReplicas != newStatus.ReadyReplicas {
 		clonesetutils.DurationStore.Push(request.String(), time.Second*time.Duration(instance.Spec.MinReadySeconds))
 	}
@@ -399,7 +436,7 @@ func (r *ReconcileCloneSet) doReconcile(request reconcile.Request) (res reconcil
 func (r *ReconcileCloneSet) syncCloneSet(
 	instance *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus,
 	currentRevision, updateRevision *apps.ControllerRevision, revisions []*apps.ControllerRevision,
-	filteredPods []*v1.Pod, filteredPVCs []*v1.PersistentVolumeClaim,
+	filteredPods, standbyPods []*v1.Pod, filteredPVCs []*v1.PersistentVolumeClaim,
 ) error {
 	if instance.DeletionTimestamp != nil {
 		return nil
@@ -419,7 +456,11 @@ func (r *ReconcileCloneSet) syncCloneSet(
 	var podsScaleErr error
 	var podsUpdateErr error
 
-	scaling, podsScaleErr = r.syncControl.Scale(currentSet, updateSet, currentRevision.Name, updateRevision.Name, filteredPods, filteredPVCs)
+	scaling, podsScaleErr = r.syncControl.SyncStandby(updateSet, currentRevision.Name, updateRevision.Name, filteredPods, standbyPods, filteredPVCs)
+	if podsScaleErr == nil && !scaling {
+		filteredPVCs = clonesetutils.FilterOutStandbyPVCs(filteredPVCs, standbyPods)
+		scaling, podsScaleErr = r.syncControl.Scale(currentSet, updateSet, currentRevision.Name, updateRevision.Name, filteredPods, filteredPVCs)
+	}
 	if podsScaleErr != nil {
 		newStatus.Conditions = append(newStatus.Conditions, appsv1alpha1.CloneSetCondition{
 			Type:               appsv1alpha1.CloneSetConditionFailedScale,
@@ -493,6 +534,7 @@ func (r *ReconcileCloneSet) getActiveRevisions(cs *appsv1alpha1.CloneSet, revisi
 		if !VCTHashEqual(lastEqualRevision, updateRevision) {
 			klog.InfoS("Revision vct hash will be updated", "revisionName", lastEqualRevision.Name, "lastRevisionVCTHash", lastEqualRevision.Annotations[volumeclaimtemplate.HashAnnotation], "updateRevisionVCTHash", updateRevision.Annotations[volumeclaimtemplate.HashAnnotation])
 			lastEqualRevision.Annotations[volumeclaimtemplate.HashAnnotation] = updateRevision.Annotations[volumeclaimtemplate.HashAnnotation]
+			lastEqualRevision.Annotations[volumeclaimtemplate.HashWithoutSizeAnnotation] = updateRevision.Annotations[volumeclaimtemplate.HashWithoutSizeAnnotation]
 		}
 		// if the equivalent revision is not immediately prior we will roll back by incrementing the
 		// Revision of the equivalent revision
@@ -553,9 +595,55 @@ func (r *ReconcileCloneSet) getOwnedPVCs(cs *appsv1alpha1.CloneSet) ([]*v1.Persi
 	return filteredPVCs, nil
 }
 
-// truncatePodsToDelete truncates any non-live pod names in spec.scaleStrategy.podsToDelete.
+// rollbackIfProgressDeadlineExceeded reverts the template of cs to the current revision, if the rollback policy is Auto
+// and the update revision has failed to progress within spec.progressDeadlineSeconds.
+func (r *ReconcileCloneSet) rollbackIfProgressDeadlineExceeded(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus, currentRevision *apps.ControllerRevision) error {
+	if cs.Spec.RollbackPolicy != appsv1alpha1.CloneSetRollbackPolicyAuto || cs.DeletionTimestamp != nil {
+		return nil
+	}
+	// nothing to roll back to if all pods have been updated
+	if newStatus.CurrentRevision == newStatus.UpdateRevision || currentRevision.Name == newStatus.UpdateRevision {
+		return nil
+	}
+	condition := GetCloneSetCondition(*newStatus, appsv1alpha1.CloneSetConditionProgressing)
+	if condition == nil || condition.Status != v1.ConditionFalse || condition.Reason != appsv1alpha1.CloneSetProgressDeadlineExceededReason {
+		return nil
+	}
+
+	rollbackSet, err := r.revisionControl.ApplyRevision(cs, currentRevision)
+	if err != nil {
+		return err
+	}
+	var rolledBack bool
+	err = retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		clone := &appsv1alpha1.CloneSet{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Namespace: cs.Namespace, Name: cs.Name}, clone); err != nil {
+			return err
+		}
+		// the spec has been changed since the rollout was observed, leave it to the next reconcile
+		if clone.Generation != cs.Generation {
+			return nil
+		}
+		clone.Spec.Template = rollbackSet.Spec.Template
+		if err := r.Update(context.TODO(), clone); err != nil {
+			return err
+		}
+		rolledBack = true
+		return nil
+	})
+	if err != nil || !rolledBack {
+		return err
+	}
+	klog.InfoS("Rolled back CloneSet since its update revision exceeded progress deadline", "cloneSet", klog.KObj(cs),
+		"updateRevision", newStatus.UpdateRevision, "currentRevision", currentRevision.Name)
+	r.recorder.Eventf(cs, v1.EventTypeWarning, "RolledBack", "Rolled back template to revision %s since revision %s exceeded progress deadline",
+		currentRevision.Name, newStatus.UpdateRevision)
+	return nil
+}
+
+// truncatePodsToDelete truncates any non-live pod names in spec.scaleStrategy.podsToDelete and spec.scaleStrategy.podsToReplace.
 func (r *ReconcileCloneSet) truncatePodsToDelete(cs *appsv1alpha1.CloneSet, pods []*v1.Pod) error {
-	if len(cs.Spec.ScaleStrategy.PodsToDelete) == 0 {
+	if len(cs.Spec.ScaleStrategy.PodsToDelete) == 0 && len(cs.Spec.ScaleStrategy.PodsToReplace) == 0 {
 		return nil
 	}
 
@@ -564,19 +652,26 @@ func (r *ReconcileCloneSet) truncatePodsToDelete(cs *appsv1alpha1.CloneSet, pods
 		existingPods.Insert(p.Name)
 	}
 
-	var newPodsToDelete []string
+	var newPodsToDelete, newPodsToReplace []string
 	for _, podName := range cs.Spec.ScaleStrategy.PodsToDelete {
 		if existingPods.Has(podName) {
 			newPodsToDelete = append(newPodsToDelete, podName)
 		}
 	}
+	for _, podName := range cs.Spec.ScaleStrategy.PodsToReplace {
+		if existingPods.Has(podName) {
+			newPodsToReplace = append(newPodsToReplace, podName)
+		}
+	}
 
-	if len(newPodsToDelete) == len(cs.Spec.ScaleStrategy.PodsToDelete) {
+	if len(newPodsToDelete) == len(cs.Spec.ScaleStrategy.PodsToDelete) &&
+		len(newPodsToReplace) == len(cs.Spec.ScaleStrategy.PodsToReplace) {
 		return nil
 	}
 
 	newCS := cs.DeepCopy()
 	newCS.Spec.ScaleStrategy.PodsToDelete = newPodsToDelete
+	newCS.Spec.ScaleStrategy.PodsToReplace = newPodsToReplace
 	return r.Update(context.TODO(), newCS)
 }
 
@@ -713,6 +808,40 @@ func (r *ReconcileCloneSet) cleanupPVCs(
 	return activePVCs, nil
 }
 
+// syncPVCRetentionOnDeletion manages the finalizer for persistentVolumeClaimRetentionPolicy.whenDeleted.
+// If whenDeleted is Retain, it releases the PVCs from the CloneSet being deleted, so that they will not be
+// garbage collected with the CloneSet.
+func (r *ReconcileCloneSet) syncPVCRetentionOnDeletion(cs *appsv1alpha1.CloneSet) (bool, error) {
+	retain := len(cs.Spec.VolumeClaimTemplates) > 0 &&
+		clonesetutils.GetPersistentVolumeClaimRetentionPolicy(cs).WhenDeleted == appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType
+	hasFinalizer := controllerutil.ContainsFinalizer(cs, appsv1alpha1.CloneSetPVCRetentionFinalizer)
+
+	if cs.DeletionTimestamp == nil {
+		if retain && !hasFinalizer {
+			return true, util.UpdateFinalizer(r.Client, cs, util.AddFinalizerOpType, appsv1alpha1.CloneSetPVCRetentionFinalizer)
+		} else if !retain && hasFinalizer {
+			return true, util.UpdateFinalizer(r.Client, cs, util.RemoveFinalizerOpType, appsv1alpha1.CloneSetPVCRetentionFinalizer)
+		}
+		return false, nil
+	} else if !hasFinalizer {
+		return false, nil
+	}
+
+	pvcs, err := r.getOwnedPVCs(cs)
+	if err != nil {
+		return false, err
+	}
+	for i := range pvcs {
+		pvc := pvcs[i].DeepCopy()
+		util.RemoveOwnerRef(pvc, cs)
+		if err := r.updateOnePVC(cs, pvc); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		klog.V(3).InfoS("Released CloneSet pvc to retain it after deletion", "cloneSet", klog.KObj(cs), "pvc", klog.KObj(pvc))
+	}
+	return true, util.UpdateFinalizer(r.Client, cs, util.RemoveFinalizerOpType, appsv1alpha1.CloneSetPVCRetentionFinalizer)
+}
+
 func (r *ReconcileCloneSet) updateOnePVC(cs *appsv1alpha1.CloneSet, pvc *v1.PersistentVolumeClaim) error {
 	if err := r.Client.Update(context.TODO(), pvc); err != nil {
 		r.recorder.Eventf(cs, v1.EventTypeWarning, "FailedUpdate", "failed to update PVC %s: %v", pvc.Name, err)
@@ -27,6 +27,9 @@ import (
 
 	"github.com/openkruise/kruise/apis/apps/defaults"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
+	revisioncontrol "github.com/openkruise/kruise/pkg/controller/cloneset/revision"
+	clonesettest "github.com/openkruise/kruise/pkg/controller/cloneset/test"
 	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
 	"github.com/openkruise/kruise/pkg/features"
 	"github.com/openkruise/kruise/pkg/util"
@@ -47,11 +50,13 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/kubernetes/pkg/apis/apps"
 	"k8s.io/kubernetes/pkg/controller/history"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -229,6 +234,115 @@ func TestClaimPods(t *testing.T) {
 	}
 }
 
+func TestSyncPVCRetentionOnDeletion(t *testing.T) {
+	now := metav1.Now()
+	newCloneSet := func(whenDeleted appsv1beta1.PersistentVolumeClaimRetentionPolicyType, deleting bool, finalizers ...string) *appsv1alpha1.CloneSet {
+		cs := clonesettest.NewCloneSet(2)
+		cs.Spec.PersistentVolumeClaimRetentionPolicy = &appsv1alpha1.CloneSetPersistentVolumeClaimRetentionPolicy{WhenDeleted: whenDeleted}
+		cs.Finalizers = finalizers
+		if deleting {
+			cs.DeletionTimestamp = &now
+		}
+		return cs
+	}
+
+	cases := []struct {
+		name             string
+		cs               *appsv1alpha1.CloneSet
+		expectModified   bool
+		expectFinalizer  bool
+		expectPVCsOwned  bool
+		expectCSNotFound bool
+	}{
+		{
+			name:            "add finalizer for Retain",
+			cs:              newCloneSet(appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType, false),
+			expectModified:  true,
+			expectFinalizer: true,
+			expectPVCsOwned: true,
+		},
+		{
+			name:            "remove finalizer for Delete",
+			cs:              newCloneSet(appsv1beta1.DeletePersistentVolumeClaimRetentionPolicyType, false, appsv1alpha1.CloneSetPVCRetentionFinalizer),
+			expectModified:  true,
+			expectPVCsOwned: true,
+		},
+		{
+			name:            "nothing to do for Retain with finalizer",
+			cs:              newCloneSet(appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType, false, appsv1alpha1.CloneSetPVCRetentionFinalizer),
+			expectFinalizer: true,
+			expectPVCsOwned: true,
+		},
+		{
+			name:             "release pvcs and remove finalizer when deleted with Retain",
+			cs:               newCloneSet(appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType, true, appsv1alpha1.CloneSetPVCRetentionFinalizer),
+			expectModified:   true,
+			expectCSNotFound: true,
+		},
+		{
+			name:            "keep pvcs owned when deleted with Delete",
+			cs:              newCloneSet(appsv1beta1.DeletePersistentVolumeClaimRetentionPolicyType, true, "foo"),
+			expectPVCsOwned: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var objects []client.Object
+			objects = append(objects, tc.cs.DeepCopy())
+			for i := 0; i < 2; i++ {
+				pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+					Namespace: tc.cs.Namespace,
+					Name:      fmt.Sprintf("datadir-foo-%d", i),
+				}}
+				util.SetOwnerRef(pvc, tc.cs, tc.cs.GroupVersionKind())
+				objects = append(objects, pvc)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(objects...).
+				WithIndex(&v1.PersistentVolumeClaim{}, fieldindex.IndexNameForOwnerRefUID, func(obj client.Object) []string {
+					var owners []string
+					for _, ref := range obj.GetOwnerReferences() {
+						owners = append(owners, string(ref.UID))
+					}
+					return owners
+				}).Build()
+			r := &ReconcileCloneSet{Client: fakeClient, recorder: record.NewFakeRecorder(10)}
+
+			modified, err := r.syncPVCRetentionOnDeletion(tc.cs)
+			if err != nil {
+				t.Fatalf("failed to sync pvc retention: %v", err)
+			}
+			if modified != tc.expectModified {
+				t.Fatalf("expected modified %v, got %v", tc.expectModified, modified)
+			}
+
+			cs := &appsv1alpha1.CloneSet{}
+			err = fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(tc.cs), cs)
+			if tc.expectCSNotFound {
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("expected CloneSet deleted after finalizer removed, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("failed to get CloneSet: %v", err)
+			} else if has := controllerutil.ContainsFinalizer(cs, appsv1alpha1.CloneSetPVCRetentionFinalizer); has != tc.expectFinalizer {
+				t.Fatalf("expected finalizer %v, got finalizers %v", tc.expectFinalizer, cs.Finalizers)
+			}
+
+			pvcList := &v1.PersistentVolumeClaimList{}
+			if err := fakeClient.List(context.TODO(), pvcList); err != nil {
+				t.Fatalf("failed to list pvcs: %v", err)
+			}
+			for i := range pvcList.Items {
+				pvc := &pvcList.Items[i]
+				owned := len(pvc.OwnerReferences) > 0 && pvc.OwnerReferences[0].UID == tc.cs.UID
+				if owned != tc.expectPVCsOwned {
+					t.Fatalf("expected pvc %s owned by CloneSet %v, got ownerReferences %v", pvc.Name, tc.expectPVCsOwned, pvc.OwnerReferences)
+				}
+			}
+		})
+	}
+}
+
 func newPod(podName string, label map[string]string, owner metav1.Object) *v1.Pod {
 	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -833,3 +947,98 @@ func getPVCNames(pvcs []*v1.PersistentVolumeClaim) sets.String {
 	}
 	return s
 }
+
+func TestRollbackIfProgressDeadlineExceeded(t *testing.T) {
+	newCloneSet := func(image string) *appsv1alpha1.CloneSet {
+		return &appsv1alpha1.CloneSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Generation: 2},
+			Spec: appsv1alpha1.CloneSetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+				Template: v1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "foo"}},
+					Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "main", Image: image}}},
+				},
+			},
+		}
+	}
+	revisionControl := revisioncontrol.NewRevisionControl()
+	currentRevision, err := revisionControl.NewRevision(newCloneSet(images[0]), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name             string
+		rollbackPolicy   appsv1alpha1.CloneSetRollbackPolicyType
+		conditionReason  string
+		staleGeneration  bool
+		expectedImage    string
+		expectedRollback bool
+	}{
+		{
+			name:             "roll back with Auto policy",
+			rollbackPolicy:   appsv1alpha1.CloneSetRollbackPolicyAuto,
+			conditionReason:  appsv1alpha1.CloneSetProgressDeadlineExceededReason,
+			expectedImage:    images[0],
+			expectedRollback: true,
+		},
+		{
+			name:            "not roll back with None policy",
+			rollbackPolicy:  appsv1alpha1.CloneSetRollbackPolicyNone,
+			conditionReason: appsv1alpha1.CloneSetProgressDeadlineExceededReason,
+			expectedImage:   images[1],
+		},
+		{
+			name:           "not roll back without progress deadline exceeded",
+			rollbackPolicy: appsv1alpha1.CloneSetRollbackPolicyAuto,
+			expectedImage:  images[1],
+		},
+		{
+			name:            "not roll back if spec changed after the rollout observed",
+			rollbackPolicy:  appsv1alpha1.CloneSetRollbackPolicyAuto,
+			conditionReason: appsv1alpha1.CloneSetProgressDeadlineExceededReason,
+			staleGeneration: true,
+			expectedImage:   images[1],
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := newCloneSet(images[1])
+			cs.Spec.RollbackPolicy = tc.rollbackPolicy
+			fakeClient := fake.NewClientBuilder().WithObjects(cs.DeepCopy()).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileCloneSet{Client: fakeClient, recorder: recorder, revisionControl: revisionControl}
+
+			newStatus := &appsv1alpha1.CloneSetStatus{CurrentRevision: currentRevision.Name, UpdateRevision: "foo-new"}
+			if tc.conditionReason != "" {
+				newStatus.Conditions = []appsv1alpha1.CloneSetCondition{{
+					Type:   appsv1alpha1.CloneSetConditionProgressing,
+					Status: v1.ConditionFalse,
+					Reason: tc.conditionReason,
+				}}
+			}
+			if tc.staleGeneration {
+				cs.Generation--
+			}
+			if err := r.rollbackIfProgressDeadlineExceeded(cs, newStatus, currentRevision); err != nil {
+				t.Fatal(err)
+			}
+
+			got := &appsv1alpha1.CloneSet{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: cs.Namespace, Name: cs.Name}, got); err != nil {
+				t.Fatal(err)
+			}
+			if image := got.Spec.Template.Spec.Containers[0].Image; image != tc.expectedImage {
+				t.Fatalf("expected image %s, got %s", tc.expectedImage, image)
+			}
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if rolledBack := len(events) == 1 && strings.Contains(events[0], "RolledBack"); rolledBack != tc.expectedRollback {
+				t.Fatalf("expected rollback event %v, got %v", tc.expectedRollback, events)
+			}
+		})
+	}
+}
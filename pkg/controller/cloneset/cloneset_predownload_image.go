@@ -78,7 +78,7 @@ func (r *ReconcileCloneSet) createImagePullJobsForInPlaceUpdate(cs *appsv1alpha1
 	// ignore if this revision can not update in-place
 	coreControl := clonesetcore.New(cs)
 	inplaceControl := inplaceupdate.New(r.Client, clonesetutils.RevisionAdapterImpl)
-	if !inplaceControl.CanUpdateInPlace(currentRevision, updateRevision, coreControl.GetUpdateOptions()) {
+	if canInPlace, _ := inplaceControl.CanUpdateInPlace(currentRevision, updateRevision, coreControl.GetUpdateOptions()); !canInPlace {
 		klog.V(4).InfoS("CloneSet skipped to create ImagePullJob because in-place update was not possible",
 			"cloneSet", klog.KObj(cs), "currentRevision", klog.KObj(currentRevision), "updateRevision", klog.KObj(updateRevision))
 		return r.patchControllerRevisionLabels(updateRevision, appsv1alpha1.ImagePreDownloadIgnoredKey, "true")
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloneset
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
+	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
+	pvcutil "github.com/openkruise/kruise/pkg/util/pvc"
+)
+
+// syncPVCResize expands the PVCs of existing pods online whose storage requests are smaller than
+// the ones in volumeClaimTemplates, and reports the compatibility of PVCs into newStatus.VolumeClaims.
+// PVCs that differ from templates in anything other than storage requests are left untouched,
+// they will be recreated along with their pods.
+func (r *ReconcileCloneSet) syncPVCResize(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus,
+	pods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim) error {
+
+	templates := cs.Spec.VolumeClaimTemplates
+	if len(templates) == 0 {
+		newStatus.VolumeClaims = nil
+		return nil
+	}
+
+	newStatus.VolumeClaims = make([]appsv1beta1.VolumeClaimStatus, len(templates))
+	templateStatuses := make(map[string]*appsv1beta1.VolumeClaimStatus, len(templates))
+	for i := range templates {
+		newStatus.VolumeClaims[i].VolumeClaimName = templates[i].Name
+		templateStatuses[templates[i].Name] = &newStatus.VolumeClaims[i]
+	}
+
+	claimsByName := make(map[string]*v1.PersistentVolumeClaim, len(pvcs))
+	for _, claim := range pvcs {
+		claimsByName[claim.Name] = claim
+	}
+
+	var errs []error
+	for _, pod := range pods {
+		for templateName, expected := range clonesetutils.GetPersistentVolumeClaims(cs, pod) {
+			claim, ok := claimsByName[expected.Name]
+			if !ok || claim.DeletionTimestamp != nil {
+				continue
+			}
+			template := &expected
+
+			if matched, needExpand := pvcutil.CompareWithCheckFn(claim, template, pvcutil.IsPVCNeedExpand); !matched && needExpand {
+				resized, err := r.resizeOnePVC(cs, claim, template)
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					claim = resized
+				}
+			}
+
+			if compatible, ready := pvcutil.IsPVCCompatibleAndReady(claim, template); compatible {
+				templateStatuses[templateName].CompatibleReplicas++
+				if ready {
+					templateStatuses[templateName].CompatibleReadyReplicas++
+				}
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *ReconcileCloneSet) resizeOnePVC(cs *appsv1alpha1.CloneSet, claim, template *v1.PersistentVolumeClaim) (*v1.PersistentVolumeClaim, error) {
+	if claim.Spec.StorageClassName != nil {
+		scName := *claim.Spec.StorageClassName
+		sc := &storagev1.StorageClass{}
+		if err := r.Get(context.TODO(), types.NamespacedName{Name: scName}, sc); err != nil {
+			return nil, fmt.Errorf("could not get storage class %s for pvc %s: %v", scName, claim.Name, err)
+		}
+		if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+			r.recorder.Eventf(cs, v1.EventTypeWarning, "FailedResize", "storage class %s of PVC %s does not support volume expansion", scName, claim.Name)
+			return nil, fmt.Errorf("storage class %s for pvc %s does not support volume expansion", scName, claim.Name)
+		}
+	}
+
+	claimClone := claim.DeepCopy()
+	claimClone.Spec.Resources = template.Spec.Resources
+	if err := r.updateOnePVC(cs, claimClone); err != nil {
+		return nil, err
+	}
+	klog.V(3).InfoS("Resized CloneSet pvc", "cloneSet", klog.KObj(cs), "pvc", klog.KObj(claimClone))
+	r.recorder.Eventf(cs, v1.EventTypeNormal, "SuccessfulResize", "succeed to resize PVC %s", claimClone.Name)
+	return claimClone, nil
+}
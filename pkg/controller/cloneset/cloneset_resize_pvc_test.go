@@ -0,0 +1,124 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloneset
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	utilpointer "k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+)
+
+func TestSyncPVCResize(t *testing.T) {
+	newClaim := func(id, size, capacity string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: fmt.Sprintf("data-foo-%s", id)},
+			Spec: v1.PersistentVolumeClaimSpec{
+				StorageClassName: utilpointer.String("standard"),
+				Resources:        v1.VolumeResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)}},
+			},
+			Status: v1.PersistentVolumeClaimStatus{Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse(capacity)}},
+		}
+	}
+	newPod := func(id string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo-" + id,
+			Labels: map[string]string{appsv1alpha1.CloneSetInstanceID: id}}}
+	}
+	cs := &appsv1alpha1.CloneSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+		Spec: appsv1alpha1.CloneSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: "data"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					StorageClassName: utilpointer.String("standard"),
+					Resources:        v1.VolumeResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("20Gi")}},
+				},
+			}},
+		},
+	}
+
+	cases := []struct {
+		name               string
+		allowExpansion     bool
+		expectedSize       string
+		expectedErr        bool
+		expectedCompatible int32
+		expectedReady      int32
+	}{
+		{
+			name:               "expand pvc online",
+			allowExpansion:     true,
+			expectedSize:       "20Gi",
+			expectedCompatible: 2,
+			expectedReady:      1,
+		},
+		{
+			name:               "storage class does not allow expansion",
+			allowExpansion:     false,
+			expectedSize:       "10Gi",
+			expectedErr:        true,
+			expectedCompatible: 1,
+			expectedReady:      1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, AllowVolumeExpansion: utilpointer.Bool(tc.allowExpansion)}
+			pvcs := []*v1.PersistentVolumeClaim{newClaim("a", "20Gi", "20Gi"), newClaim("b", "10Gi", "10Gi")}
+			objs := []client.Object{sc}
+			for _, pvc := range pvcs {
+				objs = append(objs, pvc)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objs...).Build()
+			r := &ReconcileCloneSet{Client: fakeClient, recorder: record.NewFakeRecorder(10)}
+
+			newStatus := &appsv1alpha1.CloneSetStatus{}
+			err := r.syncPVCResize(cs, newStatus, []*v1.Pod{newPod("a"), newPod("b")}, pvcs)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+
+			claim := &v1.PersistentVolumeClaim{}
+			if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "data-foo-b"}, claim); err != nil {
+				t.Fatalf("failed to get pvc: %v", err)
+			}
+			if size := claim.Spec.Resources.Requests[v1.ResourceStorage]; size.Cmp(resource.MustParse(tc.expectedSize)) != 0 {
+				t.Fatalf("expected pvc size %s, got %s", tc.expectedSize, size.String())
+			}
+			if len(newStatus.VolumeClaims) != 1 || newStatus.VolumeClaims[0].VolumeClaimName != "data" {
+				t.Fatalf("unexpected volumeClaims status %v", newStatus.VolumeClaims)
+			}
+			if newStatus.VolumeClaims[0].CompatibleReplicas != tc.expectedCompatible || newStatus.VolumeClaims[0].CompatibleReadyReplicas != tc.expectedReady {
+				t.Fatalf("expected compatible %d and ready %d, got %v", tc.expectedCompatible, tc.expectedReady, newStatus.VolumeClaims[0])
+			}
+		})
+	}
+}
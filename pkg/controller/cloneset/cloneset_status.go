@@ -19,13 +19,20 @@ package cloneset
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"time"
 
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
 	clonesetcore "github.com/openkruise/kruise/pkg/controller/cloneset/core"
 	"github.com/openkruise/kruise/pkg/controller/cloneset/sync"
 	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
 	"github.com/openkruise/kruise/pkg/util"
+	"github.com/openkruise/kruise/pkg/util/inplaceupdate"
+	"github.com/openkruise/kruise/pkg/util/lifecycle"
+	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
@@ -47,9 +54,13 @@ type realStatusUpdater struct {
 
 func (r *realStatusUpdater) UpdateCloneSetStatus(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus, pods []*v1.Pod) error {
 	r.calculateStatus(cs, newStatus, pods)
+	calculateRolloutHistory(cs, newStatus, metav1.Now())
 	if err := clonesetcore.New(cs).ExtraStatusCalculation(newStatus, pods); err != nil {
 		return fmt.Errorf("failed to calculate extra status for cloneSet %s/%s: %v", cs.Namespace, cs.Name, err)
 	}
+	if requeueDuration := calculateProgressingCondition(cs, newStatus, metav1.Now()); requeueDuration > 0 {
+		clonesetutils.DurationStore.Push(clonesetutils.GetControllerKey(cs), requeueDuration)
+	}
 	if !r.inconsistentStatus(cs, newStatus) {
 		return nil
 	}
@@ -81,19 +92,35 @@ func (r *realStatusUpdater) inconsistentStatus(cs *appsv1alpha1.CloneSet, newSta
 		newStatus.ExpectedUpdatedReplicas != oldStatus.ExpectedUpdatedReplicas ||
 		newStatus.UpdateRevision != oldStatus.UpdateRevision ||
 		newStatus.CurrentRevision != oldStatus.CurrentRevision ||
-		newStatus.LabelSelector != oldStatus.LabelSelector
+		newStatus.LabelSelector != oldStatus.LabelSelector ||
+		!reflect.DeepEqual(newStatus.CurrentStepIndex, oldStatus.CurrentStepIndex) ||
+		!reflect.DeepEqual(newStatus.StepStatuses, oldStatus.StepStatuses) ||
+		!reflect.DeepEqual(newStatus.VolumeClaims, oldStatus.VolumeClaims) ||
+		newStatus.StandbyReplicas != oldStatus.StandbyReplicas ||
+		!reflect.DeepEqual(newStatus.RevisionReplicas, oldStatus.RevisionReplicas) ||
+		!reflect.DeepEqual(newStatus.RolloutHistory, oldStatus.RolloutHistory) ||
+		!reflect.DeepEqual(newStatus.LifecycleStates, oldStatus.LifecycleStates) ||
+		!reflect.DeepEqual(newStatus.UpdateTopology, oldStatus.UpdateTopology) ||
+		!reflect.DeepEqual(GetCloneSetCondition(*newStatus, appsv1alpha1.CloneSetConditionInPlaceUpdateNotPossible),
+			GetCloneSetCondition(oldStatus, appsv1alpha1.CloneSetConditionInPlaceUpdateNotPossible)) ||
+		!reflect.DeepEqual(GetCloneSetCondition(*newStatus, appsv1alpha1.CloneSetConditionProgressing), GetCloneSetCondition(oldStatus, appsv1alpha1.CloneSetConditionProgressing))
 }
 
 func (r *realStatusUpdater) calculateStatus(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus, pods []*v1.Pod) {
 	coreControl := clonesetcore.New(cs)
 	for _, pod := range pods {
+		revisionReplicas := getRevisionReplicas(newStatus, pod.Labels[apps.ControllerRevisionHashLabelKey])
 		newStatus.Replicas++
+		revisionReplicas.Replicas++
 		if coreControl.IsPodUpdateReady(pod, 0) {
 			newStatus.ReadyReplicas++
+			revisionReplicas.ReadyReplicas++
 		}
 		if sync.IsPodAvailable(coreControl, pod, cs.Spec.MinReadySeconds) {
 			newStatus.AvailableReplicas++
+			revisionReplicas.AvailableReplicas++
 		}
+		newStatus.RevisionReplicas[pod.Labels[apps.ControllerRevisionHashLabelKey]] = revisionReplicas
 		if clonesetutils.EqualToRevisionHash("", pod, newStatus.UpdateRevision) {
 			newStatus.UpdatedReplicas++
 		}
@@ -112,4 +139,194 @@ func (r *realStatusUpdater) calculateStatus(cs *appsv1alpha1.CloneSet, newStatus
 	if partition, err := util.CalculatePartitionReplicas(cs.Spec.UpdateStrategy.Partition, cs.Spec.Replicas); err == nil {
 		newStatus.ExpectedUpdatedReplicas = *cs.Spec.Replicas - int32(partition)
 	}
+	newStatus.LifecycleStates = lifecycle.CalculateLifecycleStates(cs.Spec.Lifecycle, pods)
+
+	var soakLeft time.Duration
+	newStatus.UpdateTopology, soakLeft = sync.CalculateUpdateTopology(cs, coreControl, newStatus.UpdateRevision, pods, metav1.Now())
+	if soakLeft > 0 {
+		clonesetutils.DurationStore.Push(clonesetutils.GetControllerKey(cs), soakLeft)
+	}
+}
+
+func getRevisionReplicas(newStatus *appsv1alpha1.CloneSetStatus, revision string) appsv1alpha1.CloneSetRevisionReplicas {
+	if newStatus.RevisionReplicas == nil {
+		newStatus.RevisionReplicas = make(map[string]appsv1alpha1.CloneSetRevisionReplicas)
+	}
+	return newStatus.RevisionReplicas[revision]
+}
+
+// calculateRolloutHistory finishes the progressing rollout in history if it has completed or been re-targeted,
+// and starts a new one if the update revision has changed to another one different from the current revision.
+func calculateRolloutHistory(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus, now metav1.Time) {
+	var history []appsv1alpha1.CloneSetRolloutRecord
+	for i := range cs.Status.RolloutHistory {
+		history = append(history, *cs.Status.RolloutHistory[i].DeepCopy())
+	}
+
+	if n := len(history); n > 0 && history[n-1].Outcome == appsv1alpha1.CloneSetRolloutProgressing {
+		last := &history[n-1]
+		switch {
+		case last.Revision != newStatus.UpdateRevision && newStatus.UpdateRevision == newStatus.CurrentRevision:
+			last.Outcome = appsv1alpha1.CloneSetRolloutRolledBack
+			last.FinishTime = &now
+		case last.Revision != newStatus.UpdateRevision:
+			last.Outcome = appsv1alpha1.CloneSetRolloutSuperseded
+			last.FinishTime = &now
+		case newStatus.CurrentRevision == newStatus.UpdateRevision:
+			last.Outcome = appsv1alpha1.CloneSetRolloutSucceeded
+			last.FinishTime = &now
+		}
+	}
+
+	if cs.Status.UpdateRevision != "" && newStatus.UpdateRevision != cs.Status.UpdateRevision &&
+		newStatus.UpdateRevision != newStatus.CurrentRevision {
+		history = append(history, appsv1alpha1.CloneSetRolloutRecord{
+			Revision:  newStatus.UpdateRevision,
+			StartTime: now,
+			Outcome:   appsv1alpha1.CloneSetRolloutProgressing,
+		})
+	}
+
+	if len(history) > appsv1alpha1.MaxCloneSetRolloutHistory {
+		history = history[len(history)-appsv1alpha1.MaxCloneSetRolloutHistory:]
+	}
+	newStatus.RolloutHistory = history
+}
+
+// calculateInPlaceUpdateCondition sets the InPlaceUpdateNotPossible condition into newStatus if pods of current revision
+// can not be updated in place to the update revision, with the fields that prevent it in the message.
+func calculateInPlaceUpdateCondition(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus,
+	currentRevision, updateRevision *apps.ControllerRevision, opts *inplaceupdate.UpdateOptions, now metav1.Time) {
+
+	if cs.Spec.UpdateStrategy.Type != appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType &&
+		cs.Spec.UpdateStrategy.Type != appsv1alpha1.InPlaceOnlyCloneSetUpdateStrategyType {
+		return
+	}
+	if currentRevision.Name == updateRevision.Name {
+		return
+	}
+	canInPlace, reasons := inplaceupdate.CheckInPlaceUpdate(currentRevision, updateRevision, opts)
+	if canInPlace {
+		return
+	}
+
+	reason := appspub.InPlaceUpdateFallbackToRecreateReason
+	if cs.Spec.UpdateStrategy.Type == appsv1alpha1.InPlaceOnlyCloneSetUpdateStrategyType {
+		reason = appspub.InPlaceUpdateBlockedReason
+	}
+	condition := appsv1alpha1.CloneSetCondition{
+		Type:               appsv1alpha1.CloneSetConditionInPlaceUpdateNotPossible,
+		Status:             v1.ConditionTrue,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            fmt.Sprintf("revision %s can not be updated in place: %s", updateRevision.Name, inplaceupdate.FormatNotInPlaceUpdateReasons(reasons)),
+	}
+	// keep the old condition unchanged if nothing changed
+	if oldCondition := GetCloneSetCondition(cs.Status, condition.Type); oldCondition != nil &&
+		oldCondition.Reason == condition.Reason && oldCondition.Message == condition.Message {
+		condition = *oldCondition
+	}
+	SetCloneSetCondition(newStatus, condition)
+}
+
+// calculateProgressingCondition sets the Progressing condition into newStatus if spec.progressDeadlineSeconds is set.
+// It returns the duration to requeue for checking whether the progress deadline has been exceeded.
+func calculateProgressingCondition(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus, now metav1.Time) time.Duration {
+	if cs.Spec.ProgressDeadlineSeconds == nil {
+		return 0
+	}
+
+	oldCondition := GetCloneSetCondition(cs.Status, appsv1alpha1.CloneSetConditionProgressing)
+	var requeueDuration time.Duration
+	var newCondition *appsv1alpha1.CloneSetCondition
+	switch {
+	case isUpdatePaused(cs, newStatus):
+		newCondition = newProgressingCondition(v1.ConditionUnknown, appsv1alpha1.CloneSetPausedReason, "CloneSet is paused", now)
+
+	case newStatus.UpdatedAvailableReplicas >= newStatus.ExpectedUpdatedReplicas && newStatus.Replicas == *cs.Spec.Replicas:
+		newCondition = newProgressingCondition(v1.ConditionTrue, appsv1alpha1.CloneSetNewRevisionAvailableReason,
+			fmt.Sprintf("CloneSet has successfully progressed to revision %s", newStatus.UpdateRevision), now)
+
+	case oldCondition == nil || newStatus.UpdateRevision != cs.Status.UpdateRevision:
+		newCondition = newProgressingCondition(v1.ConditionTrue, appsv1alpha1.CloneSetNewRevisionCreatedReason,
+			fmt.Sprintf("Created new update revision %s", newStatus.UpdateRevision), now)
+
+	case oldCondition.Status == v1.ConditionUnknown || oldCondition.Reason == appsv1alpha1.CloneSetNewRevisionAvailableReason ||
+		newStatus.UpdatedReplicas > cs.Status.UpdatedReplicas ||
+		newStatus.UpdatedReadyReplicas > cs.Status.UpdatedReadyReplicas ||
+		newStatus.UpdatedAvailableReplicas > cs.Status.UpdatedAvailableReplicas:
+		// resumed from pausing, scaled after completion, or more pods have been updated or become available
+		newCondition = newProgressingCondition(v1.ConditionTrue, appsv1alpha1.CloneSetUpdatedReason,
+			fmt.Sprintf("CloneSet is progressing to revision %s", newStatus.UpdateRevision), now)
+
+	case oldCondition.Reason == appsv1alpha1.CloneSetProgressDeadlineExceededReason:
+		newCondition = oldCondition.DeepCopy()
+
+	default:
+		deadline := oldCondition.LastUpdateTime.Add(time.Duration(*cs.Spec.ProgressDeadlineSeconds) * time.Second)
+		if now.Time.Before(deadline) {
+			newCondition = oldCondition.DeepCopy()
+			requeueDuration = deadline.Sub(now.Time)
+		} else {
+			newCondition = newProgressingCondition(v1.ConditionFalse, appsv1alpha1.CloneSetProgressDeadlineExceededReason,
+				fmt.Sprintf("CloneSet revision %s has timed out progressing", newStatus.UpdateRevision), now)
+		}
+	}
+
+	// keep the old condition unchanged if it is still in the same state
+	if oldCondition != nil && oldCondition.Status == newCondition.Status && oldCondition.Reason == newCondition.Reason &&
+		newCondition.Reason != appsv1alpha1.CloneSetUpdatedReason && newCondition.Reason != appsv1alpha1.CloneSetNewRevisionCreatedReason {
+		newCondition = oldCondition.DeepCopy()
+	} else if oldCondition != nil && oldCondition.Status == newCondition.Status {
+		newCondition.LastTransitionTime = oldCondition.LastTransitionTime
+	}
+	if newCondition.Status == v1.ConditionTrue && newCondition.Reason != appsv1alpha1.CloneSetNewRevisionAvailableReason && requeueDuration == 0 {
+		requeueDuration = time.Duration(*cs.Spec.ProgressDeadlineSeconds) * time.Second
+	}
+	SetCloneSetCondition(newStatus, *newCondition)
+	return requeueDuration
+}
+
+func isUpdatePaused(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus) bool {
+	if cs.Spec.UpdateStrategy.Paused {
+		return true
+	}
+	if newStatus.CurrentStepIndex != nil && int(*newStatus.CurrentStepIndex) < len(newStatus.StepStatuses) {
+		return newStatus.StepStatuses[*newStatus.CurrentStepIndex].State == appsv1alpha1.CloneSetUpdateStepStatePaused
+	}
+	return false
+}
+
+func newProgressingCondition(status v1.ConditionStatus, reason, message string, now metav1.Time) *appsv1alpha1.CloneSetCondition {
+	return &appsv1alpha1.CloneSetCondition{
+		Type:               appsv1alpha1.CloneSetConditionProgressing,
+		Status:             status,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+		Reason:             reason,
+		Message:            message,
+	}
+}
+
+// GetCloneSetCondition returns the condition with the provided type.
+func GetCloneSetCondition(status appsv1alpha1.CloneSetStatus, condType appsv1alpha1.CloneSetConditionType) *appsv1alpha1.CloneSetCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCloneSetCondition updates the CloneSet to include the provided condition. If the condition that
+// we are about to add already exists, it will be replaced.
+func SetCloneSetCondition(status *appsv1alpha1.CloneSetStatus, condition appsv1alpha1.CloneSetCondition) {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condition.Type {
+			status.Conditions[i] = condition
+			return
+		}
+	}
+	status.Conditions = append(status.Conditions, condition)
 }
@@ -0,0 +1,237 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloneset
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilpointer "k8s.io/utils/pointer"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/util"
+)
+
+func TestCalculateProgressingCondition(t *testing.T) {
+	now := metav1.NewTime(time.Unix(time.Now().Unix(), 0))
+	before := func(d time.Duration) metav1.Time {
+		return metav1.NewTime(now.Add(-d))
+	}
+	progressing := func(status v1.ConditionStatus, reason string, lastUpdateTime metav1.Time) []appsv1alpha1.CloneSetCondition {
+		return []appsv1alpha1.CloneSetCondition{{
+			Type:               appsv1alpha1.CloneSetConditionProgressing,
+			Status:             status,
+			Reason:             reason,
+			LastUpdateTime:     lastUpdateTime,
+			LastTransitionTime: lastUpdateTime,
+		}}
+	}
+
+	cases := []struct {
+		name                   string
+		paused                 bool
+		oldStatus              appsv1alpha1.CloneSetStatus
+		newStatus              appsv1alpha1.CloneSetStatus
+		expectedStatus         v1.ConditionStatus
+		expectedReason         string
+		expectedLastUpdateTime metav1.Time
+		expectedDuration       time.Duration
+	}{
+		{
+			name:                   "new revision created",
+			oldStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v1", Replicas: 4, UpdatedReplicas: 4, UpdatedAvailableReplicas: 4},
+			newStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, ExpectedUpdatedReplicas: 4},
+			expectedStatus:         v1.ConditionTrue,
+			expectedReason:         appsv1alpha1.CloneSetNewRevisionCreatedReason,
+			expectedLastUpdateTime: now,
+			expectedDuration:       time.Minute,
+		},
+		{
+			name: "more pods updated",
+			oldStatus: appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 1,
+				Conditions: progressing(v1.ConditionTrue, appsv1alpha1.CloneSetNewRevisionCreatedReason, before(30*time.Second))},
+			newStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2, ExpectedUpdatedReplicas: 4},
+			expectedStatus:         v1.ConditionTrue,
+			expectedReason:         appsv1alpha1.CloneSetUpdatedReason,
+			expectedLastUpdateTime: now,
+			expectedDuration:       time.Minute,
+		},
+		{
+			name: "no progress within deadline",
+			oldStatus: appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2,
+				Conditions: progressing(v1.ConditionTrue, appsv1alpha1.CloneSetUpdatedReason, before(20*time.Second))},
+			newStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2, ExpectedUpdatedReplicas: 4},
+			expectedStatus:         v1.ConditionTrue,
+			expectedReason:         appsv1alpha1.CloneSetUpdatedReason,
+			expectedLastUpdateTime: before(20 * time.Second),
+			expectedDuration:       40 * time.Second,
+		},
+		{
+			name: "progress deadline exceeded",
+			oldStatus: appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2,
+				Conditions: progressing(v1.ConditionTrue, appsv1alpha1.CloneSetUpdatedReason, before(2*time.Minute))},
+			newStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2, ExpectedUpdatedReplicas: 4},
+			expectedStatus:         v1.ConditionFalse,
+			expectedReason:         appsv1alpha1.CloneSetProgressDeadlineExceededReason,
+			expectedLastUpdateTime: now,
+		},
+		{
+			name:   "paused",
+			paused: true,
+			oldStatus: appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2,
+				Conditions: progressing(v1.ConditionTrue, appsv1alpha1.CloneSetUpdatedReason, before(2*time.Minute))},
+			newStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2, ExpectedUpdatedReplicas: 4},
+			expectedStatus:         v1.ConditionUnknown,
+			expectedReason:         appsv1alpha1.CloneSetPausedReason,
+			expectedLastUpdateTime: now,
+		},
+		{
+			name: "resumed",
+			oldStatus: appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2,
+				Conditions: progressing(v1.ConditionUnknown, appsv1alpha1.CloneSetPausedReason, before(2*time.Minute))},
+			newStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 2, ExpectedUpdatedReplicas: 4},
+			expectedStatus:         v1.ConditionTrue,
+			expectedReason:         appsv1alpha1.CloneSetUpdatedReason,
+			expectedLastUpdateTime: now,
+			expectedDuration:       time.Minute,
+		},
+		{
+			name: "new revision available",
+			oldStatus: appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 4, UpdatedAvailableReplicas: 3,
+				Conditions: progressing(v1.ConditionTrue, appsv1alpha1.CloneSetUpdatedReason, before(30*time.Second))},
+			newStatus:              appsv1alpha1.CloneSetStatus{UpdateRevision: "v2", Replicas: 4, UpdatedReplicas: 4, UpdatedAvailableReplicas: 4, ExpectedUpdatedReplicas: 4},
+			expectedStatus:         v1.ConditionTrue,
+			expectedReason:         appsv1alpha1.CloneSetNewRevisionAvailableReason,
+			expectedLastUpdateTime: now,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := &appsv1alpha1.CloneSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+				Spec: appsv1alpha1.CloneSetSpec{
+					Replicas:                utilpointer.Int32(4),
+					ProgressDeadlineSeconds: utilpointer.Int32(60),
+					UpdateStrategy:          appsv1alpha1.CloneSetUpdateStrategy{Paused: tc.paused},
+				},
+				Status: tc.oldStatus,
+			}
+			newStatus := tc.newStatus.DeepCopy()
+			duration := calculateProgressingCondition(cs, newStatus, now)
+
+			if duration != tc.expectedDuration {
+				t.Fatalf("expected duration %v, got %v", tc.expectedDuration, duration)
+			}
+			condition := GetCloneSetCondition(*newStatus, appsv1alpha1.CloneSetConditionProgressing)
+			if condition == nil {
+				t.Fatalf("expected Progressing condition, got nil")
+			}
+			if condition.Status != tc.expectedStatus || condition.Reason != tc.expectedReason {
+				t.Fatalf("expected condition %s/%s, got %s/%s", tc.expectedStatus, tc.expectedReason, condition.Status, condition.Reason)
+			}
+			if !condition.LastUpdateTime.Equal(&tc.expectedLastUpdateTime) {
+				t.Fatalf("expected lastUpdateTime %v, got %v", tc.expectedLastUpdateTime, condition.LastUpdateTime)
+			}
+		})
+	}
+}
+
+func TestCalculateRolloutHistory(t *testing.T) {
+	now := metav1.NewTime(time.Unix(time.Now().Unix(), 0))
+	started := metav1.NewTime(now.Add(-time.Minute))
+	progressing := func(revision string) appsv1alpha1.CloneSetRolloutRecord {
+		return appsv1alpha1.CloneSetRolloutRecord{Revision: revision, StartTime: started, Outcome: appsv1alpha1.CloneSetRolloutProgressing}
+	}
+	finished := func(revision string, outcome appsv1alpha1.CloneSetRolloutOutcome) appsv1alpha1.CloneSetRolloutRecord {
+		return appsv1alpha1.CloneSetRolloutRecord{Revision: revision, StartTime: started, FinishTime: &now, Outcome: outcome}
+	}
+
+	var fullHistory []appsv1alpha1.CloneSetRolloutRecord
+	for i := 0; i < appsv1alpha1.MaxCloneSetRolloutHistory; i++ {
+		fullHistory = append(fullHistory, finished(fmt.Sprintf("v%d", i), appsv1alpha1.CloneSetRolloutSucceeded))
+	}
+
+	cases := []struct {
+		name            string
+		oldStatus       appsv1alpha1.CloneSetStatus
+		newStatus       appsv1alpha1.CloneSetStatus
+		expectedHistory []appsv1alpha1.CloneSetRolloutRecord
+	}{
+		{
+			name:      "no rollout for the first revision",
+			newStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v1"},
+		},
+		{
+			name:            "start a rollout",
+			oldStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v1"},
+			newStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2"},
+			expectedHistory: []appsv1alpha1.CloneSetRolloutRecord{{Revision: "v2", StartTime: now, Outcome: appsv1alpha1.CloneSetRolloutProgressing}},
+		},
+		{
+			name:            "rollout is progressing",
+			oldStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", RolloutHistory: []appsv1alpha1.CloneSetRolloutRecord{progressing("v2")}},
+			newStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2"},
+			expectedHistory: []appsv1alpha1.CloneSetRolloutRecord{progressing("v2")},
+		},
+		{
+			name:            "rollout succeeded",
+			oldStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", RolloutHistory: []appsv1alpha1.CloneSetRolloutRecord{progressing("v2")}},
+			newStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v2", UpdateRevision: "v2"},
+			expectedHistory: []appsv1alpha1.CloneSetRolloutRecord{finished("v2", appsv1alpha1.CloneSetRolloutSucceeded)},
+		},
+		{
+			name:            "rollout rolled back",
+			oldStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", RolloutHistory: []appsv1alpha1.CloneSetRolloutRecord{progressing("v2")}},
+			newStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v1"},
+			expectedHistory: []appsv1alpha1.CloneSetRolloutRecord{finished("v2", appsv1alpha1.CloneSetRolloutRolledBack)},
+		},
+		{
+			name:      "rollout superseded",
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", RolloutHistory: []appsv1alpha1.CloneSetRolloutRecord{progressing("v2")}},
+			newStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v3"},
+			expectedHistory: []appsv1alpha1.CloneSetRolloutRecord{
+				finished("v2", appsv1alpha1.CloneSetRolloutSuperseded),
+				{Revision: "v3", StartTime: now, Outcome: appsv1alpha1.CloneSetRolloutProgressing},
+			},
+		},
+		{
+			name:            "history is bounded",
+			oldStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v9", UpdateRevision: "v9", RolloutHistory: fullHistory},
+			newStatus:       appsv1alpha1.CloneSetStatus{CurrentRevision: "v9", UpdateRevision: "v10"},
+			expectedHistory: append(append([]appsv1alpha1.CloneSetRolloutRecord{}, fullHistory[1:]...), appsv1alpha1.CloneSetRolloutRecord{Revision: "v10", StartTime: now, Outcome: appsv1alpha1.CloneSetRolloutProgressing}),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := &appsv1alpha1.CloneSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+				Status:     tc.oldStatus,
+			}
+			newStatus := tc.newStatus.DeepCopy()
+			calculateRolloutHistory(cs, newStatus, now)
+			if !reflect.DeepEqual(newStatus.RolloutHistory, tc.expectedHistory) {
+				t.Fatalf("expected history %v, got %v", util.DumpJSON(tc.expectedHistory), util.DumpJSON(newStatus.RolloutHistory))
+			}
+		})
+	}
+}
@@ -103,6 +103,7 @@ func (c *commonControl) newVersionedPods(cs *appsv1alpha1.CloneSet, revision str
 		pod.Labels[appsv1alpha1.CloneSetInstanceID] = id
 
 		inplaceupdate.InjectReadinessGate(pod)
+		inplaceupdate.ProjectContainerValues(pod)
 		clonesetutils.UpdateStorage(cs, pod)
 
 		newPods = append(newPods, pod)
@@ -144,6 +145,7 @@ func (c *commonControl) GetUpdateOptions() *inplaceupdate.UpdateOptions {
 	opts := &inplaceupdate.UpdateOptions{}
 	if c.Spec.UpdateStrategy.InPlaceUpdateStrategy != nil {
 		opts.GracePeriodSeconds = c.Spec.UpdateStrategy.InPlaceUpdateStrategy.GracePeriodSeconds
+		opts.ResizeTimeoutSeconds = c.Spec.UpdateStrategy.InPlaceUpdateStrategy.ResizeTimeoutSeconds
 	}
 	// For the InPlaceOnly strategy, ignore the hash comparison of VolumeClaimTemplates.
 	// Consider making changes through a feature gate.
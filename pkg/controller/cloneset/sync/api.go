@@ -22,6 +22,8 @@ import (
 	"github.com/openkruise/kruise/pkg/util/controllerfinder"
 	"github.com/openkruise/kruise/pkg/util/inplaceupdate"
 	"github.com/openkruise/kruise/pkg/util/lifecycle"
+	"github.com/openkruise/kruise/pkg/util/podadapter"
+	"github.com/openkruise/kruise/pkg/util/podreadiness"
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
@@ -36,6 +38,12 @@ type Interface interface {
 		pods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim,
 	) (bool, error)
 
+	SyncStandby(
+		updateCS *appsv1alpha1.CloneSet,
+		currentRevision, updateRevision string,
+		pods, standbyPods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim,
+	) (bool, error)
+
 	Update(cs *appsv1alpha1.CloneSet,
 		currentRevision, updateRevision *apps.ControllerRevision, revisions []*apps.ControllerRevision,
 		pods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim,
@@ -44,18 +52,20 @@ type Interface interface {
 
 type realControl struct {
 	client.Client
-	lifecycleControl lifecycle.Interface
-	inplaceControl   inplaceupdate.Interface
-	recorder         record.EventRecorder
-	controllerFinder *controllerfinder.ControllerFinder
+	lifecycleControl    lifecycle.Interface
+	inplaceControl      inplaceupdate.Interface
+	podReadinessControl podreadiness.Interface
+	recorder            record.EventRecorder
+	controllerFinder    *controllerfinder.ControllerFinder
 }
 
 func New(c client.Client, recorder record.EventRecorder) Interface {
 	return &realControl{
-		Client:           c,
-		inplaceControl:   inplaceupdate.New(c, clonesetutils.RevisionAdapterImpl),
-		lifecycleControl: lifecycle.New(c),
-		recorder:         recorder,
-		controllerFinder: controllerfinder.Finder,
+		Client:              c,
+		inplaceControl:      inplaceupdate.New(c, clonesetutils.RevisionAdapterImpl),
+		lifecycleControl:    lifecycle.New(c),
+		podReadinessControl: podreadiness.NewForAdapter(&podadapter.AdapterRuntimeClient{Client: c}),
+		recorder:            recorder,
+		controllerFinder:    controllerfinder.Finder,
 	}
 }
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	clonesetcore "github.com/openkruise/kruise/pkg/controller/cloneset/core"
+	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
+	"github.com/openkruise/kruise/pkg/util"
+	"github.com/openkruise/kruise/pkg/util/expectations"
+	"github.com/openkruise/kruise/pkg/util/lifecycle"
+)
+
+// replacePods creates replacement pods for the pods specified to replace, and deletes the original pods
+// once their replacements are available. The number of pods replacing at the same time is limited by maxSurge.
+func (r *realControl) replacePods(
+	currentCS, updateCS *appsv1alpha1.CloneSet,
+	currentRevision, updateRevision string,
+	pods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim,
+) (bool, error) {
+	coreControl := clonesetcore.New(updateCS)
+
+	existingIDs := sets.NewString()
+	for _, pod := range pods {
+		existingIDs.Insert(pod.Labels[appsv1alpha1.CloneSetInstanceID])
+	}
+	replacements := make(map[string]*v1.Pod)
+	var originGone []*v1.Pod
+	for _, pod := range pods {
+		if id := pod.Labels[appsv1alpha1.CloneSetReplaceOriginInstanceIDKey]; len(id) > 0 {
+			if !existingIDs.Has(id) {
+				originGone = append(originGone, pod)
+				continue
+			}
+			replacements[id] = pod
+		}
+	}
+
+	// the replacement becomes a normal pod once its original pod has gone,
+	// so that it will not be mistaken for replacing a new pod with the same instance-id
+	if len(originGone) > 0 {
+		return r.removeReplaceOriginLabel(updateCS, originGone)
+	}
+
+	var podsToCreateFor, podsCanDelete []*v1.Pod
+	var replacing int
+	for _, pod := range pods {
+		if !isSpecifiedReplace(updateCS, pod) || isSpecifiedDelete(updateCS, pod) {
+			continue
+		}
+		replacement, ok := replacements[pod.Labels[appsv1alpha1.CloneSetInstanceID]]
+		if !ok {
+			if lifecycle.GetPodLifecycleState(pod) != appspub.LifecycleStatePreparingDelete {
+				podsToCreateFor = append(podsToCreateFor, pod)
+			}
+			continue
+		}
+		replacing++
+		if lifecycle.GetPodLifecycleState(pod) != appspub.LifecycleStatePreparingDelete &&
+			IsPodAvailable(coreControl, replacement, updateCS.Spec.MinReadySeconds) {
+			podsCanDelete = append(podsCanDelete, pod)
+		}
+	}
+
+	if len(podsCanDelete) > 0 {
+		klog.V(3).InfoS("CloneSet tried to delete pods whose replacements are available", "cloneSet", klog.KObj(updateCS),
+			"pods", util.GetPodNames(podsCanDelete).List())
+		if modified, err := r.deletePods(updateCS, podsCanDelete, pvcs); err != nil || modified {
+			return modified, err
+		}
+	}
+
+	replicas := int(*updateCS.Spec.Replicas)
+	maxSurge, _ := intstrutil.GetValueFromIntOrPercent(intstrutil.ValueOrDefault(updateCS.Spec.UpdateStrategy.MaxSurge, intstrutil.FromInt(0)), replicas, true)
+	limit := maxSurge - replacing
+	if limit <= 0 || len(podsToCreateFor) == 0 {
+		return false, nil
+	}
+	if len(podsToCreateFor) > limit {
+		podsToCreateFor = podsToCreateFor[:limit]
+	}
+
+	klog.V(3).InfoS("CloneSet began to create replacement pods", "cloneSet", klog.KObj(updateCS),
+		"pods", util.GetPodNames(podsToCreateFor).List(), "replacing", replacing, "maxSurge", maxSurge)
+
+	availableIDs := getOrGenAvailableIDs(len(podsToCreateFor), pods, pvcs).List()
+	existingPVCNames := sets.NewString()
+	for _, pvc := range pvcs {
+		existingPVCNames.Insert(pvc.Name)
+	}
+
+	var modified bool
+	for i, origin := range podsToCreateFor {
+		// the replacement keeps the same revision with its original pod
+		cs, expectedCurrentCreations := updateCS, 0
+		if !clonesetutils.EqualToRevisionHash("", origin, updateRevision) {
+			cs, expectedCurrentCreations = currentCS, 1
+		}
+		newPods, err := coreControl.NewVersionedPods(currentCS, updateCS, currentRevision, updateRevision,
+			1, expectedCurrentCreations, availableIDs[i:i+1])
+		if err != nil {
+			return modified, err
+		}
+		pod := newPods[0]
+		pod.Labels[appsv1alpha1.CloneSetReplaceOriginInstanceIDKey] = origin.Labels[appsv1alpha1.CloneSetInstanceID]
+		lifecycle.SetPodLifecycle(appspub.LifecycleStatePreparingNormal)(pod)
+
+		clonesetutils.ScaleExpectations.ExpectScale(clonesetutils.GetControllerKey(updateCS), expectations.Create, pod.Name)
+		if err := r.createOnePod(cs, pod, existingPVCNames); err != nil {
+			clonesetutils.ScaleExpectations.ObserveScale(clonesetutils.GetControllerKey(updateCS), expectations.Create, pod.Name)
+			return modified, err
+		}
+		modified = true
+	}
+	return modified, nil
+}
+
+func (r *realControl) removeReplaceOriginLabel(cs *appsv1alpha1.CloneSet, pods []*v1.Pod) (bool, error) {
+	var modified bool
+	for _, pod := range pods {
+		clone := pod.DeepCopy()
+		body := fmt.Sprintf(`{"metadata":{"labels":{"%s":null}}}`, appsv1alpha1.CloneSetReplaceOriginInstanceIDKey)
+		if err := r.Patch(context.TODO(), clone, client.RawPatch(types.MergePatchType, []byte(body))); err != nil {
+			return modified, err
+		}
+		modified = true
+		clonesetutils.ResourceVersionExpectations.Expect(clone)
+		klog.V(3).InfoS("CloneSet removed replace origin label from pod whose original pod has gone", "cloneSet", klog.KObj(cs), "pod", klog.KObj(pod))
+	}
+	return modified, nil
+}
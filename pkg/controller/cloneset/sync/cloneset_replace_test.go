@@ -0,0 +1,195 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sync
+
+import (
+	"context"
+	"testing"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	clonesettest "github.com/openkruise/kruise/pkg/controller/cloneset/test"
+	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
+)
+
+func TestReplacePods(t *testing.T) {
+	newPod := func(id, revision string, ready bool, originID string) *v1.Pod {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "foo-" + id,
+				Labels: map[string]string{
+					appsv1alpha1.CloneSetInstanceID:     id,
+					apps.ControllerRevisionHashLabelKey: revision,
+					appspub.LifecycleStateKey:           string(appspub.LifecycleStateNormal),
+				},
+			},
+			Status: v1.PodStatus{Phase: v1.PodPending},
+		}
+		if ready {
+			pod.Status = v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}}
+		}
+		if originID != "" {
+			pod.Labels[appsv1alpha1.CloneSetReplaceOriginInstanceIDKey] = originID
+		}
+		return pod
+	}
+
+	oneSurge := intstr.FromInt(1)
+	maxSurge := intstr.FromInt(2)
+	cases := []struct {
+		name                  string
+		podsToReplace         []string
+		maxSurge              *intstr.IntOrString
+		pods                  []*v1.Pod
+		expectedModified      bool
+		expectedPodCount      int
+		expectedOrigins       []string
+		expectedOriginCleared []string
+	}{
+		{
+			name:             "create replacement for the specified pod",
+			podsToReplace:    []string{"foo-id1"},
+			maxSurge:         &oneSurge,
+			pods:             []*v1.Pod{newPod("id1", "rev_current", true, ""), newPod("id2", "rev_current", true, "")},
+			expectedModified: true,
+			expectedPodCount: 3,
+			expectedOrigins:  []string{"id1"},
+		},
+		{
+			name:             "wait for replacement to be available",
+			podsToReplace:    []string{"foo-id1"},
+			maxSurge:         &oneSurge,
+			pods:             []*v1.Pod{newPod("id1", "rev_current", true, ""), newPod("id2", "rev_current", true, ""), newPod("id3", "rev_current", false, "id1")},
+			expectedPodCount: 3,
+		},
+		{
+			name:             "delete original pod after replacement is available",
+			podsToReplace:    []string{"foo-id1"},
+			maxSurge:         &oneSurge,
+			pods:             []*v1.Pod{newPod("id1", "rev_current", true, ""), newPod("id2", "rev_current", true, ""), newPod("id3", "rev_current", true, "id1")},
+			expectedModified: true,
+			expectedPodCount: 2,
+		},
+		{
+			name:             "no replacement without maxSurge",
+			podsToReplace:    []string{"foo-id1", "foo-id2"},
+			pods:             []*v1.Pod{newPod("id1", "rev_current", true, ""), newPod("id2", "rev_current", true, "")},
+			expectedPodCount: 2,
+		},
+		{
+			name:             "replace one pod at a time with maxSurge 1",
+			podsToReplace:    []string{"foo-id1", "foo-id2"},
+			maxSurge:         &oneSurge,
+			pods:             []*v1.Pod{newPod("id1", "rev_current", true, ""), newPod("id2", "rev_current", true, "")},
+			expectedModified: true,
+			expectedPodCount: 3,
+			expectedOrigins:  []string{"id1"},
+		},
+		{
+			name:                  "clear origin label after original pod has gone",
+			podsToReplace:         []string{"foo-id1"},
+			maxSurge:              &oneSurge,
+			pods:                  []*v1.Pod{newPod("id2", "rev_current", true, ""), newPod("id3", "rev_current", true, "id1")},
+			expectedModified:      true,
+			expectedPodCount:      2,
+			expectedOriginCleared: []string{"foo-id3"},
+		},
+		{
+			name:             "replace pods limited by maxSurge",
+			podsToReplace:    []string{"foo-id1", "foo-id2"},
+			maxSurge:         &maxSurge,
+			pods:             []*v1.Pod{newPod("id1", "rev_current", true, ""), newPod("id2", "rev_update", true, "")},
+			expectedModified: true,
+			expectedPodCount: 4,
+			expectedOrigins:  []string{"id1", "id2"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			currentCS := clonesettest.NewCloneSet(2)
+			currentCS.Spec.ScaleStrategy.PodsToReplace = tc.podsToReplace
+			currentCS.Spec.UpdateStrategy.MaxSurge = tc.maxSurge
+			updateCS := currentCS.DeepCopy()
+			// replacements are expected in the global expectations, which should not be left to other tests
+			defer clonesetutils.ScaleExpectations.DeleteExpectations(clonesetutils.GetControllerKey(currentCS))
+
+			ctrl := newFakeControl()
+			for _, p := range tc.pods {
+				_ = ctrl.Create(context.TODO(), p.DeepCopy())
+			}
+
+			modified, err := ctrl.replacePods(currentCS, updateCS, "rev_current", "rev_update", tc.pods, nil)
+			if err != nil {
+				t.Fatalf("failed to replace pods: %v", err)
+			}
+			if modified != tc.expectedModified {
+				t.Fatalf("expected modified %v, got %v", tc.expectedModified, modified)
+			}
+
+			gotPods := v1.PodList{}
+			if err := ctrl.List(context.TODO(), &gotPods, client.InNamespace("default")); err != nil {
+				t.Fatalf("failed to list pods: %v", err)
+			}
+			if len(gotPods.Items) != tc.expectedPodCount {
+				t.Fatalf("expected %d pods, got %d", tc.expectedPodCount, len(gotPods.Items))
+			}
+			origins := map[string]*v1.Pod{}
+			for i := range tc.pods {
+				origins[tc.pods[i].Labels[appsv1alpha1.CloneSetInstanceID]] = tc.pods[i]
+			}
+			var gotOrigins []string
+			for i := range gotPods.Items {
+				pod := &gotPods.Items[i]
+				if _, ok := origins[pod.Labels[appsv1alpha1.CloneSetInstanceID]]; ok {
+					continue
+				}
+				originID := pod.Labels[appsv1alpha1.CloneSetReplaceOriginInstanceIDKey]
+				origin, ok := origins[originID]
+				if !ok {
+					t.Fatalf("unexpected replacement pod %s for origin %q", pod.Name, originID)
+				}
+				if pod.Labels[apps.ControllerRevisionHashLabelKey] != origin.Labels[apps.ControllerRevisionHashLabelKey] {
+					t.Fatalf("expected replacement %s in revision %s, got %s", pod.Name,
+						origin.Labels[apps.ControllerRevisionHashLabelKey], pod.Labels[apps.ControllerRevisionHashLabelKey])
+				}
+				gotOrigins = append(gotOrigins, originID)
+			}
+			if !sets.NewString(gotOrigins...).Equal(sets.NewString(tc.expectedOrigins...)) {
+				t.Fatalf("expected replacements for %v, got %v", tc.expectedOrigins, gotOrigins)
+			}
+			for _, name := range tc.expectedOriginCleared {
+				pod := &v1.Pod{}
+				if err := ctrl.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, pod); err != nil {
+					t.Fatalf("failed to get pod %s: %v", name, err)
+				}
+				if _, ok := pod.Labels[appsv1alpha1.CloneSetReplaceOriginInstanceIDKey]; ok {
+					t.Fatalf("expected origin label of pod %s to be cleared", name)
+				}
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ import (
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
 	clonesetcore "github.com/openkruise/kruise/pkg/controller/cloneset/core"
 	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
 	"github.com/openkruise/kruise/pkg/util"
@@ -105,7 +106,12 @@ func (r *realControl) Scale(
 		}
 	}
 
-	// 5. specified delete
+	// 5. replace specified pods, whose replacements are created before they are deleted
+	if modified, err := r.replacePods(currentCS, updateCS, currentRevision, updateRevision, pods, pvcs); err != nil || modified {
+		return modified, err
+	}
+
+	// 6. specified delete
 	if podsToDelete := util.DiffPods(podsSpecifiedToDelete, podsInPreDelete); len(podsToDelete) > 0 {
 		newPodsToDelete, oldPodsToDelete := clonesetutils.GroupUpdateAndNotUpdatePods(podsToDelete, updateRevision)
 		klog.V(3).InfoS("CloneSet tried to delete pods specified", "cloneSet", klog.KObj(updateCS), "deleteReadyLimit", diffRes.deleteReadyLimit,
@@ -126,7 +132,7 @@ func (r *realControl) Scale(
 		}
 	}
 
-	// 6. scale in
+	// 7. scale in
 	if diffRes.scaleDownNum > 0 {
 		if numToDelete > 0 {
 			klog.V(3).InfoS("CloneSet skipped to scale in for deletion", "cloneSet", klog.KObj(updateCS), "scaleDownNum", diffRes.scaleDownNum,
@@ -169,7 +175,7 @@ func (r *realControl) managePreparingDelete(cs *appsv1alpha1.CloneSet, pods, pod
 		if diff <= 0 {
 			return modified, nil
 		}
-		if isSpecifiedDelete(cs, pod) {
+		if isSpecifiedDelete(cs, pod) || isSpecifiedReplace(cs, pod) {
 			continue
 		}
 
@@ -267,7 +273,8 @@ func (r *realControl) createOnePod(cs *appsv1alpha1.CloneSet, pod *v1.Pod, exist
 func (r *realControl) deletePods(cs *appsv1alpha1.CloneSet, podsToDelete []*v1.Pod, pvcs []*v1.PersistentVolumeClaim) (bool, error) {
 	var modified bool
 	for _, pod := range podsToDelete {
-		if cs.Spec.Lifecycle != nil && lifecycle.IsPodHooked(cs.Spec.Lifecycle.PreDelete, pod) {
+		if cs.Spec.Lifecycle != nil && lifecycle.IsPodHooked(cs.Spec.Lifecycle.PreDelete, pod) &&
+			!r.isPreDeleteHookProceeded(cs, pod) {
 			markPodNotReady := cs.Spec.Lifecycle.PreDelete.MarkPodNotReady
 			if updated, gotPod, err := r.lifecycleControl.UpdatePodLifecycle(pod, appspub.LifecycleStatePreparingDelete, markPodNotReady); err != nil {
 				return false, err
@@ -279,6 +286,12 @@ func (r *realControl) deletePods(cs *appsv1alpha1.CloneSet, podsToDelete []*v1.P
 			}
 			continue
 		}
+		// the pod proceeds without the PreDelete hook, whose finalizers should not hold it from being deleted
+		if cs.Spec.Lifecycle != nil && lifecycle.IsPodHooked(cs.Spec.Lifecycle.PreDelete, pod) {
+			if _, _, err := r.lifecycleControl.RemovePodHook(pod, cs.Spec.Lifecycle.PreDelete); err != nil {
+				return modified, err
+			}
+		}
 
 		clonesetutils.ScaleExpectations.ExpectScale(clonesetutils.GetControllerKey(cs), expectations.Delete, pod.Name)
 		if err := r.Delete(context.TODO(), pod); err != nil {
@@ -289,6 +302,11 @@ func (r *realControl) deletePods(cs *appsv1alpha1.CloneSet, podsToDelete []*v1.P
 		modified = true
 		r.recorder.Event(cs, v1.EventTypeNormal, "SuccessfulDelete", fmt.Sprintf("succeed to delete pod %s", pod.Name))
 
+		// keep the pvcs to be reused by pods scaled out later
+		if clonesetutils.GetPersistentVolumeClaimRetentionPolicy(cs).WhenScaled == appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType {
+			continue
+		}
+
 		// delete pvcs which have the same instance-id
 		for _, pvc := range pvcs {
 			if pvc.Labels[appsv1alpha1.CloneSetInstanceID] != pod.Labels[appsv1alpha1.CloneSetInstanceID] {
@@ -307,6 +325,16 @@ func (r *realControl) deletePods(cs *appsv1alpha1.CloneSet, podsToDelete []*v1.P
 	return modified, nil
 }
 
+// isPreDeleteHookProceeded returns true if the pod in PreparingDelete state can be deleted without waiting for
+// the PreDelete hook, otherwise requeues the CloneSet to check the hook again if needed.
+func (r *realControl) isPreDeleteHookProceeded(cs *appsv1alpha1.CloneSet, pod *v1.Pod) bool {
+	proceed, left := r.lifecycleControl.CheckHook(r.recorder, cs.Spec.Lifecycle.PreDelete, pod, appspub.LifecycleStatePreparingDelete)
+	if left > 0 {
+		clonesetutils.DurationStore.Push(clonesetutils.GetControllerKey(cs), left)
+	}
+	return proceed
+}
+
 func getPlannedDeletedPods(cs *appsv1alpha1.CloneSet, pods []*v1.Pod) ([]*v1.Pod, []*v1.Pod, int) {
 	var podsSpecifiedToDelete []*v1.Pod
 	var podsInPreDelete []*v1.Pod
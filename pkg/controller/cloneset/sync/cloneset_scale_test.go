@@ -26,10 +26,12 @@ import (
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
 	clonesettest "github.com/openkruise/kruise/pkg/controller/cloneset/test"
 	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
 	"github.com/openkruise/kruise/pkg/util"
 	"github.com/openkruise/kruise/pkg/util/expectations"
+	"github.com/openkruise/kruise/pkg/util/lifecycle"
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -505,6 +507,117 @@ func TestDeletePods(t *testing.T) {
 	}
 }
 
+func TestDeletePodsWithPVCRetained(t *testing.T) {
+	cs := &appsv1alpha1.CloneSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+		Spec: appsv1alpha1.CloneSetSpec{
+			PersistentVolumeClaimRetentionPolicy: &appsv1alpha1.CloneSetPersistentVolumeClaimRetentionPolicy{
+				WhenScaled: appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType,
+			},
+		},
+	}
+	podsToDelete := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "foo-id1",
+				Labels:    map[string]string{appsv1alpha1.CloneSetInstanceID: "id1"},
+			},
+		},
+	}
+	pvcs := []*v1.PersistentVolumeClaim{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "datadir-foo-id1",
+				Labels:    map[string]string{appsv1alpha1.CloneSetInstanceID: "id1"},
+			},
+		},
+	}
+
+	ctrl := newFakeControl()
+	for _, p := range podsToDelete {
+		_ = ctrl.Create(context.TODO(), p)
+	}
+	for _, p := range pvcs {
+		_ = ctrl.Create(context.TODO(), p)
+	}
+
+	deleted, err := ctrl.deletePods(cs, podsToDelete, pvcs)
+	if err != nil {
+		t.Fatalf("failed to delete got pods: %v", err)
+	} else if !deleted {
+		t.Fatalf("failed to delete got pods: not deleted")
+	}
+
+	gotPods := v1.PodList{}
+	if err := ctrl.List(context.TODO(), &gotPods, client.InNamespace("default")); err != nil {
+		t.Fatalf("failed to list pods: %v", err)
+	}
+	if len(gotPods.Items) > 0 {
+		t.Fatalf("expected no pods left, actually: %v", gotPods.Items)
+	}
+
+	gotPVCs := v1.PersistentVolumeClaimList{}
+	if err := ctrl.List(context.TODO(), &gotPVCs, client.InNamespace("default")); err != nil {
+		t.Fatalf("failed to list pvcs: %v", err)
+	}
+	if len(gotPVCs.Items) != 1 {
+		t.Fatalf("expected pvc retained, actually: %v", util.DumpJSON(gotPVCs.Items))
+	}
+}
+
+func TestDeletePodsWithPreDeleteHookTimedOut(t *testing.T) {
+	cs := &appsv1alpha1.CloneSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"},
+		Spec: appsv1alpha1.CloneSetSpec{
+			Lifecycle: &appspub.Lifecycle{
+				PreDelete: &appspub.LifecycleHook{
+					FinalizersHandler: []string{"example.com/hook"},
+					TimeoutSeconds:    60,
+					FailurePolicy:     appspub.LifecycleHookFailurePolicyProceed,
+				},
+			},
+		},
+	}
+	podsToDelete := []*v1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "foo-id1",
+				Labels: map[string]string{
+					appsv1alpha1.CloneSetInstanceID: "id1",
+					appspub.LifecycleStateKey:       string(appspub.LifecycleStatePreparingDelete),
+				},
+				Annotations: map[string]string{appspub.LifecycleTimestampKey: time.Now().Add(-2 * time.Minute).Format(time.RFC3339)},
+				Finalizers:  []string{"example.com/hook"},
+			},
+		},
+	}
+
+	ctrl := newFakeControl()
+	ctrl.lifecycleControl = lifecycle.New(ctrl.Client)
+	for _, p := range podsToDelete {
+		_ = ctrl.Create(context.TODO(), p)
+	}
+
+	deleted, err := ctrl.deletePods(cs, podsToDelete, nil)
+	if err != nil {
+		t.Fatalf("failed to delete got pods: %v", err)
+	} else if !deleted {
+		t.Fatalf("failed to delete got pods: not deleted")
+	}
+
+	// the pod should not be held by the finalizer of hook that has timed out
+	gotPods := v1.PodList{}
+	if err := ctrl.List(context.TODO(), &gotPods, client.InNamespace("default")); err != nil {
+		t.Fatalf("failed to list pods: %v", err)
+	}
+	if len(gotPods.Items) > 0 {
+		t.Fatalf("expected no pods left, actually: %v", util.DumpJSON(gotPods.Items))
+	}
+}
+
 func TestGetOrGenAvailableIDs(t *testing.T) {
 	pods := []*v1.Pod{
 		{
@@ -0,0 +1,187 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	clonesetcore "github.com/openkruise/kruise/pkg/controller/cloneset/core"
+	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
+	"github.com/openkruise/kruise/pkg/util"
+	"github.com/openkruise/kruise/pkg/util/expectations"
+	"github.com/openkruise/kruise/pkg/util/lifecycle"
+	"github.com/openkruise/kruise/pkg/util/podreadiness"
+	"github.com/openkruise/kruise/pkg/util/revision"
+)
+
+var standbyNotReadyMessage = podreadiness.Message{UserAgent: "CloneSet", Key: "standby"}
+
+// SyncStandby promotes standby pods to serve when scaling out, and keeps the standby pool
+// with scaleStrategy.standbyReplicas pods of the update revision.
+func (r *realControl) SyncStandby(
+	updateCS *appsv1alpha1.CloneSet,
+	currentRevision, updateRevision string,
+	pods, standbyPods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim,
+) (bool, error) {
+	if updateCS.Spec.Replicas == nil {
+		return false, fmt.Errorf("spec.Replicas is nil")
+	}
+	if updateCS.Spec.ScaleStrategy.StandbyReplicas <= 0 && len(standbyPods) == 0 {
+		return false, nil
+	}
+
+	coreControl := clonesetcore.New(updateCS)
+	if !coreControl.IsReadyToScale() {
+		return false, nil
+	}
+
+	var updatedStandby, outdatedStandby []*v1.Pod
+	for _, pod := range standbyPods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if clonesetutils.EqualToRevisionHash("", pod, updateRevision) {
+			updatedStandby = append(updatedStandby, pod)
+		} else {
+			outdatedStandby = append(outdatedStandby, pod)
+		}
+	}
+	// warm pods come first to be promoted and last to be deleted
+	sort.SliceStable(updatedStandby, func(i, j int) bool {
+		return isStandbyWarm(updatedStandby[i]) && !isStandbyWarm(updatedStandby[j])
+	})
+
+	// 1. promote standby pods instead of creating new ones for scaling out
+	diffRes := calculateDiffsWithExpectation(updateCS, pods, currentRevision, updateRevision, revision.IsPodUpdate)
+	if promoteNum := diffRes.scaleUpLimit - diffRes.scaleUpNumOldRevision; diffRes.scaleUpNum > 0 && promoteNum > 0 && len(updatedStandby) > 0 {
+		if promoteNum > len(updatedStandby) {
+			promoteNum = len(updatedStandby)
+		}
+		klog.V(3).InfoS("CloneSet began to promote standby pods", "cloneSet", klog.KObj(updateCS),
+			"pods", util.GetPodNames(updatedStandby[:promoteNum]).List())
+		return r.promoteStandbyPods(updateCS, updatedStandby[:promoteNum])
+	}
+
+	// 2. delete outdated and redundant standby pods
+	standbyReplicas := int(updateCS.Spec.ScaleStrategy.StandbyReplicas)
+	podsToDelete := outdatedStandby
+	if len(updatedStandby) > standbyReplicas {
+		podsToDelete = append(podsToDelete, updatedStandby[standbyReplicas:]...)
+		updatedStandby = updatedStandby[:standbyReplicas]
+	}
+	if len(podsToDelete) > 0 {
+		klog.V(3).InfoS("CloneSet began to delete standby pods", "cloneSet", klog.KObj(updateCS),
+			"pods", util.GetPodNames(podsToDelete).List())
+		return r.deleteStandbyPods(updateCS, podsToDelete, pvcs)
+	}
+
+	// 3. make sure existing standby pods are kept not ready
+	for _, pod := range updatedStandby {
+		if err := r.podReadinessControl.AddNotReadyKey(pod, standbyNotReadyMessage); err != nil {
+			return false, err
+		}
+	}
+
+	// 4. create standby pods of the update revision
+	if num := standbyReplicas - len(updatedStandby); num > 0 {
+		allPods := append(append([]*v1.Pod{}, pods...), standbyPods...)
+		return r.createStandbyPods(updateCS, updateRevision, num, allPods, pvcs)
+	}
+	return false, nil
+}
+
+func (r *realControl) promoteStandbyPods(cs *appsv1alpha1.CloneSet, podsToPromote []*v1.Pod) (bool, error) {
+	var modified bool
+	for _, pod := range podsToPromote {
+		// remove the not-ready key first, so that the pod will still be kept not ready
+		// if it fails to remove the standby label
+		if err := r.podReadinessControl.RemoveNotReadyKey(pod, standbyNotReadyMessage); err != nil {
+			return modified, err
+		}
+
+		clone := pod.DeepCopy()
+		body := fmt.Sprintf(`{"metadata":{"labels":{"%s":null}}}`, appsv1alpha1.CloneSetStandbyKey)
+		if err := r.Patch(context.TODO(), clone, client.RawPatch(types.MergePatchType, []byte(body))); err != nil {
+			r.recorder.Eventf(cs, v1.EventTypeWarning, "FailedPromote", "failed to promote standby pod %s: %v", pod.Name, err)
+			return modified, err
+		}
+		modified = true
+		clonesetutils.ResourceVersionExpectations.Expect(clone)
+		r.recorder.Eventf(cs, v1.EventTypeNormal, "SuccessfulPromote", "succeed to promote standby pod %s", pod.Name)
+	}
+	return modified, nil
+}
+
+func (r *realControl) deleteStandbyPods(cs *appsv1alpha1.CloneSet, podsToDelete []*v1.Pod, pvcs []*v1.PersistentVolumeClaim) (bool, error) {
+	// standby pods have never served, so they are deleted directly without lifecycle hooks
+	csCopy := cs.DeepCopy()
+	csCopy.Spec.Lifecycle = nil
+	return r.deletePods(csCopy, podsToDelete, pvcs)
+}
+
+func (r *realControl) createStandbyPods(cs *appsv1alpha1.CloneSet, updateRevision string, num int,
+	pods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim) (bool, error) {
+
+	klog.V(3).InfoS("CloneSet began to create standby pods", "cloneSet", klog.KObj(cs), "num", num)
+
+	coreControl := clonesetcore.New(cs)
+	availableIDs := getOrGenAvailableIDs(num, pods, pvcs).List()
+	newPods, err := coreControl.NewVersionedPods(cs, cs, updateRevision, updateRevision, num, 0, availableIDs)
+	if err != nil {
+		return false, err
+	}
+
+	existingPVCNames := sets.NewString()
+	for _, pvc := range pvcs {
+		existingPVCNames.Insert(pvc.Name)
+	}
+
+	var modified bool
+	for _, pod := range newPods {
+		pod.Labels[appsv1alpha1.CloneSetStandbyKey] = "true"
+		util.InjectReadinessGateToPod(pod, appspub.KruisePodReadyConditionType)
+		lifecycle.SetPodLifecycle(appspub.LifecycleStatePreparingNormal)(pod)
+
+		clonesetutils.ScaleExpectations.ExpectScale(clonesetutils.GetControllerKey(cs), expectations.Create, pod.Name)
+		if err := r.createOnePod(cs, pod, existingPVCNames); err != nil {
+			clonesetutils.ScaleExpectations.ObserveScale(clonesetutils.GetControllerKey(cs), expectations.Create, pod.Name)
+			return modified, err
+		}
+		modified = true
+	}
+	return modified, nil
+}
+
+// isStandbyWarm returns true if the containers of the standby pod have been started and ready.
+func isStandbyWarm(pod *v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	_, condition := podutil.GetPodCondition(&pod.Status, v1.ContainersReady)
+	return condition != nil && condition.Status == v1.ConditionTrue
+}
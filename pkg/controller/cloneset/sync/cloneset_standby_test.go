@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sync
+
+import (
+	"context"
+	"testing"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	clonesettest "github.com/openkruise/kruise/pkg/controller/cloneset/test"
+	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
+	"github.com/openkruise/kruise/pkg/util/podadapter"
+	"github.com/openkruise/kruise/pkg/util/podreadiness"
+)
+
+func TestSyncStandby(t *testing.T) {
+	newPod := func(id, revision string, standby, warm bool) *v1.Pod {
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "foo-" + id,
+				Labels: map[string]string{
+					appsv1alpha1.CloneSetInstanceID:     id,
+					apps.ControllerRevisionHashLabelKey: revision,
+					appspub.LifecycleStateKey:           string(appspub.LifecycleStateNormal),
+				},
+			},
+			Status: v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}},
+		}
+		if standby {
+			pod.Labels[appsv1alpha1.CloneSetStandbyKey] = "true"
+			pod.Labels[appspub.LifecycleStateKey] = string(appspub.LifecycleStatePreparingNormal)
+			pod.Spec.ReadinessGates = []v1.PodReadinessGate{{ConditionType: appspub.KruisePodReadyConditionType}}
+			pod.Status = v1.PodStatus{Phase: v1.PodPending}
+			if warm {
+				pod.Status = v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{{Type: v1.ContainersReady, Status: v1.ConditionTrue}}}
+			}
+		}
+		return pod
+	}
+
+	cases := []struct {
+		name             string
+		replicas         int
+		standbyReplicas  int32
+		pods             []*v1.Pod
+		expectedModified bool
+		expectedActive   []string
+		expectedStandby  int
+	}{
+		{
+			name:             "create standby pods",
+			replicas:         2,
+			standbyReplicas:  2,
+			pods:             []*v1.Pod{newPod("id1", "rev_update", false, false), newPod("id2", "rev_update", false, false)},
+			expectedModified: true,
+			expectedActive:   []string{"foo-id1", "foo-id2"},
+			expectedStandby:  2,
+		},
+		{
+			name:             "promote warm standby pod when scaling out",
+			replicas:         3,
+			standbyReplicas:  2,
+			pods:             []*v1.Pod{newPod("id1", "rev_update", false, false), newPod("id2", "rev_update", false, false), newPod("id3", "rev_update", true, false), newPod("id4", "rev_update", true, true)},
+			expectedModified: true,
+			expectedActive:   []string{"foo-id1", "foo-id2", "foo-id4"},
+			expectedStandby:  1,
+		},
+		{
+			name:             "delete standby pod of old revision",
+			replicas:         2,
+			standbyReplicas:  1,
+			pods:             []*v1.Pod{newPod("id1", "rev_update", false, false), newPod("id2", "rev_update", false, false), newPod("id3", "rev_current", true, true)},
+			expectedModified: true,
+			expectedActive:   []string{"foo-id1", "foo-id2"},
+			expectedStandby:  0,
+		},
+		{
+			name:             "delete redundant standby pod",
+			replicas:         2,
+			standbyReplicas:  1,
+			pods:             []*v1.Pod{newPod("id1", "rev_update", false, false), newPod("id2", "rev_update", false, false), newPod("id3", "rev_update", true, false), newPod("id4", "rev_update", true, true)},
+			expectedModified: true,
+			expectedActive:   []string{"foo-id1", "foo-id2"},
+			expectedStandby:  1,
+		},
+		{
+			name:            "nothing to do",
+			replicas:        2,
+			standbyReplicas: 1,
+			pods:            []*v1.Pod{newPod("id1", "rev_update", false, false), newPod("id2", "rev_update", false, false), newPod("id3", "rev_update", true, true)},
+			expectedActive:  []string{"foo-id1", "foo-id2"},
+			expectedStandby: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := clonesettest.NewCloneSet(tc.replicas)
+			cs.Spec.ScaleStrategy.StandbyReplicas = tc.standbyReplicas
+
+			ctrl := newFakeControl()
+			ctrl.podReadinessControl = podreadiness.NewForAdapter(&podadapter.AdapterRuntimeClient{Client: ctrl.Client})
+			for _, p := range tc.pods {
+				_ = ctrl.Create(context.TODO(), p.DeepCopy())
+			}
+
+			activePods, standbyPods := clonesetutils.SplitStandbyPods(tc.pods)
+			modified, err := ctrl.SyncStandby(cs, "rev_current", "rev_update", activePods, standbyPods, nil)
+			if err != nil {
+				t.Fatalf("failed to sync standby: %v", err)
+			}
+			if modified != tc.expectedModified {
+				t.Fatalf("expected modified %v, got %v", tc.expectedModified, modified)
+			}
+
+			podList := v1.PodList{}
+			if err := ctrl.List(context.TODO(), &podList, client.InNamespace("default")); err != nil {
+				t.Fatalf("failed to list pods: %v", err)
+			}
+			var gotActive []string
+			var gotStandby int
+			for i := range podList.Items {
+				pod := &podList.Items[i]
+				if !clonesetutils.IsStandbyPod(pod) {
+					gotActive = append(gotActive, pod.Name)
+					continue
+				}
+				gotStandby++
+				if pod.Labels[apps.ControllerRevisionHashLabelKey] != "rev_update" {
+					t.Fatalf("expected standby pod %s in update revision", pod.Name)
+				}
+				if !podreadiness.ContainsReadinessGate(pod) {
+					t.Fatalf("expected standby pod %s with readiness gate", pod.Name)
+				}
+			}
+			if !sets.NewString(gotActive...).Equal(sets.NewString(tc.expectedActive...)) {
+				t.Fatalf("expected active pods %v, got %v", tc.expectedActive, gotActive)
+			}
+			if gotStandby != tc.expectedStandby {
+				t.Fatalf("expected %d standby pods, got %d", tc.expectedStandby, gotStandby)
+			}
+		})
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/integer"
 
@@ -122,6 +123,7 @@ func calculateDiffsWithExpectation(cs *appsv1alpha1.CloneSet, pods []*v1.Pod, cu
 	scaleMaxUnavailable, _ = intstrutil.GetValueFromIntOrPercent(
 		intstrutil.ValueOrDefault(cs.Spec.ScaleStrategy.MaxUnavailable, intstrutil.FromInt(math.MaxInt32)), replicas, true)
 
+	replacedIDs := getReplacedInstanceIDs(pods)
 	var newRevisionCount, newRevisionActiveCount, oldRevisionCount, oldRevisionActiveCount int
 	var unavailableNewRevisionCount, unavailableOldRevisionCount int
 	var toDeleteNewRevisionCount, toDeleteOldRevisionCount, preDeletingNewRevisionCount, preDeletingOldRevisionCount int
@@ -161,7 +163,7 @@ func calculateDiffsWithExpectation(cs *appsv1alpha1.CloneSet, pods []*v1.Pod, cu
 			default:
 				newRevisionActiveCount++
 
-				if isSpecifiedDelete(cs, p) {
+				if isSpecifiedDelete(cs, p) || isReplaced(cs, p, replacedIDs) {
 					toDeleteNewRevisionCount++
 				} else if !IsPodAvailable(coreControl, p, cs.Spec.MinReadySeconds) {
 					unavailableNewRevisionCount++
@@ -177,7 +179,7 @@ func calculateDiffsWithExpectation(cs *appsv1alpha1.CloneSet, pods []*v1.Pod, cu
 			default:
 				oldRevisionActiveCount++
 
-				if isSpecifiedDelete(cs, p) {
+				if isSpecifiedDelete(cs, p) || isReplaced(cs, p, replacedIDs) {
 					toDeleteOldRevisionCount++
 				} else if !IsPodAvailable(coreControl, p, cs.Spec.MinReadySeconds) {
 					unavailableOldRevisionCount++
@@ -287,6 +289,35 @@ func isSpecifiedDelete(cs *appsv1alpha1.CloneSet, pod *v1.Pod) bool {
 	return false
 }
 
+func isSpecifiedReplace(cs *appsv1alpha1.CloneSet, pod *v1.Pod) bool {
+	if specifieddelete.IsSpecifiedReplace(pod) {
+		return true
+	}
+	for _, name := range cs.Spec.ScaleStrategy.PodsToReplace {
+		if name == pod.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// getReplacedInstanceIDs returns the instance-ids of original pods that already have replacement pods.
+func getReplacedInstanceIDs(pods []*v1.Pod) sets.String {
+	ids := sets.NewString()
+	for _, pod := range pods {
+		if id := pod.Labels[appsv1alpha1.CloneSetReplaceOriginInstanceIDKey]; len(id) > 0 {
+			ids.Insert(id)
+		}
+	}
+	return ids
+}
+
+// isReplaced returns true if the pod is specified to replace and its replacement has been created,
+// so that it is regarded as a pod to delete.
+func isReplaced(cs *appsv1alpha1.CloneSet, pod *v1.Pod, replacedIDs sets.String) bool {
+	return isSpecifiedReplace(cs, pod) && replacedIDs.Has(pod.Labels[appsv1alpha1.CloneSetInstanceID])
+}
+
 func isPodReady(coreControl clonesetcore.Control, pod *v1.Pod) bool {
 	return IsPodAvailable(coreControl, pod, 0)
 }
@@ -35,6 +35,7 @@ import (
 	"github.com/openkruise/kruise/pkg/util/lifecycle"
 	"github.com/openkruise/kruise/pkg/util/specifieddelete"
 	"github.com/openkruise/kruise/pkg/util/updatesort"
+	"github.com/openkruise/kruise/pkg/util/volumeclaimtemplate"
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -126,6 +127,15 @@ func (c *realControl) Update(cs *appsv1alpha1.CloneSet,
 	// 4. sort all pods waiting to update
 	waitUpdateIndexes = SortUpdateIndexes(coreControl, cs.Spec.UpdateStrategy, pods, waitUpdateIndexes)
 
+	// only pods in the current topology domain can update, and none of them when the domain is soaking
+	if cs.Spec.UpdateStrategy.TopologyStrategy != nil && diffRes.updateNum > 0 {
+		topologyStatus, soakLeft := CalculateUpdateTopology(cs, coreControl, updateRevision.Name, pods, metav1.Now())
+		if soakLeft > 0 {
+			clonesetutils.DurationStore.Push(key, soakLeft)
+		}
+		waitUpdateIndexes = updatesort.FilterUpdateTopology(cs.Spec.UpdateStrategy.TopologyStrategy, topologyStatus, pods, waitUpdateIndexes)
+	}
+
 	// 5. limit max count of pods can update
 	waitUpdateIndexes = limitUpdateIndexes(coreControl, cs.Spec.MinReadySeconds, diffRes, waitUpdateIndexes, pods, targetRevision.Name)
 
@@ -158,6 +168,11 @@ func (c *realControl) Update(cs *appsv1alpha1.CloneSet,
 func (c *realControl) refreshPodState(cs *appsv1alpha1.CloneSet, coreControl clonesetcore.Control, pod *v1.Pod, updateRevision string) (bool, time.Duration, error) {
 	opts := coreControl.GetUpdateOptions()
 	opts = inplaceupdate.SetOptionsDefaults(opts)
+	if cs.Spec.Lifecycle != nil && cs.Spec.Lifecycle.PostInPlaceUpdate != nil {
+		opts.CheckPostUpdateHook = func(pod *v1.Pod, updateTime time.Time) (bool, time.Duration) {
+			return c.lifecycleControl.CheckPostInPlaceUpdateHook(c.recorder, cs.Spec.Lifecycle.PostInPlaceUpdate, pod, updateTime)
+		}
+	}
 
 	res := c.inplaceControl.Refresh(pod, opts)
 	if res.RefreshErr != nil {
@@ -165,14 +180,22 @@ func (c *realControl) refreshPodState(cs *appsv1alpha1.CloneSet, coreControl clo
 			"cloneSet", klog.KObj(cs), "pod", klog.KObj(pod))
 		return false, 0, res.RefreshErr
 	}
+	if res.ResizeFailed {
+		return c.handleResizeFailed(cs, pod)
+	}
 
 	var state appspub.LifecycleStateType
+	var hookTimeout time.Duration
 	switch lifecycle.GetPodLifecycleState(pod) {
 	case appspub.LifecycleStatePreparingNormal:
 		if cs.Spec.Lifecycle == nil ||
 			cs.Spec.Lifecycle.PreNormal == nil ||
 			lifecycle.IsPodAllHooked(cs.Spec.Lifecycle.PreNormal, pod) {
 			state = appspub.LifecycleStateNormal
+		} else if proceed, left := c.lifecycleControl.CheckHook(c.recorder, cs.Spec.Lifecycle.PreNormal, pod, appspub.LifecycleStatePreparingNormal); proceed {
+			state = appspub.LifecycleStateNormal
+		} else {
+			hookTimeout = left
 		}
 	case appspub.LifecycleStatePreparingUpdate:
 		// when pod updated to PreparingUpdate state to wait lifecycle blocker to remove,
@@ -186,7 +209,7 @@ func (c *realControl) refreshPodState(cs *appsv1alpha1.CloneSet, coreControl clo
 			}
 		}
 	case appspub.LifecycleStateUpdating:
-		if opts.CheckPodUpdateCompleted(pod) == nil {
+		if !res.PostUpdateHooked && opts.CheckPodUpdateCompleted(pod) == nil {
 			if cs.Spec.Lifecycle != nil && !lifecycle.IsPodAllHooked(cs.Spec.Lifecycle.InPlaceUpdate, pod) {
 				state = appspub.LifecycleStateUpdated
 			} else {
@@ -198,8 +221,15 @@ func (c *realControl) refreshPodState(cs *appsv1alpha1.CloneSet, coreControl clo
 			cs.Spec.Lifecycle.InPlaceUpdate == nil ||
 			lifecycle.IsPodAllHooked(cs.Spec.Lifecycle.InPlaceUpdate, pod) {
 			state = appspub.LifecycleStateNormal
+		} else if proceed, left := c.lifecycleControl.CheckHook(c.recorder, cs.Spec.Lifecycle.InPlaceUpdate, pod, appspub.LifecycleStateUpdated); proceed {
+			state = appspub.LifecycleStateNormal
+		} else {
+			hookTimeout = left
 		}
 	}
+	if hookTimeout > 0 && (res.DelayDuration == 0 || hookTimeout < res.DelayDuration) {
+		res.DelayDuration = hookTimeout
+	}
 
 	if state != "" {
 		var markPodNotReady bool
@@ -218,6 +248,30 @@ func (c *realControl) refreshPodState(cs *appsv1alpha1.CloneSet, coreControl clo
 	return false, res.DelayDuration, nil
 }
 
+// handleResizeFailed recreates the pod whose in-place resize has timed out if the ResizeFailurePolicy is Recreate,
+// otherwise the pod is kept with InPlaceUpdateReady condition False, and the event is only recorded when it turns False.
+func (c *realControl) handleResizeFailed(cs *appsv1alpha1.CloneSet, pod *v1.Pod) (bool, time.Duration, error) {
+	strategy := cs.Spec.UpdateStrategy.InPlaceUpdateStrategy
+	if strategy == nil || strategy.ResizeFailurePolicy != appspub.InPlaceResizeFailurePolicyRecreate ||
+		cs.Spec.UpdateStrategy.Type == appsv1alpha1.InPlaceOnlyCloneSetUpdateStrategyType {
+		if !inplaceupdate.IsResizeFailed(pod) {
+			c.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateResizeFailedReason,
+				"in-place resize timed out with resize status %s", pod.Status.Resize)
+		}
+		return false, 0, nil
+	}
+
+	klog.InfoS("CloneSet recreates Pod whose in-place resize timed out", "cloneSet", klog.KObj(cs), "pod", klog.KObj(pod), "resize", pod.Status.Resize)
+	patched, err := specifieddelete.PatchPodSpecifiedDelete(c.Client, pod, "true")
+	if err != nil || !patched {
+		return false, 0, err
+	}
+	clonesetutils.ResourceVersionExpectations.Expect(pod)
+	c.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateResizeFailedReason,
+		"in-place resize timed out with resize status %s, fall back to recreate", pod.Status.Resize)
+	return true, 0, nil
+}
+
 // fix the pod-template-hash label for old pods before v1.1
 func (c *realControl) fixPodTemplateHashLabel(cs *appsv1alpha1.CloneSet, pod *v1.Pod) (bool, error) {
 	if _, exists := pod.Labels[apps.DefaultDeploymentUniqueLabelKey]; exists {
@@ -235,6 +289,25 @@ func (c *realControl) fixPodTemplateHashLabel(cs *appsv1alpha1.CloneSet, pod *v1
 	return true, nil
 }
 
+// GetInPlaceUpdateOptions returns the options to update pods from oldRevision to updateRevision in-place.
+// If volumeClaimTemplates only grow in storage requests, the PVCs of pods are expanded online instead of
+// recreated along with the pods, as long as none of them shrinks and their StorageClasses allow expansion.
+func GetInPlaceUpdateOptions(reader client.Reader, cs *appsv1alpha1.CloneSet, oldRevision, updateRevision *apps.ControllerRevision,
+	pods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim) *inplaceupdate.UpdateOptions {
+
+	opts := clonesetcore.New(cs).GetUpdateOptions()
+	if !utilfeature.DefaultFeatureGate.Enabled(features.CloneSetAutoResizePVCGate) || oldRevision == nil || updateRevision == nil ||
+		!volumeclaimtemplate.IsVCTemplateOnlySizeChanged(oldRevision, updateRevision) {
+		return opts
+	}
+	canExpand, err := clonesetutils.CanExpandPersistentVolumeClaims(reader, cs, pods, pvcs)
+	if err != nil {
+		klog.ErrorS(err, "CloneSet failed to check whether pvcs can be expanded online", "cloneSet", klog.KObj(cs))
+	}
+	opts.IgnoreVolumeClaimTemplatesHashDiff = canExpand
+	return opts
+}
+
 func (c *realControl) updatePod(cs *appsv1alpha1.CloneSet, coreControl clonesetcore.Control,
 	updateRevision *apps.ControllerRevision, revisions []*apps.ControllerRevision,
 	pod *v1.Pod, pvcs []*v1.PersistentVolumeClaim,
@@ -249,7 +322,8 @@ func (c *realControl) updatePod(cs *appsv1alpha1.CloneSet, coreControl clonesetc
 				break
 			}
 		}
-		if c.inplaceControl.CanUpdateInPlace(oldRevision, updateRevision, coreControl.GetUpdateOptions()) {
+		canInPlace, reasons := c.inplaceControl.CanUpdateInPlace(oldRevision, updateRevision, GetInPlaceUpdateOptions(c.Client, cs, oldRevision, updateRevision, []*v1.Pod{pod}, pvcs))
+		if canInPlace {
 			switch state := lifecycle.GetPodLifecycleState(pod); state {
 			case "", appspub.LifecycleStatePreparingNormal, appspub.LifecycleStateNormal:
 				var err error
@@ -278,15 +352,21 @@ func (c *realControl) updatePod(cs *appsv1alpha1.CloneSet, coreControl clonesetc
 				return 0, err
 			case appspub.LifecycleStatePreparingUpdate:
 				if cs.Spec.Lifecycle != nil && lifecycle.IsPodHooked(cs.Spec.Lifecycle.InPlaceUpdate, pod) {
-					return 0, nil
+					proceed, left := c.lifecycleControl.CheckHook(c.recorder, cs.Spec.Lifecycle.InPlaceUpdate, pod, appspub.LifecycleStatePreparingUpdate)
+					if !proceed {
+						return left, nil
+					}
 				}
 			case appspub.LifecycleStateUpdating:
 			default:
 				return 0, fmt.Errorf("not allowed to in-place update pod %s in state %s", pod.Name, state)
 			}
 
-			opts := coreControl.GetUpdateOptions()
+			opts := GetInPlaceUpdateOptions(c.Client, cs, oldRevision, updateRevision, []*v1.Pod{pod}, pvcs)
 			opts.AdditionalFuncs = append(opts.AdditionalFuncs, lifecycle.SetPodLifecycle(appspub.LifecycleStateUpdating))
+			if cs.Spec.Lifecycle != nil && cs.Spec.Lifecycle.PostInPlaceUpdate != nil {
+				opts.AdditionalFuncs = append(opts.AdditionalFuncs, lifecycle.SetPodHook(cs.Spec.Lifecycle.PostInPlaceUpdate))
+			}
 			res := c.inplaceControl.Update(pod, oldRevision, updateRevision, opts)
 			if res.InPlaceUpdate {
 				if res.UpdateErr == nil {
@@ -300,10 +380,15 @@ func (c *realControl) updatePod(cs *appsv1alpha1.CloneSet, coreControl clonesetc
 			}
 		}
 
+		message := inplaceupdate.FormatNotInPlaceUpdateReasons(reasons)
 		if cs.Spec.UpdateStrategy.Type == appsv1alpha1.InPlaceOnlyCloneSetUpdateStrategyType {
-			return 0, fmt.Errorf("find Pod %s update strategy is InPlaceOnly but can not update in-place", pod.Name)
+			c.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateBlockedReason,
+				"could not update in-place to revision %s: %s", updateRevision.Name, message)
+			return 0, fmt.Errorf("find Pod %s update strategy is InPlaceOnly but can not update in-place: %s", pod.Name, message)
 		}
-		klog.InfoS("CloneSet could not update Pod in-place, so it will back off to ReCreate", "cloneSet", klog.KObj(cs), "pod", klog.KObj(pod))
+		c.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateFallbackToRecreateReason,
+			"could not update in-place to revision %s, fall back to recreate: %s", updateRevision.Name, message)
+		klog.InfoS("CloneSet could not update Pod in-place, so it will back off to ReCreate", "cloneSet", klog.KObj(cs), "pod", klog.KObj(pod), "reasons", message)
 	}
 
 	klog.V(2).InfoS("CloneSet started to patch Pod specified-delete for update", "cloneSet", klog.KObj(cs), "pod", klog.KObj(pod), "updateRevision", klog.KObj(updateRevision))
@@ -332,6 +417,9 @@ func SortUpdateIndexes(coreControl clonesetcore.Control, strategy appsv1alpha1.C
 	if strategy.ScatterStrategy != nil {
 		waitUpdateIndexes = updatesort.NewScatterSorter(strategy.ScatterStrategy).Sort(pods, waitUpdateIndexes)
 	}
+	if strategy.TopologyStrategy != nil {
+		waitUpdateIndexes = updatesort.NewTopologySorter(strategy.TopologyStrategy).Sort(pods, waitUpdateIndexes)
+	}
 
 	// PreparingUpdate first
 	sort.SliceStable(waitUpdateIndexes, func(i, j int) bool {
@@ -345,6 +433,21 @@ func SortUpdateIndexes(coreControl clonesetcore.Control, strategy appsv1alpha1.C
 	return waitUpdateIndexes
 }
 
+// CalculateUpdateTopology calculates the topology-batched update status of the CloneSet,
+// and returns the duration left to soak the current domain.
+func CalculateUpdateTopology(cs *appsv1alpha1.CloneSet, coreControl clonesetcore.Control, updateRevision string,
+	pods []*v1.Pod, now metav1.Time) (*appspub.UpdateTopologyStatus, time.Duration) {
+	return updatesort.CalculateUpdateTopology(cs.Spec.UpdateStrategy.TopologyStrategy, cs.Status.UpdateTopology, updateRevision, pods,
+		func(pod *v1.Pod) bool {
+			return clonesetutils.EqualToRevisionHash("", pod, updateRevision)
+		},
+		func(pod *v1.Pod) bool {
+			return IsPodAvailable(coreControl, pod, cs.Spec.MinReadySeconds)
+		},
+		now,
+	)
+}
+
 // limitUpdateIndexes limits all pods waiting update by the maxUnavailable policy, and returns the indexes of pods that can finally update
 func limitUpdateIndexes(coreControl clonesetcore.Control, minReadySeconds int32, diffRes expectationDiffs, waitUpdateIndexes []int, pods []*v1.Pod, targetRevisionHash string) []int {
 	updateDiff := util.IntAbs(diffRes.updateNum)
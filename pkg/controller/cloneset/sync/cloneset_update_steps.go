@@ -0,0 +1,162 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/integer"
+	utilpointer "k8s.io/utils/pointer"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	clonesetcore "github.com/openkruise/kruise/pkg/controller/cloneset/core"
+	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
+	"github.com/openkruise/kruise/pkg/util"
+)
+
+// SyncUpdateSteps calculates the progress of updateStrategy.steps into newStatus, and overrides the partition
+// in cs with the one of the current step, so that the following scaling and updating will only roll out the
+// pods of this step.
+// It returns the duration to requeue if the current step is paused for a while.
+func SyncUpdateSteps(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus, pods []*v1.Pod) time.Duration {
+	return syncUpdateSteps(cs, newStatus, pods, metav1.Now())
+}
+
+func syncUpdateSteps(cs *appsv1alpha1.CloneSet, newStatus *appsv1alpha1.CloneSetStatus, pods []*v1.Pod, now metav1.Time) time.Duration {
+	steps := cs.Spec.UpdateStrategy.Steps
+	if len(steps) == 0 || newStatus.CurrentRevision == newStatus.UpdateRevision {
+		newStatus.CurrentStepIndex = nil
+		newStatus.StepStatuses = nil
+		return 0
+	}
+
+	// start over from the first step if it is a new rollout
+	var index int
+	var stepStatuses []appsv1alpha1.CloneSetUpdateStepStatus
+	if cs.Status.UpdateRevision == newStatus.UpdateRevision && cs.Status.CurrentStepIndex != nil &&
+		int(*cs.Status.CurrentStepIndex) < len(steps) && len(cs.Status.StepStatuses) == len(steps) {
+		index = int(*cs.Status.CurrentStepIndex)
+		stepStatuses = make([]appsv1alpha1.CloneSetUpdateStepStatus, len(steps))
+		for i := range cs.Status.StepStatuses {
+			cs.Status.StepStatuses[i].DeepCopyInto(&stepStatuses[i])
+		}
+	} else {
+		stepStatuses = make([]appsv1alpha1.CloneSetUpdateStepStatus, len(steps))
+		for i := range stepStatuses {
+			stepStatuses[i].State = appsv1alpha1.CloneSetUpdateStepStatePending
+		}
+		stepStatuses[0].State = appsv1alpha1.CloneSetUpdateStepStateUpdating
+		stepStatuses[0].StartTime = now.DeepCopy()
+		klog.V(3).InfoS("CloneSet started rolling out with steps", "cloneSet", klog.KObj(cs), "updateRevision", newStatus.UpdateRevision)
+	}
+
+	coreControl := clonesetcore.New(cs)
+	var updatedAvailable int
+	for _, pod := range pods {
+		if clonesetutils.EqualToRevisionHash("", pod, newStatus.UpdateRevision) && IsPodAvailable(coreControl, pod, cs.Spec.MinReadySeconds) {
+			updatedAvailable++
+		}
+	}
+
+	// the partition in update strategy holds the rollout if it is stricter than the one of a step,
+	// and the step should be finished once the stricter partition has been reached
+	strategyPartition := 0
+	if cs.Spec.UpdateStrategy.Partition != nil {
+		if pValue, err := util.CalculatePartitionReplicas(cs.Spec.UpdateStrategy.Partition, cs.Spec.Replicas); err == nil {
+			strategyPartition = pValue
+		}
+	}
+
+	var requeueDuration time.Duration
+	for {
+		stepStatus := &stepStatuses[index]
+		step := &steps[index]
+
+		if stepStatus.State == appsv1alpha1.CloneSetUpdateStepStateUpdating {
+			partition := integer.IntMax(getStepPartition(cs, step), strategyPartition)
+			if updatedAvailable < int(*cs.Spec.Replicas)-partition {
+				break
+			}
+			stepStatus.FinishTime = now.DeepCopy()
+			if step.Pause != nil {
+				stepStatus.State = appsv1alpha1.CloneSetUpdateStepStatePaused
+			} else {
+				stepStatus.State = appsv1alpha1.CloneSetUpdateStepStateCompleted
+			}
+		}
+
+		if stepStatus.State == appsv1alpha1.CloneSetUpdateStepStatePaused {
+			if step.Pause == nil {
+				// the pause of this step has been removed from spec
+			} else if step.Pause.Duration != nil && stepStatus.FinishTime != nil {
+				pauseEnd := stepStatus.FinishTime.Add(time.Duration(*step.Pause.Duration) * time.Second)
+				if now.Time.Before(pauseEnd) {
+					requeueDuration = pauseEnd.Sub(now.Time)
+					break
+				}
+			} else if step.Pause.Duration == nil && !isUpdateStepResumed(cs, newStatus.UpdateRevision, index) {
+				break
+			}
+			stepStatus.State = appsv1alpha1.CloneSetUpdateStepStateCompleted
+		}
+
+		if stepStatus.State != appsv1alpha1.CloneSetUpdateStepStateCompleted || index >= len(steps)-1 {
+			break
+		}
+		index++
+		stepStatuses[index].State = appsv1alpha1.CloneSetUpdateStepStateUpdating
+		stepStatuses[index].StartTime = now.DeepCopy()
+		klog.V(3).InfoS("CloneSet went on to the next step", "cloneSet", klog.KObj(cs), "stepIndex", index)
+	}
+
+	newStatus.CurrentStepIndex = utilpointer.Int32(int32(index))
+	newStatus.StepStatuses = stepStatuses
+
+	// the partition of current step overrides the one in update strategy unless the latter is larger
+	partition := integer.IntMax(getStepPartition(cs, &steps[index]), strategyPartition)
+	cs.Spec.UpdateStrategy.Partition = util.GetIntOrStrPointer(intstrutil.FromInt(partition))
+	return requeueDuration
+}
+
+// isUpdateStepResumed checks whether the paused step of the rollout to the update revision is resumed by annotation.
+func isUpdateStepResumed(cs *appsv1alpha1.CloneSet, updateRevision string, index int) bool {
+	return cs.Annotations[appsv1alpha1.CloneSetResumeStepKey] == fmt.Sprintf("%s/%d", updateRevision, index)
+}
+
+// getStepPartition returns the number of pods that should stay in old revisions at the given step.
+func getStepPartition(cs *appsv1alpha1.CloneSet, step *appsv1alpha1.CloneSetUpdateStep) int {
+	replicas := int(*cs.Spec.Replicas)
+	if step.Replicas != nil {
+		updated, err := intstrutil.GetScaledValueFromIntOrPercent(step.Replicas, replicas, true)
+		if err != nil {
+			klog.ErrorS(err, "CloneSet step replicas value was illegal", "cloneSet", klog.KObj(cs))
+			return replicas
+		}
+		return integer.IntMax(replicas-updated, 0)
+	}
+	partition, err := util.CalculatePartitionReplicas(step.Partition, cs.Spec.Replicas)
+	if err != nil {
+		klog.ErrorS(err, "CloneSet step partition value was illegal", "cloneSet", klog.KObj(cs))
+		return replicas
+	}
+	return partition
+}
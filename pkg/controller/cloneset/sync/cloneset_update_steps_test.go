@@ -0,0 +1,202 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sync
+
+import (
+	"testing"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	utilpointer "k8s.io/utils/pointer"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/util"
+)
+
+func TestSyncUpdateSteps(t *testing.T) {
+	now := metav1.NewTime(time.Unix(time.Now().Unix(), 0))
+	before := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(-d))
+		return &t
+	}
+	newPod := func(revision string, available bool) *v1.Pod {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			apps.ControllerRevisionHashLabelKey: revision,
+			appspub.LifecycleStateKey:           string(appspub.LifecycleStateNormal),
+		}}}
+		if available {
+			pod.Status = v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}}
+		}
+		return pod
+	}
+	steps := []appsv1alpha1.CloneSetUpdateStep{
+		{Replicas: util.GetIntOrStrPointer(intstrutil.FromInt(1)), Pause: &appsv1alpha1.CloneSetUpdateStepPause{}},
+		{Replicas: util.GetIntOrStrPointer(intstrutil.FromString("50%")), Pause: &appsv1alpha1.CloneSetUpdateStepPause{Duration: utilpointer.Int32(60)}},
+		{Partition: util.GetIntOrStrPointer(intstrutil.FromInt(0))},
+	}
+
+	cases := []struct {
+		name              string
+		updateRevision    string
+		annotations       map[string]string
+		partition         *intstrutil.IntOrString
+		oldStatus         appsv1alpha1.CloneSetStatus
+		pods              []*v1.Pod
+		expectedIndex     *int32
+		expectedStates    []appsv1alpha1.CloneSetUpdateStepState
+		expectedPartition int
+		expectedDuration  time.Duration
+	}{
+		{
+			name:           "no rollout",
+			updateRevision: "v1",
+			oldStatus:      appsv1alpha1.CloneSetStatus{UpdateRevision: "v1"},
+			pods:           []*v1.Pod{newPod("v1", true), newPod("v1", true), newPod("v1", true), newPod("v1", true)},
+		},
+		{
+			name:              "start a new rollout",
+			oldStatus:         appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v1"},
+			pods:              []*v1.Pod{newPod("v1", true), newPod("v1", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(0),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Updating", "Pending", "Pending"},
+			expectedPartition: 3,
+		},
+		{
+			name: "pause at the first step",
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", CurrentStepIndex: utilpointer.Int32(0),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Updating", StartTime: before(time.Minute)}, {State: "Pending"}, {State: "Pending"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v1", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(0),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Paused", "Pending", "Pending"},
+			expectedPartition: 3,
+		},
+		{
+			name:        "resume the first step",
+			annotations: map[string]string{appsv1alpha1.CloneSetResumeStepKey: "v2/0"},
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", CurrentStepIndex: utilpointer.Int32(0),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Paused", StartTime: before(time.Minute), FinishTime: before(time.Second)}, {State: "Pending"}, {State: "Pending"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v1", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(1),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Completed", "Updating", "Pending"},
+			expectedPartition: 2,
+		},
+		{
+			name:        "not resumed by the annotation of a previous rollout",
+			annotations: map[string]string{appsv1alpha1.CloneSetResumeStepKey: "v1/0"},
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", CurrentStepIndex: utilpointer.Int32(0),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Paused", StartTime: before(time.Minute), FinishTime: before(time.Second)}, {State: "Pending"}, {State: "Pending"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v1", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(0),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Paused", "Pending", "Pending"},
+			expectedPartition: 3,
+		},
+		{
+			name: "updated pods of the second step are not available",
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", CurrentStepIndex: utilpointer.Int32(1),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Completed"}, {State: "Updating", StartTime: before(time.Minute)}, {State: "Pending"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v2", false), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(1),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Completed", "Updating", "Pending"},
+			expectedPartition: 2,
+		},
+		{
+			name: "pause at the second step for a duration",
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", CurrentStepIndex: utilpointer.Int32(1),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Completed"}, {State: "Paused", FinishTime: before(20 * time.Second)}, {State: "Pending"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v2", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(1),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Completed", "Paused", "Pending"},
+			expectedPartition: 2,
+			expectedDuration:  40 * time.Second,
+		},
+		{
+			name: "go on to the last step after pausing",
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", CurrentStepIndex: utilpointer.Int32(1),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Completed"}, {State: "Paused", FinishTime: before(time.Minute)}, {State: "Pending"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v2", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(2),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Completed", "Completed", "Updating"},
+			expectedPartition: 0,
+		},
+		{
+			name:      "finish the second step with a stricter partition in update strategy",
+			partition: util.GetIntOrStrPointer(intstrutil.FromInt(3)),
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2", CurrentStepIndex: utilpointer.Int32(1),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Completed"}, {State: "Updating", StartTime: before(time.Minute)}, {State: "Pending"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v1", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(1),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Completed", "Paused", "Pending"},
+			expectedPartition: 3,
+			expectedDuration:  time.Minute,
+		},
+		{
+			name: "start over for a new update revision",
+			oldStatus: appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v3", CurrentStepIndex: utilpointer.Int32(2),
+				StepStatuses: []appsv1alpha1.CloneSetUpdateStepStatus{{State: "Completed"}, {State: "Completed"}, {State: "Updating"}}},
+			pods:              []*v1.Pod{newPod("v2", true), newPod("v1", true), newPod("v1", true), newPod("v1", true)},
+			expectedIndex:     utilpointer.Int32(0),
+			expectedStates:    []appsv1alpha1.CloneSetUpdateStepState{"Paused", "Pending", "Pending"},
+			expectedPartition: 3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := &appsv1alpha1.CloneSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", Annotations: tc.annotations},
+				Spec: appsv1alpha1.CloneSetSpec{
+					Replicas:       utilpointer.Int32(4),
+					UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{Partition: tc.partition, Steps: steps},
+				},
+				Status: tc.oldStatus,
+			}
+			newStatus := &appsv1alpha1.CloneSetStatus{CurrentRevision: "v1", UpdateRevision: "v2"}
+			if tc.updateRevision != "" {
+				newStatus.UpdateRevision = tc.updateRevision
+			}
+			duration := syncUpdateSteps(cs, newStatus, tc.pods, now)
+
+			if duration != tc.expectedDuration {
+				t.Fatalf("expected duration %v, got %v", tc.expectedDuration, duration)
+			}
+			if tc.expectedIndex == nil {
+				if newStatus.CurrentStepIndex != nil || newStatus.StepStatuses != nil {
+					t.Fatalf("expected no step status, got %v", newStatus.StepStatuses)
+				}
+				if cs.Spec.UpdateStrategy.Partition != nil {
+					t.Fatalf("expected partition not overridden, got %v", cs.Spec.UpdateStrategy.Partition)
+				}
+				return
+			}
+			if newStatus.CurrentStepIndex == nil || *newStatus.CurrentStepIndex != *tc.expectedIndex {
+				t.Fatalf("expected step index %v, got %v", *tc.expectedIndex, newStatus.CurrentStepIndex)
+			}
+			for i, state := range tc.expectedStates {
+				if newStatus.StepStatuses[i].State != state {
+					t.Fatalf("expected step %d in %s, got %s", i, state, newStatus.StepStatuses[i].State)
+				}
+			}
+			if cs.Spec.UpdateStrategy.Partition.IntValue() != tc.expectedPartition {
+				t.Fatalf("expected partition %d, got %v", tc.expectedPartition, cs.Spec.UpdateStrategy.Partition)
+			}
+		})
+	}
+}
@@ -28,6 +28,8 @@ import (
 
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -50,6 +52,9 @@ import (
 	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
 	"github.com/openkruise/kruise/pkg/util/inplaceupdate"
 	"github.com/openkruise/kruise/pkg/util/lifecycle"
+	"github.com/openkruise/kruise/pkg/util/podadapter"
+	"github.com/openkruise/kruise/pkg/util/podreadiness"
+	"github.com/openkruise/kruise/pkg/util/volumeclaimtemplate"
 )
 
 type manageCase struct {
@@ -958,11 +963,12 @@ func TestUpdate(t *testing.T) {
 			initialObjs := mc.initial()
 			fakeClient := fake.NewClientBuilder().WithObjects(initialObjs...).Build()
 			ctrl := &realControl{
-				fakeClient,
-				lifecycle.New(fakeClient),
-				inplaceupdate.New(fakeClient, clonesetutils.RevisionAdapterImpl),
-				record.NewFakeRecorder(10),
-				&controllerfinder.ControllerFinder{Client: fakeClient},
+				Client:              fakeClient,
+				lifecycleControl:    lifecycle.New(fakeClient),
+				inplaceControl:      inplaceupdate.New(fakeClient, clonesetutils.RevisionAdapterImpl),
+				podReadinessControl: podreadiness.NewForAdapter(&podadapter.AdapterRuntimeClient{Client: fakeClient}),
+				recorder:            record.NewFakeRecorder(10),
+				controllerFinder:    &controllerfinder.ControllerFinder{Client: fakeClient},
 			}
 			currentRevision := mc.updateRevision
 			if len(mc.revisions) > 0 {
@@ -1242,3 +1248,85 @@ func TestCalculateUpdateCount(t *testing.T) {
 		}
 	}
 }
+
+func TestGetInPlaceUpdateOptions(t *testing.T) {
+	newTemplate := func(size string) v1.PersistentVolumeClaim {
+		return v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+		}
+	}
+	newRevision := func(name, size string) *apps.ControllerRevision {
+		revision := &apps.ControllerRevision{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		volumeclaimtemplate.PatchVCTemplateHash(revision, []v1.PersistentVolumeClaim{newTemplate(size)})
+		return revision
+	}
+	newStorageClass := func(name string, allowExpansion bool) *storagev1.StorageClass {
+		return &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name}, AllowVolumeExpansion: &allowExpansion}
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod-0", Labels: map[string]string{appsv1alpha1.CloneSetInstanceID: "id-0"}}}
+	newClaim := func(storageClassName, size string) *v1.PersistentVolumeClaim {
+		claim := newTemplate(size)
+		claim.Name = "data-clone-test-id-0"
+		claim.Spec.StorageClassName = &storageClassName
+		return &claim
+	}
+
+	cases := []struct {
+		name        string
+		enabled     bool
+		oldSize     string
+		newSize     string
+		claim       *v1.PersistentVolumeClaim
+		expectation bool
+	}{
+		{
+			name:    "gate disabled",
+			oldSize: "1Gi", newSize: "2Gi",
+			claim:       newClaim("expandable", "1Gi"),
+			expectation: false,
+		},
+		{
+			name:    "grow with expandable storage class",
+			enabled: true, oldSize: "1Gi", newSize: "2Gi",
+			claim:       newClaim("expandable", "1Gi"),
+			expectation: true,
+		},
+		{
+			name:    "grow with storage class not allowing expansion",
+			enabled: true, oldSize: "1Gi", newSize: "2Gi",
+			claim:       newClaim("fixed", "1Gi"),
+			expectation: false,
+		},
+		{
+			name:    "shrink with expandable storage class",
+			enabled: true, oldSize: "2Gi", newSize: "1Gi",
+			claim:       newClaim("expandable", "2Gi"),
+			expectation: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.CloneSetAutoResizePVCGate, tc.enabled)()
+			cs := &appsv1alpha1.CloneSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "clone-test", Namespace: "default"},
+				Spec: appsv1alpha1.CloneSetSpec{
+					Selector:             &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+					VolumeClaimTemplates: []v1.PersistentVolumeClaim{newTemplate(tc.newSize)},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithObjects(newStorageClass("expandable", true), newStorageClass("fixed", false)).Build()
+			opts := GetInPlaceUpdateOptions(fakeClient, cs, newRevision("old", tc.oldSize), newRevision("new", tc.newSize),
+				[]*v1.Pod{pod}, []*v1.PersistentVolumeClaim{tc.claim})
+			if opts.IgnoreVolumeClaimTemplatesHashDiff != tc.expectation {
+				t.Fatalf("expected IgnoreVolumeClaimTemplatesHashDiff %v, got %v", tc.expectation, opts.IgnoreVolumeClaimTemplatesHashDiff)
+			}
+		})
+	}
+}
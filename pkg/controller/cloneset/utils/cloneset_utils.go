@@ -24,6 +24,7 @@ import (
 
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
@@ -32,10 +33,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
 	"github.com/openkruise/kruise/pkg/features"
 	utilclient "github.com/openkruise/kruise/pkg/util/client"
 	"github.com/openkruise/kruise/pkg/util/expectations"
 	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+	pvcutil "github.com/openkruise/kruise/pkg/util/pvc"
 	"github.com/openkruise/kruise/pkg/util/requeueduration"
 	"github.com/openkruise/kruise/pkg/util/revision"
 )
@@ -144,6 +147,42 @@ func SplitPodsByRevision(pods []*v1.Pod, rev string) (matched, unmatched []*v1.P
 	return
 }
 
+// IsStandbyPod returns true if the pod is a warm standby pod created for scaleStrategy.standbyReplicas.
+func IsStandbyPod(pod *v1.Pod) bool {
+	return pod.Labels[appsv1alpha1.CloneSetStandbyKey] == "true"
+}
+
+// SplitStandbyPods returns the active Pods and the standby Pods
+func SplitStandbyPods(pods []*v1.Pod) (active, standby []*v1.Pod) {
+	for _, p := range pods {
+		if IsStandbyPod(p) {
+			standby = append(standby, p)
+		} else {
+			active = append(active, p)
+		}
+	}
+	return
+}
+
+// FilterOutStandbyPVCs returns the PVCs that do not belong to the standby Pods,
+// so that the instance-ids of standby Pods will not be reused by the active ones.
+func FilterOutStandbyPVCs(pvcs []*v1.PersistentVolumeClaim, standbyPods []*v1.Pod) []*v1.PersistentVolumeClaim {
+	if len(standbyPods) == 0 {
+		return pvcs
+	}
+	standbyIDs := make(map[string]struct{}, len(standbyPods))
+	for _, p := range standbyPods {
+		standbyIDs[GetInstanceID(p)] = struct{}{}
+	}
+	var filtered []*v1.PersistentVolumeClaim
+	for _, pvc := range pvcs {
+		if _, ok := standbyIDs[GetInstanceID(pvc)]; !ok {
+			filtered = append(filtered, pvc)
+		}
+	}
+	return filtered
+}
+
 func GroupUpdateAndNotUpdatePods(pods []*v1.Pod, updateRevision string) (update, notUpdate []*v1.Pod) {
 	for _, p := range pods {
 		if revision.IsPodUpdate(p, updateRevision) {
@@ -209,6 +248,73 @@ func GetPersistentVolumeClaims(cs *appsv1alpha1.CloneSet, pod *v1.Pod) map[strin
 	return claims
 }
 
+// GetPersistentVolumeClaimRetentionPolicy returns the PVC retention policy of CloneSet, in which
+// the unset fields default to Delete.
+func GetPersistentVolumeClaimRetentionPolicy(cs *appsv1alpha1.CloneSet) appsv1alpha1.CloneSetPersistentVolumeClaimRetentionPolicy {
+	policy := appsv1alpha1.CloneSetPersistentVolumeClaimRetentionPolicy{
+		WhenDeleted: appsv1beta1.DeletePersistentVolumeClaimRetentionPolicyType,
+		WhenScaled:  appsv1beta1.DeletePersistentVolumeClaimRetentionPolicyType,
+	}
+	if cs.Spec.PersistentVolumeClaimRetentionPolicy != nil {
+		if cs.Spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted != "" {
+			policy.WhenDeleted = cs.Spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted
+		}
+		if cs.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled != "" {
+			policy.WhenScaled = cs.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled
+		}
+	}
+	return policy
+}
+
+// CanExpandPersistentVolumeClaims returns whether the existing PVCs of pods can be expanded online to match
+// the volumeClaimTemplates, which means none of them has to shrink or change anything other than storage
+// requests, and the StorageClasses of the ones to grow allow volume expansion.
+func CanExpandPersistentVolumeClaims(reader client.Reader, cs *appsv1alpha1.CloneSet, pods []*v1.Pod, pvcs []*v1.PersistentVolumeClaim) (bool, error) {
+	claimsByName := make(map[string]*v1.PersistentVolumeClaim, len(pvcs))
+	for _, claim := range pvcs {
+		claimsByName[claim.Name] = claim
+	}
+
+	allowExpansion := make(map[string]bool)
+	for _, pod := range pods {
+		for _, expected := range GetPersistentVolumeClaims(cs, pod) {
+			claim, ok := claimsByName[expected.Name]
+			if !ok || claim.DeletionTimestamp != nil {
+				continue
+			}
+			template := &expected
+			matched, needExpand := pvcutil.CompareWithCheckFn(claim, template, pvcutil.IsPVCNeedExpand)
+			if matched {
+				// storage requests of the claim can be larger than the template, which can not be shrunk
+				if claim.Spec.Resources.Requests.Storage().Cmp(*template.Spec.Resources.Requests.Storage()) > 0 {
+					return false, nil
+				}
+				continue
+			} else if !needExpand {
+				return false, nil
+			}
+
+			if claim.Spec.StorageClassName == nil {
+				return false, nil
+			}
+			scName := *claim.Spec.StorageClassName
+			allowed, ok := allowExpansion[scName]
+			if !ok {
+				sc := &storagev1.StorageClass{}
+				if err := reader.Get(context.TODO(), types.NamespacedName{Name: scName}, sc); err != nil {
+					return false, fmt.Errorf("could not get storage class %s for pvc %s: %v", scName, claim.Name, err)
+				}
+				allowed = sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion
+				allowExpansion[scName] = allowed
+			}
+			if !allowed {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
 // getPersistentVolumeClaimName gets the name of PersistentVolumeClaim for a Pod with an instance id. claim
 // must be a PersistentVolumeClaim from set's VolumeClaims template.
 func getPersistentVolumeClaimName(cs *appsv1alpha1.CloneSet, claim *v1.PersistentVolumeClaim, id string) string {
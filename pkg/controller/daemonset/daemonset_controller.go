@@ -400,9 +400,15 @@ func (dsc *ReconcileDaemonSet) syncDaemonSet(ctx context.Context, request reconc
 		return fmt.Errorf("failed to construct revisions of DaemonSet: %v", err)
 	}
 	hash := cur.Labels[apps.DefaultDaemonSetUniqueLabelKey]
+	inPlaceUpdateCondition := getInPlaceUpdateCondition(ds, cur, old)
+	// Advanced: the stage of rolling update is calculated once, to limit the nodes to update and to be reported in status
+	updateStage, err := dsc.getUpdateStage(ctx, ds, nodeList, hash)
+	if err != nil {
+		return fmt.Errorf("couldn't calculate update stage for DaemonSet %q: %v", ds.Name, err)
+	}
 
 	if !dsc.expectations.SatisfiedExpectations(logger, dsKey) || !dsc.hasPodExpectationsSatisfied(ctx, ds) {
-		return dsc.updateDaemonSetStatus(ctx, ds, nodeList, hash, false)
+		return dsc.updateDaemonSetStatus(ctx, ds, nodeList, hash, inPlaceUpdateCondition, updateStage, false)
 	}
 
 	if !isPreDownloadDisabled && dsc.Client != nil {
@@ -438,10 +444,10 @@ func (dsc *ReconcileDaemonSet) syncDaemonSet(ctx context.Context, request reconc
 
 	// return and wait next reconcile if expectation changed to unsatisfied
 	if !dsc.expectations.SatisfiedExpectations(logger, dsKey) || !dsc.hasPodExpectationsSatisfied(ctx, ds) {
-		return dsc.updateDaemonSetStatus(ctx, ds, nodeList, hash, false)
+		return dsc.updateDaemonSetStatus(ctx, ds, nodeList, hash, inPlaceUpdateCondition, updateStage, false)
 	}
 
-	if err := dsc.refreshUpdateStates(ctx, ds); err != nil {
+	if err := dsc.refreshUpdateStates(ctx, ds, hash); err != nil {
 		return err
 	}
 
@@ -451,7 +457,7 @@ func (dsc *ReconcileDaemonSet) syncDaemonSet(ctx context.Context, request reconc
 		switch ds.Spec.UpdateStrategy.Type {
 		case appsv1alpha1.OnDeleteDaemonSetStrategyType:
 		case appsv1alpha1.RollingUpdateDaemonSetStrategyType:
-			err = dsc.rollingUpdate(ctx, ds, nodeList, cur, old)
+			err = dsc.rollingUpdate(ctx, ds, nodeList, cur, old, updateStage)
 			if err != nil {
 				return err
 			}
@@ -463,7 +469,7 @@ func (dsc *ReconcileDaemonSet) syncDaemonSet(ctx context.Context, request reconc
 		return fmt.Errorf("failed to clean up revisions of DaemonSet: %v", err)
 	}
 
-	return dsc.updateDaemonSetStatus(ctx, ds, nodeList, hash, true)
+	return dsc.updateDaemonSetStatus(ctx, ds, nodeList, hash, inPlaceUpdateCondition, updateStage, true)
 }
 
 // Predicates checks if a DaemonSet's pod can run on a node.
@@ -502,26 +508,50 @@ func NewPod(ds *appsv1alpha1.DaemonSet, nodeName string) *corev1.Pod {
 		return pod
 	}
 
-	newPod := &corev1.Pod{Spec: ds.Spec.Template.Spec, ObjectMeta: ds.Spec.Template.ObjectMeta}
+	newPod := newPodFromTemplate(ds, &ds.Spec.Template)
+	cache := &newPodForDS{generation: ds.Generation, pod: newPod}
+	for i := range ds.Spec.NodePoolPatches {
+		patch := &ds.Spec.NodePoolPatches[i]
+		template, err := getNodePoolPodTemplate(&ds.Spec.Template, patch)
+		if err != nil {
+			klog.ErrorS(err, "Failed to apply node pool patch", "daemonSet", klog.KObj(ds), "nodePool", patch.Name)
+			continue
+		}
+		if cache.nodePoolPods == nil {
+			cache.nodePoolPods = make(map[string]*corev1.Pod, len(ds.Spec.NodePoolPatches))
+		}
+		cache.nodePoolPods[patch.Name] = newPodFromTemplate(ds, template)
+	}
+
+	newPodForDSCache.Store(ds.UID, cache)
+	return newPod
+}
+
+func newPodFromTemplate(ds *appsv1alpha1.DaemonSet, template *corev1.PodTemplateSpec) *corev1.Pod {
+	newPod := &corev1.Pod{Spec: template.Spec, ObjectMeta: template.ObjectMeta}
 	newPod.Namespace = ds.Namespace
 	// no need to set nodeName
 	// newPod.Spec.NodeName = nodeName
 
 	// Added default tolerations for DaemonSet pods.
 	util.AddOrUpdateDaemonPodTolerations(&newPod.Spec)
-
-	newPodForDSCache.Store(ds.UID, &newPodForDS{generation: ds.Generation, pod: newPod})
+	inplaceupdate.ProjectContainerValues(newPod)
 	return newPod
 }
 
-func (dsc *ReconcileDaemonSet) updateDaemonSetStatus(ctx context.Context, ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node, hash string, updateObservedGen bool) error {
+func (dsc *ReconcileDaemonSet) updateDaemonSetStatus(ctx context.Context, ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node, hash string,
+	inPlaceUpdateCondition *apps.DaemonSetCondition, updateStage *appsv1alpha1.DaemonSetUpdateStageStatus, updateObservedGen bool) error {
 	nodeToDaemonPods, err := dsc.getNodesToDaemonPods(ctx, ds)
 	if err != nil {
 		return fmt.Errorf("couldn't get node to daemon pod mapping for DaemonSet %q: %v", ds.Name, err)
 	}
 
 	var desiredNumberScheduled, currentNumberScheduled, numberMisscheduled, numberReady, updatedNumberScheduled, numberAvailable int
+	var allPods []*corev1.Pod
 	now := dsc.failedPodsBackoff.Clock.Now()
+	for _, pods := range nodeToDaemonPods {
+		allPods = append(allPods, pods...)
+	}
 	for _, node := range nodeList {
 		shouldRun, _ := nodeShouldRunDaemonPod(node, ds)
 		scheduled := len(nodeToDaemonPods[node.Name]) > 0
@@ -546,7 +576,7 @@ func (dsc *ReconcileDaemonSet) updateDaemonSetStatus(ctx context.Context, ds *ap
 				if err != nil {
 					generation = nil
 				}
-				if util.IsPodUpdated(pod, hash, generation) {
+				if util.IsPodUpdated(pod, hash, generation) && isPodInNodePool(ds, pod, node) {
 					updatedNumberScheduled++
 				}
 			}
@@ -558,7 +588,9 @@ func (dsc *ReconcileDaemonSet) updateDaemonSetStatus(ctx context.Context, ds *ap
 	}
 	numberUnavailable := desiredNumberScheduled - numberAvailable
 
-	err = dsc.storeDaemonSetStatus(ctx, ds, desiredNumberScheduled, currentNumberScheduled, numberMisscheduled, numberReady, updatedNumberScheduled, numberAvailable, numberUnavailable, updateObservedGen, hash)
+	conditions := setDaemonSetCondition(ds.Status.Conditions, appsv1alpha1.DaemonSetConditionInPlaceUpdateNotPossible, inPlaceUpdateCondition)
+	lifecycleStates := lifecycle.CalculateLifecycleStates(ds.Spec.Lifecycle, allPods)
+	err = dsc.storeDaemonSetStatus(ctx, ds, desiredNumberScheduled, currentNumberScheduled, numberMisscheduled, numberReady, updatedNumberScheduled, numberAvailable, numberUnavailable, conditions, lifecycleStates, updateStage, updateObservedGen, hash)
 	if err != nil {
 		return fmt.Errorf("error storing status for DaemonSet %v: %v", ds.Name, err)
 	}
@@ -580,6 +612,9 @@ func (dsc *ReconcileDaemonSet) storeDaemonSetStatus(
 	updatedNumberScheduled,
 	numberAvailable,
 	numberUnavailable int,
+	conditions []apps.DaemonSetCondition,
+	lifecycleStates []appspub.LifecycleStateStatus,
+	updateStage *appsv1alpha1.DaemonSetUpdateStageStatus,
 	updateObservedGen bool,
 	hash string) error {
 	if int(ds.Status.DesiredNumberScheduled) == desiredNumberScheduled &&
@@ -590,7 +625,10 @@ func (dsc *ReconcileDaemonSet) storeDaemonSetStatus(
 		int(ds.Status.NumberAvailable) == numberAvailable &&
 		int(ds.Status.NumberUnavailable) == numberUnavailable &&
 		ds.Status.ObservedGeneration >= ds.Generation &&
-		ds.Status.DaemonSetHash == hash {
+		ds.Status.DaemonSetHash == hash &&
+		reflect.DeepEqual(ds.Status.Conditions, conditions) &&
+		reflect.DeepEqual(ds.Status.LifecycleStates, lifecycleStates) &&
+		reflect.DeepEqual(ds.Status.UpdateStage, updateStage) {
 		return nil
 	}
 
@@ -609,6 +647,9 @@ func (dsc *ReconcileDaemonSet) storeDaemonSetStatus(
 		toUpdate.Status.NumberAvailable = int32(numberAvailable)
 		toUpdate.Status.NumberUnavailable = int32(numberUnavailable)
 		toUpdate.Status.DaemonSetHash = hash
+		toUpdate.Status.Conditions = conditions
+		toUpdate.Status.LifecycleStates = lifecycleStates
+		toUpdate.Status.UpdateStage = updateStage
 
 		if _, updateErr = dsClient.UpdateStatus(ctx, toUpdate, metav1.UpdateOptions{}); updateErr == nil {
 			klog.InfoS("Updated DaemonSet status", "daemonSet", klog.KObj(ds), "status", kruiseutil.DumpJSON(toUpdate.Status))
@@ -651,7 +692,7 @@ func (dsc *ReconcileDaemonSet) manage(ctx context.Context, ds *appsv1alpha1.Daem
 		if shouldRun, _ := nodeShouldRunDaemonPod(node, ds); shouldRun {
 			nodesDesireScheduled++
 		}
-		if newPod, _, ok := findUpdatedPodsOnNode(ds, nodeToDaemonPods[node.Name], hash); ok && newPod != nil {
+		if newPod, _, ok := findUpdatedPodsOnNode(ds, node, nodeToDaemonPods[node.Name], hash); ok && newPod != nil {
 			newPodCount++
 		}
 	}
@@ -700,31 +741,48 @@ func (dsc *ReconcileDaemonSet) syncNodes(ctx context.Context, ds *appsv1alpha1.D
 		deleteDiff = burstReplicas
 	}
 
-	if err := dsc.expectations.SetExpectations(logger, dsKey, createDiff, deleteDiff); err != nil {
-		utilruntime.HandleError(err)
-	}
-	// error channel to communicate back failures.  make the buffer big enough to avoid any blocking
-	errCh := make(chan error, createDiff+deleteDiff)
-
-	klog.V(4).InfoS("Nodes needing daemon pods for DaemonSet", "daemonSet", klog.KObj(ds), "nodes", nodesNeedingDaemonPods, "count", createDiff)
-	createWait := sync.WaitGroup{}
 	// If the returned error is not nil we have a parse error.
 	// The controller handles this via the hash.
 	generation, err := GetTemplateGeneration(ds)
 	if err != nil {
 		generation = nil
 	}
-	template := util.CreatePodTemplate(ds.Spec.Template, generation, hash)
+	newTemplate := func(t corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+		template := util.CreatePodTemplate(t, generation, hash)
+		if ds.Spec.UpdateStrategy.Type == appsv1alpha1.RollingUpdateDaemonSetStrategyType &&
+			ds.Spec.UpdateStrategy.RollingUpdate != nil &&
+			ds.Spec.UpdateStrategy.RollingUpdate.Type == appsv1alpha1.InplaceRollingUpdateType {
+			readinessGate := corev1.PodReadinessGate{
+				ConditionType: appspub.InPlaceUpdateReady,
+			}
+			template.Spec.ReadinessGates = append(template.Spec.ReadinessGates, readinessGate)
+		}
+		return template
+	}
+	template := newTemplate(ds.Spec.Template)
 
-	if ds.Spec.UpdateStrategy.Type == appsv1alpha1.RollingUpdateDaemonSetStrategyType &&
-		ds.Spec.UpdateStrategy.RollingUpdate != nil &&
-		ds.Spec.UpdateStrategy.RollingUpdate.Type == appsv1alpha1.InplaceRollingUpdateType {
-		readinessGate := corev1.PodReadinessGate{
-			ConditionType: appspub.InPlaceUpdateReady,
+	// Advanced: pods on the nodes of node pools are created from the templates patched by node pool patches
+	nodePoolTemplates := make(map[string]*corev1.PodTemplateSpec)
+	for _, nodeName := range nodesNeedingDaemonPods[:createDiff] {
+		nodePoolTemplate, err := dsc.getNodePoolTemplate(ds, nodeName)
+		if err != nil {
+			return err
+		}
+		if nodePoolTemplate != nil {
+			t := newTemplate(*nodePoolTemplate)
+			nodePoolTemplates[nodeName] = &t
 		}
-		template.Spec.ReadinessGates = append(template.Spec.ReadinessGates, readinessGate)
 	}
 
+	if err := dsc.expectations.SetExpectations(logger, dsKey, createDiff, deleteDiff); err != nil {
+		utilruntime.HandleError(err)
+	}
+	// error channel to communicate back failures.  make the buffer big enough to avoid any blocking
+	errCh := make(chan error, createDiff+deleteDiff)
+
+	klog.V(4).InfoS("Nodes needing daemon pods for DaemonSet", "daemonSet", klog.KObj(ds), "nodes", nodesNeedingDaemonPods, "count", createDiff)
+	createWait := sync.WaitGroup{}
+
 	// Batch the pod creates. Batch sizes start at SlowStartInitialBatchSize
 	// and double with each successful iteration in a kind of "slow start".
 	// This handles attempts to start large numbers of pods that would
@@ -743,6 +801,9 @@ func (dsc *ReconcileDaemonSet) syncNodes(ctx context.Context, ds *appsv1alpha1.D
 				var err error
 
 				podTemplate := template.DeepCopy()
+				if nodePoolTemplate, ok := nodePoolTemplates[nodesNeedingDaemonPods[ix]]; ok {
+					podTemplate = nodePoolTemplate.DeepCopy()
+				}
 				if scheduleDaemonSetPods {
 					// The pod's NodeAffinity will be updated to make sure the Pod is bound
 					// to the target node by default scheduler. It is safe to do so because there
@@ -823,6 +884,16 @@ func (dsc *ReconcileDaemonSet) syncWithPreparingDelete(ds *appsv1alpha1.DaemonSe
 			podsCanDelete = append(podsCanDelete, podName)
 			continue
 		}
+		if proceed, left := dsc.lifecycleControl.CheckHook(dsc.eventRecorder, ds.Spec.Lifecycle.PreDelete, pod, appspub.LifecycleStatePreparingDelete); proceed {
+			// the pod proceeds without the hook, whose finalizers should not hold it from being deleted
+			if _, _, err := dsc.lifecycleControl.RemovePodHook(pod, ds.Spec.Lifecycle.PreDelete); err != nil {
+				return nil, err
+			}
+			podsCanDelete = append(podsCanDelete, podName)
+			continue
+		} else if left > 0 {
+			durationStore.Push(keyFunc(ds), left)
+		}
 		markPodNotReady := ds.Spec.Lifecycle.PreDelete.MarkPodNotReady
 		if updated, gotPod, err := dsc.lifecycleControl.UpdatePodLifecycle(pod, appspub.LifecycleStatePreparingDelete, markPodNotReady); err != nil {
 			return nil, err
@@ -919,7 +990,7 @@ func (dsc *ReconcileDaemonSet) podsShouldBeOnNode(
 		var oldestNewPod, oldestOldPod *corev1.Pod
 		sort.Sort(podByCreationTimestampAndPhase(daemonPodsRunning))
 		for _, pod := range daemonPodsRunning {
-			if pod.Labels[apps.ControllerRevisionHashLabelKey] == hash {
+			if pod.Labels[apps.ControllerRevisionHashLabelKey] == hash && isPodInNodePool(ds, pod, node) {
 				if oldestNewPod == nil {
 					oldestNewPod = pod
 					continue
@@ -1053,7 +1124,7 @@ func (dsc *ReconcileDaemonSet) cleanupHistory(ctx context.Context, ds *appsv1alp
 	return nil
 }
 
-func (dsc *ReconcileDaemonSet) refreshUpdateStates(ctx context.Context, ds *appsv1alpha1.DaemonSet) error {
+func (dsc *ReconcileDaemonSet) refreshUpdateStates(ctx context.Context, ds *appsv1alpha1.DaemonSet, hash string) error {
 	dsKey := keyFunc(ds)
 	pods, err := dsc.getDaemonPods(ctx, ds)
 	if err != nil {
@@ -1062,6 +1133,17 @@ func (dsc *ReconcileDaemonSet) refreshUpdateStates(ctx context.Context, ds *apps
 
 	opts := &inplaceupdate.UpdateOptions{}
 	opts = inplaceupdate.SetOptionsDefaults(opts)
+	strategy := getInPlaceUpdateStrategy(ds)
+	if strategy != nil {
+		opts.GracePeriodSeconds = strategy.GracePeriodSeconds
+		opts.ResizeTimeoutSeconds = strategy.ResizeTimeoutSeconds
+	}
+	if ds.Spec.Lifecycle != nil && ds.Spec.Lifecycle.PostInPlaceUpdate != nil {
+		opts.CheckPostUpdateHook = func(pod *corev1.Pod, updateTime time.Time) (bool, time.Duration) {
+			return dsc.lifecycleControl.CheckPostInPlaceUpdateHook(dsc.eventRecorder, ds.Spec.Lifecycle.PostInPlaceUpdate, pod, updateTime)
+		}
+	}
+	var podsToRecreate []string
 	for _, pod := range pods {
 		if dsc.inplaceControl == nil {
 			continue
@@ -1074,8 +1156,23 @@ func (dsc *ReconcileDaemonSet) refreshUpdateStates(ctx context.Context, ds *apps
 		if res.DelayDuration != 0 {
 			durationStore.Push(dsKey, res.DelayDuration)
 		}
+		if res.ResizeFailed {
+			// recreate the pod whose in-place resize has timed out if the ResizeFailurePolicy is Recreate,
+			// otherwise the event is only recorded when the InPlaceUpdateReady condition turns False
+			if strategy != nil && strategy.ResizeFailurePolicy == appspub.InPlaceResizeFailurePolicyRecreate {
+				dsc.eventRecorder.Eventf(pod, corev1.EventTypeWarning, appspub.InPlaceUpdateResizeFailedReason,
+					"in-place resize timed out with resize status %s, fall back to recreate", pod.Status.Resize)
+				podsToRecreate = append(podsToRecreate, pod.Name)
+			} else if !inplaceupdate.IsResizeFailed(pod) {
+				dsc.eventRecorder.Eventf(pod, corev1.EventTypeWarning, appspub.InPlaceUpdateResizeFailedReason,
+					"in-place resize timed out with resize status %s", pod.Status.Resize)
+			}
+		}
 	}
 
+	if len(podsToRecreate) > 0 {
+		return dsc.syncNodes(ctx, ds, podsToRecreate, nil, hash)
+	}
 	return nil
 }
 
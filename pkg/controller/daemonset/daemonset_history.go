@@ -157,6 +157,10 @@ func getPatch(ds *appsv1alpha1.DaemonSet) ([]byte, error) {
 	template := spec["template"].(map[string]interface{})
 	specCopy["template"] = template
 	template["$patch"] = "replace"
+	// Only record spec.nodePoolPatches if exists, so that the existing revisions are kept the same
+	if nodePoolPatches, ok := spec["nodePoolPatches"]; ok {
+		specCopy["nodePoolPatches"] = nodePoolPatches
+	}
 	objCopy["spec"] = specCopy
 	patch, err := json.Marshal(objCopy)
 	return patch, err
@@ -178,7 +182,7 @@ func (dsc *ReconcileDaemonSet) snapshot(ctx context.Context, ds *appsv1alpha1.Da
 	if err != nil {
 		return nil, err
 	}
-	hash := kubecontroller.ComputeHash(&ds.Spec.Template, ds.Status.CollisionCount)
+	hash := computeHash(ds)
 	name := ds.Name + "-" + hash
 	history := &apps.ControllerRevision{
 		ObjectMeta: metav1.ObjectMeta{
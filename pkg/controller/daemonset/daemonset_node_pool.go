@@ -0,0 +1,297 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemonset
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/klog/v2"
+	kubecontroller "k8s.io/kubernetes/pkg/controller"
+	hashutil "k8s.io/kubernetes/pkg/util/hash"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
+	"github.com/openkruise/kruise/pkg/util"
+)
+
+// revisionData is the data recorded in the revisions of DaemonSet.
+type revisionData struct {
+	Spec struct {
+		Template        corev1.PodTemplateSpec                `json:"template"`
+		NodePoolPatches []appsv1alpha1.DaemonSetNodePoolPatch `json:"nodePoolPatches,omitempty"`
+	} `json:"spec"`
+}
+
+// computeHash returns the hash of the template, together with the node pool patches if any.
+// The hash of DaemonSet without node pool patches is the same as before.
+func computeHash(ds *appsv1alpha1.DaemonSet) string {
+	if len(ds.Spec.NodePoolPatches) == 0 {
+		return kubecontroller.ComputeHash(&ds.Spec.Template, ds.Status.CollisionCount)
+	}
+
+	hasher := fnv.New32a()
+	hashutil.DeepHashObject(hasher, &ds.Spec.Template)
+	hashutil.DeepHashObject(hasher, ds.Spec.NodePoolPatches)
+	// Add collisionCount in the hash if it exists.
+	if ds.Status.CollisionCount != nil {
+		collisionCountBytes := make([]byte, 8)
+		binary.LittleEndian.PutUint32(collisionCountBytes, uint32(*ds.Status.CollisionCount))
+		hasher.Write(collisionCountBytes)
+	}
+	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32()))
+}
+
+// getNodePoolPatch returns the first node pool patch whose nodeSelector matches the node, or nil if not found.
+func getNodePoolPatch(patches []appsv1alpha1.DaemonSetNodePoolPatch, node *corev1.Node) *appsv1alpha1.DaemonSetNodePoolPatch {
+	if node == nil {
+		return nil
+	}
+	for i := range patches {
+		selector, err := util.ValidatedLabelSelectorAsSelector(patches[i].NodeSelector)
+		if err != nil {
+			klog.ErrorS(err, "Failed to parse nodeSelector of node pool patch", "nodePool", patches[i].Name)
+			continue
+		}
+		if selector.Matches(labels.Set(node.Labels)) {
+			return &patches[i]
+		}
+	}
+	return nil
+}
+
+// applyNodePoolPatch returns a copy of the template patched by the node pool patch.
+func applyNodePoolPatch(template *corev1.PodTemplateSpec, patch *appsv1alpha1.DaemonSetNodePoolPatch) (*corev1.PodTemplateSpec, error) {
+	if patch == nil || len(patch.Patch.Raw) == 0 {
+		return template.DeepCopy(), nil
+	}
+	templateBytes, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	modified, err := strategicpatch.StrategicMergePatch(templateBytes, patch.Patch.Raw, &corev1.PodTemplateSpec{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply patch of node pool %s: %v", patch.Name, err)
+	}
+	patched := &corev1.PodTemplateSpec{}
+	if err = json.Unmarshal(modified, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
+
+// getNodePoolTemplate returns the template patched by the node pool patch matching the node,
+// or nil if there is no patch for the node.
+func (dsc *ReconcileDaemonSet) getNodePoolTemplate(ds *appsv1alpha1.DaemonSet, nodeName string) (*corev1.PodTemplateSpec, error) {
+	if len(ds.Spec.NodePoolPatches) == 0 {
+		return nil, nil
+	}
+	node, err := dsc.nodeLister.Get(nodeName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	patch := getNodePoolPatch(ds.Spec.NodePoolPatches, node)
+	if patch == nil {
+		return nil, nil
+	}
+	return getNodePoolPodTemplate(&ds.Spec.Template, patch)
+}
+
+// getNodePoolPodTemplate returns the template patched by the node pool patch to create pods from,
+// which records the name of the node pool in the annotations.
+func getNodePoolPodTemplate(template *corev1.PodTemplateSpec, patch *appsv1alpha1.DaemonSetNodePoolPatch) (*corev1.PodTemplateSpec, error) {
+	patched, err := applyNodePoolPatch(template, patch)
+	if err != nil {
+		return nil, err
+	}
+	if patched.Annotations == nil {
+		patched.Annotations = make(map[string]string)
+	}
+	patched.Annotations[appsv1alpha1.DaemonSetNodePoolAnnotationKey] = patch.Name
+	return patched, nil
+}
+
+// isPodInNodePool returns whether the pod is created for the node pool that the node belongs to now.
+// Pods on the nodes moved between node pools have to be updated, even if they are labeled with the current revision.
+// It returns true if node is nil.
+func isPodInNodePool(ds *appsv1alpha1.DaemonSet, pod *corev1.Pod, node *corev1.Node) bool {
+	if node == nil {
+		return true
+	}
+	var nodePool string
+	if patch := getNodePoolPatch(ds.Spec.NodePoolPatches, node); patch != nil {
+		nodePool = patch.Name
+	}
+	return pod.Annotations[appsv1alpha1.DaemonSetNodePoolAnnotationKey] == nodePool
+}
+
+// newPodForNode returns the new pod for the node, which is created from the template patched
+// by the node pool patch matching the node.
+func newPodForNode(ds *appsv1alpha1.DaemonSet, node *corev1.Node) *corev1.Pod {
+	pod := NewPod(ds, node.Name)
+	patch := getNodePoolPatch(ds.Spec.NodePoolPatches, node)
+	if patch == nil {
+		return pod
+	}
+	if val, ok := newPodForDSCache.Load(ds.UID); ok {
+		if nodePoolPod, ok := val.(*newPodForDS).nodePoolPods[patch.Name]; ok {
+			return nodePoolPod
+		}
+	}
+	return pod
+}
+
+// revisionHasNodePoolPatches returns whether node pool patches are recorded in the revision.
+func revisionHasNodePoolPatches(revision *apps.ControllerRevision) bool {
+	var data struct {
+		Spec struct {
+			NodePoolPatches json.RawMessage `json:"nodePoolPatches,omitempty"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(revision.Data.Raw, &data); err != nil {
+		return false
+	}
+	return len(data.Spec.NodePoolPatches) > 0 && string(data.Spec.NodePoolPatches) != "null"
+}
+
+// getNodePoolRevisions returns the revisions of the effective templates for pods on the node,
+// whose data only contain the templates patched by the node pool patches recorded in them,
+// so that they can be compared with each other for in-place update.
+// The revisions are returned directly if none of them has node pool patches.
+// If node is nil, the effective templates are the templates without any patch.
+func getNodePoolRevisions(node *corev1.Node, revisions ...*apps.ControllerRevision) ([]*apps.ControllerRevision, error) {
+	var hasPatches bool
+	for _, revision := range revisions {
+		if revisionHasNodePoolPatches(revision) {
+			hasPatches = true
+			break
+		}
+	}
+	if !hasPatches {
+		return revisions, nil
+	}
+
+	nodePoolRevisions := make([]*apps.ControllerRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		data := revisionData{}
+		if err := json.Unmarshal(revision.Data.Raw, &data); err != nil {
+			return nil, fmt.Errorf("failed to parse revision %s: %v", revision.Name, err)
+		}
+		template, err := applyNodePoolPatch(&data.Spec.Template, getNodePoolPatch(data.Spec.NodePoolPatches, node))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get template of revision %s: %v", revision.Name, err)
+		}
+		raw, err := json.Marshal(map[string]interface{}{"spec": map[string]interface{}{"template": template}})
+		if err != nil {
+			return nil, err
+		}
+		nodePoolRevision := revision.DeepCopy()
+		nodePoolRevision.Data = runtime.RawExtension{Raw: raw}
+		nodePoolRevisions = append(nodePoolRevisions, nodePoolRevision)
+	}
+	return nodePoolRevisions, nil
+}
+
+// getPodNodePoolRevisions returns the revisions of the effective templates for pods on the node of the pod.
+func (dsc *ReconcileDaemonSet) getPodNodePoolRevisions(pod *corev1.Pod, revisions ...*apps.ControllerRevision) ([]*apps.ControllerRevision, error) {
+	node, err := dsc.getNode(pod.Spec.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	return getNodePoolRevisions(node, revisions...)
+}
+
+// getNode returns the node with the name, or nil if the name is empty or the node is not found.
+func (dsc *ReconcileDaemonSet) getNode(nodeName string) (*corev1.Node, error) {
+	if nodeName == "" {
+		return nil, nil
+	}
+	node, err := dsc.nodeLister.Get(nodeName)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return node, err
+}
+
+// relabelPodsWithUnchangedTemplate updates the revision label of old pods, whose effective templates are not changed
+// in the current revision, such as pods in the node pools whose patches are not changed.
+// These pods are regarded as updated without being updated in-place or recreated.
+// Relabeling is a kind of update, so nodeToPodsToUpdate must be the pods filtered by filterDaemonPodsToUpdate,
+// which are limited by partition, selector and stages, and nothing is relabeled if the rolling update is paused.
+// It returns the names of nodes whose pods have been relabeled.
+func (dsc *ReconcileDaemonSet) relabelPodsWithUnchangedTemplate(ctx context.Context, ds *appsv1alpha1.DaemonSet, nodeToPodsToUpdate map[string][]*corev1.Pod,
+	curRevision *apps.ControllerRevision, oldRevisions []*apps.ControllerRevision) (sets.String, error) {
+	if isDaemonSetPaused(ds) {
+		return nil, nil
+	}
+	hash := curRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey]
+	relabeledNodes := sets.NewString()
+	for nodeName, pods := range nodeToPodsToUpdate {
+		node, err := dsc.getNode(nodeName)
+		if err != nil {
+			return nil, err
+		}
+		newPod, oldPod, ok := findUpdatedPodsOnNode(ds, node, pods, hash)
+		// pods created for another node pool have to be updated to the template of the current one
+		if !ok || newPod != nil || isPodNilOrPreDeleting(oldPod) || !isPodInNodePool(ds, oldPod, node) {
+			continue
+		}
+		var oldRevision *apps.ControllerRevision
+		for _, r := range oldRevisions {
+			if clonesetutils.EqualToRevisionHash("", oldPod, r.Labels[apps.DefaultDaemonSetUniqueLabelKey]) {
+				oldRevision = r
+				break
+			}
+		}
+		if oldRevision == nil {
+			continue
+		}
+		nodePoolRevisions, err := getNodePoolRevisions(node, oldRevision, curRevision)
+		if err != nil {
+			return nil, err
+		}
+		if nodePoolRevisions[0] == oldRevision || !bytes.Equal(nodePoolRevisions[0].Data.Raw, nodePoolRevisions[1].Data.Raw) {
+			continue
+		}
+
+		patch := fmt.Sprintf(`{"metadata":{"labels":{"%s":"%s"}}}`, apps.DefaultDaemonSetUniqueLabelKey, hash)
+		newPod, err = dsc.kubeClient.CoreV1().Pods(ds.Namespace).Patch(ctx, oldPod.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+		if err != nil {
+			return nil, err
+		}
+		klog.V(3).InfoS("DaemonSet relabeled pod whose template was not changed in the current revision", "daemonSet", klog.KObj(ds), "pod", klog.KObj(oldPod), "revision", curRevision.Name)
+		dsc.resourceVersionExpectations.Expect(newPod)
+		relabeledNodes.Insert(nodeName)
+	}
+	return relabeledNodes, nil
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemonset
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubecontroller "k8s.io/kubernetes/pkg/controller"
+	utilpointer "k8s.io/utils/pointer"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/util/inplaceupdate"
+	"github.com/openkruise/kruise/pkg/util/revisionadapter"
+)
+
+func newNodePoolRevision(t *testing.T, ds *appsv1alpha1.DaemonSet) *apps.ControllerRevision {
+	patch, err := getPatch(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := computeHash(ds)
+	return &apps.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   ds.Name + "-" + hash,
+			Labels: map[string]string{apps.DefaultDaemonSetUniqueLabelKey: hash},
+		},
+		Data: runtime.RawExtension{Raw: patch},
+	}
+}
+
+func TestNodePoolPatches(t *testing.T) {
+	ds := newDaemonSet("foo")
+	ds.Spec.Template.Spec.Containers[0].Name = "foo"
+	if hash := computeHash(ds); hash != kubecontroller.ComputeHash(&ds.Spec.Template, ds.Status.CollisionCount) {
+		t.Fatalf("expected the same hash without node pool patches, got %s", hash)
+	}
+	noPatchRevision := newNodePoolRevision(t, ds)
+
+	ds.Spec.NodePoolPatches = []appsv1alpha1.DaemonSetNodePoolPatch{
+		{
+			Name:         "gpu",
+			NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "gpu"}},
+			Patch:        runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"foo","image":"foo/gpu:v1"}]}}`)},
+		},
+		{
+			Name:         "arm",
+			NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "arm"}},
+			Patch:        runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"foo","image":"foo/arm:v1"}]}}`)},
+		},
+	}
+	oldRevision := newNodePoolRevision(t, ds)
+	ds.Spec.NodePoolPatches[0].Patch = runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"foo","image":"foo/gpu:v2"}]}}`)}
+	ds.Generation++
+	curRevision := newNodePoolRevision(t, ds)
+	if oldRevision.Name == curRevision.Name {
+		t.Fatalf("expected different revisions when node pool patches changed")
+	}
+
+	gpuNode := newNode("gpu-node", map[string]string{"pool": "gpu"})
+	armNode := newNode("arm-node", map[string]string{"pool": "arm"})
+	defaultNode := newNode("default-node", nil)
+
+	// new pods are created from the patched templates
+	for node, image := range map[*corev1.Node]string{gpuNode: "foo/gpu:v2", armNode: "foo/arm:v1", defaultNode: "foo/bar"} {
+		if pod := newPodForNode(ds, node); pod.Spec.Containers[0].Image != image {
+			t.Fatalf("expected image %s for new pod on %s, got %s", image, node.Name, pod.Spec.Containers[0].Image)
+		} else if !isPodInNodePool(ds, pod, node) {
+			t.Fatalf("expected new pod on %s in its node pool, got annotations %v", node.Name, pod.Annotations)
+		}
+	}
+
+	// revisions without node pool patches are returned directly
+	if revisions, err := getNodePoolRevisions(gpuNode, noPatchRevision, noPatchRevision); err != nil || revisions[0] != noPatchRevision {
+		t.Fatalf("expected the original revisions, got %v, %v", revisions, err)
+	}
+
+	// the templates are changed only for the gpu node pool
+	for _, tc := range []struct {
+		node      *corev1.Node
+		unchanged bool
+		image     string
+	}{
+		{node: gpuNode, image: "foo/gpu:v2"},
+		{node: armNode, unchanged: true, image: "foo/arm:v1"},
+		{node: defaultNode, unchanged: true, image: "foo/bar"},
+		{node: nil, unchanged: true, image: "foo/bar"},
+	} {
+		revisions, err := getNodePoolRevisions(tc.node, oldRevision, curRevision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unchanged := bytes.Equal(revisions[0].Data.Raw, revisions[1].Data.Raw); unchanged != tc.unchanged {
+			t.Fatalf("expected unchanged %v for node %v, got %v", tc.unchanged, tc.node, unchanged)
+		}
+		if revisions[1].Labels[apps.DefaultDaemonSetUniqueLabelKey] != curRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey] {
+			t.Fatalf("expected the labels of revision to be kept")
+		}
+		template, err := inplaceupdate.GetTemplateFromRevision(revisions[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if template.Spec.Containers[0].Image != tc.image {
+			t.Fatalf("expected image %s, got %s", tc.image, template.Spec.Containers[0].Image)
+		}
+	}
+
+	// the image change of gpu node pool can be updated in place
+	revisions, err := getNodePoolRevisions(gpuNode, oldRevision, curRevision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canInPlace, reasons := inplaceupdate.CheckInPlaceUpdate(revisions[0], revisions[1], getInPlaceUpdateOptions()); !canInPlace {
+		t.Fatalf("expected to update in place, got %v", reasons)
+	}
+}
+
+func newNodePoolDaemonSet() *appsv1alpha1.DaemonSet {
+	ds := newDaemonSet("foo")
+	ds.Spec.Template.Spec.Containers[0].Name = "foo"
+	ds.Spec.NodePoolPatches = []appsv1alpha1.DaemonSetNodePoolPatch{
+		{
+			Name:         "gpu",
+			NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "gpu"}},
+			Patch:        runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"foo","image":"foo/gpu:v1"}]}}`)},
+		},
+		{
+			Name:         "arm",
+			NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "arm"}},
+			Patch:        runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"foo","image":"foo/arm:v1"}]}}`)},
+		},
+	}
+	return ds
+}
+
+func newNodePoolPod(name string, node *corev1.Node, nodePool, hash string) *corev1.Pod {
+	pod := newPod(name, node.Name, map[string]string{apps.DefaultDaemonSetUniqueLabelKey: hash}, nil)
+	pod.Name = name
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: appspub.InPlaceUpdateReady}}
+	if nodePool != "" {
+		pod.Annotations = map[string]string{appsv1alpha1.DaemonSetNodePoolAnnotationKey: nodePool}
+	}
+	return pod
+}
+
+func TestPodsOnNodeMovedBetweenNodePools(t *testing.T) {
+	ds := newNodePoolDaemonSet()
+	oldRevision := newNodePoolRevision(t, ds)
+	ds.Spec.NodePoolPatches[1].Patch = runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"foo","image":"foo/arm:v2"}]}}`)}
+	ds.Generation++
+	curRevision := newNodePoolRevision(t, ds)
+	hash := curRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey]
+
+	dsc, _, _, err := newTestController(ds)
+	if err != nil {
+		t.Fatalf("error creating DaemonSets controller: %v", err)
+	}
+	dsc.inplaceControl = inplaceupdate.NewForTypedClient(dsc.kubeClient, revisionadapter.NewDefaultImpl())
+	// the node created in gpu node pool has been moved to arm node pool
+	node := newNode("node-1", map[string]string{"pool": "arm"})
+	if err := dsc.nodeStore.Add(node); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		nodePool string
+		updated  bool
+	}{
+		{nodePool: "arm", updated: true},
+		{nodePool: "gpu"},
+		{nodePool: ""},
+	} {
+		pod := newNodePoolPod("pod-1", node, tc.nodePool, hash)
+		newPod, oldPod, ok := findUpdatedPodsOnNode(ds, node, []*corev1.Pod{pod}, hash)
+		if !ok || (newPod != nil) != tc.updated || (oldPod != nil) == tc.updated {
+			t.Fatalf("expected pod created for node pool %q updated %v, got new pod %v, old pod %v", tc.nodePool, tc.updated, newPod, oldPod)
+		}
+		if newPod, _, _ := findUpdatedPodsOnNode(ds, nil, []*corev1.Pod{pod}, hash); newPod == nil {
+			t.Fatalf("expected pod created for node pool %q updated without node", tc.nodePool)
+		}
+
+		// the old pod created for another node pool can not be updated in place
+		pod.Labels[apps.DefaultDaemonSetUniqueLabelKey] = oldRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey]
+		canInPlace, reasons := dsc.canPodInPlaceUpdate(ds, pod, curRevision, []*apps.ControllerRevision{oldRevision})
+		if canInPlace != tc.updated {
+			t.Fatalf("expected pod created for node pool %q updated in place %v, got %v", tc.nodePool, tc.updated, reasons)
+		}
+	}
+}
+
+func TestRelabelPodsWithUnchangedTemplate(t *testing.T) {
+	tests := []struct {
+		name            string
+		rollingUpdate   *appsv1alpha1.RollingUpdateDaemonSet
+		expectRelabeled []string
+	}{
+		{
+			name:            "without limit",
+			rollingUpdate:   &appsv1alpha1.RollingUpdateDaemonSet{},
+			expectRelabeled: []string{"arm-1", "arm-2", "arm-3"},
+		},
+		{
+			name:            "partition",
+			rollingUpdate:   &appsv1alpha1.RollingUpdateDaemonSet{Partition: utilpointer.Int32(2)},
+			expectRelabeled: []string{"arm-3"},
+		},
+		{
+			name:          "paused",
+			rollingUpdate: &appsv1alpha1.RollingUpdateDaemonSet{Paused: utilpointer.Bool(true)},
+		},
+		{
+			name: "stages",
+			rollingUpdate: &appsv1alpha1.RollingUpdateDaemonSet{Stages: []appsv1alpha1.DaemonSetUpdateStage{
+				{Name: "gpu", NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "gpu"}}},
+				{Name: "all"},
+			}},
+		},
+		{
+			name: "stages and selector",
+			rollingUpdate: &appsv1alpha1.RollingUpdateDaemonSet{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"canary": "true"}},
+				Stages:   []appsv1alpha1.DaemonSetUpdateStage{{Name: "arm", NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "arm"}}}},
+			},
+			expectRelabeled: []string{"arm-1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ds := newNodePoolDaemonSet()
+			ds.Spec.UpdateStrategy = appsv1alpha1.DaemonSetUpdateStrategy{Type: appsv1alpha1.RollingUpdateDaemonSetStrategyType, RollingUpdate: test.rollingUpdate}
+			oldRevision := newNodePoolRevision(t, ds)
+			ds.Spec.NodePoolPatches[0].Patch = runtime.RawExtension{Raw: []byte(`{"spec":{"containers":[{"name":"foo","image":"foo/gpu:v2"}]}}`)}
+			ds.Generation++
+			curRevision := newNodePoolRevision(t, ds)
+			oldHash := oldRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey]
+
+			dsc, _, client, err := newTestController(ds)
+			if err != nil {
+				t.Fatalf("error creating DaemonSets controller: %v", err)
+			}
+			nodes := []*corev1.Node{
+				newNode("arm-1", map[string]string{"pool": "arm", "canary": "true"}),
+				newNode("arm-2", map[string]string{"pool": "arm"}),
+				newNode("arm-3", map[string]string{"pool": "arm"}),
+				newNode("gpu-1", map[string]string{"pool": "gpu"}),
+			}
+			nodeToDaemonPods := make(map[string][]*corev1.Pod, len(nodes))
+			for _, node := range nodes {
+				if err := dsc.nodeStore.Add(node); err != nil {
+					t.Fatal(err)
+				}
+				pod := newNodePoolPod("pod-"+node.Name, node, node.Labels["pool"], oldHash)
+				if _, err := client.CoreV1().Pods(pod.Namespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+				nodeToDaemonPods[node.Name] = []*corev1.Pod{pod}
+			}
+
+			hash := curRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey]
+			updateStage, _, err := calculateUpdateStage(ds, hash, nodes, nodeToDaemonPods, time.Now())
+			if err != nil {
+				t.Fatal(err)
+			}
+			nodeToPodsToUpdate, err := dsc.filterDaemonPodsToUpdate(ds, nodes, hash, nodeToDaemonPods, updateStage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			relabeled, err := dsc.relabelPodsWithUnchangedTemplate(context.TODO(), ds, nodeToPodsToUpdate, curRevision, []*apps.ControllerRevision{oldRevision})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !relabeled.Equal(sets.NewString(test.expectRelabeled...)) {
+				t.Fatalf("expected relabeled nodes %v, got %v", test.expectRelabeled, relabeled.List())
+			}
+		})
+	}
+}
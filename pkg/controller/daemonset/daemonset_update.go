@@ -22,41 +22,60 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	apps "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
 
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
 	clonesetutils "github.com/openkruise/kruise/pkg/controller/cloneset/utils"
 	"github.com/openkruise/kruise/pkg/util"
 	"github.com/openkruise/kruise/pkg/util/inplaceupdate"
+	"github.com/openkruise/kruise/pkg/util/lifecycle"
+	"github.com/openkruise/kruise/pkg/util/updatestage"
 )
 
 // rollingUpdate identifies the set of old pods to in-place update, delete, or additional pods to create on nodes,
 // remaining within the constraints imposed by the update strategy.
-func (dsc *ReconcileDaemonSet) rollingUpdate(ctx context.Context, ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node, curRevision *apps.ControllerRevision, oldRevisions []*apps.ControllerRevision) error {
+func (dsc *ReconcileDaemonSet) rollingUpdate(ctx context.Context, ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node, curRevision *apps.ControllerRevision, oldRevisions []*apps.ControllerRevision,
+	updateStage *appsv1alpha1.DaemonSetUpdateStageStatus) error {
 	hash := curRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey]
 	nodeToDaemonPods, err := dsc.getNodesToDaemonPods(ctx, ds)
 	if err != nil {
 		return fmt.Errorf("couldn't get node to daemon pod mapping for daemon set %q: %v", ds.Name, err)
 	}
+	nodes := make(map[string]*corev1.Node, len(nodeList))
+	for _, node := range nodeList {
+		nodes[node.Name] = node
+	}
 	maxSurge, maxUnavailable, err := dsc.updatedDesiredNodeCounts(ds, nodeList, nodeToDaemonPods)
 	if err != nil {
 		return fmt.Errorf("couldn't get unavailable numbers: %v", err)
 	}
 
-	// Advanced: filter the pods updated, updating and can update, according to partition and selector
-	nodeToDaemonPods, err = dsc.filterDaemonPodsToUpdate(ds, nodeList, hash, nodeToDaemonPods)
+	// Advanced: filter the pods updated, updating and can update, according to partition, selector and stages
+	nodeToDaemonPods, err = dsc.filterDaemonPodsToUpdate(ds, nodeList, hash, nodeToDaemonPods, updateStage)
 	if err != nil {
 		return fmt.Errorf("failed to filterDaemonPodsToUpdate: %v", err)
 	}
 
+	// Advanced: pods whose effective templates on their nodes are not changed only need to be relabeled
+	relabeledNodes, err := dsc.relabelPodsWithUnchangedTemplate(ctx, ds, nodeToDaemonPods, curRevision, oldRevisions)
+	if err != nil {
+		return fmt.Errorf("failed to relabel pods with unchanged template: %v", err)
+	}
+	for nodeName := range relabeledNodes {
+		delete(nodeToDaemonPods, nodeName)
+	}
+
 	now := dsc.failedPodsBackoff.Clock.Now()
 
 	// When not surging, we delete just enough pods to stay under the maxUnavailable limit, if any
@@ -76,7 +95,7 @@ func (dsc *ReconcileDaemonSet) rollingUpdate(ctx context.Context, ds *appsv1alph
 		var allowedReplacementPods []string
 		var candidatePodsToDelete []string
 		for nodeName, pods := range nodeToDaemonPods {
-			newPod, oldPod, ok := findUpdatedPodsOnNode(ds, pods, hash)
+			newPod, oldPod, ok := findUpdatedPodsOnNode(ds, nodes[nodeName], pods, hash)
 			if !ok {
 				// let the manage loop clean up this node, and treat it as an unavailable node
 				klog.V(3).InfoS("DaemonSet had excess pods on node, skipped to allow the core loop to process", "daemonSet", klog.KObj(ds), "nodeName", nodeName)
@@ -167,7 +186,7 @@ func (dsc *ReconcileDaemonSet) rollingUpdate(ctx context.Context, ds *appsv1alph
 	var numSurge int
 
 	for nodeName, pods := range nodeToDaemonPods {
-		newPod, oldPod, ok := findUpdatedPodsOnNode(ds, pods, hash)
+		newPod, oldPod, ok := findUpdatedPodsOnNode(ds, nodes[nodeName], pods, hash)
 		if !ok {
 			// let the manage loop clean up this node, and treat it as a surge node
 			klog.V(3).InfoS("DaemonSet has excess pods on node, skipping to allow the core loop to process", "daemonSet", klog.KObj(ds), "nodeName", nodeName)
@@ -280,7 +299,8 @@ func GetTemplateGeneration(ds *appsv1alpha1.DaemonSet) (*int64, error) {
 	return &generation, nil
 }
 
-func (dsc *ReconcileDaemonSet) filterDaemonPodsToUpdate(ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node, hash string, nodeToDaemonPods map[string][]*corev1.Pod) (map[string][]*corev1.Pod, error) {
+func (dsc *ReconcileDaemonSet) filterDaemonPodsToUpdate(ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node, hash string, nodeToDaemonPods map[string][]*corev1.Pod,
+	updateStage *appsv1alpha1.DaemonSetUpdateStageStatus) (map[string][]*corev1.Pod, error) {
 	existingNodes := sets.NewString()
 	for _, node := range nodeList {
 		existingNodes.Insert(node.Name)
@@ -291,7 +311,16 @@ func (dsc *ReconcileDaemonSet) filterDaemonPodsToUpdate(ds *appsv1alpha1.DaemonS
 		}
 	}
 
-	nodeNames, err := dsc.filterDaemonPodsNodeToUpdate(ds, hash, nodeToDaemonPods)
+	// Advanced: only nodes in the current stage can be updated, and none of them when the stage is soaking or paused
+	var stageNodes sets.String
+	if updateStage != nil {
+		var err error
+		if stageNodes, err = getNodesToUpdateInStage(ds, updateStage, hash, getDesiredNodes(ds, nodeList), nodeToDaemonPods); err != nil {
+			return nil, err
+		}
+	}
+
+	nodeNames, err := dsc.filterDaemonPodsNodeToUpdate(ds, hash, nodeToDaemonPods, stageNodes)
 	if err != nil {
 		return nil, err
 	}
@@ -303,7 +332,9 @@ func (dsc *ReconcileDaemonSet) filterDaemonPodsToUpdate(ds *appsv1alpha1.DaemonS
 	return ret, nil
 }
 
-func (dsc *ReconcileDaemonSet) filterDaemonPodsNodeToUpdate(ds *appsv1alpha1.DaemonSet, hash string, nodeToDaemonPods map[string][]*corev1.Pod) ([]string, error) {
+// filterDaemonPodsNodeToUpdate returns the names of nodes whose pods have been updated, are updating or can be updated,
+// according to partition and selector. If stageNodes is not nil, only pods on these nodes can be updated.
+func (dsc *ReconcileDaemonSet) filterDaemonPodsNodeToUpdate(ds *appsv1alpha1.DaemonSet, hash string, nodeToDaemonPods map[string][]*corev1.Pod, stageNodes sets.String) ([]string, error) {
 	var err error
 	var partition int32
 	var selector labels.Selector
@@ -329,7 +360,11 @@ func (dsc *ReconcileDaemonSet) filterDaemonPodsNodeToUpdate(ds *appsv1alpha1.Dae
 	for i := len(allNodeNames) - 1; i >= 0; i-- {
 		nodeName := allNodeNames[i]
 
-		newPod, oldPod, ok := findUpdatedPodsOnNode(ds, nodeToDaemonPods[nodeName], hash)
+		node, err := dsc.getNode(nodeName)
+		if err != nil {
+			return nil, err
+		}
+		newPod, oldPod, ok := findUpdatedPodsOnNode(ds, node, nodeToDaemonPods[nodeName], hash)
 		if !ok || newPod != nil {
 			updated = append(updated, nodeName)
 			continue
@@ -338,6 +373,9 @@ func (dsc *ReconcileDaemonSet) filterDaemonPodsNodeToUpdate(ds *appsv1alpha1.Dae
 			updating = append(updating, nodeName)
 			continue
 		}
+		if stageNodes != nil && !stageNodes.Has(nodeName) {
+			continue
+		}
 
 		if selector != nil {
 			node, err := dsc.nodeLister.Get(nodeName)
@@ -367,15 +405,202 @@ func (dsc *ReconcileDaemonSet) filterDaemonPodsNodeToUpdate(ds *appsv1alpha1.Dae
 	return sorted, nil
 }
 
+func getUpdateStages(ds *appsv1alpha1.DaemonSet) []appsv1alpha1.DaemonSetUpdateStage {
+	if ds.Spec.UpdateStrategy.Type != appsv1alpha1.RollingUpdateDaemonSetStrategyType || ds.Spec.UpdateStrategy.RollingUpdate == nil {
+		return nil
+	}
+	return ds.Spec.UpdateStrategy.RollingUpdate.Stages
+}
+
+// getDesiredNodes returns the nodes that should run the daemon pod.
+func getDesiredNodes(ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node) []*corev1.Node {
+	var desiredNodes []*corev1.Node
+	for _, node := range nodeList {
+		if shouldRun, _ := nodeShouldRunDaemonPod(node, ds); shouldRun {
+			desiredNodes = append(desiredNodes, node)
+		}
+	}
+	return desiredNodes
+}
+
+// getStageNodes returns the names of nodes to update in the stages up to the given index.
+// In each stage, the nodes selected by its nodeSelector are sorted with the updated ones first, then by names in reverse order,
+// which is the same order as partition, and the first replicas of them are chosen. So the nodes keep stable during the update.
+func getStageNodes(stages []appsv1alpha1.DaemonSetUpdateStage, index int, desiredNodes []*corev1.Node, isUpdated func(nodeName string) bool) (sets.String, error) {
+	nodeNames := sets.NewString()
+	for i := 0; i <= index && i < len(stages); i++ {
+		stage := &stages[i]
+		selector := labels.Everything()
+		if stage.NodeSelector != nil {
+			var err error
+			if selector, err = util.ValidatedLabelSelectorAsSelector(stage.NodeSelector); err != nil {
+				return nil, err
+			}
+		}
+
+		var selected []string
+		for _, node := range desiredNodes {
+			if selector.Matches(labels.Set(node.Labels)) {
+				selected = append(selected, node.Name)
+			}
+		}
+		count := len(selected)
+		if stage.Replicas != nil {
+			var err error
+			if count, err = intstrutil.GetScaledValueFromIntOrPercent(stage.Replicas, len(selected), true); err != nil {
+				return nil, fmt.Errorf("invalid value for replicas of stage %d: %v", i, err)
+			}
+			if count > len(selected) {
+				count = len(selected)
+			}
+		}
+
+		sort.SliceStable(selected, func(a, b int) bool {
+			if updatedA, updatedB := isUpdated(selected[a]), isUpdated(selected[b]); updatedA != updatedB {
+				return updatedA
+			}
+			return selected[a] > selected[b]
+		})
+		nodeNames.Insert(selected[:count]...)
+	}
+	return nodeNames, nil
+}
+
+// getUpdateStage calculates the status of the staged rolling update with the current pods,
+// and requeues the DaemonSet after the duration left to soak the current stage.
+// It returns nil if there is no stage.
+func (dsc *ReconcileDaemonSet) getUpdateStage(ctx context.Context, ds *appsv1alpha1.DaemonSet, nodeList []*corev1.Node, hash string) (*appsv1alpha1.DaemonSetUpdateStageStatus, error) {
+	if len(getUpdateStages(ds)) == 0 {
+		return nil, nil
+	}
+	nodeToDaemonPods, err := dsc.getNodesToDaemonPods(ctx, ds)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get node to daemon pod mapping for DaemonSet %q: %v", ds.Name, err)
+	}
+	updateStage, soakLeft, err := calculateUpdateStage(ds, hash, getDesiredNodes(ds, nodeList), nodeToDaemonPods, dsc.failedPodsBackoff.Clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	if soakLeft > 0 {
+		durationStore.Push(keyFunc(ds), soakLeft)
+	}
+	return updateStage, nil
+}
+
+// calculateUpdateStage calculates the status of the staged rolling update,
+// and returns the duration left to soak the current stage if it is soaking.
+func calculateUpdateStage(ds *appsv1alpha1.DaemonSet, hash string, desiredNodes []*corev1.Node, nodeToDaemonPods map[string][]*corev1.Pod,
+	now time.Time) (*appsv1alpha1.DaemonSetUpdateStageStatus, time.Duration, error) {
+	stages := getUpdateStages(ds)
+	if len(stages) == 0 {
+		return nil, 0, nil
+	}
+	oldStatus := ds.Status.UpdateStage
+	if oldStatus != nil && oldStatus.Revision != hash {
+		oldStatus = nil
+	}
+
+	nodes := make(map[string]*corev1.Node, len(desiredNodes))
+	for _, node := range desiredNodes {
+		nodes[node.Name] = node
+	}
+	isUpdated := func(nodeName string) bool {
+		newPod, _, ok := findUpdatedPodsOnNode(ds, nodes[nodeName], nodeToDaemonPods[nodeName], hash)
+		return ok && newPod != nil
+	}
+	isAvailable := func(nodeName string) bool {
+		newPod, _, ok := findUpdatedPodsOnNode(ds, nodes[nodeName], nodeToDaemonPods[nodeName], hash)
+		return ok && newPod != nil && podutil.IsPodAvailable(newPod, ds.Spec.MinReadySeconds, metav1.Time{Time: now})
+	}
+
+	stageStatus, err := updatestage.Calculate(len(stages), func(i int) (*updatestage.Stage, error) {
+		nodeNames, err := getStageNodes(stages, i, desiredNodes, isUpdated)
+		if err != nil {
+			return nil, err
+		}
+		stage := &updatestage.Stage{
+			AllUpdated:   true,
+			AllAvailable: true,
+			SoakSeconds:  stages[i].SoakSeconds,
+			Paused:       stages[i].Pause && !isUpdateStageResumed(ds, hash, i),
+		}
+		for nodeName := range nodeNames {
+			if !isUpdated(nodeName) {
+				stage.AllUpdated = false
+			} else if !isAvailable(nodeName) {
+				stage.AllAvailable = false
+			}
+		}
+		if oldStatus != nil {
+			stage.Passed = oldStatus.CurrentStage > int32(i) || oldStatus.Phase == appsv1alpha1.DaemonSetUpdateStagePhaseCompleted
+			if oldStatus.CurrentStage == int32(i) {
+				stage.SoakStartTime = oldStatus.SoakStartTime
+			}
+		}
+		return stage, nil
+	}, metav1.Time{Time: now})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// the last stage is kept as the current one once all stages have been completed
+	current := stageStatus.Index
+	if current >= len(stages) {
+		current = len(stages) - 1
+	}
+	return &appsv1alpha1.DaemonSetUpdateStageStatus{
+		Revision:      hash,
+		CurrentStage:  int32(current),
+		Name:          stages[current].Name,
+		Phase:         appsv1alpha1.DaemonSetUpdateStagePhase(stageStatus.Phase),
+		SoakStartTime: stageStatus.SoakStartTime,
+	}, stageStatus.SoakLeft, nil
+}
+
+// isUpdateStageResumed checks whether the paused stage of the rolling update to the revision is resumed by annotation.
+func isUpdateStageResumed(ds *appsv1alpha1.DaemonSet, hash string, index int) bool {
+	return ds.Annotations[appsv1alpha1.DaemonSetResumeStageKey] == fmt.Sprintf("%s/%d", hash, index)
+}
+
+// getNodesToUpdateInStage returns the names of nodes that can be updated in the current stage.
+// It returns nil if all stages have been completed, which means no limit to the nodes to update.
+func getNodesToUpdateInStage(ds *appsv1alpha1.DaemonSet, status *appsv1alpha1.DaemonSetUpdateStageStatus, hash string,
+	desiredNodes []*corev1.Node, nodeToDaemonPods map[string][]*corev1.Pod) (sets.String, error) {
+	if status == nil {
+		return nil, nil
+	}
+	switch status.Phase {
+	case appsv1alpha1.DaemonSetUpdateStagePhaseUpdating:
+		nodes := make(map[string]*corev1.Node, len(desiredNodes))
+		for _, node := range desiredNodes {
+			nodes[node.Name] = node
+		}
+		return getStageNodes(getUpdateStages(ds), int(status.CurrentStage), desiredNodes, func(nodeName string) bool {
+			newPod, _, ok := findUpdatedPodsOnNode(ds, nodes[nodeName], nodeToDaemonPods[nodeName], hash)
+			return ok && newPod != nil
+		})
+	case appsv1alpha1.DaemonSetUpdateStagePhaseSoaking, appsv1alpha1.DaemonSetUpdateStagePhasePaused:
+		return sets.NewString(), nil
+	}
+	return nil, nil
+}
+
 func getInPlaceUpdateOptions() *inplaceupdate.UpdateOptions {
 	return &inplaceupdate.UpdateOptions{GetRevision: func(rev *apps.ControllerRevision) string {
 		return rev.Labels[apps.DefaultDaemonSetUniqueLabelKey]
 	}}
 }
 
-func (dsc *ReconcileDaemonSet) canPodInPlaceUpdate(pod *corev1.Pod, curRevision *apps.ControllerRevision, oldRevisions []*apps.ControllerRevision) bool {
+func getInPlaceUpdateStrategy(ds *appsv1alpha1.DaemonSet) *appspub.InPlaceUpdateStrategy {
+	if ds.Spec.UpdateStrategy.RollingUpdate == nil {
+		return nil
+	}
+	return ds.Spec.UpdateStrategy.RollingUpdate.InPlaceUpdateStrategy
+}
+
+func (dsc *ReconcileDaemonSet) canPodInPlaceUpdate(ds *appsv1alpha1.DaemonSet, pod *corev1.Pod, curRevision *apps.ControllerRevision, oldRevisions []*apps.ControllerRevision) (bool, []inplaceupdate.NotInPlaceUpdateReason) {
 	if !ContainsReadinessGate(pod) {
-		return false
+		return false, []inplaceupdate.NotInPlaceUpdateReason{{Path: "/spec/readinessGates", Message: fmt.Sprintf("%s readiness gate not found", appspub.InPlaceUpdateReady)}}
 	}
 	var oldRevision *apps.ControllerRevision
 	for _, r := range oldRevisions {
@@ -385,16 +610,77 @@ func (dsc *ReconcileDaemonSet) canPodInPlaceUpdate(pod *corev1.Pod, curRevision
 		}
 	}
 	if oldRevision == nil {
-		return false
+		return false, []inplaceupdate.NotInPlaceUpdateReason{{Message: "old revision not found"}}
+	}
+	node, err := dsc.getNode(pod.Spec.NodeName)
+	if err != nil {
+		return false, []inplaceupdate.NotInPlaceUpdateReason{{Message: err.Error()}}
+	}
+	if !isPodInNodePool(ds, pod, node) {
+		return false, []inplaceupdate.NotInPlaceUpdateReason{{Path: "/metadata/annotations", Message: "node moved to another node pool"}}
+	}
+	nodePoolRevisions, err := getNodePoolRevisions(node, oldRevision, curRevision)
+	if err != nil {
+		return false, []inplaceupdate.NotInPlaceUpdateReason{{Message: err.Error()}}
+	}
+	return dsc.inplaceControl.CanUpdateInPlace(nodePoolRevisions[0], nodePoolRevisions[1], getInPlaceUpdateOptions())
+}
+
+// getInPlaceUpdateCondition returns the InPlaceUpdateNotPossible condition if pods of the last revision
+// can not be updated in place to the current revision, or nil if the condition should not be reported.
+func getInPlaceUpdateCondition(ds *appsv1alpha1.DaemonSet, curRevision *apps.ControllerRevision, oldRevisions []*apps.ControllerRevision) *apps.DaemonSetCondition {
+	if ds.Spec.UpdateStrategy.Type != appsv1alpha1.RollingUpdateDaemonSetStrategyType || ds.Spec.UpdateStrategy.RollingUpdate == nil ||
+		ds.Spec.UpdateStrategy.RollingUpdate.Type != appsv1alpha1.InplaceRollingUpdateType || len(oldRevisions) == 0 {
+		return nil
+	}
+	// no need to report after all pods have been updated
+	if ds.Status.DaemonSetHash == curRevision.Labels[apps.DefaultDaemonSetUniqueLabelKey] &&
+		ds.Status.UpdatedNumberScheduled >= ds.Status.DesiredNumberScheduled {
+		return nil
+	}
+
+	lastRevision := oldRevisions[0]
+	for _, r := range oldRevisions {
+		if r.Revision > lastRevision.Revision {
+			lastRevision = r
+		}
+	}
+	// only check the templates without node pool patches, and the patched templates are checked for each pod
+	var canInPlace bool
+	var reasons []inplaceupdate.NotInPlaceUpdateReason
+	if revisions, err := getNodePoolRevisions(nil, lastRevision, curRevision); err != nil {
+		reasons = []inplaceupdate.NotInPlaceUpdateReason{{Message: err.Error()}}
+	} else {
+		canInPlace, reasons = inplaceupdate.CheckInPlaceUpdate(revisions[0], revisions[1], getInPlaceUpdateOptions())
+	}
+	if canInPlace {
+		return nil
+	}
+
+	msg := fmt.Sprintf("revision %s can not be updated in place: %s", curRevision.Name, inplaceupdate.FormatNotInPlaceUpdateReasons(reasons))
+	if oldCondition := getDaemonSetCondition(ds.Status, appsv1alpha1.DaemonSetConditionInPlaceUpdateNotPossible); oldCondition != nil && oldCondition.Message == msg {
+		return oldCondition.DeepCopy()
+	}
+	return &apps.DaemonSetCondition{
+		Type:               appsv1alpha1.DaemonSetConditionInPlaceUpdateNotPossible,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             appspub.InPlaceUpdateFallbackToRecreateReason,
+		Message:            msg,
 	}
-	return dsc.inplaceControl.CanUpdateInPlace(oldRevision, curRevision, getInPlaceUpdateOptions())
 }
 
 func (dsc *ReconcileDaemonSet) inPlaceUpdatePods(ds *appsv1alpha1.DaemonSet, podNames []string, curRevision *apps.ControllerRevision, oldRevisions []*apps.ControllerRevision) (podsNeedDelete []string, err error) {
 	var podsToUpdate []*corev1.Pod
 	for _, name := range podNames {
 		pod, err := dsc.podLister.Pods(ds.Namespace).Get(name)
-		if err != nil || !dsc.canPodInPlaceUpdate(pod, curRevision, oldRevisions) {
+		if err != nil {
+			podsNeedDelete = append(podsNeedDelete, name)
+			continue
+		}
+		if canInPlace, reasons := dsc.canPodInPlaceUpdate(ds, pod, curRevision, oldRevisions); !canInPlace {
+			dsc.eventRecorder.Eventf(pod, corev1.EventTypeWarning, appspub.InPlaceUpdateFallbackToRecreateReason,
+				"could not update in-place to revision %s, fall back to recreate: %s", curRevision.Name, inplaceupdate.FormatNotInPlaceUpdateReasons(reasons))
 			podsNeedDelete = append(podsNeedDelete, name)
 			continue
 		}
@@ -423,7 +709,19 @@ func (dsc *ReconcileDaemonSet) inPlaceUpdatePods(ds *appsv1alpha1.DaemonSet, pod
 					break
 				}
 			}
-			res := dsc.inplaceControl.Update(pod, oldRevision, curRevision, getInPlaceUpdateOptions())
+			nodePoolRevisions, err := dsc.getPodNodePoolRevisions(pod, oldRevision, curRevision)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			opts := getInPlaceUpdateOptions()
+			if strategy := getInPlaceUpdateStrategy(ds); strategy != nil {
+				opts.GracePeriodSeconds = strategy.GracePeriodSeconds
+			}
+			if ds.Spec.Lifecycle != nil && ds.Spec.Lifecycle.PostInPlaceUpdate != nil {
+				opts.AdditionalFuncs = append(opts.AdditionalFuncs, lifecycle.SetPodHook(ds.Spec.Lifecycle.PostInPlaceUpdate))
+			}
+			res := dsc.inplaceControl.Update(pod, nodePoolRevisions[0], nodePoolRevisions[1], opts)
 			if res.InPlaceUpdate {
 				if res.UpdateErr == nil {
 					dsc.eventRecorder.Eventf(ds, corev1.EventTypeNormal, "SuccessfulUpdatePodInPlace", "successfully update pod %s in-place", pod.Name)
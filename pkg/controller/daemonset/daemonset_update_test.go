@@ -27,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
@@ -873,7 +874,7 @@ func TestFilterDaemonPodsNodeToUpdate(t *testing.T) {
 			Type:          appsv1alpha1.RollingUpdateDaemonSetStrategyType,
 			RollingUpdate: test.rolling,
 		}}}
-		got, err := dsc.filterDaemonPodsNodeToUpdate(ds, test.hash, test.nodeToDaemonPods)
+		got, err := dsc.filterDaemonPodsNodeToUpdate(ds, test.hash, test.nodeToDaemonPods, nil)
 		if err != nil {
 			t.Fatalf("failed to call filterDaemonPodsNodeToUpdate: %v", err)
 		}
@@ -888,3 +889,144 @@ func TestFilterDaemonPodsNodeToUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateUpdateStage(t *testing.T) {
+	now := time.Now()
+	stages := []appsv1alpha1.DaemonSetUpdateStage{
+		{
+			Name:         "canary",
+			NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "canary"}},
+			Replicas:     &intstr.IntOrString{Type: intstr.Int, IntVal: 1},
+		},
+		{
+			Name:        "half",
+			Replicas:    &intstr.IntOrString{Type: intstr.String, StrVal: "50%"},
+			SoakSeconds: 60,
+			Pause:       true,
+		},
+	}
+	nodes := []*corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "n1", Labels: map[string]string{"pool": "canary"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "n2", Labels: map[string]string{"pool": "canary"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "n3"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "n4"}},
+	}
+	newNodeToDaemonPods := func(updatedNodes ...string) map[string][]*corev1.Pod {
+		updated := sets.NewString(updatedNodes...)
+		nodeToDaemonPods := make(map[string][]*corev1.Pod, len(nodes))
+		for _, node := range nodes {
+			hash := "v1"
+			if updated.Has(node.Name) {
+				hash = "v2"
+			}
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{apps.DefaultDaemonSetUniqueLabelKey: hash}}}
+			markPodReady(pod)
+			nodeToDaemonPods[node.Name] = []*corev1.Pod{pod}
+		}
+		return nodeToDaemonPods
+	}
+	soakStartTime := metav1.NewTime(now.Add(-61 * time.Second))
+
+	tests := []struct {
+		name             string
+		pause            bool
+		resume           string
+		oldStatus        *appsv1alpha1.DaemonSetUpdateStageStatus
+		nodeToDaemonPods map[string][]*corev1.Pod
+		expectStatus     *appsv1alpha1.DaemonSetUpdateStageStatus
+		expectSoak       time.Duration
+		expectNodes      []string
+	}{
+		{
+			name:             "update canary nodes first",
+			pause:            true,
+			nodeToDaemonPods: newNodeToDaemonPods(),
+			expectStatus:     &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 0, Name: "canary", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseUpdating},
+			expectNodes:      []string{"n2"},
+		},
+		{
+			name:             "move to the next stage with updated nodes counted",
+			pause:            true,
+			nodeToDaemonPods: newNodeToDaemonPods("n2"),
+			expectStatus:     &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseUpdating},
+			expectNodes:      []string{"n2", "n4"},
+		},
+		{
+			name:             "soak the stage",
+			pause:            true,
+			oldStatus:        &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseUpdating},
+			nodeToDaemonPods: newNodeToDaemonPods("n2", "n4"),
+			expectStatus:     &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseSoaking, SoakStartTime: &metav1.Time{Time: now}},
+			expectSoak:       60 * time.Second,
+			expectNodes:      []string{},
+		},
+		{
+			name:             "pause after soaked",
+			pause:            true,
+			oldStatus:        &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseSoaking, SoakStartTime: &soakStartTime},
+			nodeToDaemonPods: newNodeToDaemonPods("n2", "n4"),
+			expectStatus:     &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhasePaused, SoakStartTime: &soakStartTime},
+			expectNodes:      []string{},
+		},
+		{
+			name:             "not resumed for another revision",
+			pause:            true,
+			resume:           "v1/1",
+			oldStatus:        &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhasePaused, SoakStartTime: &soakStartTime},
+			nodeToDaemonPods: newNodeToDaemonPods("n2", "n4"),
+			expectStatus:     &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhasePaused, SoakStartTime: &soakStartTime},
+			expectNodes:      []string{},
+		},
+		{
+			name:             "complete after resumed",
+			pause:            true,
+			resume:           "v2/1",
+			oldStatus:        &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhasePaused, SoakStartTime: &soakStartTime},
+			nodeToDaemonPods: newNodeToDaemonPods("n2", "n4"),
+			expectStatus:     &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseCompleted},
+		},
+		{
+			name:             "restart stages for a new revision",
+			oldStatus:        &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v1", CurrentStage: 1, Name: "half", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseCompleted},
+			nodeToDaemonPods: newNodeToDaemonPods(),
+			expectStatus:     &appsv1alpha1.DaemonSetUpdateStageStatus{Revision: "v2", CurrentStage: 0, Name: "canary", Phase: appsv1alpha1.DaemonSetUpdateStagePhaseUpdating},
+			expectNodes:      []string{"n2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ds := newDaemonSet("ds")
+			ds.Spec.UpdateStrategy = newUpdateUnavailable(intstr.FromInt(1))
+			ds.Spec.UpdateStrategy.RollingUpdate.Stages = append([]appsv1alpha1.DaemonSetUpdateStage{}, stages...)
+			ds.Spec.UpdateStrategy.RollingUpdate.Stages[1].Pause = test.pause
+			if test.resume != "" {
+				ds.Annotations = map[string]string{appsv1alpha1.DaemonSetResumeStageKey: test.resume}
+			}
+			ds.Status.UpdateStage = test.oldStatus
+
+			status, soak, err := calculateUpdateStage(ds, "v2", nodes, test.nodeToDaemonPods, now)
+			if err != nil {
+				t.Fatalf("failed to calculate update stage: %v", err)
+			}
+			if !reflect.DeepEqual(status, test.expectStatus) {
+				t.Fatalf("expected status %+v, got %+v", test.expectStatus, status)
+			}
+			if soak != test.expectSoak {
+				t.Fatalf("expected soak duration %v, got %v", test.expectSoak, soak)
+			}
+
+			stageNodes, err := getNodesToUpdateInStage(ds, status, "v2", nodes, test.nodeToDaemonPods)
+			if err != nil {
+				t.Fatalf("failed to get nodes to update in stage: %v", err)
+			}
+			if test.expectNodes == nil {
+				if stageNodes != nil {
+					t.Fatalf("expected no limit of nodes, got %v", stageNodes.List())
+				}
+			} else if !stageNodes.Equal(sets.NewString(test.expectNodes...)) {
+				t.Fatalf("expected nodes %v, got %v", test.expectNodes, stageNodes.List())
+			}
+		})
+	}
+}
@@ -49,6 +49,8 @@ var (
 type newPodForDS struct {
 	generation int64
 	pod        *corev1.Pod
+	// nodePoolPods are the new pods patched by node pool patches, it is map[nodePoolName]*corev1.Pod
+	nodePoolPods map[string]*corev1.Pod
 }
 
 func loadNewPodForDS(ds *appsv1alpha1.DaemonSet) *corev1.Pod {
@@ -99,7 +101,7 @@ func nodeInSameCondition(old []corev1.NodeCondition, cur []corev1.NodeCondition)
 //     Returns true when a daemonset should continue running on a node if a daemonset pod is already
 //     running on that node.
 func nodeShouldRunDaemonPod(node *corev1.Node, ds *appsv1alpha1.DaemonSet) (bool, bool) {
-	pod := NewPod(ds, node.Name)
+	pod := newPodForNode(ds, node)
 
 	// If the daemon set specifies a node name, check that it matches with node.Name.
 	if !(ds.Spec.Template.Spec.NodeName == "" || ds.Spec.Template.Spec.NodeName == node.Name) {
@@ -310,7 +312,8 @@ func getUnscheduledPodsWithoutNode(runningNodesList []*corev1.Node, nodeToDaemon
 // is at most one of each old and new pods, or false if there are multiples. We can skip
 // processing the particular node in those scenarios and let the manage loop prune the
 // excess pods for our next time around.
-func findUpdatedPodsOnNode(ds *appsv1alpha1.DaemonSet, podsOnNode []*corev1.Pod, hash string) (newPod, oldPod *corev1.Pod, ok bool) {
+// Advanced: pods created for another node pool than the one the node belongs to are regarded as old pods.
+func findUpdatedPodsOnNode(ds *appsv1alpha1.DaemonSet, node *corev1.Node, podsOnNode []*corev1.Pod, hash string) (newPod, oldPod *corev1.Pod, ok bool) {
 	for _, pod := range podsOnNode {
 		if pod.DeletionTimestamp != nil {
 			continue
@@ -319,7 +322,7 @@ func findUpdatedPodsOnNode(ds *appsv1alpha1.DaemonSet, podsOnNode []*corev1.Pod,
 		if err != nil {
 			generation = nil
 		}
-		if util.IsPodUpdated(pod, hash, generation) {
+		if util.IsPodUpdated(pod, hash, generation) && isPodInNodePool(ds, pod, node) {
 			if newPod != nil {
 				return nil, nil, false
 			}
@@ -371,3 +374,28 @@ func podAvailableWaitingTime(pod *corev1.Pod, minReadySeconds int32, now time.Ti
 	}
 	return minReadySecondsDuration - now.Sub(c.LastTransitionTime.Time)
 }
+
+// getDaemonSetCondition returns the condition with the provided type.
+func getDaemonSetCondition(status appsv1alpha1.DaemonSetStatus, condType apps.DaemonSetConditionType) *apps.DaemonSetCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setDaemonSetCondition returns the conditions with the one of provided type replaced by condition,
+// or removed if condition is nil.
+func setDaemonSetCondition(conditions []apps.DaemonSetCondition, condType apps.DaemonSetConditionType, condition *apps.DaemonSetCondition) []apps.DaemonSetCondition {
+	var newConditions []apps.DaemonSetCondition
+	for _, c := range conditions {
+		if c.Type != condType {
+			newConditions = append(newConditions, c)
+		}
+	}
+	if condition != nil {
+		newConditions = append(newConditions, *condition)
+	}
+	return newConditions
+}
@@ -139,5 +139,11 @@ func (r *ReconcileSidecarSet) Reconcile(_ context.Context, request reconcile.Req
 	}
 
 	klog.V(3).InfoS("Began to process sidecarset for reconcile", "sidecarSet", klog.KObj(sidecarSet))
-	return r.processor.UpdateSidecarSet(sidecarSet)
+	result, err := r.processor.UpdateSidecarSet(sidecarSet)
+	// the events of pods not injected are not watched, so resync the injection preview periodically
+	if err == nil && sidecarSet.Spec.InjectionStrategy.Preview &&
+		(result.RequeueAfter == 0 || result.RequeueAfter > injectionPreviewResyncPeriod) {
+		result.RequeueAfter = injectionPreviewResyncPeriod
+	}
+	return result, err
 }
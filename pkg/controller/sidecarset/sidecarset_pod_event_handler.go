@@ -187,5 +187,12 @@ func isPodConsistentChanged(oldPod, newPod *corev1.Pod, sidecarSet *appsv1alpha1
 		return true, 0
 	}
 
+	// If the resources of pod's containers changed, and the sidecar containers derive their resources from them, should reconcile.
+	if isSidecarResourcesResizable(sidecarSet) && isPodContainerResourcesChanged(oldPod, newPod) {
+		klog.V(3).InfoS("Pod's container resources changed and SidecarSet derives sidecar resources from them, and reconcile SidecarSet",
+			"pod", klog.KObj(newPod), "sidecarSet", klog.KObj(sidecarSet))
+		return true, 0
+	}
+
 	return false, enqueueDelayTime
 }
@@ -0,0 +1,110 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecarset
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/control/sidecarcontrol"
+	utilclient "github.com/openkruise/kruise/pkg/util/client"
+	"github.com/openkruise/kruise/pkg/webhook/pod/mutating"
+)
+
+const (
+	// maxInjectionPreviewPods is the max number of pods recorded in status.injectionPreview.
+	maxInjectionPreviewPods = 100
+	// injectionPreviewResyncPeriod is the period to resync the injection preview of SidecarSet.
+	injectionPreviewResyncPeriod = time.Minute
+)
+
+// previewInjection previews the injection of sidecarSet into the active pods selected by it,
+// with the same code of the pod mutating webhook, but without touching any pod.
+func (p *Processor) previewInjection(sidecarSet *appsv1alpha1.SidecarSet) (*appsv1alpha1.SidecarSetInjectionPreview, error) {
+	selectedPods, err := p.getSidecarSetSelectedPods(sidecarSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// the other SidecarSets may be injected into the same pods, which are checked for conflicts
+	sidecarSetList := &appsv1alpha1.SidecarSetList{}
+	if err = p.Client.List(context.TODO(), sidecarSetList, utilclient.DisableDeepCopy); err != nil {
+		return nil, err
+	}
+
+	preview := &appsv1alpha1.SidecarSetInjectionPreview{}
+	var podPreviews []appsv1alpha1.SidecarSetPodInjectionPreview
+	for _, pod := range selectedPods {
+		if !sidecarcontrol.IsActivePod(pod) {
+			continue
+		}
+		var others []*appsv1alpha1.SidecarSet
+		for i := range sidecarSetList.Items {
+			other := &sidecarSetList.Items[i]
+			if other.Name == sidecarSet.Name || other.Spec.InjectionStrategy.Paused {
+				continue
+			}
+			if matched, _ := sidecarcontrol.PodMatchedSidecarSet(p.Client, pod, other); matched {
+				others = append(others, other)
+			}
+		}
+		injectedPod, conflicts := mutating.PreviewSidecarSetInjection(sidecarSet, others, pod)
+		preview.MatchedPods++
+		if len(conflicts) > 0 {
+			preview.ConflictedPods++
+		}
+		podPreviews = append(podPreviews, newPodInjectionPreview(injectedPod, conflicts))
+	}
+
+	// the conflicted pods are recorded first
+	sort.SliceStable(podPreviews, func(i, j int) bool {
+		if conflictedI, conflictedJ := len(podPreviews[i].Conflicts) > 0, len(podPreviews[j].Conflicts) > 0; conflictedI != conflictedJ {
+			return conflictedI
+		}
+		if podPreviews[i].Namespace != podPreviews[j].Namespace {
+			return podPreviews[i].Namespace < podPreviews[j].Namespace
+		}
+		return podPreviews[i].Name < podPreviews[j].Name
+	})
+	if len(podPreviews) > maxInjectionPreviewPods {
+		podPreviews = podPreviews[:maxInjectionPreviewPods]
+	}
+	preview.Pods = podPreviews
+	return preview, nil
+}
+
+func newPodInjectionPreview(pod *corev1.Pod, conflicts []string) appsv1alpha1.SidecarSetPodInjectionPreview {
+	podPreview := appsv1alpha1.SidecarSetPodInjectionPreview{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Conflicts: conflicts,
+	}
+	for _, c := range pod.Spec.InitContainers {
+		podPreview.InitContainers = append(podPreview.InitContainers, c.Name)
+	}
+	for _, c := range pod.Spec.Containers {
+		podPreview.Containers = append(podPreview.Containers, c.Name)
+	}
+	for _, v := range pod.Spec.Volumes {
+		podPreview.Volumes = append(podPreview.Volumes, v.Name)
+	}
+	return podPreview
+}
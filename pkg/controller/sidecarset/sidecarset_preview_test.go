@@ -0,0 +1,63 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecarset
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openkruise/kruise/pkg/control/sidecarcontrol"
+)
+
+func TestPreviewInjection(t *testing.T) {
+	sidecarSet := sidecarSetDemo.DeepCopy()
+	other := sidecarSetDemo.DeepCopy()
+	other.Name = "other"
+	paused := sidecarSetDemo.DeepCopy()
+	paused.Name = "paused"
+	paused.Spec.InjectionStrategy.Paused = true
+
+	pod1 := podDemo.DeepCopy()
+	// the pod which can't be injected by webhook
+	pod2 := podDemo.DeepCopy()
+	pod2.Name = "test-pod-2"
+	pod2.Annotations[sidecarcontrol.SidecarSetHashAnnotation] = "invalid"
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sidecarSet, other, paused, pod1, pod2).Build()
+	processor := NewSidecarSetProcessor(fakeClient, record.NewFakeRecorder(10))
+	preview, err := processor.previewInjection(sidecarSet)
+	if err != nil {
+		t.Fatalf("preview injection failed: %v", err)
+	}
+	if preview.MatchedPods != 2 || preview.ConflictedPods != 2 || len(preview.Pods) != 2 {
+		t.Fatalf("expect 2 matched and conflicted pods, but got %v", preview)
+	}
+
+	otherConflict := "container test-sidecar already exist in other"
+	if preview.Pods[0].Name != pod1.Name || !reflect.DeepEqual(preview.Pods[0].Conflicts, []string{otherConflict}) {
+		t.Fatalf("expect pod %s conflicts with other sidecarSet, but got %v", pod1.Name, preview.Pods[0])
+	}
+	conflicts := preview.Pods[1].Conflicts
+	if preview.Pods[1].Name != pod2.Name || len(conflicts) != 2 || conflicts[0] != otherConflict ||
+		!strings.HasPrefix(conflicts[1], "failed to build sidecars") {
+		t.Fatalf("expect pod %s failed to build sidecars, but got %v", pod2.Name, preview.Pods[1])
+	}
+}
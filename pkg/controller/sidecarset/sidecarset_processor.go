@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -71,8 +72,17 @@ func (p *Processor) UpdateSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) (recon
 	if !control.IsActiveSidecarSet() {
 		return reconcile.Result{}, nil
 	}
+	// roll back the matched pods to the history revision instead of the latest one
+	if sidecarSet.Spec.UpdateStrategy.RollbackTo != nil {
+		rollbackSidecarSet, err := sidecarcontrol.NewHistoryControl(p.Client).GetRollbackSidecarSet(sidecarSet)
+		if err != nil {
+			klog.ErrorS(err, "SidecarSet get rollback revision error", "sidecarSet", klog.KObj(sidecarSet))
+			return reconcile.Result{}, err
+		}
+		control = sidecarcontrol.New(rollbackSidecarSet)
+	}
 	// 1. get matching pods with the sidecarSet
-	pods, err := p.getMatchingPods(sidecarSet)
+	pods, err := p.getMatchingPods(control.GetSidecarset())
 	if err != nil {
 		klog.ErrorS(err, "SidecarSet get matching pods error", "sidecarSet", klog.KObj(sidecarSet))
 		return reconcile.Result{}, err
@@ -88,11 +98,36 @@ func (p *Processor) UpdateSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) (recon
 
 	// 2. calculate SidecarSet status based on pod and revision information
 	status := calculateStatus(control, pods, latestRevision, collisionCount)
+	if status.Revisions, err = p.calculateRevisionsStatus(sidecarSet, pods); err != nil {
+		klog.ErrorS(err, "SidecarSet calculate revisions status error", "sidecarSet", klog.KObj(sidecarSet))
+		return reconcile.Result{}, err
+	}
+	// calculate the current stage of the staged rolling update
+	var namespaceLabels map[string]labels.Set
+	var soakLeft time.Duration
+	if len(getUpdateStages(control.GetSidecarset())) > 0 {
+		if namespaceLabels, err = p.getNamespaceLabels(pods); err != nil {
+			klog.ErrorS(err, "SidecarSet get namespaces of matched pods error", "sidecarSet", klog.KObj(sidecarSet))
+			return reconcile.Result{}, err
+		}
+		if status.UpdateStage, soakLeft, err = calculateUpdateStage(control, pods, namespaceLabels, time.Now()); err != nil {
+			klog.ErrorS(err, "SidecarSet calculate update stage error", "sidecarSet", klog.KObj(sidecarSet))
+			return reconcile.Result{}, err
+		}
+	}
+	if sidecarSet.Spec.InjectionStrategy.Preview {
+		if status.InjectionPreview, err = p.previewInjection(control.GetSidecarset()); err != nil {
+			klog.ErrorS(err, "SidecarSet preview injection error", "sidecarSet", klog.KObj(sidecarSet))
+			return reconcile.Result{}, err
+		}
+	}
 	//update sidecarSet status in store
 	if err := p.updateSidecarSetStatus(sidecarSet, status); err != nil {
 		return reconcile.Result{}, err
 	}
 	sidecarSet.Status = *status
+	// the matched pods are updated to the rollback revision if rollbackTo is set, otherwise the latest one
+	sidecarSet = control.GetSidecarset()
 
 	// in case of informer cache latency
 	for _, pod := range pods {
@@ -136,10 +171,15 @@ func (p *Processor) UpdateSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) (recon
 		return reconcile.Result{}, nil
 	}
 
+	// 4.1 resize the sidecar containers whose resources are derived from the app containers
+	if isSidecarResourcesResizable(sidecarSet) && !sidecarSet.Spec.UpdateStrategy.Paused {
+		p.resizeSidecarContainers(control, pods)
+	}
+
 	// 5. sidecarset already updates all matched pods, then return
 	if isSidecarSetUpdateFinish(status) {
 		klog.V(3).InfoS("SidecarSet matched pods were latest, and don't need update", "sidecarSet", klog.KObj(sidecarSet), "matchedPodCount", len(pods))
-		return reconcile.Result{}, nil
+		return reconcile.Result{RequeueAfter: soakLeft}, nil
 	}
 
 	// 6. Paused indicates that the SidecarSet is paused to update matched pods
@@ -148,17 +188,22 @@ func (p *Processor) UpdateSidecarSet(sidecarSet *appsv1alpha1.SidecarSet) (recon
 		return reconcile.Result{}, nil
 	}
 
-	// 7. upgrade pod sidecar
-	if err := p.updatePods(control, pods); err != nil {
+	// 7. upgrade pod sidecar, only the pods in the current stage can be upgraded in the staged rolling update
+	stageScope, err := getUpdateStageScope(sidecarSet, status.UpdateStage, pods, namespaceLabels)
+	if err != nil {
 		return reconcile.Result{}, err
 	}
-	return reconcile.Result{}, nil
+	if err := p.updatePods(control, pods, stageScope); err != nil {
+		return reconcile.Result{}, err
+	}
+	// resync after the current stage has been soaked
+	return reconcile.Result{RequeueAfter: soakLeft}, nil
 }
 
-func (p *Processor) updatePods(control sidecarcontrol.SidecarControl, pods []*corev1.Pod) error {
+func (p *Processor) updatePods(control sidecarcontrol.SidecarControl, pods []*corev1.Pod, stageScope *UpdateStageScope) error {
 	sidecarset := control.GetSidecarset()
 	// compute next updated pods based on the sidecarset upgrade strategy
-	upgradePods, notUpgradablePods := NewStrategy().GetNextUpgradePods(control, pods)
+	upgradePods, notUpgradablePods := NewStrategy().GetNextUpgradePods(control, pods, stageScope)
 	for _, pod := range notUpgradablePods {
 		if err := p.updatePodSidecarSetUpgradableCondition(sidecarset, pod, false); err != nil {
 			klog.ErrorS(err, "Failed to update NotUpgradable PodCondition", "sidecarSet", klog.KObj(sidecarset), "pod", klog.KObj(pod))
@@ -167,7 +212,11 @@ func (p *Processor) updatePods(control sidecarcontrol.SidecarControl, pods []*co
 		// Since the pod sidecarSet hash is not updated here, it cannot be called ExpectUpdated
 		// TODO: add ResourceVersionExpectation instead of UpdateExpectations
 	}
-	if len(notUpgradablePods) > 0 {
+	if len(notUpgradablePods) > 0 && sidecarset.Spec.UpdateStrategy.RollbackTo != nil {
+		// the fields other than image differ from the revision rolled back to, the pods will never be rolled back in place
+		p.recorder.Eventf(sidecarset, corev1.EventTypeWarning, "NotRollbackablePods", "SidecarSet can not roll back %d pod(s) to revision %s in place, "+
+			"for the fields of sidecar containers other than image are changed, the pods need to be recreated.", len(notUpgradablePods), sidecarset.Status.LatestRevision)
+	} else if len(notUpgradablePods) > 0 {
 		p.recorder.Eventf(sidecarset, corev1.EventTypeNormal, "NotUpgradablePods", "SidecarSet in-place update detected %d not upgradable pod(s) in this round, will skip them.", len(notUpgradablePods))
 	}
 
@@ -284,6 +333,27 @@ func (p *Processor) updateSidecarSetStatus(sidecarSet *appsv1alpha1.SidecarSet,
 
 // If you need update the pod object, you must DeepCopy it
 func (p *Processor) getMatchingPods(s *appsv1alpha1.SidecarSet) ([]*corev1.Pod, error) {
+	selectedPods, err := p.getSidecarSetSelectedPods(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// filter out pods that don't require updated, include the following:
+	// 1. inActive pod
+	// 2. never be injected sidecar container
+	var filteredPods []*corev1.Pod
+	for _, pod := range selectedPods {
+		if sidecarcontrol.IsActivePod(pod) && sidecarcontrol.IsPodInjectedSidecarSet(pod, s) &&
+			sidecarcontrol.IsPodConsistentWithSidecarSet(pod, s) {
+			filteredPods = append(filteredPods, pod)
+		}
+	}
+	return filteredPods, nil
+}
+
+// get selected pods(DisableDeepCopy:true, indicates must be deep copy before update pod objection)
+// getSidecarSetSelectedPods returns the pods selected by the selector and namespaces of sidecarSet.
+func (p *Processor) getSidecarSetSelectedPods(s *appsv1alpha1.SidecarSet) ([]*corev1.Pod, error) {
 	// get more faster selector
 	selector, err := util.ValidatedLabelSelectorAsSelector(s.Spec.Selector)
 	if err != nil {
@@ -299,25 +369,25 @@ func (p *Processor) getMatchingPods(s *appsv1alpha1.SidecarSet) ([]*corev1.Pod,
 		// when namespace="", client will list pods in all namespaces
 		scopedNamespaces.Insert("")
 	}
-	selectedPods, err := p.getSelectedPods(scopedNamespaces, selector)
-	if err != nil {
-		return nil, err
-	}
+	return p.getSelectedPods(scopedNamespaces, selector)
+}
 
-	// filter out pods that don't require updated, include the following:
-	// 1. inActive pod
-	// 2. never be injected sidecar container
-	var filteredPods []*corev1.Pod
-	for _, pod := range selectedPods {
-		if sidecarcontrol.IsActivePod(pod) && sidecarcontrol.IsPodInjectedSidecarSet(pod, s) &&
-			sidecarcontrol.IsPodConsistentWithSidecarSet(pod, s) {
-			filteredPods = append(filteredPods, pod)
+// getNamespaceLabels returns the labels of namespaces of pods, which are selected by the namespaceSelector of update stages.
+func (p *Processor) getNamespaceLabels(pods []*corev1.Pod) (map[string]labels.Set, error) {
+	namespaceLabels := make(map[string]labels.Set)
+	for _, pod := range pods {
+		if _, ok := namespaceLabels[pod.Namespace]; ok {
+			continue
 		}
+		ns := &corev1.Namespace{}
+		if err := p.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Namespace}, ns); err != nil {
+			return nil, err
+		}
+		namespaceLabels[pod.Namespace] = labels.Set(ns.Labels)
 	}
-	return filteredPods, nil
+	return namespaceLabels, nil
 }
 
-// get selected pods(DisableDeepCopy:true, indicates must be deep copy before update pod objection)
 func (p *Processor) getSelectedPods(namespaces sets.String, selector labels.Selector) (relatedPods []*corev1.Pod, err error) {
 	// DisableDeepCopy:true, indicates must be deep copy before update pod objection
 	listOpts := &client.ListOptions{LabelSelector: selector}
@@ -467,26 +537,62 @@ func filterActiveRevisions(s *appsv1alpha1.SidecarSet, pods []*corev1.Pod, revis
 	}
 
 	if s.Spec.InjectionStrategy.Revision != nil {
-		equalRevisions := make([]*apps.ControllerRevision, 0)
-		if s.Spec.InjectionStrategy.Revision.RevisionName != nil {
-			activeRevisions.Insert(*s.Spec.InjectionStrategy.Revision.RevisionName)
-		}
+		insertSpecificRevision(activeRevisions, revisions, s.Spec.InjectionStrategy.Revision.RevisionName, s.Spec.InjectionStrategy.Revision.CustomVersion)
+	}
+	// the revision rolled back to is in use as well
+	if s.Spec.UpdateStrategy.RollbackTo != nil {
+		insertSpecificRevision(activeRevisions, revisions, s.Spec.UpdateStrategy.RollbackTo.RevisionName, s.Spec.UpdateStrategy.RollbackTo.CustomVersion)
+	}
 
-		if s.Spec.InjectionStrategy.Revision.CustomVersion != nil {
-			for i := range revisions {
-				revision := revisions[i]
-				if revision.Labels[appsv1alpha1.SidecarSetCustomVersionLabel] == *s.Spec.InjectionStrategy.Revision.CustomVersion {
-					equalRevisions = append(equalRevisions, revision)
-				}
-			}
-			if len(equalRevisions) > 0 {
-				history.SortControllerRevisions(equalRevisions)
-				activeRevisions.Insert(equalRevisions[len(equalRevisions)-1].Name)
+	return activeRevisions
+}
+
+// insertSpecificRevision inserts the revision specified by revisionName or customVersion into activeRevisions.
+func insertSpecificRevision(activeRevisions sets.String, revisions []*apps.ControllerRevision, revisionName, customVersion *string) {
+	if revisionName != nil {
+		activeRevisions.Insert(*revisionName)
+	}
+
+	if customVersion != nil {
+		equalRevisions := make([]*apps.ControllerRevision, 0)
+		for i := range revisions {
+			revision := revisions[i]
+			if revision.Labels[appsv1alpha1.SidecarSetCustomVersionLabel] == *customVersion {
+				equalRevisions = append(equalRevisions, revision)
 			}
 		}
+		if len(equalRevisions) > 0 {
+			history.SortControllerRevisions(equalRevisions)
+			activeRevisions.Insert(equalRevisions[len(equalRevisions)-1].Name)
+		}
 	}
+}
 
-	return activeRevisions
+// calculateRevisionsStatus returns the history revisions of sidecarSet with the number of pods injected with each of them.
+func (p *Processor) calculateRevisionsStatus(sidecarSet *appsv1alpha1.SidecarSet, pods []*corev1.Pod) ([]appsv1alpha1.SidecarSetRevisionStatus, error) {
+	hc := sidecarcontrol.NewHistoryControl(p.Client)
+	revisions, err := p.historyController.ListControllerRevisions(sidecarcontrol.MockSidecarSetForRevision(sidecarSet), hc.GetRevisionSelector(sidecarSet))
+	if err != nil {
+		return nil, err
+	}
+	history.SortControllerRevisions(revisions)
+
+	podCounts := make(map[string]int32)
+	for _, pod := range pods {
+		if revision := sidecarcontrol.GetPodSidecarSetControllerRevision(sidecarSet.Name, pod); revision != "" {
+			podCounts[revision]++
+		}
+	}
+	var revisionsStatus []appsv1alpha1.SidecarSetRevisionStatus
+	for _, revision := range revisions {
+		revisionsStatus = append(revisionsStatus, appsv1alpha1.SidecarSetRevisionStatus{
+			Name:          revision.Name,
+			Revision:      revision.Revision,
+			CustomVersion: revision.Labels[appsv1alpha1.SidecarSetCustomVersionLabel],
+			Pods:          podCounts[revision.Name],
+		})
+	}
+	return revisionsStatus, nil
 }
 
 // replaceRevision will remove old from revisions, and add new to the end of revisions.
@@ -552,6 +658,13 @@ func isSidecarSetNotUpdate(s *appsv1alpha1.SidecarSet) bool {
 }
 
 func updateContainerInPod(container corev1.Container, pod *corev1.Pod) {
+	// native sidecar containers are injected in pod.spec.initContainers
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == container.Name {
+			pod.Spec.InitContainers[i] = container
+			return
+		}
+	}
 	for i := range pod.Spec.Containers {
 		if pod.Spec.Containers[i].Name == container.Name {
 			pod.Spec.Containers[i] = container
@@ -626,7 +739,10 @@ func inconsistentStatus(sidecarSet *appsv1alpha1.SidecarSet, status *appsv1alpha
 		status.ReadyPods != sidecarSet.Status.ReadyPods ||
 		status.UpdatedReadyPods != sidecarSet.Status.UpdatedReadyPods ||
 		status.LatestRevision != sidecarSet.Status.LatestRevision ||
-		!pointer.Int32Equal(sidecarSet.Status.CollisionCount, status.CollisionCount)
+		!pointer.Int32Equal(sidecarSet.Status.CollisionCount, status.CollisionCount) ||
+		!reflect.DeepEqual(sidecarSet.Status.InjectionPreview, status.InjectionPreview) ||
+		!reflect.DeepEqual(sidecarSet.Status.Revisions, status.Revisions) ||
+		!reflect.DeepEqual(sidecarSet.Status.UpdateStage, status.UpdateStage)
 }
 
 func isSidecarSetUpdateFinish(status *appsv1alpha1.SidecarSetStatus) bool {
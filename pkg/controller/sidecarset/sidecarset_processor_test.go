@@ -20,7 +20,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
@@ -33,6 +35,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/controller/history"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -241,6 +244,62 @@ func testUpdateColdUpgradeSidecar(t *testing.T, podDemo *corev1.Pod, sidecarSetI
 	}
 }
 
+func TestUpdateNativeSidecar(t *testing.T) {
+	sidecarSetInput := sidecarSetDemo.DeepCopy()
+	sidecarSetInput.Spec.InjectionStrategy.NativeSidecar = true
+	// the sidecar container is injected into pod.spec.initContainers as native sidecar container
+	podInput := podDemo.DeepCopy()
+	restartPolicy := corev1.ContainerRestartPolicyAlways
+	sidecar := podInput.Spec.Containers[1]
+	sidecar.RestartPolicy = &restartPolicy
+	podInput.Spec.InitContainers = []corev1.Container{sidecar}
+	podInput.Spec.Containers = podInput.Spec.Containers[:1]
+	podInput.Status.InitContainerStatuses = []corev1.ContainerStatus{podInput.Status.ContainerStatuses[1]}
+	podInput.Status.ContainerStatuses = podInput.Status.ContainerStatuses[:1]
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(sidecarSetInput, podInput).
+		WithStatusSubresource(&appsv1alpha1.SidecarSet{}).Build()
+	processor := NewSidecarSetProcessor(fakeClient, record.NewFakeRecorder(10))
+	if _, err := processor.UpdateSidecarSet(sidecarSetInput); err != nil {
+		t.Fatalf("processor update sidecarset failed: %s", err.Error())
+	}
+	podOutput, err := getLatestPod(fakeClient, podInput)
+	if err != nil {
+		t.Fatalf("get latest pod failed: %s", err.Error())
+	}
+	if len(podOutput.Spec.Containers) != 1 || len(podOutput.Spec.InitContainers) != 1 {
+		t.Fatalf("expect native sidecar container kept in initContainers, but got %v", podOutput.Spec)
+	}
+	sidecarContainer := &podOutput.Spec.InitContainers[0]
+	if sidecarContainer.Image != "test-image:v2" || !sidecarcontrol.IsSidecarContainer(*sidecarContainer) {
+		t.Fatalf("expect native sidecar container upgraded to test-image:v2 in-place, but got %v", sidecarContainer)
+	}
+	sidecarSetOutput, err := getLatestSidecarSet(fakeClient, sidecarSetInput)
+	if err != nil {
+		t.Fatalf("get latest sidecarset failed: %s", err.Error())
+	}
+	if sidecarSetOutput.Status.MatchedPods != 1 || sidecarSetOutput.Status.UpdatedPods != 0 {
+		t.Fatalf("unexpected sidecarset status %v", sidecarSetOutput.Status)
+	}
+
+	// the native sidecar container has been restarted with the new image
+	podOutput.Status.InitContainerStatuses[0].Image = "test-image:v2"
+	podOutput.Status.InitContainerStatuses[0].ImageID = testImageV2ImageID
+	if err = fakeClient.Status().Update(context.TODO(), podOutput); err != nil {
+		t.Fatalf("update pod status failed: %s", err.Error())
+	}
+	if _, err = processor.UpdateSidecarSet(sidecarSetOutput); err != nil {
+		t.Fatalf("processor update sidecarset failed: %s", err.Error())
+	}
+	if sidecarSetOutput, err = getLatestSidecarSet(fakeClient, sidecarSetInput); err != nil {
+		t.Fatalf("get latest sidecarset failed: %s", err.Error())
+	}
+	if sidecarSetOutput.Status.UpdatedPods != 1 || sidecarSetOutput.Status.UpdatedReadyPods != 1 {
+		t.Fatalf("expect native sidecar container update completed, but got status %v", sidecarSetOutput.Status)
+	}
+}
+
 func TestScopeNamespacePods(t *testing.T) {
 	sidecarSet := sidecarSetDemo.DeepCopy()
 	sidecarSet.Spec.Namespace = "test-ns"
@@ -518,3 +577,147 @@ func TestTruncateHistory(t *testing.T) {
 		t.Fatalf("expected name %s, actual : %s", getName(15), rvs[9].Name)
 	}
 }
+
+// newRollbackRevisions returns the latest sidecarSet with image test-image:v2, and the ControllerRevisions of
+// test-image:v1 and test-image:v2, whose hash without image is withoutImageV1 and without-image respectively.
+func newRollbackRevisions(t *testing.T, withoutImageV1 string) (*appsv1alpha1.SidecarSet, *apps.ControllerRevision, *apps.ControllerRevision) {
+	hc := sidecarcontrol.NewHistoryControl(nil)
+	sidecarSetV1 := sidecarSetDemo.DeepCopy()
+	sidecarSetV1.Spec.Containers[0].Image = "test-image:v1"
+	sidecarSetV1.Annotations[sidecarcontrol.SidecarSetHashAnnotation] = "v1"
+	sidecarSetV1.Annotations[sidecarcontrol.SidecarSetHashWithoutImageAnnotation] = withoutImageV1
+	revisionV1, err := hc.NewRevision(sidecarSetV1, webhookutil.GetNamespace(), 1, nil)
+	if err != nil {
+		t.Fatalf("failed to create revision v1: %v", err)
+	}
+
+	sidecarSet := sidecarSetDemo.DeepCopy()
+	sidecarSet.Annotations[sidecarcontrol.SidecarSetHashAnnotation] = "v2"
+	sidecarSet.Annotations[sidecarcontrol.SidecarSetHashWithoutImageAnnotation] = "without-image"
+	revisionV2, err := hc.NewRevision(sidecarSet, webhookutil.GetNamespace(), 2, nil)
+	if err != nil {
+		t.Fatalf("failed to create revision v2: %v", err)
+	}
+	sidecarSet.Status.LatestRevision = revisionV2.Name
+	return sidecarSet, revisionV1, revisionV2
+}
+
+// newRevisionPod returns a ready pod injected with test-image:v2 of the given revision.
+func newRevisionPod(name, hash, revision string) *corev1.Pod {
+	pod := podDemo.DeepCopy()
+	pod.Name = name
+	pod.Annotations[sidecarcontrol.SidecarSetHashAnnotation] = fmt.Sprintf(
+		`{"test-sidecarset":{"hash":"%s","sidecarList":["test-sidecar"],"controllerRevision":"%s"}}`, hash, revision)
+	pod.Annotations[sidecarcontrol.SidecarSetHashWithoutImageAnnotation] = `{"test-sidecarset":{"hash":"without-image","sidecarList":["test-sidecar"]}}`
+	pod.Spec.Containers[1].Image = "test-image:" + hash
+	pod.Status.ContainerStatuses[1].Image = "test-image:" + hash
+	pod.Status.ContainerStatuses[1].ImageID = testImageV2ImageID
+	return pod
+}
+
+func TestRollbackTo(t *testing.T) {
+	cases := []struct {
+		name           string
+		partition      intstr.IntOrString
+		maxUnavailable intstr.IntOrString
+		withoutImageV1 string
+		expectRollback int
+		expectEvent    string
+	}{
+		{
+			name:           "rollback limited by maxUnavailable",
+			partition:      intstr.FromInt(1),
+			maxUnavailable: intstr.FromInt(2),
+			withoutImageV1: "without-image",
+			expectRollback: 2,
+		},
+		{
+			name:           "rollback limited by partition",
+			partition:      intstr.FromInt(3),
+			maxUnavailable: intstr.FromInt(2),
+			withoutImageV1: "without-image",
+			expectRollback: 1,
+		},
+		{
+			name:           "rollback with hash without image changed",
+			partition:      intstr.FromInt(0),
+			maxUnavailable: intstr.FromInt(4),
+			withoutImageV1: "other-without-image",
+			expectRollback: 0,
+			expectEvent:    "Warning NotRollbackablePods",
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			sidecarSet, revisionV1, revisionV2 := newRollbackRevisions(t, cs.withoutImageV1)
+			defer sidecarcontrol.UpdateExpectations.DeleteExpectations(sidecarSet.Name)
+			sidecarSet.Spec.UpdateStrategy.Partition = &cs.partition
+			sidecarSet.Spec.UpdateStrategy.MaxUnavailable = &cs.maxUnavailable
+			sidecarSet.Spec.UpdateStrategy.RollbackTo = &appsv1alpha1.SidecarSetRollbackTo{RevisionName: &revisionV1.Name}
+			objects := []client.Object{sidecarSet, revisionV1, revisionV2}
+			for i := 0; i < 4; i++ {
+				objects = append(objects, newRevisionPod(fmt.Sprintf("test-pod-%d", i), "v2", revisionV2.Name))
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+				WithStatusSubresource(&appsv1alpha1.SidecarSet{}).Build()
+			recorder := record.NewFakeRecorder(10)
+			processor := NewSidecarSetProcessor(fakeClient, recorder)
+			if _, err := processor.UpdateSidecarSet(sidecarSet); err != nil {
+				t.Fatalf("processor update sidecarset failed: %s", err.Error())
+			}
+
+			podList := &corev1.PodList{}
+			if err := fakeClient.List(context.TODO(), podList); err != nil {
+				t.Fatalf("failed to list pods: %v", err)
+			}
+			var rollback int
+			for i := range podList.Items {
+				pod := &podList.Items[i]
+				if pod.Spec.Containers[1].Image != "test-image:v1" {
+					continue
+				}
+				rollback++
+				if revision := sidecarcontrol.GetPodSidecarSetControllerRevision(sidecarSet.Name, pod); revision != revisionV1.Name {
+					t.Fatalf("expect pod %s rolled back to revision %s, but got %s", pod.Name, revisionV1.Name, revision)
+				}
+			}
+			if rollback != cs.expectRollback {
+				t.Fatalf("expect %d pods rolled back, but got %d", cs.expectRollback, rollback)
+			}
+
+			var event string
+			select {
+			case event = <-recorder.Events:
+			default:
+			}
+			if !strings.HasPrefix(event, cs.expectEvent) {
+				t.Fatalf("expect event %q, but got %q", cs.expectEvent, event)
+			}
+		})
+	}
+}
+
+func TestCalculateRevisionsStatus(t *testing.T) {
+	sidecarSet, revisionV1, revisionV2 := newRollbackRevisions(t, "without-image")
+	pods := []*corev1.Pod{
+		newRevisionPod("test-pod-0", "v1", revisionV1.Name),
+		newRevisionPod("test-pod-1", "v2", revisionV2.Name),
+		newRevisionPod("test-pod-2", "v2", revisionV2.Name),
+		// the pod injected before the revision is recorded in annotations
+		newRevisionPod("test-pod-3", "v2", ""),
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sidecarSet, revisionV2, revisionV1).Build()
+	processor := NewSidecarSetProcessor(fakeClient, record.NewFakeRecorder(10))
+	revisions, err := processor.calculateRevisionsStatus(sidecarSet, pods)
+	if err != nil {
+		t.Fatalf("failed to calculate revisions status: %v", err)
+	}
+	expected := []appsv1alpha1.SidecarSetRevisionStatus{
+		{Name: revisionV1.Name, Revision: 1, Pods: 1},
+		{Name: revisionV2.Name, Revision: 2, Pods: 2},
+	}
+	if !reflect.DeepEqual(revisions, expected) {
+		t.Fatalf("expect revisions %v, but got %v", expected, revisions)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecarset
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/control/sidecarcontrol"
+	"github.com/openkruise/kruise/pkg/features"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+)
+
+// isSidecarResourcesResizable checks whether the resources of sidecar containers in pods are re-evaluated
+// after the resources of the app containers are changed.
+func isSidecarResourcesResizable(sidecarSet *appsv1alpha1.SidecarSet) bool {
+	return utilfeature.DefaultFeatureGate.Enabled(features.InPlaceWorkloadVerticalScaling) &&
+		sidecarcontrol.IsSidecarSetHasResourcesPolicy(sidecarSet)
+}
+
+// resizeSidecarContainers resizes the sidecar containers in-place, whose resources are derived from the app containers
+// and have been changed since the resources of the app containers are changed.
+// The number of pods that are unavailable during resizing is limited by maxUnavailable of the update strategy,
+// and the pods failed to resize are skipped to be retried in the next round.
+func (p *Processor) resizeSidecarContainers(control sidecarcontrol.SidecarControl, pods []*corev1.Pod) {
+	sidecarSet := control.GetSidecarset()
+	var waitResizePods []*corev1.Pod
+	var unavailableCount int
+	for _, pod := range pods {
+		// only resize the sidecar containers of the latest sidecarSet
+		if !sidecarcontrol.IsPodSidecarUpdated(sidecarSet, pod) {
+			continue
+		}
+		if isPodSidecarUnavailable(control, pod) {
+			unavailableCount++
+			continue
+		}
+		if changed := sidecarcontrol.UpdatePodSidecarResources(sidecarSet, pod.DeepCopy()); len(changed) > 0 {
+			waitResizePods = append(waitResizePods, pod)
+		}
+	}
+
+	maxUnavailable := 1
+	if sidecarSet.Spec.UpdateStrategy.MaxUnavailable != nil {
+		maxUnavailable, _ = intstrutil.GetValueFromIntOrPercent(sidecarSet.Spec.UpdateStrategy.MaxUnavailable, len(pods), true)
+	}
+	if needResizeCount := maxUnavailable - unavailableCount; needResizeCount < len(waitResizePods) {
+		if needResizeCount <= 0 {
+			klog.V(3).InfoS("SidecarSet skipped resizing sidecar containers for maxUnavailable", "sidecarSet", klog.KObj(sidecarSet),
+				"waitResize", len(waitResizePods), "unavailable", unavailableCount)
+			return
+		}
+		waitResizePods = waitResizePods[:needResizeCount]
+	}
+
+	for _, pod := range waitResizePods {
+		var changed []string
+		podClone := &corev1.Pod{}
+		err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+			if err := p.Client.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, podClone); err != nil {
+				return err
+			}
+			if changed = sidecarcontrol.UpdatePodSidecarResources(sidecarSet, podClone); len(changed) == 0 {
+				return nil
+			}
+			return p.Client.Update(context.TODO(), podClone)
+		})
+		if err != nil {
+			klog.ErrorS(err, "SidecarSet resized sidecar containers of pod failed", "sidecarSet", klog.KObj(sidecarSet), "pod", klog.KObj(pod))
+			p.recorder.Eventf(sidecarSet, corev1.EventTypeWarning, "FailedResizeSidecarContainers", "failed to resize sidecar containers of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+		if len(changed) > 0 {
+			klog.V(3).InfoS("SidecarSet resized sidecar containers of pod", "sidecarSet", klog.KObj(sidecarSet), "pod", klog.KObj(pod), "containers", changed)
+			p.recorder.Eventf(sidecarSet, corev1.EventTypeNormal, "ResizeSidecarContainers", "resize sidecar containers %v of pod %s/%s", changed, pod.Namespace, pod.Name)
+		}
+	}
+}
+
+// isPodSidecarUnavailable checks whether the pod is not ready, or the update or resizing of its sidecar containers
+// has not been completed yet.
+func isPodSidecarUnavailable(control sidecarcontrol.SidecarControl, pod *corev1.Pod) bool {
+	return !control.IsPodReady(pod) || !control.IsPodStateConsistent(pod, nil) ||
+		pod.Status.Resize == corev1.PodResizeStatusInProgress
+}
+
+// isPodContainerResourcesChanged checks whether the resources of pod.spec.containers are changed.
+func isPodContainerResourcesChanged(oldPod, newPod *corev1.Pod) bool {
+	if len(oldPod.Spec.Containers) != len(newPod.Spec.Containers) {
+		return true
+	}
+	for i := range newPod.Spec.Containers {
+		if !reflect.DeepEqual(oldPod.Spec.Containers[i].Resources, newPod.Spec.Containers[i].Resources) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sidecarset
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/control/sidecarcontrol"
+)
+
+func TestResizeSidecarContainers(t *testing.T) {
+	cases := []struct {
+		name           string
+		maxUnavailable *intstr.IntOrString
+		notReadyPods   int
+		expectResized  int
+	}{
+		{
+			name:          "default maxUnavailable",
+			expectResized: 1,
+		},
+		{
+			name:           "maxUnavailable with not ready pod",
+			maxUnavailable: &intstr.IntOrString{Type: intstr.Int, IntVal: 2},
+			notReadyPods:   1,
+			expectResized:  1,
+		},
+		{
+			name:           "all pods",
+			maxUnavailable: &intstr.IntOrString{Type: intstr.String, StrVal: "100%"},
+			expectResized:  3,
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			sidecarSet := factorySidecarSet()
+			sidecarSet.Spec.UpdateStrategy.MaxUnavailable = cs.maxUnavailable
+			sidecarSet.Spec.Containers[0].ResourcesPolicy = &appsv1alpha1.SidecarResourcesPolicy{
+				TargetContainerNames: []string{"nginx"},
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{
+					corev1.ResourceCPU: {Percent: 10},
+				},
+			}
+			sidecarSet.Spec.InitContainers = []appsv1alpha1.SidecarContainer{sidecarSet.Spec.Containers[0]}
+			sidecarSet.Spec.InitContainers[0].Name = "init-sidecar"
+
+			pods := factoryPods(3, 3, 3)
+			var objects []client.Object
+			for i, pod := range pods {
+				pod.Namespace = "default"
+				pod.Spec.Containers[0].Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+				// the regular init container should not be resized
+				pod.Spec.InitContainers = []corev1.Container{{Name: "init-sidecar", Image: "test-image:v2"}}
+				if i < cs.notReadyPods {
+					pod.Status.Conditions[0].Status = corev1.ConditionFalse
+				}
+				objects = append(objects, pod)
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			processor := NewSidecarSetProcessor(fakeClient, record.NewFakeRecorder(10))
+			processor.resizeSidecarContainers(sidecarcontrol.New(sidecarSet), pods)
+
+			var resized int
+			for _, pod := range pods {
+				got := &corev1.Pod{}
+				if err := fakeClient.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, got); err != nil {
+					t.Fatalf("failed to get pod: %v", err)
+				}
+				if len(got.Spec.InitContainers[0].Resources.Requests) != 0 {
+					t.Fatalf("expect init container not resized, but got %v", got.Spec.InitContainers[0].Resources)
+				}
+				if cpu, ok := got.Spec.Containers[1].Resources.Requests[corev1.ResourceCPU]; ok {
+					if cpu.Cmp(resource.MustParse("100m")) != 0 {
+						t.Fatalf("expect cpu request 100m, but got %s", cpu.String())
+					}
+					resized++
+				}
+			}
+			if resized != cs.expectResized {
+				t.Fatalf("expect %d pods resized, but got %d", cs.expectResized, resized)
+			}
+		})
+	}
+}
@@ -1,16 +1,21 @@
 package sidecarset
 
 import (
+	"fmt"
 	"sort"
+	"time"
 
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
 	"github.com/openkruise/kruise/pkg/control/sidecarcontrol"
 	"github.com/openkruise/kruise/pkg/util"
 	"github.com/openkruise/kruise/pkg/util/updatesort"
+	"github.com/openkruise/kruise/pkg/util/updatestage"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
 )
 
@@ -23,7 +28,16 @@ type Strategy interface {
 	//3. sort waitUpdateIndexes based on the scatter rules
 	//4. calculate max count of pods can update with maxUnavailable
 	//5. also return the pods that are not upgradable
-	GetNextUpgradePods(control sidecarcontrol.SidecarControl, pods []*corev1.Pod) (upgradePods []*corev1.Pod, notUpgradablePods []*corev1.Pod)
+	// If stageScope is not nil, only the pods in the current stage can be upgraded.
+	GetNextUpgradePods(control sidecarcontrol.SidecarControl, pods []*corev1.Pod, stageScope *UpdateStageScope) (upgradePods []*corev1.Pod, notUpgradablePods []*corev1.Pod)
+}
+
+// UpdateStageScope limits the pods to upgrade in the current stage of the staged rolling update.
+type UpdateStageScope struct {
+	// Pods are the keys of the pods selected by the current and previous stages.
+	Pods sets.String
+	// MaxUnavailable is the maxUnavailable of the current stage.
+	MaxUnavailable *intstrutil.IntOrString
 }
 
 type spreadingStrategy struct{}
@@ -36,7 +50,7 @@ func NewStrategy() Strategy {
 	return globalSpreadingStrategy
 }
 
-func (p *spreadingStrategy) GetNextUpgradePods(control sidecarcontrol.SidecarControl, pods []*corev1.Pod) (upgradePods []*corev1.Pod, notUpgradablePods []*corev1.Pod) {
+func (p *spreadingStrategy) GetNextUpgradePods(control sidecarcontrol.SidecarControl, pods []*corev1.Pod, stageScope *UpdateStageScope) (upgradePods []*corev1.Pod, notUpgradablePods []*corev1.Pod) {
 	sidecarset := control.GetSidecarset()
 	// wait to upgrade pod index
 	var waitUpgradedIndexes []int
@@ -63,6 +77,10 @@ func (p *spreadingStrategy) GetNextUpgradePods(control sidecarcontrol.SidecarCon
 		//Not matched, then return false
 		return false
 	}
+	// If stageScope is not nil, check whether the pod is in the current stage
+	isInStage := func(pod *corev1.Pod) bool {
+		return stageScope == nil || stageScope.Pods.Has(getPodKey(pod))
+	}
 
 	//1. select which pods can be upgraded, the following:
 	//	* pod must be not updated for the latest sidecarSet
@@ -71,7 +89,7 @@ func (p *spreadingStrategy) GetNextUpgradePods(control sidecarcontrol.SidecarCon
 	//  * It is to determine whether there are other fields that have been modified for pod.
 	for index, pod := range pods {
 		isUpdated := sidecarcontrol.IsPodSidecarUpdated(sidecarset, pod)
-		if !isUpdated && isSelected(pod) {
+		if !isUpdated && isSelected(pod) && isInStage(pod) {
 			canUpgrade, consistent := control.IsSidecarSetUpgradable(pod)
 			if canUpgrade && consistent {
 				waitUpgradedIndexes = append(waitUpgradedIndexes, index)
@@ -88,7 +106,7 @@ func (p *spreadingStrategy) GetNextUpgradePods(control sidecarcontrol.SidecarCon
 	waitUpgradedIndexes = SortUpdateIndexes(strategy, pods, waitUpgradedIndexes)
 
 	//3. calculate to be upgraded pods number for the time
-	needToUpgradeCount := calculateUpgradeCount(control, waitUpgradedIndexes, pods)
+	needToUpgradeCount := calculateUpgradeCount(control, waitUpgradedIndexes, pods, stageScope)
 	if needToUpgradeCount < len(waitUpgradedIndexes) {
 		waitUpgradedIndexes = waitUpgradedIndexes[:needToUpgradeCount]
 	}
@@ -131,7 +149,7 @@ func SortUpdateIndexes(strategy appsv1alpha1.SidecarSetUpdateStrategy, pods []*c
 	return waitUpdateIndexes
 }
 
-func calculateUpgradeCount(coreControl sidecarcontrol.SidecarControl, waitUpdateIndexes []int, pods []*corev1.Pod) int {
+func calculateUpgradeCount(coreControl sidecarcontrol.SidecarControl, waitUpdateIndexes []int, pods []*corev1.Pod, stageScope *UpdateStageScope) int {
 	totalReplicas := len(pods)
 	sidecarSet := coreControl.GetSidecarset()
 	strategy := sidecarSet.Spec.UpdateStrategy
@@ -153,9 +171,22 @@ func calculateUpgradeCount(coreControl sidecarcontrol.SidecarControl, waitUpdate
 	if strategy.MaxUnavailable != nil {
 		maxUnavailable, _ = intstrutil.GetValueFromIntOrPercent(strategy.MaxUnavailable, totalReplicas, true)
 	}
+	// in the staged rolling update, maxUnavailable of the current stage is calculated with the pods in it
+	if stageScope != nil {
+		stageMaxUnavailable := stageScope.MaxUnavailable
+		if stageMaxUnavailable == nil {
+			stageMaxUnavailable = strategy.MaxUnavailable
+		}
+		if stageMaxUnavailable != nil {
+			maxUnavailable, _ = intstrutil.GetValueFromIntOrPercent(stageMaxUnavailable, stageScope.Pods.Len(), true)
+		}
+	}
 
 	var upgradeAndNotReadyCount int
 	for _, pod := range pods {
+		if stageScope != nil && !stageScope.Pods.Has(getPodKey(pod)) {
+			continue
+		}
 		// 1. sidecar containers have been updated to the latest sidecarSet version, for pod.spec.containers
 		// 2. whether pod.spec and pod.status is inconsistent after updating the sidecar containers
 		// 3. whether pod is not ready
@@ -222,3 +253,138 @@ func matchScatterTerms(pods []*corev1.Pod, regularLabel string) []appsv1alpha1.U
 	}
 	return terms
 }
+
+func getPodKey(pod *corev1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
+
+func getUpdateStages(sidecarSet *appsv1alpha1.SidecarSet) []appsv1alpha1.SidecarSetUpdateStage {
+	if sidecarSet.Spec.UpdateStrategy.Type == appsv1alpha1.NotUpdateSidecarSetStrategyType {
+		return nil
+	}
+	return sidecarSet.Spec.UpdateStrategy.Stages
+}
+
+// getStagePods returns the keys of pods to update in the stages up to the given index.
+// A pod is selected by a stage if both its namespace and itself match the selectors of the stage.
+func getStagePods(stages []appsv1alpha1.SidecarSetUpdateStage, index int, pods []*corev1.Pod, namespaceLabels map[string]labels.Set) (sets.String, error) {
+	podKeys := sets.NewString()
+	for i := 0; i <= index && i < len(stages); i++ {
+		stage := &stages[i]
+		namespaceSelector, selector := labels.Everything(), labels.Everything()
+		var err error
+		if stage.NamespaceSelector != nil {
+			if namespaceSelector, err = util.ValidatedLabelSelectorAsSelector(stage.NamespaceSelector); err != nil {
+				return nil, fmt.Errorf("invalid namespaceSelector of stage %d: %v", i, err)
+			}
+		}
+		if stage.Selector != nil {
+			if selector, err = util.ValidatedLabelSelectorAsSelector(stage.Selector); err != nil {
+				return nil, fmt.Errorf("invalid selector of stage %d: %v", i, err)
+			}
+		}
+		for _, pod := range pods {
+			if namespaceSelector.Matches(namespaceLabels[pod.Namespace]) && selector.Matches(labels.Set(pod.Labels)) {
+				podKeys.Insert(getPodKey(pod))
+			}
+		}
+	}
+	return podKeys, nil
+}
+
+// calculateUpdateStage calculates the status of the staged rolling update,
+// and returns the duration left to soak the current stage if it is soaking.
+// The pods not selected by updateStrategy.selector are ignored, for they will never be updated.
+func calculateUpdateStage(control sidecarcontrol.SidecarControl, pods []*corev1.Pod, namespaceLabels map[string]labels.Set,
+	now time.Time) (*appsv1alpha1.SidecarSetUpdateStageStatus, time.Duration, error) {
+	sidecarSet := control.GetSidecarset()
+	stages := getUpdateStages(sidecarSet)
+	if len(stages) == 0 {
+		return nil, 0, nil
+	}
+	revision := sidecarcontrol.GetSidecarSetRevision(sidecarSet)
+	oldStatus := sidecarSet.Status.UpdateStage
+	if oldStatus != nil && oldStatus.Revision != revision {
+		oldStatus = nil
+	}
+
+	selector := labels.Everything()
+	if sidecarSet.Spec.UpdateStrategy.Selector != nil {
+		var err error
+		if selector, err = util.ValidatedLabelSelectorAsSelector(sidecarSet.Spec.UpdateStrategy.Selector); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// the counts of pods are kept for the last stage calculated, which is the current one
+	status := &appsv1alpha1.SidecarSetUpdateStageStatus{Revision: revision}
+	stageStatus, err := updatestage.Calculate(len(stages), func(i int) (*updatestage.Stage, error) {
+		podKeys, err := getStagePods(stages, i, pods, namespaceLabels)
+		if err != nil {
+			return nil, err
+		}
+		var matchedPods, updatedPods, updatedReadyPods int32
+		for _, pod := range pods {
+			if !podKeys.Has(getPodKey(pod)) || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			matchedPods++
+			if sidecarcontrol.IsPodSidecarUpdated(sidecarSet, pod) {
+				updatedPods++
+				if control.IsPodStateConsistent(pod, nil) && control.IsPodReady(pod) {
+					updatedReadyPods++
+				}
+			}
+		}
+		status.CurrentStage = int32(i)
+		status.Name = stages[i].Name
+		status.MatchedPods, status.UpdatedPods, status.UpdatedReadyPods = matchedPods, updatedPods, updatedReadyPods
+
+		stage := &updatestage.Stage{
+			AllUpdated:   updatedPods == matchedPods,
+			AllAvailable: updatedReadyPods == updatedPods,
+			SoakSeconds:  stages[i].SoakSeconds,
+			Paused:       stages[i].Pause && !isUpdateStageResumed(sidecarSet, revision, i),
+		}
+		if oldStatus != nil {
+			stage.Passed = oldStatus.CurrentStage > int32(i) || oldStatus.Phase == appsv1alpha1.SidecarSetUpdateStagePhaseCompleted
+			if oldStatus.CurrentStage == int32(i) {
+				stage.SoakStartTime = oldStatus.SoakStartTime
+			}
+		}
+		return stage, nil
+	}, metav1.Time{Time: now})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	status.Phase = appsv1alpha1.SidecarSetUpdateStagePhase(stageStatus.Phase)
+	status.SoakStartTime = stageStatus.SoakStartTime
+	return status, stageStatus.SoakLeft, nil
+}
+
+// isUpdateStageResumed checks whether the paused stage of the rolling update to the revision is resumed by annotation.
+func isUpdateStageResumed(sidecarSet *appsv1alpha1.SidecarSet, revision string, index int) bool {
+	return sidecarSet.Annotations[appsv1alpha1.SidecarSetResumeStageKey] == fmt.Sprintf("%s/%d", revision, index)
+}
+
+// getUpdateStageScope returns the scope of pods that can be updated in the current stage.
+// It returns nil if all stages have been completed, which means no limit to the pods to update.
+func getUpdateStageScope(sidecarSet *appsv1alpha1.SidecarSet, status *appsv1alpha1.SidecarSetUpdateStageStatus, pods []*corev1.Pod,
+	namespaceLabels map[string]labels.Set) (*UpdateStageScope, error) {
+	if status == nil {
+		return nil, nil
+	}
+	switch status.Phase {
+	case appsv1alpha1.SidecarSetUpdateStagePhaseUpdating:
+		stages := getUpdateStages(sidecarSet)
+		podKeys, err := getStagePods(stages, int(status.CurrentStage), pods, namespaceLabels)
+		if err != nil {
+			return nil, err
+		}
+		return &UpdateStageScope{Pods: podKeys, MaxUnavailable: stages[status.CurrentStage].MaxUnavailable}, nil
+	case appsv1alpha1.SidecarSetUpdateStagePhaseSoaking, appsv1alpha1.SidecarSetUpdateStagePhasePaused:
+		return &UpdateStageScope{Pods: sets.NewString()}, nil
+	}
+	return nil, nil
+}
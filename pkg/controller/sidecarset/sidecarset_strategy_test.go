@@ -22,6 +22,7 @@ import (
 	"math/rand"
 	"reflect"
 	"testing"
+	"time"
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
@@ -29,6 +30,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	podutil "k8s.io/kubernetes/pkg/api/v1/pod"
 	utilpointer "k8s.io/utils/pointer"
@@ -420,7 +422,7 @@ func testGetNextUpgradePods(t *testing.T, factoryPods FactoryPods, factorySideca
 		t.Run(cs.name, func(t *testing.T) {
 			control := sidecarcontrol.New(cs.getSidecarset())
 			pods := cs.getPods()
-			upgradePods, notUpgradablePods := strategy.GetNextUpgradePods(control, pods)
+			upgradePods, notUpgradablePods := strategy.GetNextUpgradePods(control, pods, nil)
 			if cs.exceptNeedUpgradeCount != len(upgradePods) {
 				t.Fatalf("except NeedUpgradeCount(%d), but get value(%d)", cs.exceptNeedUpgradeCount, len(upgradePods))
 			}
@@ -431,6 +433,180 @@ func testGetNextUpgradePods(t *testing.T, factoryPods FactoryPods, factorySideca
 	}
 }
 
+func TestCalculateUpdateStage(t *testing.T) {
+	namespaceLabels := map[string]labels.Set{
+		"dev":  {"env": "dev"},
+		"prod": {"env": "prod"},
+	}
+	getSidecarSet := func(oldStatus *appsv1alpha1.SidecarSetUpdateStageStatus) *appsv1alpha1.SidecarSet {
+		sidecarSet := factorySidecarSet()
+		sidecarSet.Spec.UpdateStrategy.Stages = []appsv1alpha1.SidecarSetUpdateStage{
+			{
+				Name:              "dev",
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}},
+				MaxUnavailable:    &intstr.IntOrString{Type: intstr.Int, IntVal: 2},
+				SoakSeconds:       60,
+			},
+			{
+				Name:              "prod",
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			},
+		}
+		sidecarSet.Status.UpdateStage = oldStatus
+		return sidecarSet
+	}
+	getPods := func(upgraded, upgradedAndReady int) []*corev1.Pod {
+		pods := factoryPods(10, upgraded, upgradedAndReady)
+		for i := range pods {
+			pods[i].Namespace = "prod"
+			if i < 4 {
+				pods[i].Namespace = "dev"
+			}
+		}
+		return pods
+	}
+	now := metav1.Now()
+	soakStartTime := metav1.NewTime(now.Add(-2 * time.Minute))
+
+	cases := []struct {
+		name             string
+		oldStatus        *appsv1alpha1.SidecarSetUpdateStageStatus
+		pause            bool
+		resume           string
+		upgraded         int
+		upgradedAndReady int
+		expectStatus     *appsv1alpha1.SidecarSetUpdateStageStatus
+		expectSoaking    bool
+		expectUpgrade    int
+	}{
+		{
+			name: "update pods in the first stage",
+			expectStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseUpdating,
+				MatchedPods: 4,
+			},
+			expectUpgrade: 2,
+		},
+		{
+			name:             "soak the first stage",
+			upgraded:         4,
+			upgradedAndReady: 4,
+			expectStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseSoaking,
+				SoakStartTime: &now, MatchedPods: 4, UpdatedPods: 4, UpdatedReadyPods: 4,
+			},
+			expectSoaking: true,
+		},
+		{
+			name: "update pods in the second stage after soaked",
+			oldStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseSoaking,
+				SoakStartTime: &soakStartTime,
+			},
+			upgraded:         4,
+			upgradedAndReady: 4,
+			expectStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 1, Name: "prod", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseUpdating,
+				MatchedPods: 10, UpdatedPods: 4, UpdatedReadyPods: 4,
+			},
+			expectUpgrade: 1,
+		},
+		{
+			name: "pause after soaked",
+			oldStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseSoaking,
+				SoakStartTime: &soakStartTime,
+			},
+			pause:            true,
+			upgraded:         4,
+			upgradedAndReady: 4,
+			expectStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhasePaused,
+				SoakStartTime: &soakStartTime, MatchedPods: 4, UpdatedPods: 4, UpdatedReadyPods: 4,
+			},
+		},
+		{
+			name: "not resumed for another revision",
+			oldStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhasePaused,
+				SoakStartTime: &soakStartTime,
+			},
+			pause:            true,
+			resume:           "aaa/0",
+			upgraded:         4,
+			upgradedAndReady: 4,
+			expectStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhasePaused,
+				SoakStartTime: &soakStartTime, MatchedPods: 4, UpdatedPods: 4, UpdatedReadyPods: 4,
+			},
+		},
+		{
+			name: "update pods in the second stage after resumed",
+			oldStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 0, Name: "dev", Phase: appsv1alpha1.SidecarSetUpdateStagePhasePaused,
+				SoakStartTime: &soakStartTime,
+			},
+			pause:            true,
+			resume:           "bbb/0",
+			upgraded:         4,
+			upgradedAndReady: 4,
+			expectStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 1, Name: "prod", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseUpdating,
+				MatchedPods: 10, UpdatedPods: 4, UpdatedReadyPods: 4,
+			},
+			expectUpgrade: 1,
+		},
+		{
+			name: "all stages completed",
+			oldStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 1, Name: "prod", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseUpdating,
+			},
+			upgraded:         10,
+			upgradedAndReady: 10,
+			expectStatus: &appsv1alpha1.SidecarSetUpdateStageStatus{
+				Revision: "bbb", CurrentStage: 1, Name: "prod", Phase: appsv1alpha1.SidecarSetUpdateStagePhaseCompleted,
+				MatchedPods: 10, UpdatedPods: 10, UpdatedReadyPods: 10,
+			},
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			sidecarSet := getSidecarSet(cs.oldStatus)
+			sidecarSet.Spec.UpdateStrategy.Stages[0].Pause = cs.pause
+			if cs.resume != "" {
+				sidecarSet.Annotations[appsv1alpha1.SidecarSetResumeStageKey] = cs.resume
+			}
+			control := sidecarcontrol.New(sidecarSet)
+			pods := getPods(cs.upgraded, cs.upgradedAndReady)
+			status, soakLeft, err := calculateUpdateStage(control, pods, namespaceLabels, now.Time)
+			if err != nil {
+				t.Fatalf("calculate update stage failed: %v", err)
+			}
+			if !reflect.DeepEqual(status, cs.expectStatus) {
+				t.Fatalf("expect status %v, but got %v", cs.expectStatus, status)
+			}
+			if (soakLeft > 0) != cs.expectSoaking {
+				t.Fatalf("expect soaking %v, but got soakLeft %v", cs.expectSoaking, soakLeft)
+			}
+
+			stageScope, err := getUpdateStageScope(control.GetSidecarset(), status, pods, namespaceLabels)
+			if err != nil {
+				t.Fatalf("get update stage scope failed: %v", err)
+			}
+			upgradePods, _ := NewStrategy().GetNextUpgradePods(control, pods, stageScope)
+			if len(upgradePods) != cs.expectUpgrade {
+				t.Fatalf("expect %d pods to upgrade, but got %d", cs.expectUpgrade, len(upgradePods))
+			}
+			for _, pod := range upgradePods {
+				if stageScope != nil && !stageScope.Pods.Has(getPodKey(pod)) {
+					t.Fatalf("expect pod %s in the current stage", getPodKey(pod))
+				}
+			}
+		})
+	}
+}
+
 func TestParseUpdateScatterTerms(t *testing.T) {
 	cases := []struct {
 		name                  string
@@ -689,7 +865,7 @@ func testSortNextUpgradePods(t *testing.T, factoryPods FactoryPods, factorySidec
 		t.Run(cs.name, func(t *testing.T) {
 			control := sidecarcontrol.New(cs.getSidecarset())
 			pods := cs.getPods()
-			injectedPods, _ := strategy.GetNextUpgradePods(control, pods)
+			injectedPods, _ := strategy.GetNextUpgradePods(control, pods, nil)
 			if len(cs.exceptNextUpgradePods) != len(injectedPods) {
 				t.Fatalf("except NeedUpgradeCount(%d), but get value(%d)", len(cs.exceptNextUpgradePods), len(injectedPods))
 			}
@@ -50,6 +50,7 @@ type StatefulPodControlObjectManager interface {
 	CreateClaim(claim *v1.PersistentVolumeClaim) error
 	GetClaim(namespace, claimName string) (*v1.PersistentVolumeClaim, error)
 	UpdateClaim(claim *v1.PersistentVolumeClaim) error
+	DeleteClaim(claim *v1.PersistentVolumeClaim) error
 	GetStorageClass(scName string) (*storagev1.StorageClass, error)
 }
 
@@ -117,6 +118,10 @@ func (om *realStatefulPodControlObjectManager) UpdateClaim(claim *v1.PersistentV
 	return err
 }
 
+func (om *realStatefulPodControlObjectManager) DeleteClaim(claim *v1.PersistentVolumeClaim) error {
+	return om.client.CoreV1().PersistentVolumeClaims(claim.Namespace).Delete(context.TODO(), claim.Name, metav1.DeleteOptions{})
+}
+
 func (om *realStatefulPodControlObjectManager) GetStorageClass(scName string) (*storagev1.StorageClass, error) {
 	return om.scLister.Get(scName)
 }
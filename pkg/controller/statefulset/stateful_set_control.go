@@ -27,6 +27,8 @@ import (
 
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -46,6 +48,7 @@ import (
 	"github.com/openkruise/kruise/pkg/util/inplaceupdate"
 	"github.com/openkruise/kruise/pkg/util/lifecycle"
 	"github.com/openkruise/kruise/pkg/util/specifieddelete"
+	"github.com/openkruise/kruise/pkg/util/updatesort"
 )
 
 // Realistic value for maximum in-flight requests when processing in parallel mode.
@@ -81,7 +84,8 @@ func NewDefaultStatefulSetControl(
 	lifecycleControl lifecycle.Interface,
 	statusUpdater StatusUpdaterInterface,
 	controllerHistory history.Interface,
-	recorder record.EventRecorder) StatefulSetControlInterface {
+	recorder record.EventRecorder,
+	snapshotControl VolumeSnapshotControl) StatefulSetControlInterface {
 	return &defaultStatefulSetControl{
 		podControl,
 		statusUpdater,
@@ -89,6 +93,7 @@ func NewDefaultStatefulSetControl(
 		recorder,
 		inplaceControl,
 		lifecycleControl,
+		snapshotControl,
 	}
 }
 
@@ -102,6 +107,7 @@ type defaultStatefulSetControl struct {
 	recorder          record.EventRecorder
 	inplaceControl    inplaceupdate.Interface
 	lifecycleControl  lifecycle.Interface
+	snapshotControl   VolumeSnapshotControl
 }
 
 // UpdateStatefulSet executes the core logic loop for a stateful set, applying the predictable and
@@ -126,7 +132,11 @@ func (ssc *defaultStatefulSetControl) UpdateStatefulSet(ctx context.Context, set
 	}
 
 	// maintain the set's revision history limit
-	return ssc.truncateHistory(set, pods, revisions, currentRevision, updateRevision)
+	if err = ssc.truncateHistory(set, pods, revisions, currentRevision, updateRevision); err != nil {
+		return err
+	}
+	// maintain the retention limit of VolumeSnapshots
+	return ssc.truncateVolumeSnapshots(set, pods, revisions)
 }
 
 func (ssc *defaultStatefulSetControl) performUpdate(
@@ -377,6 +387,11 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 
 	ssc.doPreDownload(set, currentRevision, updateRevision)
 
+	// surge Pods become regular replicas once the StatefulSet is scaled up to their ordinals
+	if err := ssc.adoptSurgePods(set, pods); err != nil {
+		return set.Status.DeepCopy(), err
+	}
+
 	// set the generation, and revisions in the returned status
 	status := appsv1beta1.StatefulSetStatus{}
 	status.ObservedGeneration = set.Generation
@@ -386,14 +401,26 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 	status.LabelSelector = selector.String()
 	minReadySeconds := getMinReadySeconds(set)
 
-	ssc.updatePVCStatus(&status, set, pods)
+	// surge Pods are not counted in the status, neither in the topology of update
+	nonSurgePods := getNonSurgePods(pods)
+	ssc.updatePVCStatus(&status, set, nonSurgePods)
 	updateStatus(&status, minReadySeconds, currentRevision, updateRevision, pods)
+	updateInPlaceUpdateCondition(set, &status, currentRevision, updateRevision)
+	status.LifecycleStates = lifecycle.CalculateLifecycleStates(set.Spec.Lifecycle, nonSurgePods)
+	var soakLeft time.Duration
+	if status.UpdateTopology, soakLeft = calculateUpdateTopology(set, updateRevision.Name, minReadySeconds, nonSurgePods, metav1.Now()); soakLeft > 0 {
+		durationStore.Push(getStatefulSetKey(set), soakLeft)
+	}
+	status.PendingOrdinals = getPendingOrdinals(set, updateRevision.Name, nonSurgePods)
 
 	startOrdinal, endOrdinal, reserveOrdinals := getStatefulSetReplicasRange(set)
 	// slice that will contain all Pods such that startOrdinal <= getOrdinal(pod) < endOrdinal and not in reserveOrdinals
 	replicas := make([]*v1.Pod, endOrdinal-startOrdinal)
 	// slice that will contain all Pods such that getOrdinal(pod) < startOrdinal or getOrdinal(pod) >= endOrdinal or in reserveOrdinals
 	condemned := make([]*v1.Pod, 0, len(pods))
+	// slice that will contain all surge Pods such that getOrdinal(pod) >= endOrdinal if maxSurge is set
+	var surge []*v1.Pod
+	maxSurge := getMaxSurge(set)
 	unhealthy := 0
 	firstUnhealthyOrdinal := math.MaxInt32
 	var firstUnhealthyPod *v1.Pod
@@ -406,6 +433,10 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 			// insert it at the indirection of its ordinal
 			replicas[ord-startOrdinal] = pods[i]
 
+		} else if ord >= endOrdinal && maxSurge > 0 && isSurgePod(pods[i]) {
+			// surge Pods are not condemned, they will be deleted after the rolling update
+			surge = append(surge, pods[i])
+
 		} else if ord >= 0 {
 			// if the ordinal is valid, but not within the range or in reserveOrdinals,
 			// add it to the condemned list
@@ -523,18 +554,21 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 	}
 
 	return ssc.rollingUpdateStatefulsetPods(
-		set, &status, currentRevision, updateRevision, revisions, pods, replicas, minReadySeconds,
+		ctx, set, updateSet, &status, currentRevision, updateRevision, revisions, pods, replicas, surge, minReadySeconds,
 	)
 }
 
 func (ssc *defaultStatefulSetControl) rollingUpdateStatefulsetPods(
+	ctx context.Context,
 	set *appsv1beta1.StatefulSet,
+	updateSet *appsv1beta1.StatefulSet,
 	status *appsv1beta1.StatefulSetStatus,
 	currentRevision *apps.ControllerRevision,
 	updateRevision *apps.ControllerRevision,
 	revisions []*apps.ControllerRevision,
 	pods []*v1.Pod,
 	replicas []*v1.Pod,
+	surge []*v1.Pod,
 	minReadySeconds int32,
 ) (*appsv1beta1.StatefulSetStatus, error) {
 
@@ -582,6 +616,13 @@ func (ssc *defaultStatefulSetControl) rollingUpdateStatefulsetPods(
 		}
 	}
 
+	// each available surge Pod allows one more replica to be unavailable for update
+	availableSurge, err := ssc.syncSurgePods(ctx, set, updateSet, updateRevision, pods, replicas, surge, minReadySeconds)
+	if err != nil {
+		return status, err
+	}
+	maxUnavailable += availableSurge
+
 	minWaitTime := appsv1beta1.MaxMinReadySeconds * time.Second
 	unavailablePods := sets.NewString()
 	opts := &inplaceupdate.UpdateOptions{}
@@ -608,8 +649,7 @@ func (ssc *defaultStatefulSetControl) rollingUpdateStatefulsetPods(
 				minWaitTime = waitTime
 				durationStore.Push(getStatefulSetKey(set), waitTime)
 			}
-		} else if utilfeature.DefaultFeatureGate.Enabled(features.StatefulSetAutoResizePVCGate) &&
-			set.Spec.VolumeClaimUpdateStrategy.Type == appsv1beta1.OnPodRollingUpdateVolumeClaimUpdateStrategyType {
+		} else if isVolumeClaimUpdatedOnPodRollingUpdate(set) {
 			// check pvc resize status, if not ready, record pod to unavailablePods
 			ready, err := ssc.podControl.IsOwnedPVCsReady(set, replicas[target])
 			if err == nil && ready {
@@ -633,12 +673,13 @@ func (ssc *defaultStatefulSetControl) rollingUpdateStatefulsetPods(
 	}
 
 	updateIndexes := sortPodsToUpdate(set.Spec.UpdateStrategy.RollingUpdate, updateRevision.Name, *set.Spec.Replicas, replicas)
+	// only pods in the current topology domain can update, and none of them when the domain is soaking
+	updateIndexes = updatesort.FilterUpdateTopology(getUpdateTopologyStrategy(set), status.UpdateTopology, replicas, updateIndexes)
 	klog.V(3).InfoS("Prepare to update pods indexes for StatefulSet", "statefulSet", klog.KObj(set), "podIndexes", updateIndexes)
 	// update pods in sequence
 	for _, target := range updateIndexes {
 		var pvcMatched bool = true
-		if utilfeature.DefaultFeatureGate.Enabled(features.StatefulSetAutoResizePVCGate) &&
-			set.Spec.VolumeClaimUpdateStrategy.Type == appsv1beta1.OnPodRollingUpdateVolumeClaimUpdateStrategyType {
+		if isVolumeClaimUpdatedOnPodRollingUpdate(set) {
 			if pvcMatched, err = ssc.podControl.IsClaimsCompatible(set, replicas[target]); err != nil {
 				return status, err
 			}
@@ -660,8 +701,39 @@ func (ssc *defaultStatefulSetControl) rollingUpdateStatefulsetPods(
 		// Kruise currently will not patch pvc size until a pod references the resized volume.
 		// online-file-system-expansion: if no pods referencing the volume are running, file system expansion will not happen.
 		// refer to https://kubernetes.io/blog/2018/07/12/resizing-persistent-volumes-using-kubernetes/#online-file-system-expansion
-		if utilfeature.DefaultFeatureGate.Enabled(features.StatefulSetAutoResizePVCGate) &&
-			set.Spec.VolumeClaimUpdateStrategy.Type == appsv1beta1.OnPodRollingUpdateVolumeClaimUpdateStrategyType {
+		if isVolumeClaimUpdatedOnPodRollingUpdate(set) {
+			// recreate the pvcs that cannot be updated in place together with the Pod
+			if !pvcMatched && isVolumeClaimRecreatedOnPodRollingUpdate(set) {
+				claimNames, err := ssc.podControl.GetClaimsToRecreate(set, replicas[target])
+				if err != nil {
+					return status, err
+				}
+				if len(claimNames) > 0 {
+					// never start to recreate the claims of a terminating pod, but the gates are still checked
+					// for the pod that has been marked before it is terminated by others
+					_, recreating := getRecreateClaimsState(replicas[target])
+					ready := false
+					if recreating || !isTerminating(replicas[target]) {
+						// snapshot the volumes first if necessary, which gives a rollback point for the deleted pvcs
+						if ready, err = ssc.snapshotVolumesBeforeUpdate(set, status, replicas[target], updateRevision.Name); err != nil {
+							return status, err
+						}
+					}
+					if ready {
+						actualDeleting, err := ssc.recreatePodClaims(set, replicas[target], claimNames)
+						if err != nil {
+							return status, err
+						}
+						if actualDeleting && getPodRevision(replicas[target]) == currentRevision.Name {
+							status.CurrentReplicas--
+						}
+					}
+					// mark target as unavailable because its pvcs are being recreated
+					unavailablePods.Insert(replicas[target].Name)
+					continue
+				}
+			}
+
 			// resize pvc if necessary and wait for resize completed
 			if !pvcMatched {
 				err = ssc.podControl.TryPatchPVC(set, replicas[target])
@@ -683,6 +755,13 @@ func (ssc *defaultStatefulSetControl) rollingUpdateStatefulsetPods(
 
 		// delete the Pod if it is not already terminating and does not match the update revision.
 		if !specifiedDeletedPods.Has(replicas[target].Name) && !isTerminating(replicas[target]) {
+			// snapshot the volumes of the Pod before update if necessary, and wait for them to be ready to use
+			if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, replicas[target], updateRevision.Name); err != nil {
+				return status, err
+			} else if !ready {
+				unavailablePods.Insert(replicas[target].Name)
+				continue
+			}
 			// todo validate in-place for pub
 			inplacing, inplaceUpdateErr := ssc.inPlaceUpdatePod(set, replicas[target], updateRevision, revisions)
 			if inplaceUpdateErr != nil {
@@ -710,6 +789,163 @@ func (ssc *defaultStatefulSetControl) rollingUpdateStatefulsetPods(
 	return status, nil
 }
 
+// syncSurgePods creates surge Pods in update revision with the ordinals after the last replica when there are replicas
+// waiting for update, and deletes them once all the replicas in update revision are available.
+// It returns the number of available surge Pods.
+func (ssc *defaultStatefulSetControl) syncSurgePods(
+	ctx context.Context,
+	set *appsv1beta1.StatefulSet,
+	updateSet *appsv1beta1.StatefulSet,
+	updateRevision *apps.ControllerRevision,
+	pods []*v1.Pod,
+	replicas []*v1.Pod,
+	surge []*v1.Pod,
+	minReadySeconds int32,
+) (int, error) {
+	maxSurge := getMaxSurge(set)
+	if maxSurge <= 0 && len(surge) == 0 {
+		return 0, nil
+	}
+
+	var waitUpdate int
+	for _, target := range sortPodsToUpdate(set.Spec.UpdateStrategy.RollingUpdate, updateRevision.Name, *set.Spec.Replicas, replicas) {
+		if getPodRevision(replicas[target]) != updateRevision.Name {
+			waitUpdate++
+		}
+	}
+	allUpdatedAvailable := true
+	for _, pod := range replicas {
+		if pod == nil || getPodRevision(pod) != updateRevision.Name {
+			continue
+		}
+		if isAvailable, _ := isRunningAndAvailable(pod, minReadySeconds); !isAvailable || !isHealthy(pod) {
+			allUpdatedAvailable = false
+		}
+	}
+
+	// surge Pods in old revisions or more than maxSurge should be deleted,
+	// and all of them should be deleted when the update has been finished
+	var validSurge, surgeToDelete []*v1.Pod
+	for _, pod := range surge {
+		if isTerminating(pod) {
+			continue
+		}
+		if getPodRevision(pod) != updateRevision.Name || len(validSurge) >= maxSurge || (waitUpdate == 0 && allUpdatedAvailable) {
+			surgeToDelete = append(surgeToDelete, pod)
+		} else {
+			validSurge = append(validSurge, pod)
+		}
+	}
+	for _, pod := range surgeToDelete {
+		klog.V(2).InfoS("StatefulSet terminating surge Pod", "statefulSet", klog.KObj(set), "pod", klog.KObj(pod))
+		if _, _, err := ssc.deletePod(set, pod); err != nil {
+			return 0, err
+		}
+		// the claims of surge Pods are always deleted together, whatever the retention policy is
+		if err := ssc.deleteSurgePodClaims(updateSet, pod); err != nil {
+			return 0, err
+		}
+	}
+
+	var availableSurge int
+	for _, pod := range validSurge {
+		if isAvailable, waitTime := isRunningAndAvailable(pod, minReadySeconds); isAvailable && isHealthy(pod) {
+			availableSurge++
+		} else if waitTime > 0 {
+			durationStore.Push(getStatefulSetKey(set), waitTime)
+		}
+	}
+
+	surgeToCreate := integer.IntMin(maxSurge, waitUpdate) - len(validSurge)
+	if surgeToCreate <= 0 {
+		return availableSurge, nil
+	}
+	usedOrdinals := sets.New[int]()
+	for _, pod := range pods {
+		usedOrdinals.Insert(getOrdinal(pod))
+	}
+	_, endOrdinal, _ := getStatefulSetReplicasRange(set)
+	for ord := endOrdinal; surgeToCreate > 0; ord++ {
+		if usedOrdinals.Has(ord) {
+			continue
+		}
+		// never reuse the claims retained by the replicas scaled down before, which will be deleted with the surge Pod
+		if hasClaims, err := ssc.hasOrdinalClaims(updateSet, ord); err != nil {
+			return availableSurge, err
+		} else if hasClaims {
+			continue
+		}
+		pod := newStatefulSetPod(updateSet, ord)
+		setPodRevision(pod, updateRevision.Name)
+		pod.Labels[appsv1beta1.StatefulSetSurgePodLabelKey] = "true"
+		state := appspub.LifecycleStatePreparingNormal
+		if set.Spec.Lifecycle == nil ||
+			set.Spec.Lifecycle.PreNormal == nil ||
+			lifecycle.IsPodAllHooked(set.Spec.Lifecycle.PreNormal, pod) {
+			state = appspub.LifecycleStateNormal
+		}
+		lifecycle.SetPodLifecycle(state)(pod)
+		klog.V(2).InfoS("StatefulSet creating surge Pod", "statefulSet", klog.KObj(set), "pod", klog.KObj(pod))
+		if err := ssc.podControl.CreateStatefulPod(ctx, set, pod); err != nil {
+			return availableSurge, err
+		}
+		surgeToCreate--
+	}
+	return availableSurge, nil
+}
+
+// adoptSurgePods removes the surge label from the surge Pods within the range of replicas,
+// which happens if the StatefulSet is scaled up during the rolling update.
+func (ssc *defaultStatefulSetControl) adoptSurgePods(set *appsv1beta1.StatefulSet, pods []*v1.Pod) error {
+	startOrdinal, endOrdinal, reserveOrdinals := getStatefulSetReplicasRange(set)
+	for i := range pods {
+		if !isSurgePod(pods[i]) || !podInOrdinalRangeWithParams(pods[i], startOrdinal, endOrdinal, reserveOrdinals) {
+			continue
+		}
+		clone := pods[i].DeepCopy()
+		delete(clone.Labels, appsv1beta1.StatefulSetSurgePodLabelKey)
+		if err := ssc.podControl.objectMgr.UpdatePod(clone); err != nil {
+			return fmt.Errorf("failed to adopt surge Pod %s as a replica: %v", pods[i].Name, err)
+		}
+		klog.V(2).InfoS("StatefulSet adopted surge Pod as a replica", "statefulSet", klog.KObj(set), "pod", klog.KObj(pods[i]))
+		pods[i] = clone
+	}
+	return nil
+}
+
+// hasOrdinalClaims returns whether any claim of the templates exists for the ordinal.
+func (ssc *defaultStatefulSetControl) hasOrdinalClaims(set *appsv1beta1.StatefulSet, ordinal int) (bool, error) {
+	for i := range set.Spec.VolumeClaimTemplates {
+		claimName := getPersistentVolumeClaimName(set, &set.Spec.VolumeClaimTemplates[i], ordinal)
+		if _, err := ssc.podControl.objectMgr.GetClaim(set.Namespace, claimName); err == nil {
+			return true, nil
+		} else if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// deleteSurgePodClaims deletes the claims created for the surge Pod.
+func (ssc *defaultStatefulSetControl) deleteSurgePodClaims(set *appsv1beta1.StatefulSet, pod *v1.Pod) error {
+	for _, claim := range getPersistentVolumeClaims(set, pod) {
+		existing, err := ssc.podControl.objectMgr.GetClaim(set.Namespace, claim.Name)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		} else if existing.DeletionTimestamp != nil {
+			continue
+		}
+		err = ssc.podControl.objectMgr.DeleteClaim(existing)
+		ssc.podControl.recordClaimEvent("delete", set, pod, existing, err)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (ssc *defaultStatefulSetControl) handleSpecifiedDeletedPods(
 	set *appsv1beta1.StatefulSet,
 	status *appsv1beta1.StatefulSetStatus,
@@ -752,7 +988,8 @@ func (ssc *defaultStatefulSetControl) handleSpecifiedDeletedPods(
 }
 
 func (ssc *defaultStatefulSetControl) deletePod(set *appsv1beta1.StatefulSet, pod *v1.Pod) (modified, actualDeleting bool, err error) {
-	if set.Spec.Lifecycle != nil && lifecycle.IsPodHooked(set.Spec.Lifecycle.PreDelete, pod) {
+	if set.Spec.Lifecycle != nil && lifecycle.IsPodHooked(set.Spec.Lifecycle.PreDelete, pod) &&
+		!ssc.isPreDeleteHookProceeded(set, pod) {
 		markPodNotReady := set.Spec.Lifecycle.PreDelete.MarkPodNotReady
 		if updated, _, err := ssc.lifecycleControl.UpdatePodLifecycle(pod, appspub.LifecycleStatePreparingDelete, markPodNotReady); err != nil {
 			return false, false, err
@@ -762,6 +999,12 @@ func (ssc *defaultStatefulSetControl) deletePod(set *appsv1beta1.StatefulSet, po
 		}
 		return false, false, nil
 	}
+	// the pod proceeds without the PreDelete hook, whose finalizers should not hold it from being deleted
+	if set.Spec.Lifecycle != nil && lifecycle.IsPodHooked(set.Spec.Lifecycle.PreDelete, pod) {
+		if _, _, err := ssc.lifecycleControl.RemovePodHook(pod, set.Spec.Lifecycle.PreDelete); err != nil {
+			return false, false, err
+		}
+	}
 	if err := ssc.podControl.DeleteStatefulPod(set, pod); err != nil {
 		ssc.recorder.Eventf(set, v1.EventTypeWarning, "FailedDelete", "failed to delete pod %s: %v", pod.Name, err)
 		return false, false, err
@@ -769,12 +1012,28 @@ func (ssc *defaultStatefulSetControl) deletePod(set *appsv1beta1.StatefulSet, po
 	return true, true, nil
 }
 
+// isPreDeleteHookProceeded returns true if the pod in PreparingDelete state can be deleted without waiting for
+// the PreDelete hook, otherwise requeues the StatefulSet to check the hook again if needed.
+func (ssc *defaultStatefulSetControl) isPreDeleteHookProceeded(set *appsv1beta1.StatefulSet, pod *v1.Pod) bool {
+	proceed, left := ssc.lifecycleControl.CheckHook(ssc.recorder, set.Spec.Lifecycle.PreDelete, pod, appspub.LifecycleStatePreparingDelete)
+	if left > 0 {
+		durationStore.Push(getStatefulSetKey(set), left)
+	}
+	return proceed
+}
+
 func (ssc *defaultStatefulSetControl) refreshPodState(set *appsv1beta1.StatefulSet, pod *v1.Pod, updateRevision string) (bool, time.Duration, error) {
 	opts := &inplaceupdate.UpdateOptions{}
 	if set.Spec.UpdateStrategy.RollingUpdate != nil && set.Spec.UpdateStrategy.RollingUpdate.InPlaceUpdateStrategy != nil {
 		opts.GracePeriodSeconds = set.Spec.UpdateStrategy.RollingUpdate.InPlaceUpdateStrategy.GracePeriodSeconds
+		opts.ResizeTimeoutSeconds = set.Spec.UpdateStrategy.RollingUpdate.InPlaceUpdateStrategy.ResizeTimeoutSeconds
 	}
 	opts = inplaceupdate.SetOptionsDefaults(opts)
+	if set.Spec.Lifecycle != nil && set.Spec.Lifecycle.PostInPlaceUpdate != nil {
+		opts.CheckPostUpdateHook = func(pod *v1.Pod, updateTime time.Time) (bool, time.Duration) {
+			return ssc.lifecycleControl.CheckPostInPlaceUpdateHook(ssc.recorder, set.Spec.Lifecycle.PostInPlaceUpdate, pod, updateTime)
+		}
+	}
 
 	res := ssc.inplaceControl.Refresh(pod, opts)
 	if res.RefreshErr != nil {
@@ -782,14 +1041,22 @@ func (ssc *defaultStatefulSetControl) refreshPodState(set *appsv1beta1.StatefulS
 			"statefulSet", klog.KObj(set), "pod", klog.KObj(pod))
 		return false, 0, res.RefreshErr
 	}
+	if res.ResizeFailed {
+		return ssc.handleResizeFailed(set, pod)
+	}
 
 	var state appspub.LifecycleStateType
+	var hookTimeout time.Duration
 	switch lifecycle.GetPodLifecycleState(pod) {
 	case appspub.LifecycleStatePreparingNormal:
 		if set.Spec.Lifecycle == nil ||
 			set.Spec.Lifecycle.PreNormal == nil ||
 			lifecycle.IsPodAllHooked(set.Spec.Lifecycle.PreNormal, pod) {
 			state = appspub.LifecycleStateNormal
+		} else if proceed, left := ssc.lifecycleControl.CheckHook(ssc.recorder, set.Spec.Lifecycle.PreNormal, pod, appspub.LifecycleStatePreparingNormal); proceed {
+			state = appspub.LifecycleStateNormal
+		} else {
+			hookTimeout = left
 		}
 	case appspub.LifecycleStatePreparingUpdate:
 		// when pod updated to PreparingUpdate state to wait lifecycle blocker to remove,
@@ -803,7 +1070,7 @@ func (ssc *defaultStatefulSetControl) refreshPodState(set *appsv1beta1.StatefulS
 			}
 		}
 	case appspub.LifecycleStateUpdating:
-		if opts.CheckPodUpdateCompleted(pod) == nil {
+		if !res.PostUpdateHooked && opts.CheckPodUpdateCompleted(pod) == nil {
 			if set.Spec.Lifecycle != nil && !lifecycle.IsPodAllHooked(set.Spec.Lifecycle.InPlaceUpdate, pod) {
 				state = appspub.LifecycleStateUpdated
 			} else {
@@ -815,8 +1082,15 @@ func (ssc *defaultStatefulSetControl) refreshPodState(set *appsv1beta1.StatefulS
 			set.Spec.Lifecycle.InPlaceUpdate == nil ||
 			lifecycle.IsPodAllHooked(set.Spec.Lifecycle.InPlaceUpdate, pod) {
 			state = appspub.LifecycleStateNormal
+		} else if proceed, left := ssc.lifecycleControl.CheckHook(ssc.recorder, set.Spec.Lifecycle.InPlaceUpdate, pod, appspub.LifecycleStateUpdated); proceed {
+			state = appspub.LifecycleStateNormal
+		} else {
+			hookTimeout = left
 		}
 	}
+	if hookTimeout > 0 && (res.DelayDuration == 0 || hookTimeout < res.DelayDuration) {
+		res.DelayDuration = hookTimeout
+	}
 
 	if state != "" {
 		var markPodNotReady bool
@@ -834,6 +1108,33 @@ func (ssc *defaultStatefulSetControl) refreshPodState(set *appsv1beta1.StatefulS
 	return false, res.DelayDuration, nil
 }
 
+// handleResizeFailed deletes the pod whose in-place resize has timed out if the ResizeFailurePolicy is Recreate,
+// otherwise the pod is kept with InPlaceUpdateReady condition False, and the event is only recorded when it turns False.
+func (ssc *defaultStatefulSetControl) handleResizeFailed(set *appsv1beta1.StatefulSet, pod *v1.Pod) (bool, time.Duration, error) {
+	rollingUpdate := set.Spec.UpdateStrategy.RollingUpdate
+	if rollingUpdate == nil || rollingUpdate.InPlaceUpdateStrategy == nil ||
+		rollingUpdate.InPlaceUpdateStrategy.ResizeFailurePolicy != appspub.InPlaceResizeFailurePolicyRecreate ||
+		rollingUpdate.PodUpdatePolicy == appsv1beta1.InPlaceOnlyPodUpdateStrategyType {
+		if !inplaceupdate.IsResizeFailed(pod) {
+			ssc.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateResizeFailedReason,
+				"in-place resize timed out with resize status %s", pod.Status.Resize)
+		}
+		return false, 0, nil
+	}
+	if isTerminating(pod) {
+		return false, 0, nil
+	}
+
+	klog.InfoS("AdvancedStatefulSet deletes Pod whose in-place resize timed out", "statefulSet", klog.KObj(set), "pod", klog.KObj(pod), "resize", pod.Status.Resize)
+	if err := ssc.podControl.DeleteStatefulPod(set, pod); err != nil {
+		ssc.recorder.Eventf(set, v1.EventTypeWarning, "FailedDelete", "failed to delete pod %s: %v", pod.Name, err)
+		return false, 0, err
+	}
+	ssc.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateResizeFailedReason,
+		"in-place resize timed out with resize status %s, fall back to recreate", pod.Status.Resize)
+	return true, 0, nil
+}
+
 func (ssc *defaultStatefulSetControl) inPlaceUpdatePod(
 	set *appsv1beta1.StatefulSet, pod *v1.Pod,
 	updateRevision *apps.ControllerRevision, revisions []*apps.ControllerRevision,
@@ -859,7 +1160,8 @@ func (ssc *defaultStatefulSetControl) inPlaceUpdatePod(
 		opts.GracePeriodSeconds = set.Spec.UpdateStrategy.RollingUpdate.InPlaceUpdateStrategy.GracePeriodSeconds
 	}
 
-	if ssc.inplaceControl.CanUpdateInPlace(oldRevision, updateRevision, opts) {
+	canInPlace, reasons := ssc.inplaceControl.CanUpdateInPlace(oldRevision, updateRevision, opts)
+	if canInPlace {
 		state := lifecycle.GetPodLifecycleState(pod)
 		switch state {
 		case "", appspub.LifecycleStatePreparingNormal, appspub.LifecycleStateNormal:
@@ -885,7 +1187,13 @@ func (ssc *defaultStatefulSetControl) inPlaceUpdatePod(
 			return true, err
 		case appspub.LifecycleStatePreparingUpdate:
 			if set.Spec.Lifecycle != nil && lifecycle.IsPodHooked(set.Spec.Lifecycle.InPlaceUpdate, pod) {
-				return true, nil
+				proceed, left := ssc.lifecycleControl.CheckHook(ssc.recorder, set.Spec.Lifecycle.InPlaceUpdate, pod, appspub.LifecycleStatePreparingUpdate)
+				if !proceed {
+					if left > 0 {
+						durationStore.Push(getStatefulSetKey(set), left)
+					}
+					return true, nil
+				}
 			}
 		case appspub.LifecycleStateUpdating:
 		default:
@@ -895,6 +1203,9 @@ func (ssc *defaultStatefulSetControl) inPlaceUpdatePod(
 		if state != "" {
 			opts.AdditionalFuncs = append(opts.AdditionalFuncs, lifecycle.SetPodLifecycle(appspub.LifecycleStateUpdating))
 		}
+		if set.Spec.Lifecycle != nil && set.Spec.Lifecycle.PostInPlaceUpdate != nil {
+			opts.AdditionalFuncs = append(opts.AdditionalFuncs, lifecycle.SetPodHook(set.Spec.Lifecycle.PostInPlaceUpdate))
+		}
 		res := ssc.inplaceControl.Update(pod, oldRevision, updateRevision, opts)
 		if res.InPlaceUpdate {
 			if res.DelayDuration > 0 {
@@ -911,10 +1222,15 @@ func (ssc *defaultStatefulSetControl) inPlaceUpdatePod(
 		}
 	}
 
+	message := inplaceupdate.FormatNotInPlaceUpdateReasons(reasons)
 	if set.Spec.UpdateStrategy.RollingUpdate.PodUpdatePolicy == appsv1beta1.InPlaceOnlyPodUpdateStrategyType {
-		return false, fmt.Errorf("find strategy is InPlaceOnly but Pod %s can not update in-place", pod.Name)
+		ssc.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateBlockedReason,
+			"could not update in-place to revision %s: %s", updateRevision.Name, message)
+		return false, fmt.Errorf("find strategy is InPlaceOnly but Pod %s can not update in-place: %s", pod.Name, message)
 	}
 
+	ssc.recorder.Eventf(pod, v1.EventTypeWarning, appspub.InPlaceUpdateFallbackToRecreateReason,
+		"could not update in-place to revision %s, fall back to recreate: %s", updateRevision.Name, message)
 	return false, nil
 }
 
@@ -957,7 +1273,7 @@ type replicaStatus struct {
 func computeReplicaStatus(pods []*v1.Pod, minReadySeconds int32, currentRevision, updateRevision *apps.ControllerRevision) replicaStatus {
 	status := replicaStatus{}
 	for _, pod := range pods {
-		if pod == nil {
+		if pod == nil || isSurgePod(pod) {
 			continue
 		}
 		if isCreated(pod) {
@@ -1123,7 +1439,19 @@ func (ssc *defaultStatefulSetControl) processReplica(
 			lifecycle.IsPodAllHooked(set.Spec.Lifecycle.PreNormal, replicas[i]) {
 			state = appspub.LifecycleStateNormal
 		}
+		if isVolumeClaimRecreatedOnPodRollingUpdate(set) {
+			// wait for the pvcs being recreated to be deleted, so that new pvcs can be created for the Pod
+			if terminating, err := ssc.podControl.HasTerminatingClaims(set, replicas[i]); err != nil {
+				return true, false, err
+			} else if terminating {
+				durationStore.Push(getStatefulSetKey(set), terminatingClaimsRequeueDuration)
+				return true, false, nil
+			}
+		}
 		lifecycle.SetPodLifecycle(state)(replicas[i])
+		if err := ssc.setVolumeSnapshotsAnnotation(set, replicas[i]); err != nil {
+			return true, false, err
+		}
 		if err := ssc.podControl.CreateStatefulPod(ctx, set, replicas[i]); err != nil {
 			msg := fmt.Sprintf("StatefulPodControl failed to create Pod error: %s", err)
 			condition := NewStatefulsetCondition(appsv1beta1.FailedCreatePod, v1.ConditionTrue, "", msg)
@@ -83,7 +83,7 @@ func setupController(client clientset.Interface, kruiseClient kruiseclientset.In
 	recorder := &noopRecorder{}
 	inplaceControl := inplaceupdate.NewForInformer(informerFactory.Core().V1().Pods(), revisionadapter.NewDefaultImpl())
 	lifecycleControl := lifecycle.NewForInformer(informerFactory.Core().V1().Pods())
-	ssc := NewDefaultStatefulSetControl(spc, inplaceControl, lifecycleControl, ssu, history.NewFakeHistory(informerFactory.Apps().V1().ControllerRevisions()), recorder)
+	ssc := NewDefaultStatefulSetControl(spc, inplaceControl, lifecycleControl, ssu, history.NewFakeHistory(informerFactory.Apps().V1().ControllerRevisions()), recorder, nil)
 
 	stop := make(chan struct{})
 	informerFactory.Start(stop)
@@ -693,7 +693,7 @@ func TestStatefulSetControl_getSetRevisions(t *testing.T) {
 		recorder := record.NewFakeRecorder(10)
 		inplaceControl := inplaceupdate.NewForInformer(informerFactory.Core().V1().Pods(), revisionadapter.NewDefaultImpl())
 		lifecycleControl := lifecycle.NewForInformer(informerFactory.Core().V1().Pods())
-		ssc := defaultStatefulSetControl{spc, ssu, history.NewFakeHistory(informerFactory.Apps().V1().ControllerRevisions()), recorder, inplaceControl, lifecycleControl}
+		ssc := defaultStatefulSetControl{spc, ssu, history.NewFakeHistory(informerFactory.Apps().V1().ControllerRevisions()), recorder, inplaceControl, lifecycleControl, nil}
 
 		stop := make(chan struct{})
 		defer close(stop)
@@ -2220,6 +2220,138 @@ func TestStatefulSetControlRollingUpdateBlockByMaxUnavailable(t *testing.T) {
 	}
 }
 
+func TestStatefulSetControlRollingUpdateWithMaxSurge(t *testing.T) {
+	set := burst(newStatefulSet(3))
+	var maxUnavailable = intstr.FromInt(1)
+	var maxSurge = intstr.FromInt(1)
+	set.Spec.UpdateStrategy = appsv1beta1.StatefulSetUpdateStrategy{
+		Type: apps.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1beta1.RollingUpdateStatefulSetStrategy{
+			Partition:      utilpointer.Int32(0),
+			MaxUnavailable: &maxUnavailable,
+			MaxSurge:       &maxSurge,
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	kruiseClient := kruisefake.NewSimpleClientset(set)
+	spc, _, ssc, stop := setupController(client, kruiseClient)
+	defer close(stop)
+	if err := scaleUpStatefulSetControl(set, ssc, spc, assertBurstInvariants); err != nil {
+		t.Fatal(err)
+	}
+	set, err := spc.setsLister.StatefulSets(set.Namespace).Get(set.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(set.Spec.Selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalPods, err := spc.podsLister.Pods(set.Namespace).List(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(ascendingOrdinal(originalPods))
+
+	// start to update, create a surge pod and update pod 2
+	set.Spec.Template.Spec.Containers[0].Image = "foo"
+	if err = ssc.UpdateStatefulSet(context.TODO(), set, originalPods); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := spc.podsLister.Pods(set.Namespace).List(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(ascendingOrdinal(pods))
+	if len(pods) != 3 {
+		t.Fatalf("Expected 3 pods, got pods %v", pods)
+	}
+	if !reflect.DeepEqual(pods[:2], originalPods[:2]) {
+		t.Fatalf("Expected pods %v, got pods %v", originalPods[:2], pods[:2])
+	}
+	surgePod := pods[2]
+	if getOrdinal(surgePod) != 3 || !isSurgePod(surgePod) {
+		t.Fatalf("Expected surge pod with ordinal 3, got %v", surgePod)
+	}
+	if getPodRevision(surgePod) == getPodRevision(originalPods[0]) {
+		t.Fatalf("Expected surge pod in update revision, got %s", getPodRevision(surgePod))
+	}
+	for _, claim := range getPersistentVolumeClaims(set, surgePod) {
+		if _, err := spc.claimsLister.PersistentVolumeClaims(set.Namespace).Get(claim.Name); err != nil {
+			t.Fatalf("Expected claim %s of surge pod to be created, got %v", claim.Name, err)
+		}
+	}
+
+	// the available surge pod allows one more replica to be unavailable, so pod 1 is updated before pod 2 is ready
+	if _, err = spc.setPodRunning(set, 2); err != nil {
+		t.Fatal(err)
+	}
+	if pods, err = spc.setPodReady(set, 2); err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(ascendingOrdinal(pods))
+	if err = ssc.UpdateStatefulSet(context.TODO(), set, pods); err != nil {
+		t.Fatal(err)
+	}
+	pods, err = spc.podsLister.Pods(set.Namespace).List(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Sort(ascendingOrdinal(pods))
+	if len(pods) != 3 || getOrdinal(pods[1]) != 2 || getOrdinal(pods[2]) != 3 {
+		t.Fatalf("Expected pods 0, 2 and surge pod 3, got pods %v", pods)
+	}
+	if isRunningAndReady(pods[1]) || getPodRevision(pods[1]) != getPodRevision(surgePod) {
+		t.Fatalf("Expected pod 2 created in update revision and not ready, got %v", pods[1])
+	}
+	// the surge pod is not counted in status
+	if set, err = spc.setsLister.StatefulSets(set.Namespace).Get(set.Name); err != nil {
+		t.Fatal(err)
+	}
+	if set.Status.Replicas != 2 || set.Status.UpdatedReplicas != 0 {
+		t.Fatalf("Expected 2 replicas and 0 updated replicas in status, got %d and %d", set.Status.Replicas, set.Status.UpdatedReplicas)
+	}
+
+	// the surge pod and its claims are deleted after all replicas are updated and available
+	for i := 0; i < 5; i++ {
+		for j := range pods {
+			if !isRunningAndReady(pods[j]) {
+				if _, err = spc.setPodRunning(set, j); err != nil {
+					t.Fatal(err)
+				}
+				if _, err = spc.setPodReady(set, j); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if pods, err = spc.podsLister.Pods(set.Namespace).List(selector); err != nil {
+			t.Fatal(err)
+		}
+		sort.Sort(ascendingOrdinal(pods))
+		if err = ssc.UpdateStatefulSet(context.TODO(), set, pods); err != nil {
+			t.Fatal(err)
+		}
+		if pods, err = spc.podsLister.Pods(set.Namespace).List(selector); err != nil {
+			t.Fatal(err)
+		}
+		sort.Sort(ascendingOrdinal(pods))
+	}
+	if len(pods) != 3 || getOrdinal(pods[2]) != 2 {
+		t.Fatalf("Expected surge pod to be deleted, got pods %v", pods)
+	}
+	for _, pod := range pods {
+		if getPodRevision(pod) != getPodRevision(surgePod) {
+			t.Fatalf("Expected pod %s in update revision, got %s", pod.Name, getPodRevision(pod))
+		}
+	}
+	for _, claim := range getPersistentVolumeClaims(set, surgePod) {
+		if _, err := spc.claimsLister.PersistentVolumeClaims(set.Namespace).Get(claim.Name); !apierrors.IsNotFound(err) {
+			t.Fatalf("Expected claim %s of surge pod to be deleted, got %v", claim.Name, err)
+		}
+	}
+}
+
 func TestStatefulSetControlRollingUpdateWithSpecifiedDelete(t *testing.T) {
 	set := burst(newStatefulSet(6))
 	var partition int32 = 3
@@ -3951,6 +4083,10 @@ func (om *fakeObjectManager) UpdateClaim(claim *v1.PersistentVolumeClaim) error
 	return nil
 }
 
+func (om *fakeObjectManager) DeleteClaim(claim *v1.PersistentVolumeClaim) error {
+	return om.claimsIndexer.Delete(claim)
+}
+
 func (om *fakeObjectManager) GetStorageClass(scName string) (*storagev1.StorageClass, error) {
 	return om.scLister.Get(scName)
 }
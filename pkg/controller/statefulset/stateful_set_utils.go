@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
@@ -31,6 +32,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -43,6 +45,7 @@ import (
 	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
 	"github.com/openkruise/kruise/pkg/features"
 	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+	"github.com/openkruise/kruise/pkg/util/inplaceupdate"
 	"github.com/openkruise/kruise/pkg/util/lifecycle"
 	"github.com/openkruise/kruise/pkg/util/revision"
 )
@@ -468,6 +471,37 @@ func getMinReadySeconds(set *appsv1beta1.StatefulSet) int32 {
 	return *set.Spec.UpdateStrategy.RollingUpdate.MinReadySeconds
 }
 
+// getMaxSurge returns the max number of surge pods during rolling update, default is 0
+func getMaxSurge(set *appsv1beta1.StatefulSet) int {
+	if set.Spec.UpdateStrategy.Type == apps.OnDeleteStatefulSetStrategyType ||
+		set.Spec.UpdateStrategy.RollingUpdate == nil ||
+		set.Spec.UpdateStrategy.RollingUpdate.MaxSurge == nil {
+		return 0
+	}
+	maxSurge, err := intstrutil.GetValueFromIntOrPercent(set.Spec.UpdateStrategy.RollingUpdate.MaxSurge, int(*set.Spec.Replicas), true)
+	if err != nil {
+		return 0
+	}
+	return maxSurge
+}
+
+// isSurgePod returns true if the pod is created for rollingUpdate.maxSurge
+func isSurgePod(pod *v1.Pod) bool {
+	return pod.Labels[appsv1beta1.StatefulSetSurgePodLabelKey] == "true"
+}
+
+// getNonSurgePods returns the pods except the surge ones, which are not counted as replicas of the StatefulSet
+func getNonSurgePods(pods []*v1.Pod) []*v1.Pod {
+	nonSurgePods := make([]*v1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod != nil && isSurgePod(pod) {
+			continue
+		}
+		nonSurgePods = append(nonSurgePods, pod)
+	}
+	return nonSurgePods
+}
+
 // setPodRevision sets the revision of Pod to revision by adding the StatefulSetRevisionLabel
 func setPodRevision(pod *v1.Pod, revision string) {
 	if pod.Labels == nil {
@@ -491,6 +525,7 @@ func newStatefulSetPod(set *appsv1beta1.StatefulSet, ordinal int) *v1.Pod {
 	pod.Name = getPodName(set, ordinal)
 	initIdentity(set, pod)
 	updateStorage(set, pod)
+	inplaceupdate.ProjectContainerValues(pod)
 	return pod
 }
 
@@ -519,6 +554,9 @@ func isCurrentRevisionNeeded(set *appsv1beta1.StatefulSet, updateRevision string
 	if set.Spec.UpdateStrategy.RollingUpdate == nil {
 		return ordinal < getStartOrdinal(set)+int(set.Status.CurrentReplicas)
 	}
+	if ordinals := set.Spec.UpdateStrategy.RollingUpdate.Ordinals; len(ordinals) > 0 {
+		return !apiutil.GetReserveOrdinalIntSet(ordinals).Has(ordinal)
+	}
 	if set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate == nil {
 		unreservedPodsNum := 0
 		// assume all pods [0, idx) are created and only reserved pods are nil
@@ -646,7 +684,14 @@ func inconsistentStatus(set *appsv1beta1.StatefulSet, status *appsv1beta1.Statef
 		status.UpdatedReplicas != set.Status.UpdatedReplicas ||
 		status.CurrentRevision != set.Status.CurrentRevision ||
 		status.UpdateRevision != set.Status.UpdateRevision ||
-		status.LabelSelector != set.Status.LabelSelector {
+		status.LabelSelector != set.Status.LabelSelector ||
+		!reflect.DeepEqual(GetStatefulsetConditition(*status, appsv1beta1.InPlaceUpdateNotPossible),
+			GetStatefulsetConditition(set.Status, appsv1beta1.InPlaceUpdateNotPossible)) ||
+		!reflect.DeepEqual(GetStatefulsetConditition(*status, appsv1beta1.VolumeSnapshotTimeout),
+			GetStatefulsetConditition(set.Status, appsv1beta1.VolumeSnapshotTimeout)) ||
+		!reflect.DeepEqual(status.LifecycleStates, set.Status.LifecycleStates) ||
+		!reflect.DeepEqual(status.UpdateTopology, set.Status.UpdateTopology) ||
+		!reflect.DeepEqual(status.PendingOrdinals, set.Status.PendingOrdinals) {
 		return true
 	}
 
@@ -726,6 +771,34 @@ func NewStatefulsetCondition(conditionType apps.StatefulSetConditionType, condit
 	}
 }
 
+// updateInPlaceUpdateCondition sets the InPlaceUpdateNotPossible condition into status if pods of current revision
+// can not be updated in place to the update revision, with the fields that prevent it in the message.
+func updateInPlaceUpdateCondition(set *appsv1beta1.StatefulSet, status *appsv1beta1.StatefulSetStatus, currentRevision, updateRevision *apps.ControllerRevision) {
+	if set.Spec.UpdateStrategy.RollingUpdate == nil || currentRevision.Name == updateRevision.Name {
+		return
+	}
+	policy := set.Spec.UpdateStrategy.RollingUpdate.PodUpdatePolicy
+	if policy != appsv1beta1.InPlaceIfPossiblePodUpdateStrategyType && policy != appsv1beta1.InPlaceOnlyPodUpdateStrategyType {
+		return
+	}
+	canInPlace, reasons := inplaceupdate.CheckInPlaceUpdate(currentRevision, updateRevision, &inplaceupdate.UpdateOptions{})
+	if canInPlace {
+		return
+	}
+
+	reason := appspub.InPlaceUpdateFallbackToRecreateReason
+	if policy == appsv1beta1.InPlaceOnlyPodUpdateStrategyType {
+		reason = appspub.InPlaceUpdateBlockedReason
+	}
+	msg := fmt.Sprintf("revision %s can not be updated in place: %s", updateRevision.Name, inplaceupdate.FormatNotInPlaceUpdateReasons(reasons))
+	condition := NewStatefulsetCondition(appsv1beta1.InPlaceUpdateNotPossible, v1.ConditionTrue, reason, msg)
+	if oldCondition := GetStatefulsetConditition(set.Status, condition.Type); oldCondition != nil &&
+		oldCondition.Reason == condition.Reason && oldCondition.Message == condition.Message {
+		condition = *oldCondition
+	}
+	SetStatefulsetCondition(status, condition)
+}
+
 // GetStatefulsetConditition returns the condition with the provided type.
 func GetStatefulsetConditition(status appsv1beta1.StatefulSetStatus, condType apps.StatefulSetConditionType) *apps.StatefulSetCondition {
 	for i := range status.Conditions {
@@ -17,14 +17,25 @@ limitations under the License.
 package statefulset
 
 import (
+	"time"
+
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/integer"
 
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
+	apiutil "github.com/openkruise/kruise/pkg/util/api"
 	"github.com/openkruise/kruise/pkg/util/revision"
 	"github.com/openkruise/kruise/pkg/util/updatesort"
 )
 
 func sortPodsToUpdate(rollingUpdateStrategy *appsv1beta1.RollingUpdateStatefulSetStrategy, updateRevision string, totalReplicas int32, replicas []*v1.Pod) []int {
+	if rollingUpdateStrategy != nil && len(rollingUpdateStrategy.Ordinals) > 0 {
+		return sortPodsToUpdateByOrdinals(rollingUpdateStrategy.Ordinals, replicas)
+	}
+
 	var updateMin int
 	if rollingUpdateStrategy != nil && rollingUpdateStrategy.Partition != nil {
 		updateMin = int(*rollingUpdateStrategy.Partition)
@@ -66,6 +77,9 @@ func sortPodsToUpdate(rollingUpdateStrategy *appsv1beta1.RollingUpdateStatefulSe
 	if priorityStrategy != nil {
 		waitUpdateIdxs = updatesort.NewPrioritySorter(priorityStrategy).Sort(replicas, waitUpdateIdxs)
 	}
+	if topologyStrategy := rollingUpdateStrategy.UnorderedUpdate.TopologyStrategy; topologyStrategy != nil {
+		waitUpdateIdxs = updatesort.NewTopologySorter(topologyStrategy).Sort(replicas, waitUpdateIdxs)
+	}
 
 	allIdxs := append(updatedIdxs, waitUpdateIdxs...)
 	if len(allIdxs) > maxUpdate {
@@ -74,3 +88,70 @@ func sortPodsToUpdate(rollingUpdateStrategy *appsv1beta1.RollingUpdateStatefulSe
 
 	return allIdxs
 }
+
+func getUpdateTopologyStrategy(set *appsv1beta1.StatefulSet) *appspub.UpdateTopologyStrategy {
+	if set.Spec.UpdateStrategy.RollingUpdate == nil || set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate == nil {
+		return nil
+	}
+	return set.Spec.UpdateStrategy.RollingUpdate.UnorderedUpdate.TopologyStrategy
+}
+
+// calculateUpdateTopology calculates the topology-batched update status of the StatefulSet,
+// and returns the duration left to soak the current domain.
+func calculateUpdateTopology(set *appsv1beta1.StatefulSet, updateRevision string, minReadySeconds int32,
+	pods []*v1.Pod, now metav1.Time) (*appspub.UpdateTopologyStatus, time.Duration) {
+	return updatesort.CalculateUpdateTopology(getUpdateTopologyStrategy(set), set.Status.UpdateTopology, updateRevision, pods,
+		func(pod *v1.Pod) bool {
+			return getPodRevision(pod) == updateRevision
+		},
+		func(pod *v1.Pod) bool {
+			isAvailable, _ := isRunningAndAvailable(pod, minReadySeconds)
+			return isAvailable
+		},
+		now,
+	)
+}
+
+// sortPodsToUpdateByOrdinals returns the indexes of replicas in the order of the ordinals to update.
+func sortPodsToUpdateByOrdinals(ordinals []intstr.IntOrString, replicas []*v1.Pod) []int {
+	ordinalToIndex := make(map[int]int, len(replicas))
+	maxOrdinal := -1
+	for i, pod := range replicas {
+		if pod != nil {
+			ord := getOrdinal(pod)
+			ordinalToIndex[ord] = i
+			maxOrdinal = integer.IntMax(maxOrdinal, ord)
+		}
+	}
+
+	indexes := []int{}
+	for _, ord := range apiutil.GetOrdinalList(ordinals, maxOrdinal) {
+		if i, ok := ordinalToIndex[ord]; ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// getPendingOrdinals returns the ordinals in rollingUpdate.ordinals whose pods have not been updated
+// to the update revision, in the order to update.
+func getPendingOrdinals(set *appsv1beta1.StatefulSet, updateRevision string, pods []*v1.Pod) []int32 {
+	if set.Spec.UpdateStrategy.RollingUpdate == nil || len(set.Spec.UpdateStrategy.RollingUpdate.Ordinals) == 0 {
+		return nil
+	}
+
+	podRevisions := make(map[int]string, len(pods))
+	maxOrdinal := -1
+	for _, pod := range pods {
+		ord := getOrdinal(pod)
+		podRevisions[ord] = getPodRevision(pod)
+		maxOrdinal = integer.IntMax(maxOrdinal, ord)
+	}
+	var pendingOrdinals []int32
+	for _, ord := range apiutil.GetOrdinalList(set.Spec.UpdateStrategy.RollingUpdate.Ordinals, maxOrdinal) {
+		if podRevision, ok := podRevisions[ord]; ok && podRevision != updateRevision {
+			pendingOrdinals = append(pendingOrdinals, int32(ord))
+		}
+	}
+	return pendingOrdinals
+}
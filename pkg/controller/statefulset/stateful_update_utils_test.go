@@ -23,6 +23,7 @@ import (
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
@@ -167,3 +168,32 @@ func TestSortPodsToUpdate(t *testing.T) {
 		}
 	}
 }
+
+func TestSortPodsToUpdateWithOrdinals(t *testing.T) {
+	set := newStatefulSet(5)
+	set.Spec.UpdateStrategy.RollingUpdate = &appsv1beta1.RollingUpdateStatefulSetStrategy{
+		Partition: func() *int32 { var i int32 = 4; return &i }(),
+		Ordinals:  []intstr.IntOrString{intstr.FromString("2-3"), intstr.FromInt32(0), intstr.FromInt32(7)},
+	}
+	var replicas []*v1.Pod
+	for i := 0; i < 5; i++ {
+		pod := newStatefulSetPod(set, i)
+		setPodRevision(pod, "r0")
+		replicas = append(replicas, pod)
+	}
+	setPodRevision(replicas[3], "r1")
+	replicas[1] = nil
+
+	if indexes := sortPodsToUpdate(set.Spec.UpdateStrategy.RollingUpdate, "r1", 5, replicas); !reflect.DeepEqual(indexes, []int{2, 3, 0}) {
+		t.Fatalf("expected indexes [2 3 0], got %v", indexes)
+	}
+	if pending := getPendingOrdinals(set, "r1", []*v1.Pod{replicas[0], replicas[2], replicas[3], replicas[4]}); !reflect.DeepEqual(pending, []int32{2, 0}) {
+		t.Fatalf("expected pending ordinals [2 0], got %v", pending)
+	}
+	set.Spec.UpdateStrategy.Type = apps.RollingUpdateStatefulSetStrategyType
+	for ordinal, expected := range map[int]bool{0: false, 1: true, 2: false, 4: true} {
+		if got := isCurrentRevisionNeeded(set, "r1", ordinal, replicas); got != expected {
+			t.Fatalf("expected current revision needed %v for ordinal %d, got %v", expected, ordinal, got)
+		}
+	}
+}
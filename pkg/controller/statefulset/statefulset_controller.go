@@ -146,6 +146,10 @@ func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
 
 	// new a client
 	sigsruntimeClient = utilclient.NewClientFromManager(mgr, "statefulset-controller")
+	var snapshotControl VolumeSnapshotControl
+	if utildiscovery.DiscoverGVK(volumeSnapshotGVK) {
+		snapshotControl = NewVolumeSnapshotControl(sigsruntimeClient)
+	}
 
 	return &ReconcileStatefulSet{
 		kruiseClient: genericClient.KruiseClient,
@@ -161,6 +165,7 @@ func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
 			NewRealStatefulSetStatusUpdater(genericClient.KruiseClient, statefulSetLister),
 			history.NewHistory(genericClient.KubeClient, appslisters.NewControllerRevisionLister(revInformer.(toolscache.SharedIndexInformer).GetIndexer())),
 			recorder,
+			snapshotControl,
 		),
 		podControl: kubecontroller.RealPodControl{KubeClient: genericClient.KubeClient, Recorder: recorder},
 		podLister:  podLister,
@@ -235,6 +240,7 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 // +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
 // +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps.kruise.io,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps.kruise.io,resources=statefulsets/status,verbs=get;update;patch
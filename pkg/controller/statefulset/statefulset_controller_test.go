@@ -638,7 +638,7 @@ func newFakeStatefulSetController(initialObjects ...runtime.Object) (*StatefulSe
 	recorder := record.NewFakeRecorder(10)
 	inplaceControl := inplaceupdate.NewForInformer(informerFactory.Core().V1().Pods(), revisionadapter.NewDefaultImpl())
 	lifecycleControl := lifecycle.NewForInformer(informerFactory.Core().V1().Pods())
-	ssc.control = NewDefaultStatefulSetControl(fpc, inplaceControl, lifecycleControl, ssu, ssh, recorder)
+	ssc.control = NewDefaultStatefulSetControl(fpc, inplaceControl, lifecycleControl, ssu, ssh, recorder, nil)
 
 	return ssc, om
 }
@@ -802,6 +802,7 @@ func NewStatefulSetController(
 				NewRealStatefulSetStatusUpdater(kruiseClient, setInformer.Lister()),
 				history.NewHistory(kubeClient, revInformer.Lister()),
 				recorder,
+				nil,
 			),
 			podControl: controller.RealPodControl{KubeClient: kubeClient, Recorder: recorder},
 			podLister:  podInformer.Lister(),
@@ -82,7 +82,7 @@ func (dss *defaultStatefulSetControl) createImagePullJobsForInPlaceUpdate(sts *a
 
 	// ignore if this revision can not update in-place
 	inplaceControl := inplaceupdate.New(sigsruntimeClient, revisionadapter.NewDefaultImpl())
-	if !inplaceControl.CanUpdateInPlace(currentRevision, updateRevision, opts) {
+	if canInPlace, _ := inplaceControl.CanUpdateInPlace(currentRevision, updateRevision, opts); !canInPlace {
 		klog.V(4).InfoS("Statefulset skipped to create ImagePullJob because it could not update in-place",
 			"statefulSet", klog.KObj(sts), "currentRevisionName", currentRevision.Name, "updateRevisionName", updateRevision.Name)
 		return dss.patchControllerRevisionLabels(updateRevision, appsv1alpha1.ImagePreDownloadIgnoredKey, "true")
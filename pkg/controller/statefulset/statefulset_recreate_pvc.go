@@ -0,0 +1,166 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
+	"github.com/openkruise/kruise/pkg/features"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+	"github.com/openkruise/kruise/pkg/util/pvc"
+)
+
+// the deletion of claims is not watched by the controller, so we check them again after this duration.
+const terminatingClaimsRequeueDuration = 5 * time.Second
+
+// recreateClaimsState is the value of annotation apps.kruise.io/recreate-volume-claims.
+type recreateClaimsState struct {
+	// ClaimNames are the names of claims to recreate.
+	ClaimNames []string `json:"claimNames"`
+	// Timestamp is the time when the pod was marked, from which the timeout of PreRecreateHook is counted.
+	Timestamp metav1.Time `json:"timestamp"`
+}
+
+// isVolumeClaimUpdatedOnPodRollingUpdate returns whether the claims of pods are updated during the rolling update.
+func isVolumeClaimUpdatedOnPodRollingUpdate(set *appsv1beta1.StatefulSet) bool {
+	if !utilfeature.DefaultFeatureGate.Enabled(features.StatefulSetAutoResizePVCGate) {
+		return false
+	}
+	switch set.Spec.VolumeClaimUpdateStrategy.Type {
+	case appsv1beta1.OnPodRollingUpdateVolumeClaimUpdateStrategyType, appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType:
+		return true
+	}
+	return false
+}
+
+// isVolumeClaimRecreatedOnPodRollingUpdate returns whether the claims that cannot be updated in place
+// are recreated during the rolling update.
+func isVolumeClaimRecreatedOnPodRollingUpdate(set *appsv1beta1.StatefulSet) bool {
+	return utilfeature.DefaultFeatureGate.Enabled(features.StatefulSetAutoResizePVCGate) &&
+		set.Spec.VolumeClaimUpdateStrategy.Type == appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType
+}
+
+// GetClaimsToRecreate returns the names of claims of the pod whose changes in the templates cannot be applied in place,
+// such as storage class or access modes changes.
+func (spc *StatefulPodControl) GetClaimsToRecreate(set *appsv1beta1.StatefulSet, pod *v1.Pod) ([]string, error) {
+	var claimNames []string
+	fn := func(claim, template *v1.PersistentVolumeClaim) (bool, error) {
+		if matched, needExpand := pvc.CompareWithCheckFn(claim, template, pvc.IsPVCNeedExpand); !matched && !needExpand {
+			claimNames = append(claimNames, claim.Name)
+		}
+		return true, nil
+	}
+	if _, err := spc.handlePVCWithCustomFn(set, pod, true, fn); err != nil {
+		return nil, err
+	}
+	return claimNames, nil
+}
+
+// HasTerminatingClaims returns whether any claim of the pod is terminating.
+func (spc *StatefulPodControl) HasTerminatingClaims(set *appsv1beta1.StatefulSet, pod *v1.Pod) (bool, error) {
+	fn := func(claim, template *v1.PersistentVolumeClaim) (bool, error) {
+		return claim.DeletionTimestamp == nil, nil
+	}
+	noTerminating, err := spc.handlePVCWithCustomFn(set, pod, false, fn)
+	if err != nil {
+		return false, err
+	}
+	return !noTerminating, nil
+}
+
+func getRecreateClaimsState(pod *v1.Pod) (*recreateClaimsState, bool) {
+	value, ok := pod.Annotations[appsv1beta1.StatefulSetRecreateVolumeClaimsAnnotationKey]
+	if !ok {
+		return nil, false
+	}
+	state := &recreateClaimsState{}
+	if err := json.Unmarshal([]byte(value), state); err != nil {
+		klog.ErrorS(err, "Failed to unmarshal recreate claims state", "pod", klog.KObj(pod), "value", value)
+		return nil, false
+	}
+	return state, true
+}
+
+// recreatePodClaims marks the pod with the claims to recreate and waits for the PreRecreateHook,
+// then it deletes the pod and the claims, so that the pod will be created again with new claims from the templates.
+// It returns true if the pod is actually deleted in this round.
+func (ssc *defaultStatefulSetControl) recreatePodClaims(set *appsv1beta1.StatefulSet, pod *v1.Pod, claimNames []string) (bool, error) {
+	state, ok := getRecreateClaimsState(pod)
+	if !ok {
+		// never start to recreate the claims of a terminating pod, which has not been marked and hooked
+		if isTerminating(pod) {
+			return false, nil
+		}
+		state = &recreateClaimsState{ClaimNames: claimNames, Timestamp: metav1.Now()}
+		value, _ := json.Marshal(state)
+		clone := pod.DeepCopy()
+		if clone.Annotations == nil {
+			clone.Annotations = map[string]string{}
+		}
+		clone.Annotations[appsv1beta1.StatefulSetRecreateVolumeClaimsAnnotationKey] = string(value)
+		if err := ssc.podControl.objectMgr.UpdatePod(clone); err != nil {
+			return false, fmt.Errorf("failed to mark Pod %s to recreate claims: %v", pod.Name, err)
+		}
+		ssc.recorder.Eventf(set, v1.EventTypeNormal, "MarkRecreateClaims", "mark Pod %s to recreate claims %v", pod.Name, claimNames)
+		return false, nil
+	}
+
+	// the hook is also checked when the pod is terminating, to make sure the claims are never deleted before it is done
+	hook := set.Spec.VolumeClaimUpdateStrategy.PreRecreateHook
+	if held, left := ssc.lifecycleControl.CheckHookSince(ssc.recorder, hook, pod, state.Timestamp.Time, "PreRecreateHook of volume claims"); held {
+		klog.V(4).InfoS("StatefulSet was waiting for PreRecreateHook of Pod", "statefulSet", klog.KObj(set), "pod", klog.KObj(pod))
+		if left > 0 {
+			durationStore.Push(getStatefulSetKey(set), left)
+		}
+		return false, nil
+	}
+
+	var actualDeleting bool
+	if !isTerminating(pod) {
+		klog.V(2).InfoS("StatefulSet terminating Pod to recreate claims", "statefulSet", klog.KObj(set), "pod", klog.KObj(pod), "claims", state.ClaimNames)
+		var err error
+		if _, actualDeleting, err = ssc.deletePod(set, pod); err != nil || !actualDeleting {
+			return false, err
+		}
+	}
+
+	// delete the claims recorded in the pod once it is being deleted, and the pod will not be created again until they are deleted
+	for _, claimName := range state.ClaimNames {
+		claim, err := ssc.podControl.objectMgr.GetClaim(set.Namespace, claimName)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, fmt.Errorf("could not retrieve claim %s for %s when recreating claims: %v", claimName, pod.Name, err)
+		} else if claim.DeletionTimestamp != nil {
+			continue
+		}
+		err = ssc.podControl.objectMgr.DeleteClaim(claim)
+		ssc.podControl.recordClaimEvent("delete", set, pod, claim, err)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return actualDeleting, err
+		}
+	}
+	return actualDeleting, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilpointer "k8s.io/utils/pointer"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
+	kruisefake "github.com/openkruise/kruise/pkg/client/clientset/versioned/fake"
+	"github.com/openkruise/kruise/pkg/features"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+	"github.com/openkruise/kruise/pkg/util/lifecycle"
+)
+
+func TestRecreatePodClaims(t *testing.T) {
+	defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.StatefulSetAutoResizePVCGate, true)()
+
+	client := fake.NewSimpleClientset()
+	om, _, _, stop := setupController(client, kruisefake.NewSimpleClientset())
+	defer close(stop)
+	ssc := &defaultStatefulSetControl{
+		podControl:       NewStatefulPodControlFromManager(om, &noopRecorder{}),
+		lifecycleControl: lifecycle.NewForTypedClient(client),
+		recorder:         &noopRecorder{},
+	}
+
+	set := newStatefulSetWithGivenSC(3, 2, []*string{utilpointer.String("new-sc")})
+	set.Spec.VolumeClaimUpdateStrategy = appsv1beta1.VolumeClaimUpdateStrategy{
+		Type:            appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType,
+		PreRecreateHook: &appspub.LifecycleHook{LabelsHandler: map[string]string{"migration": "true"}},
+	}
+	if !isVolumeClaimUpdatedOnPodRollingUpdate(set) || !isVolumeClaimRecreatedOnPodRollingUpdate(set) {
+		t.Fatalf("expected claims to be recreated on pod rolling update")
+	}
+
+	pod := newStatefulSetPod(set, 0)
+	pod.Labels["migration"] = "true"
+	if err := om.podsIndexer.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	for _, claim := range getPersistentVolumeClaims(set, pod) {
+		claim := claim
+		if claim.Name == "datadir-0-foo-0" {
+			claim.Spec.StorageClassName = utilpointer.String("old-sc")
+		}
+		if err := om.claimsIndexer.Add(&claim); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimNames, err := ssc.podControl.GetClaimsToRecreate(set, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"datadir-0-foo-0"}; !reflect.DeepEqual(claimNames, expected) {
+		t.Fatalf("expected claims to recreate %v, got %v", expected, claimNames)
+	}
+
+	// never start to recreate the claims of a terminating pod
+	terminating := pod.DeepCopy()
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	if deleting, err := ssc.recreatePodClaims(set, terminating, claimNames); err != nil || deleting {
+		t.Fatalf("expected not deleting without error, got %v, %v", deleting, err)
+	}
+	if pod, _ := om.podsLister.Pods(set.Namespace).Get(pod.Name); pod.Annotations[appsv1beta1.StatefulSetRecreateVolumeClaimsAnnotationKey] != "" {
+		t.Fatalf("expected terminating pod not to be marked, got %v", pod.Annotations)
+	}
+
+	// mark the pod first
+	if deleting, err := ssc.recreatePodClaims(set, pod, claimNames); err != nil || deleting {
+		t.Fatalf("expected not deleting without error, got %v, %v", deleting, err)
+	}
+	pod, _ = om.podsLister.Pods(set.Namespace).Get(pod.Name)
+	if state, ok := getRecreateClaimsState(pod); !ok || !reflect.DeepEqual(state.ClaimNames, claimNames) {
+		t.Fatalf("expected pod to be marked with claims %v, got %v", claimNames, pod.Annotations)
+	}
+
+	// wait for the PreRecreateHook
+	if deleting, err := ssc.recreatePodClaims(set, pod, claimNames); err != nil || deleting {
+		t.Fatalf("expected not deleting without error, got %v, %v", deleting, err)
+	}
+	if _, err := om.claimsLister.PersistentVolumeClaims(set.Namespace).Get("datadir-0-foo-0"); err != nil {
+		t.Fatalf("expected claim not to be deleted while the pod is hooked, got %v", err)
+	}
+
+	// delete the pod and the claims recorded in it after the hook is removed
+	pod = pod.DeepCopy()
+	delete(pod.Labels, "migration")
+	if deleting, err := ssc.recreatePodClaims(set, pod, nil); err != nil || !deleting {
+		t.Fatalf("expected deleting without error, got %v, %v", deleting, err)
+	}
+	if _, err := om.podsLister.Pods(set.Namespace).Get(pod.Name); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected pod to be deleted, got %v", err)
+	}
+	if _, err := om.claimsLister.PersistentVolumeClaims(set.Namespace).Get("datadir-0-foo-0"); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected claim to be deleted, got %v", err)
+	}
+	if _, err := om.claimsLister.PersistentVolumeClaims(set.Namespace).Get("datadir-1-foo-0"); err != nil {
+		t.Fatalf("expected compatible claim not to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,416 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
+	"github.com/openkruise/kruise/pkg/util/fieldindex"
+)
+
+var volumeSnapshotGVK = fieldindex.VolumeSnapshotGVK
+
+const (
+	defaultVolumeSnapshotTimeoutSeconds = 600
+	defaultVolumeSnapshotRetentionLimit = 3
+	// VolumeSnapshots are not watched by the controller, so we check them again after this duration.
+	volumeSnapshotRequeueDuration = 5 * time.Second
+	// the message of VolumeSnapshotTimeout condition, followed by the names of pods joined by comma
+	volumeSnapshotTimeoutMessagePrefix = "VolumeSnapshots are not ready to use in time for Pods: "
+)
+
+// VolumeSnapshotControl defines the interface to get, list, create and delete VolumeSnapshots,
+// which allows the snapshot API to be mocked out for testing.
+type VolumeSnapshotControl interface {
+	GetVolumeSnapshot(namespace, name string) (*unstructured.Unstructured, error)
+	// ListVolumeSnapshots lists the VolumeSnapshots owned by the StatefulSet.
+	ListVolumeSnapshots(set *appsv1beta1.StatefulSet) ([]unstructured.Unstructured, error)
+	CreateVolumeSnapshot(snapshot *unstructured.Unstructured) error
+	DeleteVolumeSnapshot(snapshot *unstructured.Unstructured) error
+}
+
+// NewVolumeSnapshotControl returns a VolumeSnapshotControl using the given client.
+func NewVolumeSnapshotControl(c client.Client) VolumeSnapshotControl {
+	return &realVolumeSnapshotControl{client: c}
+}
+
+type realVolumeSnapshotControl struct {
+	client client.Client
+}
+
+func (c *realVolumeSnapshotControl) GetVolumeSnapshot(namespace, name string) (*unstructured.Unstructured, error) {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	if err := c.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (c *realVolumeSnapshotControl) ListVolumeSnapshots(set *appsv1beta1.StatefulSet) ([]unstructured.Unstructured, error) {
+	snapshotList := &unstructured.UnstructuredList{}
+	snapshotList.SetGroupVersionKind(volumeSnapshotGVK.GroupVersion().WithKind(volumeSnapshotGVK.Kind + "List"))
+	if err := c.client.List(context.TODO(), snapshotList, client.InNamespace(set.Namespace),
+		client.MatchingFields{fieldindex.IndexNameForOwnerRefUID: string(set.UID)}); err != nil {
+		return nil, err
+	}
+	return snapshotList.Items, nil
+}
+
+func (c *realVolumeSnapshotControl) CreateVolumeSnapshot(snapshot *unstructured.Unstructured) error {
+	return c.client.Create(context.TODO(), snapshot)
+}
+
+func (c *realVolumeSnapshotControl) DeleteVolumeSnapshot(snapshot *unstructured.Unstructured) error {
+	return c.client.Delete(context.TODO(), snapshot)
+}
+
+func getVolumeSnapshotStrategy(set *appsv1beta1.StatefulSet) *appsv1beta1.VolumeSnapshotStrategy {
+	if set.Spec.UpdateStrategy.RollingUpdate == nil || len(set.Spec.VolumeClaimTemplates) == 0 {
+		return nil
+	}
+	return set.Spec.UpdateStrategy.RollingUpdate.VolumeSnapshotBeforeUpdate
+}
+
+// getVolumeSnapshotName returns the name of VolumeSnapshot for the claim before it is updated to the revision.
+// The suffix makes the names different for each update, even if the pod is updated to the same revision again.
+func getVolumeSnapshotName(set *appsv1beta1.StatefulSet, claimName, revision, suffix string) string {
+	return fmt.Sprintf("%s-%s-%s", claimName, strings.TrimPrefix(revision, set.Name+"-"), suffix)
+}
+
+// isVolumeSnapshotNameOfRevision returns whether the VolumeSnapshot name is generated for the claim and the revision.
+func isVolumeSnapshotNameOfRevision(set *appsv1beta1.StatefulSet, name, claimName, revision string) bool {
+	return strings.HasPrefix(name, fmt.Sprintf("%s-%s-", claimName, strings.TrimPrefix(revision, set.Name+"-")))
+}
+
+// getRecordedVolumeSnapshots returns the VolumeSnapshot names recorded in the pod annotation.
+func getRecordedVolumeSnapshots(pod *v1.Pod) map[string]string {
+	snapshots := map[string]string{}
+	if value, ok := pod.Annotations[appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey]; ok {
+		if err := json.Unmarshal([]byte(value), &snapshots); err != nil {
+			klog.ErrorS(err, "Failed to unmarshal VolumeSnapshots of Pod", "pod", klog.KObj(pod), "value", value)
+		}
+	}
+	return snapshots
+}
+
+func newVolumeSnapshot(set *appsv1beta1.StatefulSet, strategy *appsv1beta1.VolumeSnapshotStrategy, pod *v1.Pod, claimName, name, revision string) *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetNamespace(set.Namespace)
+	snapshot.SetName(name)
+	snapshot.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(set, controllerKind)})
+	snapshot.SetLabels(map[string]string{
+		apps.StatefulSetPodNameLabel:                          pod.Name,
+		appsv1beta1.StatefulSetVolumeSnapshotRevisionLabelKey: revision,
+	})
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{"persistentVolumeClaimName": claimName},
+	}
+	if strategy.VolumeSnapshotClassName != nil {
+		spec["volumeSnapshotClassName"] = *strategy.VolumeSnapshotClassName
+	}
+	snapshot.Object["spec"] = spec
+	return snapshot
+}
+
+func isVolumeSnapshotReadyToUse(snapshot *unstructured.Unstructured) bool {
+	ready, found, err := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	return err == nil && found && ready
+}
+
+// snapshotVolumesBeforeUpdate creates VolumeSnapshots for the PVCs of the pod before it is updated to the update revision,
+// whose names are recorded in the pod annotation before they are created.
+// It returns true if the pod can be updated, which means all the snapshots are ready to use,
+// or they have timed out with the Proceed failure policy.
+func (ssc *defaultStatefulSetControl) snapshotVolumesBeforeUpdate(
+	set *appsv1beta1.StatefulSet, status *appsv1beta1.StatefulSetStatus, pod *v1.Pod, updateRevision string,
+) (bool, error) {
+	strategy := getVolumeSnapshotStrategy(set)
+	if strategy == nil {
+		return true, nil
+	}
+	if ssc.snapshotControl == nil {
+		return false, fmt.Errorf("volume snapshot is not supported for StatefulSet %s/%s", set.Namespace, set.Name)
+	}
+
+	ordinal := getOrdinal(pod)
+	recorded := getRecordedVolumeSnapshots(pod)
+	suffix := utilrand.String(5)
+	snapshots := make(map[string]string, len(set.Spec.VolumeClaimTemplates))
+	claimNames := make(map[string]string, len(set.Spec.VolumeClaimTemplates))
+	for i := range set.Spec.VolumeClaimTemplates {
+		template := &set.Spec.VolumeClaimTemplates[i]
+		claimName := getPersistentVolumeClaimName(set, template, ordinal)
+		claim, err := ssc.podControl.objectMgr.GetClaim(set.Namespace, claimName)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, fmt.Errorf("could not retrieve claim %s for %s when snapshotting volumes: %v", claimName, pod.Name, err)
+		} else if claim.DeletionTimestamp != nil {
+			continue
+		}
+
+		// the recorded snapshot is reused only if it is generated for this update
+		name, ok := recorded[template.Name]
+		if !ok || !isVolumeSnapshotNameOfRevision(set, name, claimName, updateRevision) {
+			name = getVolumeSnapshotName(set, claimName, updateRevision, suffix)
+		}
+		snapshots[template.Name] = name
+		claimNames[template.Name] = claimName
+	}
+
+	if modified, err := ssc.recordVolumeSnapshots(pod, snapshots); err != nil || modified {
+		// wait for the pod event after it has been annotated
+		return false, err
+	}
+
+	var notReady []string
+	var oldestCreation time.Time
+	for i := range set.Spec.VolumeClaimTemplates {
+		templateName := set.Spec.VolumeClaimTemplates[i].Name
+		name, ok := snapshots[templateName]
+		if !ok {
+			continue
+		}
+		claimName := claimNames[templateName]
+		snapshot, err := ssc.snapshotControl.GetVolumeSnapshot(set.Namespace, name)
+		if apierrors.IsNotFound(err) {
+			snapshot = newVolumeSnapshot(set, strategy, pod, claimName, name, updateRevision)
+			if err = ssc.snapshotControl.CreateVolumeSnapshot(snapshot); err != nil && !apierrors.IsAlreadyExists(err) {
+				ssc.recorder.Eventf(set, v1.EventTypeWarning, "FailedCreateVolumeSnapshot",
+					"failed to create VolumeSnapshot %s of claim %s for Pod %s: %v", name, claimName, pod.Name, err)
+				return false, err
+			}
+			ssc.recorder.Eventf(set, v1.EventTypeNormal, "SuccessfulCreateVolumeSnapshot",
+				"create VolumeSnapshot %s of claim %s for Pod %s before update", name, claimName, pod.Name)
+		} else if err != nil {
+			return false, err
+		}
+
+		if !isVolumeSnapshotReadyToUse(snapshot) {
+			notReady = append(notReady, name)
+			creation := snapshot.GetCreationTimestamp().Time
+			if creation.IsZero() {
+				creation = time.Now()
+			}
+			if oldestCreation.IsZero() || creation.Before(oldestCreation) {
+				oldestCreation = creation
+			}
+		}
+	}
+	if len(notReady) == 0 {
+		return true, nil
+	}
+
+	timeoutSeconds := strategy.TimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = defaultVolumeSnapshotTimeoutSeconds
+	}
+	if left := time.Duration(timeoutSeconds)*time.Second - time.Since(oldestCreation); left > 0 {
+		if left > volumeSnapshotRequeueDuration {
+			left = volumeSnapshotRequeueDuration
+		}
+		durationStore.Push(getStatefulSetKey(set), left)
+		return false, nil
+	}
+
+	ssc.setVolumeSnapshotTimeoutCondition(set, status, pod, notReady, timeoutSeconds)
+	if strategy.FailurePolicy == appsv1beta1.VolumeSnapshotFailurePolicyProceed {
+		return true, nil
+	}
+	durationStore.Push(getStatefulSetKey(set), volumeSnapshotRequeueDuration)
+	return false, nil
+}
+
+// setVolumeSnapshotTimeoutCondition adds the pod into the VolumeSnapshotTimeout condition of the status,
+// and the warning is only emitted when the pod is newly added, so that it will not be repeated when the update is blocked.
+func (ssc *defaultStatefulSetControl) setVolumeSnapshotTimeoutCondition(
+	set *appsv1beta1.StatefulSet, status *appsv1beta1.StatefulSetStatus, pod *v1.Pod, notReady []string, timeoutSeconds int32,
+) {
+	if !sets.NewString(getVolumeSnapshotTimeoutPods(set.Status)...).Has(pod.Name) {
+		ssc.recorder.Eventf(set, v1.EventTypeWarning, "VolumeSnapshotTimeout",
+			"VolumeSnapshots %v for Pod %s are not ready to use in %d seconds", notReady, pod.Name, timeoutSeconds)
+	}
+
+	podNames := sets.NewString(getVolumeSnapshotTimeoutPods(*status)...).Insert(pod.Name).List()
+	condition := NewStatefulsetCondition(appsv1beta1.VolumeSnapshotTimeout, v1.ConditionTrue, "",
+		volumeSnapshotTimeoutMessagePrefix+strings.Join(podNames, ","))
+	if oldCondition := GetStatefulsetConditition(set.Status, condition.Type); oldCondition != nil && oldCondition.Message == condition.Message {
+		condition = *oldCondition
+	}
+	status.Conditions = append(filterOutCondition(status.Conditions, condition.Type), condition)
+}
+
+// getVolumeSnapshotTimeoutPods returns the names of pods in the VolumeSnapshotTimeout condition.
+func getVolumeSnapshotTimeoutPods(status appsv1beta1.StatefulSetStatus) []string {
+	condition := GetStatefulsetConditition(status, appsv1beta1.VolumeSnapshotTimeout)
+	if condition == nil || !strings.HasPrefix(condition.Message, volumeSnapshotTimeoutMessagePrefix) {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(condition.Message, volumeSnapshotTimeoutMessagePrefix), ",")
+}
+
+// recordVolumeSnapshots records the VolumeSnapshot names in the pod annotation, and returns true if the pod is modified.
+func (ssc *defaultStatefulSetControl) recordVolumeSnapshots(pod *v1.Pod, snapshots map[string]string) (bool, error) {
+	if len(snapshots) == 0 {
+		return false, nil
+	}
+	value, _ := json.Marshal(snapshots)
+	if pod.Annotations[appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey] == string(value) {
+		return false, nil
+	}
+
+	clone := pod.DeepCopy()
+	if clone.Annotations == nil {
+		clone.Annotations = map[string]string{}
+	}
+	clone.Annotations[appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey] = string(value)
+	if err := ssc.podControl.objectMgr.UpdatePod(clone); err != nil {
+		return false, fmt.Errorf("failed to record VolumeSnapshots for Pod %s: %v", pod.Name, err)
+	}
+	klog.V(3).InfoS("StatefulSet recorded VolumeSnapshots for Pod", "pod", klog.KObj(pod), "volumeSnapshots", snapshots)
+	return true, nil
+}
+
+// setVolumeSnapshotsAnnotation sets the annotation of VolumeSnapshots to the pod to be created,
+// which have been created before the previous pod with the same ordinal is recreated to the pod's revision.
+// If there are several snapshots of a claim for the revision, the latest one is used.
+func (ssc *defaultStatefulSetControl) setVolumeSnapshotsAnnotation(set *appsv1beta1.StatefulSet, pod *v1.Pod) error {
+	if getVolumeSnapshotStrategy(set) == nil || ssc.snapshotControl == nil {
+		return nil
+	}
+
+	snapshotList, err := ssc.snapshotControl.ListVolumeSnapshots(set)
+	if err != nil {
+		return err
+	}
+	revision := getPodRevision(pod)
+	latest := make(map[string]*unstructured.Unstructured, len(set.Spec.VolumeClaimTemplates))
+	for i := range snapshotList {
+		snapshot := &snapshotList[i]
+		if snapshot.GetLabels()[apps.StatefulSetPodNameLabel] != pod.Name ||
+			snapshot.GetLabels()[appsv1beta1.StatefulSetVolumeSnapshotRevisionLabelKey] != revision {
+			continue
+		}
+		claimName, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+		if old, ok := latest[claimName]; ok {
+			oldCreation, creation := old.GetCreationTimestamp(), snapshot.GetCreationTimestamp()
+			if !oldCreation.Before(&creation) {
+				continue
+			}
+		}
+		latest[claimName] = snapshot
+	}
+
+	ordinal := getOrdinal(pod)
+	snapshots := make(map[string]string, len(set.Spec.VolumeClaimTemplates))
+	for i := range set.Spec.VolumeClaimTemplates {
+		template := &set.Spec.VolumeClaimTemplates[i]
+		if snapshot, ok := latest[getPersistentVolumeClaimName(set, template, ordinal)]; ok {
+			snapshots[template.Name] = snapshot.GetName()
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	value, _ := json.Marshal(snapshots)
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey] = string(value)
+	return nil
+}
+
+// truncateVolumeSnapshots deletes the VolumeSnapshots of the StatefulSet beyond the retention limit of each claim,
+// starting with the oldest ones, and the ones of revisions that have been removed from the history.
+// Snapshots recorded in the pods are never deleted, for they may be still waited by updating pods
+// or referred to by the pods recreated from them.
+func (ssc *defaultStatefulSetControl) truncateVolumeSnapshots(set *appsv1beta1.StatefulSet, pods []*v1.Pod, revisions []*apps.ControllerRevision) error {
+	strategy := getVolumeSnapshotStrategy(set)
+	if strategy == nil || ssc.snapshotControl == nil {
+		return nil
+	}
+	limit := int(strategy.RetentionLimit)
+	if limit <= 0 {
+		limit = defaultVolumeSnapshotRetentionLimit
+	}
+
+	snapshotList, err := ssc.snapshotControl.ListVolumeSnapshots(set)
+	if err != nil {
+		return err
+	}
+	recorded := sets.NewString()
+	for _, pod := range pods {
+		for _, name := range getRecordedVolumeSnapshots(pod) {
+			recorded.Insert(name)
+		}
+	}
+	existingRevisions := sets.NewString()
+	for _, revision := range revisions {
+		existingRevisions.Insert(revision.Name)
+	}
+
+	claimSnapshots := make(map[string][]*unstructured.Unstructured)
+	for i := range snapshotList {
+		snapshot := &snapshotList[i]
+		if snapshot.GetDeletionTimestamp() != nil {
+			continue
+		}
+		claimName, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+		claimSnapshots[claimName] = append(claimSnapshots[claimName], snapshot)
+	}
+
+	for _, snapshots := range claimSnapshots {
+		// the latest snapshots come first
+		sort.SliceStable(snapshots, func(i, j int) bool {
+			creationI, creationJ := snapshots[i].GetCreationTimestamp(), snapshots[j].GetCreationTimestamp()
+			return creationJ.Before(&creationI)
+		})
+		for i, snapshot := range snapshots {
+			if recorded.Has(snapshot.GetName()) {
+				continue
+			}
+			if i < limit && existingRevisions.Has(snapshot.GetLabels()[appsv1beta1.StatefulSetVolumeSnapshotRevisionLabelKey]) {
+				continue
+			}
+			if err := ssc.snapshotControl.DeleteVolumeSnapshot(snapshot); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete VolumeSnapshot %s: %v", snapshot.GetName(), err)
+			}
+			klog.V(3).InfoS("StatefulSet deleted VolumeSnapshot", "statefulSet", klog.KObj(set), "volumeSnapshot", snapshot.GetName())
+		}
+	}
+	return nil
+}
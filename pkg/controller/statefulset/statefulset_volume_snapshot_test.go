@@ -0,0 +1,259 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefulset
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
+	kruisefake "github.com/openkruise/kruise/pkg/client/clientset/versioned/fake"
+)
+
+type fakeVolumeSnapshotControl struct {
+	snapshots map[string]*unstructured.Unstructured
+}
+
+func (c *fakeVolumeSnapshotControl) GetVolumeSnapshot(namespace, name string) (*unstructured.Unstructured, error) {
+	if snapshot, ok := c.snapshots[namespace+"/"+name]; ok {
+		return snapshot.DeepCopy(), nil
+	}
+	return nil, apierrors.NewNotFound(volumeSnapshotGVK.GroupVersion().WithResource("volumesnapshots").GroupResource(), name)
+}
+
+func (c *fakeVolumeSnapshotControl) ListVolumeSnapshots(set *appsv1beta1.StatefulSet) ([]unstructured.Unstructured, error) {
+	var snapshots []unstructured.Unstructured
+	for _, snapshot := range c.snapshots {
+		if owner := metav1.GetControllerOf(snapshot); snapshot.GetNamespace() == set.Namespace && owner != nil && owner.UID == set.UID {
+			snapshots = append(snapshots, *snapshot.DeepCopy())
+		}
+	}
+	return snapshots, nil
+}
+
+func (c *fakeVolumeSnapshotControl) CreateVolumeSnapshot(snapshot *unstructured.Unstructured) error {
+	key := snapshot.GetNamespace() + "/" + snapshot.GetName()
+	if _, ok := c.snapshots[key]; ok {
+		return apierrors.NewAlreadyExists(volumeSnapshotGVK.GroupVersion().WithResource("volumesnapshots").GroupResource(), snapshot.GetName())
+	}
+	snapshot = snapshot.DeepCopy()
+	snapshot.SetCreationTimestamp(metav1.Now())
+	c.snapshots[key] = snapshot
+	return nil
+}
+
+func (c *fakeVolumeSnapshotControl) DeleteVolumeSnapshot(snapshot *unstructured.Unstructured) error {
+	key := snapshot.GetNamespace() + "/" + snapshot.GetName()
+	if _, ok := c.snapshots[key]; !ok {
+		return apierrors.NewNotFound(volumeSnapshotGVK.GroupVersion().WithResource("volumesnapshots").GroupResource(), snapshot.GetName())
+	}
+	delete(c.snapshots, key)
+	return nil
+}
+
+func TestSnapshotVolumesBeforeUpdate(t *testing.T) {
+	om, _, _, stop := setupController(fake.NewSimpleClientset(), kruisefake.NewSimpleClientset())
+	defer close(stop)
+	snapshotControl := &fakeVolumeSnapshotControl{snapshots: map[string]*unstructured.Unstructured{}}
+	recorder := record.NewFakeRecorder(10)
+	ssc := &defaultStatefulSetControl{
+		podControl:      NewStatefulPodControlFromManager(om, &noopRecorder{}),
+		recorder:        recorder,
+		snapshotControl: snapshotControl,
+	}
+
+	set := newStatefulSet(3)
+	set.Spec.UpdateStrategy.RollingUpdate = &appsv1beta1.RollingUpdateStatefulSetStrategy{
+		VolumeSnapshotBeforeUpdate: &appsv1beta1.VolumeSnapshotStrategy{TimeoutSeconds: 60},
+	}
+	pod := newStatefulSetPod(set, 1)
+	if err := om.podsIndexer.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	for _, claim := range getPersistentVolumeClaims(set, pod) {
+		claim := claim
+		if err := om.claimsIndexer.Add(&claim); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updateRevision := set.Name + "-rev2"
+	claimName := getPersistentVolumeClaimName(set, &set.Spec.VolumeClaimTemplates[0], 1)
+	status := &appsv1beta1.StatefulSetStatus{}
+
+	// record the snapshot name in the pod annotation first
+	if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, pod, updateRevision); err != nil || ready {
+		t.Fatalf("expected not ready without error, got %v, %v", ready, err)
+	}
+	pod, _ = om.podsLister.Pods(set.Namespace).Get(pod.Name)
+	snapshotName := getRecordedVolumeSnapshots(pod)[set.Spec.VolumeClaimTemplates[0].Name]
+	if !isVolumeSnapshotNameOfRevision(set, snapshotName, claimName, updateRevision) {
+		t.Fatalf("expected VolumeSnapshot of claim %s for %s recorded, got %v", claimName, updateRevision, pod.Annotations)
+	}
+	expectedAnnotation := pod.Annotations[appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey]
+	snapshotKey := set.Namespace + "/" + snapshotName
+
+	// create the recorded snapshot
+	if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, pod, updateRevision); err != nil || ready {
+		t.Fatalf("expected not ready without error, got %v, %v", ready, err)
+	}
+	snapshot, ok := snapshotControl.snapshots[snapshotKey]
+	if !ok {
+		t.Fatalf("expected VolumeSnapshot %s to be created", snapshotName)
+	}
+	if source, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName"); source != claimName {
+		t.Fatalf("expected VolumeSnapshot source %s, got %s", claimName, source)
+	}
+	if owner := metav1.GetControllerOf(snapshot); owner == nil || owner.UID != set.UID {
+		t.Fatalf("expected VolumeSnapshot owned by StatefulSet, got %v", snapshot.GetOwnerReferences())
+	}
+
+	// wait for the snapshot to be ready to use
+	if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, pod, updateRevision); err != nil || ready {
+		t.Fatalf("expected not ready without error, got %v, %v", ready, err)
+	}
+
+	// block the update after timeout by default, and the warning is emitted only once
+	snapshot.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-time.Minute)))
+	for i := 0; i < 2; i++ {
+		if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, pod, updateRevision); err != nil || ready {
+			t.Fatalf("expected not ready without error, got %v, %v", ready, err)
+		}
+		if pods := getVolumeSnapshotTimeoutPods(*status); len(pods) != 1 || pods[0] != pod.Name {
+			t.Fatalf("expected pod %s in VolumeSnapshotTimeout condition, got %v", pod.Name, status.Conditions)
+		}
+		set.Status = *status.DeepCopy()
+		status = &appsv1beta1.StatefulSetStatus{}
+	}
+	drainCreateEvents := func() {
+		for len(recorder.Events) > 0 {
+			if event := <-recorder.Events; strings.Contains(event, "VolumeSnapshotTimeout") {
+				t.Fatalf("expected no more timeout event, got %s", event)
+			}
+		}
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "SuccessfulCreateVolumeSnapshot") {
+		t.Fatalf("expected create event, got %s", event)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "VolumeSnapshotTimeout") {
+		t.Fatalf("expected timeout event, got %s", event)
+	}
+	drainCreateEvents()
+
+	// proceed the update after timeout
+	set.Spec.UpdateStrategy.RollingUpdate.VolumeSnapshotBeforeUpdate.FailurePolicy = appsv1beta1.VolumeSnapshotFailurePolicyProceed
+	if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, pod, updateRevision); err != nil || !ready {
+		t.Fatalf("expected ready without error, got %v, %v", ready, err)
+	}
+
+	// update the pod once the snapshot is ready to use
+	set.Spec.UpdateStrategy.RollingUpdate.VolumeSnapshotBeforeUpdate.FailurePolicy = ""
+	if err := unstructured.SetNestedField(snapshot.Object, true, "status", "readyToUse"); err != nil {
+		t.Fatal(err)
+	}
+	if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, pod, updateRevision); err != nil || !ready {
+		t.Fatalf("expected ready without error, got %v, %v", ready, err)
+	}
+
+	// the recreated pod in update revision should be annotated with the snapshot
+	newPod := newStatefulSetPod(set, 1)
+	setPodRevision(newPod, updateRevision)
+	if err := ssc.setVolumeSnapshotsAnnotation(set, newPod); err != nil {
+		t.Fatal(err)
+	}
+	if value := newPod.Annotations[appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey]; value != expectedAnnotation {
+		t.Fatalf("expected annotation %s on recreated pod, got %s", expectedAnnotation, value)
+	}
+
+	// a new snapshot should be created when the pod is updated to the same revision again
+	newPod.Annotations[appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey] = `{"` + set.Spec.VolumeClaimTemplates[0].Name + `":"` +
+		getVolumeSnapshotName(set, claimName, set.Name+"-rev1", "0") + `"}`
+	if err := om.podsIndexer.Update(newPod); err != nil {
+		t.Fatal(err)
+	}
+	if ready, err := ssc.snapshotVolumesBeforeUpdate(set, status, newPod, updateRevision); err != nil || ready {
+		t.Fatalf("expected not ready without error, got %v, %v", ready, err)
+	}
+	newPod, _ = om.podsLister.Pods(set.Namespace).Get(newPod.Name)
+	if name := getRecordedVolumeSnapshots(newPod)[set.Spec.VolumeClaimTemplates[0].Name]; name == snapshotName ||
+		!isVolumeSnapshotNameOfRevision(set, name, claimName, updateRevision) {
+		t.Fatalf("expected a new VolumeSnapshot recorded, got %s", name)
+	}
+}
+
+func TestTruncateVolumeSnapshots(t *testing.T) {
+	snapshotControl := &fakeVolumeSnapshotControl{snapshots: map[string]*unstructured.Unstructured{}}
+	ssc := &defaultStatefulSetControl{snapshotControl: snapshotControl}
+
+	set := newStatefulSet(3)
+	set.Spec.UpdateStrategy.RollingUpdate = &appsv1beta1.RollingUpdateStatefulSetStrategy{
+		VolumeSnapshotBeforeUpdate: &appsv1beta1.VolumeSnapshotStrategy{RetentionLimit: 2},
+	}
+	pod := newStatefulSetPod(set, 1)
+	claimName := getPersistentVolumeClaimName(set, &set.Spec.VolumeClaimTemplates[0], 1)
+	revisions := []*apps.ControllerRevision{
+		{ObjectMeta: metav1.ObjectMeta{Name: set.Name + "-rev2"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: set.Name + "-rev3"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: set.Name + "-rev4"}},
+	}
+	now := time.Now()
+	for i, revision := range []string{"rev1", "rev2", "rev3", "rev3", "rev4"} {
+		name := getVolumeSnapshotName(set, claimName, set.Name+"-"+revision, strconv.Itoa(i))
+		snapshot := newVolumeSnapshot(set, set.Spec.UpdateStrategy.RollingUpdate.VolumeSnapshotBeforeUpdate, pod, claimName, name, set.Name+"-"+revision)
+		snapshot.SetCreationTimestamp(metav1.NewTime(now.Add(time.Duration(i) * time.Minute)))
+		snapshotControl.snapshots[set.Namespace+"/"+name] = snapshot
+	}
+	// snapshots not owned by the StatefulSet are ignored
+	other := newVolumeSnapshot(set, set.Spec.UpdateStrategy.RollingUpdate.VolumeSnapshotBeforeUpdate, pod, claimName, "other", set.Name+"-rev1")
+	other.SetOwnerReferences(nil)
+	snapshotControl.snapshots[set.Namespace+"/other"] = other
+	// the recorded snapshot is retained even if it is beyond the limit
+	recordedName := getVolumeSnapshotName(set, claimName, set.Name+"-rev2", "1")
+	pod.Annotations = map[string]string{
+		appsv1beta1.StatefulSetVolumeSnapshotsAnnotationKey: `{"` + set.Spec.VolumeClaimTemplates[0].Name + `":"` + recordedName + `"}`,
+	}
+
+	if err := ssc.truncateVolumeSnapshots(set, []*v1.Pod{pod}, revisions); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for key := range snapshotControl.snapshots {
+		names = append(names, strings.TrimPrefix(key, set.Namespace+"/"))
+	}
+	sort.Strings(names)
+	expected := []string{
+		getVolumeSnapshotName(set, claimName, set.Name+"-rev3", "3"),
+		getVolumeSnapshotName(set, claimName, set.Name+"-rev4", "4"),
+		recordedName,
+		"other",
+	}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected VolumeSnapshots %v retained, got %v", expected, names)
+	}
+}
@@ -132,6 +132,9 @@ const (
 	// Enables policies auto resizing PVCs created by a StatefulSet when user expands volumeClaimTemplates.
 	StatefulSetAutoResizePVCGate featuregate.Feature = "StatefulSetAutoResizePVCGate"
 
+	// Enables CloneSet to expand PVCs online when user expands volumeClaimTemplates.
+	CloneSetAutoResizePVCGate featuregate.Feature = "CloneSetAutoResizePVCGate"
+
 	// ForceDeleteTimeoutExpectationFeatureGate enable delete timeout expectation, for example: cloneSet ScaleExpectation
 	ForceDeleteTimeoutExpectationFeatureGate = "ForceDeleteTimeoutExpectationGate"
 
@@ -141,6 +144,9 @@ const (
 
 	// EnablePodProbeMarkerOnServerless enable PodProbeMarker on Serverless Pod
 	EnablePodProbeMarkerOnServerless featuregate.Feature = "EnablePodProbeMarkerOnServerless"
+
+	// LifecycleHookHTTPHandler enables workload controllers to call the httpHandler of lifecycle hooks.
+	LifecycleHookHTTPHandler featuregate.Feature = "LifecycleHookHTTPHandler"
 )
 
 var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
@@ -176,9 +182,11 @@ var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
 	PodIndexLabel:                            {Default: true, PreRelease: featuregate.Beta},
 	EnableExternalCerts:                      {Default: false, PreRelease: featuregate.Alpha},
 	StatefulSetAutoResizePVCGate:             {Default: false, PreRelease: featuregate.Alpha},
+	CloneSetAutoResizePVCGate:                {Default: false, PreRelease: featuregate.Alpha},
 	ForceDeleteTimeoutExpectationFeatureGate: {Default: false, PreRelease: featuregate.Alpha},
 	InPlaceWorkloadVerticalScaling:           {Default: false, PreRelease: featuregate.Alpha},
 	EnablePodProbeMarkerOnServerless:         {Default: false, PreRelease: featuregate.Alpha},
+	LifecycleHookHTTPHandler:                 {Default: false, PreRelease: featuregate.Alpha},
 }
 
 func init() {
@@ -65,3 +65,40 @@ func GetReserveOrdinalIntSet(r []intstr.IntOrString) sets.Set[int] {
 	}
 	return values
 }
+
+// MaxOrdinalRangeSize is the max number of ordinals that a range in the ordinals list can contain.
+const MaxOrdinalRangeSize = 10000
+
+// GetOrdinalList returns a list of ints from parsed ordinals in the order they are specified,
+// and the duplicated ones are dropped. The ordinals greater than maxOrdinal are dropped as well,
+// so that a range is never enumerated beyond the existing replicas.
+func GetOrdinalList(r []intstr.IntOrString, maxOrdinal int) []int {
+	values := make([]int, 0, len(r))
+	existing := sets.New[int]()
+	add := func(v int) {
+		if !existing.Has(v) {
+			existing.Insert(v)
+			values = append(values, v)
+		}
+	}
+	for _, elem := range r {
+		if elem.Type == intstr.Int {
+			if int(elem.IntVal) <= maxOrdinal {
+				add(int(elem.IntVal))
+			}
+		} else {
+			start, end, err := ParseRange(elem.StrVal)
+			if err != nil {
+				klog.ErrorS(err, "invalid range ordinal found, an empty slice will be returned", "ordinal", elem.StrVal)
+				return nil
+			}
+			if end > maxOrdinal {
+				end = maxOrdinal
+			}
+			for i := start; i <= end; i++ {
+				add(i)
+			}
+		}
+	}
+	return values
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package api
 
 import (
+	"reflect"
 	"testing"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -145,3 +146,50 @@ func TestGetIntSet(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOrdinalList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []intstr.IntOrString
+		expected []int
+	}{
+		{
+			name:     "keep the specified order",
+			input:    []intstr.IntOrString{intstr.FromInt32(2), intstr.FromInt32(1), intstr.FromInt32(0)},
+			expected: []int{2, 1, 0},
+		},
+		{
+			name:     "range with number",
+			input:    []intstr.IntOrString{intstr.FromString("1-3"), intstr.FromInt32(0)},
+			expected: []int{1, 2, 3, 0},
+		},
+		{
+			name:     "duplicate numbers",
+			input:    []intstr.IntOrString{intstr.FromInt32(3), intstr.FromString("1-3"), intstr.FromInt32(1)},
+			expected: []int{3, 1, 2},
+		},
+		{
+			name:     "empty input",
+			input:    []intstr.IntOrString{},
+			expected: []int{},
+		},
+		{
+			name:  "invalid range",
+			input: []intstr.IntOrString{intstr.FromInt32(1), intstr.FromString("3-1")},
+		},
+		{
+			name:     "ordinals beyond the max",
+			input:    []intstr.IntOrString{intstr.FromInt32(20), intstr.FromString("8-2147483647"), intstr.FromInt32(0)},
+			expected: []int{8, 9, 10, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := GetOrdinalList(tt.input, 10)
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("For case %q, expected %v, but got %v", tt.name, tt.expected, actual)
+			}
+		})
+	}
+}
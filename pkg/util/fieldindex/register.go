@@ -26,6 +26,8 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -43,6 +45,10 @@ const (
 var (
 	registerOnce sync.Once
 	apiGVStr     = appsv1alpha1.GroupVersion.String()
+
+	// VolumeSnapshotGVK is the GroupVersionKind of VolumeSnapshot, which is not registered in the scheme,
+	// so that VolumeSnapshots are read as unstructured objects.
+	VolumeSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot"}
 )
 
 var ownerIndexFunc = func(obj client.Object) []string {
@@ -69,6 +75,14 @@ func RegisterFieldIndexes(c cache.Cache) error {
 		if err = c.IndexField(context.TODO(), &appsv1alpha1.ImagePullJob{}, IndexNameForOwnerRefUID, ownerIndexFunc); err != nil {
 			return
 		}
+		// VolumeSnapshot ownerReference
+		if utildiscovery.DiscoverGVK(VolumeSnapshotGVK) {
+			snapshot := &unstructured.Unstructured{}
+			snapshot.SetGroupVersionKind(VolumeSnapshotGVK)
+			if err = c.IndexField(context.TODO(), snapshot, IndexNameForOwnerRefUID, ownerIndexFunc); err != nil {
+				return
+			}
+		}
 
 		// pod name
 		if err = indexPodNodeName(c); err != nil {
@@ -34,7 +34,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/features"
 	"github.com/openkruise/kruise/pkg/util"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
 	"github.com/openkruise/kruise/pkg/util/podadapter"
 	"github.com/openkruise/kruise/pkg/util/revisionadapter"
 )
@@ -42,6 +44,11 @@ import (
 var (
 	containerImagePatchRexp     = regexp.MustCompile("^/spec/containers/([0-9]+)/image$")
 	containerResourcesPatchRexp = regexp.MustCompile("^/spec/containers/([0-9]+)/resources/.*$")
+	// command, args, env and probes are immutable in pod spec, and kubelet always starts a container
+	// with the spec in pod, so recreating the container will not apply the changes of them,
+	// except the values projected into pod annotations with InPlaceUpdateRecreateContainerKey.
+	containerImmutablePatchRexp = regexp.MustCompile("^/spec/containers/([0-9]+)/(command|args|env|livenessProbe|readinessProbe|startupProbe)(/.*)?$")
+	containerProjectedPatchRexp = regexp.MustCompile("^/spec/containers/([0-9]+)/(env/([0-9]+)/value|command/([0-9]+)|args/([0-9]+))$")
 	rfc6901Decoder              = strings.NewReplacer("~1", "/", "~0", "~")
 
 	Clock clock.Clock = clock.RealClock{}
@@ -50,6 +57,10 @@ var (
 type RefreshResult struct {
 	RefreshErr    error
 	DelayDuration time.Duration
+	// ResizeFailed is true if the in-place resizing of resources has not been done within ResizeTimeoutSeconds.
+	ResizeFailed bool
+	// PostUpdateHooked is true if the in-place updated pod is kept not ready by the post-update hook.
+	PostUpdateHooked bool
 }
 
 type UpdateResult struct {
@@ -65,6 +76,14 @@ type UpdateOptions struct {
 	GracePeriodSeconds int32
 	AdditionalFuncs    []func(*v1.Pod)
 
+	// ResizeTimeoutSeconds is the timespan to wait for the Infeasible or Deferred resize of pod,
+	// after which the in-place update will be marked failed. No timeout if it is not positive.
+	ResizeTimeoutSeconds int32
+
+	// CheckPostUpdateHook returns true if the pod, whose containers have been updated in-place since updateTime,
+	// should be kept not ready by the post-update hook, and the duration after which it should be checked again.
+	CheckPostUpdateHook func(pod *v1.Pod, updateTime time.Time) (bool, time.Duration)
+
 	CalculateSpec                  func(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) *UpdateSpec
 	PatchSpecToPod                 func(pod *v1.Pod, spec *UpdateSpec, state *appspub.InPlaceUpdateState) (*v1.Pod, error)
 	CheckPodUpdateCompleted        func(pod *v1.Pod) error
@@ -73,9 +92,36 @@ type UpdateOptions struct {
 	GetRevision                    func(rev *apps.ControllerRevision) string
 }
 
+// NotInPlaceUpdateReason describes why the changes between revisions can not be updated in place.
+type NotInPlaceUpdateReason struct {
+	// Path is the JSON path of the changed field in pod, such as /spec/containers/0/command.
+	// It is empty if the reason is not caused by a specific field.
+	Path string `json:"path,omitempty"`
+	// Message is a human readable explanation.
+	Message string `json:"message"`
+}
+
+func (r NotInPlaceUpdateReason) String() string {
+	if r.Path == "" {
+		return r.Message
+	}
+	return fmt.Sprintf("%s: %s", r.Path, r.Message)
+}
+
+// FormatNotInPlaceUpdateReasons joins the reasons into a message for events and conditions.
+func FormatNotInPlaceUpdateReasons(reasons []NotInPlaceUpdateReason) string {
+	messages := make([]string, 0, len(reasons))
+	for _, r := range reasons {
+		messages = append(messages, r.String())
+	}
+	return strings.Join(messages, "; ")
+}
+
 // Interface for managing pods in-place update.
 type Interface interface {
-	CanUpdateInPlace(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) bool
+	// CanUpdateInPlace returns whether the changes between revisions can be updated in place.
+	// If not, it also returns the reasons.
+	CanUpdateInPlace(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) (bool, []NotInPlaceUpdateReason)
 	Update(pod *v1.Pod, oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) UpdateResult
 	Refresh(pod *v1.Pod, opts *UpdateOptions) RefreshResult
 }
@@ -128,16 +174,18 @@ func (c *realControl) Refresh(pod *v1.Pod, opts *UpdateOptions) RefreshResult {
 		return RefreshResult{DelayDuration: delayDuration}
 	}
 
+	var updateTime *time.Time
 	if stateStr, ok := appspub.GetInPlaceUpdateState(pod); ok {
 		state := appspub.InPlaceUpdateState{}
 		if err := json.Unmarshal([]byte(stateStr), &state); err != nil {
 			return RefreshResult{RefreshErr: err}
 		}
+		updateTime = &state.UpdateTimestamp.Time
 
 		// check in-place updating has not completed yet
 		if checkErr := opts.CheckContainersUpdateCompleted(pod, &state); checkErr != nil {
 			klog.V(6).ErrorS(checkErr, "Check Pod in-place update not completed yet", "namespace", pod.Namespace, "name", pod.Name)
-			return RefreshResult{}
+			return c.checkResizeTimeout(pod, &state, opts)
 		}
 
 		// check if there are containers with lower-priority that have to in-place update in next batch
@@ -158,10 +206,19 @@ func (c *realControl) Refresh(pod *v1.Pod, opts *UpdateOptions) RefreshResult {
 		}
 	}
 
-	if !containsReadinessGate(pod) {
+	// the condition may also be set to False for the pod without readiness-gate if its resize failed
+	if !containsReadinessGate(pod) && GetCondition(pod) == nil {
 		return RefreshResult{}
 	}
 
+	// keep the pod not ready after in-place update until the post-update hook has been released
+	if condition := GetCondition(pod); opts.CheckPostUpdateHook != nil && updateTime != nil &&
+		condition != nil && condition.Status == v1.ConditionFalse {
+		if hooked, delayDuration := opts.CheckPostUpdateHook(pod, *updateTime); hooked {
+			return RefreshResult{DelayDuration: delayDuration, PostUpdateHooked: true}
+		}
+	}
+
 	newCondition := v1.PodCondition{
 		Type:               appspub.InPlaceUpdateReady,
 		Status:             v1.ConditionTrue,
@@ -171,6 +228,39 @@ func (c *realControl) Refresh(pod *v1.Pod, opts *UpdateOptions) RefreshResult {
 	return RefreshResult{RefreshErr: err}
 }
 
+// checkResizeTimeout marks the in-place update failed, if kubelet has reported the resize of pod as
+// Infeasible or Deferred and it has not been done within the ResizeTimeoutSeconds since the update.
+func (c *realControl) checkResizeTimeout(pod *v1.Pod, state *appspub.InPlaceUpdateState, opts *UpdateOptions) RefreshResult {
+	if opts.ResizeTimeoutSeconds <= 0 || !state.UpdateResources ||
+		!utilfeature.DefaultFeatureGate.Enabled(features.InPlaceWorkloadVerticalScaling) {
+		return RefreshResult{}
+	}
+	if pod.Status.Resize != v1.PodResizeStatusInfeasible && pod.Status.Resize != v1.PodResizeStatusDeferred {
+		return RefreshResult{}
+	}
+
+	timeout := time.Second * time.Duration(opts.ResizeTimeoutSeconds)
+	if span := Clock.Since(state.UpdateTimestamp.Time); span < timeout {
+		return RefreshResult{DelayDuration: roundupSeconds(timeout - span)}
+	}
+
+	if IsResizeFailed(pod) {
+		return RefreshResult{ResizeFailed: true}
+	}
+	klog.InfoS("Pod in-place resize timed out", "namespace", pod.Namespace, "name", pod.Name, "resize", pod.Status.Resize)
+	newCondition := v1.PodCondition{
+		Type:               appspub.InPlaceUpdateReady,
+		Status:             v1.ConditionFalse,
+		Reason:             appspub.InPlaceUpdateResizeFailedReason,
+		Message:            fmt.Sprintf("resize is %s for more than %d seconds", pod.Status.Resize, opts.ResizeTimeoutSeconds),
+		LastTransitionTime: metav1.NewTime(Clock.Now()),
+	}
+	if err := c.updateCondition(pod, newCondition); err != nil {
+		return RefreshResult{RefreshErr: err}
+	}
+	return RefreshResult{ResizeFailed: true}
+}
+
 func (c *realControl) updateCondition(pod *v1.Pod, condition v1.PodCondition) error {
 	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
 		clone, err := c.podAdapter.GetPod(pod.Namespace, pod.Name)
@@ -277,9 +367,22 @@ func (c *realControl) updateNextBatch(pod *v1.Pod, opts *UpdateOptions) (bool, e
 	return updated, err
 }
 
-func (c *realControl) CanUpdateInPlace(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) bool {
-	opts = SetOptionsDefaults(opts)
-	return opts.CalculateSpec(oldRevision, newRevision, opts) != nil
+func (c *realControl) CanUpdateInPlace(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) (bool, []NotInPlaceUpdateReason) {
+	return CheckInPlaceUpdate(oldRevision, newRevision, opts)
+}
+
+// CheckInPlaceUpdate returns whether the changes between revisions can be updated in place, and the reasons if not.
+// Only the default calculation can tell the detailed reasons, a customized CalculateSpec in opts
+// returns a general reason instead.
+func CheckInPlaceUpdate(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) (bool, []NotInPlaceUpdateReason) {
+	if opts == nil || opts.CalculateSpec == nil {
+		spec, reasons := calculateInPlaceUpdateSpec(oldRevision, newRevision, opts)
+		return spec != nil, reasons
+	}
+	if opts.CalculateSpec(oldRevision, newRevision, opts) == nil {
+		return false, []NotInPlaceUpdateReason{{Message: "rejected by the customized calculation"}}
+	}
+	return true, nil
 }
 
 func (c *realControl) Update(pod *v1.Pod, oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) UpdateResult {
@@ -404,6 +507,12 @@ func GetCondition(pod *v1.Pod) *v1.PodCondition {
 	return util.GetCondition(pod, appspub.InPlaceUpdateReady)
 }
 
+// IsResizeFailed returns whether the in-place update of pod has been marked failed for its resize timed out.
+func IsResizeFailed(pod *v1.Pod) bool {
+	condition := GetCondition(pod)
+	return condition != nil && condition.Status == v1.ConditionFalse && condition.Reason == appspub.InPlaceUpdateResizeFailedReason
+}
+
 func roundupSeconds(d time.Duration) time.Duration {
 	if d%time.Second == 0 {
 		return d
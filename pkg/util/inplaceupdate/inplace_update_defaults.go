@@ -171,6 +171,29 @@ func defaultPatchUpdateSpecToPod(pod *v1.Pod, spec *UpdateSpec, state *appspub.I
 			}
 		}
 
+		// record restart counts of the containers that kubelet will restart to resize,
+		// so that the update will not be completed until they have been restarted,
+		// the containers with image updated are checked by imageID instead
+		resizedResources := make(map[string]v1.ResourceRequirements, len(expectedResources))
+		for cName, r := range expectedResources {
+			if _, ok := spec.ContainerImages[cName]; !ok {
+				resizedResources[cName] = *r
+			}
+		}
+		restartContainers := getResizeRestartContainers(pod, resizedResources)
+		for _, cs := range pod.Status.ContainerStatuses {
+			if !restartContainers.Has(cs.Name) {
+				continue
+			}
+			if state.LastContainerStatuses == nil {
+				state.LastContainerStatuses = map[string]appspub.InPlaceUpdateContainerStatus{}
+			}
+			status := state.LastContainerStatuses[cs.Name]
+			status.RestartCount = cs.RestartCount
+			status.ResizeRestart = true
+			state.LastContainerStatuses[cs.Name] = status
+		}
+
 		// vertical update containers in a batch,
 		// or internal enterprise implementations can update+sync pod resources here at once
 		verticalUpdateImpl.UpdateResource(pod, expectedResources)
@@ -178,6 +201,9 @@ func defaultPatchUpdateSpecToPod(pod *v1.Pod, spec *UpdateSpec, state *appspub.I
 
 	// update annotations and labels for the containers to update
 	for cName, objMeta := range spec.ContainerRefMetadata {
+		if err := checkProjectedMetadata(pod, cName, objMeta); err != nil {
+			return nil, err
+		}
 		if containersToUpdate.Has(cName) {
 			for k, v := range objMeta.Labels {
 				pod.Labels[k] = v
@@ -259,14 +285,21 @@ func addMetadataSharedContainersToUpdate(pod *v1.Pod, containersToUpdate sets.St
 // If the diff just contains replace operation of spec.containers[x].image, it will returns an UpdateSpec.
 // Otherwise, it returns nil which means can not use in-place update.
 func defaultCalculateInPlaceUpdateSpec(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) *UpdateSpec {
+	spec, _ := calculateInPlaceUpdateSpec(oldRevision, newRevision, opts)
+	return spec
+}
+
+// calculateInPlaceUpdateSpec is the implementation of defaultCalculateInPlaceUpdateSpec,
+// which also returns the reasons why the revisions can not be updated in place if the spec is nil.
+func calculateInPlaceUpdateSpec(oldRevision, newRevision *apps.ControllerRevision, opts *UpdateOptions) (*UpdateSpec, []NotInPlaceUpdateReason) {
 	if oldRevision == nil || newRevision == nil {
-		return nil
+		return nil, []NotInPlaceUpdateReason{{Message: "old or new revision not found"}}
 	}
 	opts = SetOptionsDefaults(opts)
 
 	patches, err := jsonpatch.CreatePatch(oldRevision.Data.Raw, newRevision.Data.Raw)
 	if err != nil {
-		return nil
+		return nil, []NotInPlaceUpdateReason{{Message: fmt.Sprintf("failed to diff revisions: %v", err)}}
 	}
 
 	// RecreatePodWhenChangeVCTInCloneSetGate enabled
@@ -274,18 +307,26 @@ func defaultCalculateInPlaceUpdateSpec(oldRevision, newRevision *apps.Controller
 		if !opts.IgnoreVolumeClaimTemplatesHashDiff {
 			canInPlace := volumeclaimtemplate.CanVCTemplateInplaceUpdate(oldRevision, newRevision)
 			if !canInPlace {
-				return nil
+				return nil, []NotInPlaceUpdateReason{{Path: "/spec/volumeClaimTemplates", Message: "volumeClaimTemplates changed"}}
 			}
 		}
 	}
 
 	oldTemp, err := GetTemplateFromRevision(oldRevision)
 	if err != nil {
-		return nil
+		return nil, []NotInPlaceUpdateReason{{Message: fmt.Sprintf("failed to get template from revision %s: %v", oldRevision.Name, err)}}
 	}
 	newTemp, err := GetTemplateFromRevision(newRevision)
 	if err != nil {
-		return nil
+		return nil, []NotInPlaceUpdateReason{{Message: fmt.Sprintf("failed to get template from revision %s: %v", newRevision.Name, err)}}
+	}
+
+	// pods are created with the values of containers projected into annotations or not,
+	// so the changes of them can only be updated in place with the same InPlaceUpdateRecreateContainerKey
+	recreateContainer := IsRecreateContainerEnabled(&oldTemp.ObjectMeta)
+	if recreateContainer != IsRecreateContainerEnabled(&newTemp.ObjectMeta) {
+		return nil, []NotInPlaceUpdateReason{{Path: "/metadata/annotations/" + strings.Replace(appspub.InPlaceUpdateRecreateContainerKey, "/", "~1", -1),
+			Message: "recreating container for in-place update enabled or disabled"}}
 	}
 
 	updateSpec := &UpdateSpec{
@@ -300,6 +341,8 @@ func defaultCalculateInPlaceUpdateSpec(oldRevision, newRevision *apps.Controller
 	}
 
 	// all patches for podSpec can just update images in pod spec
+	// collect the reasons of all patches that can not be updated in place
+	var reasons []NotInPlaceUpdateReason
 	var metadataPatches []jsonpatch.Operation
 	for _, op := range patches {
 		op.Path = strings.Replace(op.Path, "/spec/template", "", 1)
@@ -309,18 +352,21 @@ func defaultCalculateInPlaceUpdateSpec(oldRevision, newRevision *apps.Controller
 				metadataPatches = append(metadataPatches, op)
 				continue
 			}
-			return nil
+			reasons = append(reasons, NotInPlaceUpdateReason{Path: op.Path, Message: "field out of pod template changed"})
+			continue
 		}
 
 		if op.Operation != "replace" {
-			return nil
+			reasons = append(reasons, NotInPlaceUpdateReason{Path: op.Path, Message: fmt.Sprintf("%s operation is not supported", op.Operation)})
+			continue
 		}
 		if containerImagePatchRexp.MatchString(op.Path) {
 			// for example: /spec/containers/0/image
 			words := strings.Split(op.Path, "/")
 			idx, _ := strconv.Atoi(words[3])
 			if len(oldTemp.Spec.Containers) <= idx {
-				return nil
+				reasons = append(reasons, NotInPlaceUpdateReason{Path: op.Path, Message: "container not found in old revision"})
+				continue
 			}
 			updateSpec.ContainerImages[oldTemp.Spec.Containers[idx].Name] = op.Value.(string)
 			continue
@@ -331,18 +377,31 @@ func defaultCalculateInPlaceUpdateSpec(oldRevision, newRevision *apps.Controller
 			err = verticalUpdateImpl.UpdateInplaceUpdateMetadata(&op, oldTemp, updateSpec)
 			if err != nil {
 				klog.InfoS("UpdateInplaceUpdateMetadata error", "err", err)
-				return nil
+				reasons = append(reasons, NotInPlaceUpdateReason{Path: op.Path, Message: err.Error()})
 			}
 			continue
 		}
-		return nil
+		if recreateContainer && containerProjectedPatchRexp.MatchString(op.Path) {
+			if err = projectPatchToMetadata(&op, oldTemp, updateSpec); err != nil {
+				reasons = append(reasons, NotInPlaceUpdateReason{Path: op.Path, Message: err.Error()})
+			}
+			continue
+		}
+		if containerImmutablePatchRexp.MatchString(op.Path) {
+			reasons = append(reasons, NotInPlaceUpdateReason{Path: op.Path, Message: "field is immutable in pod spec and requires recreating the pod"})
+			continue
+		}
+		reasons = append(reasons, NotInPlaceUpdateReason{Path: op.Path, Message: "field can not be updated in place"})
+	}
+	if len(reasons) > 0 {
+		return nil, reasons
 	}
 	if utilfeature.DefaultFeatureGate.Enabled(features.InPlaceWorkloadVerticalScaling) &&
 		len(updateSpec.ContainerResources) != 0 {
 		// when container resources changes exist, we should check pod qos
 		if changed := verticalUpdateImpl.IsPodQoSChanged(oldTemp, newTemp); changed {
 			klog.InfoS("can not inplace update when qos changed")
-			return nil
+			return nil, []NotInPlaceUpdateReason{{Path: "/spec/containers", Message: "pod QoS class changed"}}
 		}
 	}
 
@@ -396,12 +455,12 @@ func defaultCalculateInPlaceUpdateSpec(oldRevision, newRevision *apps.Controller
 		newBytes, _ := json.Marshal(v1.Pod{ObjectMeta: newTemp.ObjectMeta})
 		patchBytes, err := strategicpatch.CreateTwoWayMergePatch(oldBytes, newBytes, &v1.Pod{})
 		if err != nil {
-			return nil
+			return nil, []NotInPlaceUpdateReason{{Path: "/metadata", Message: fmt.Sprintf("failed to create metadata patch: %v", err)}}
 		}
 		updateSpec.MetaDataPatch = patchBytes
 	}
 
-	return updateSpec
+	return updateSpec, nil
 }
 
 // DefaultCheckInPlaceUpdateCompleted checks whether imageID in pod status has been changed since in-place update.
@@ -444,6 +503,9 @@ func defaultCheckContainersInPlaceUpdateCompleted(pod *v1.Pod, inPlaceUpdateStat
 		if completed, err := verticalUpdateImpl.IsUpdateCompleted(pod); !completed {
 			return err
 		}
+		if err := checkContainersResizeRestarted(pod, inPlaceUpdateState); err != nil {
+			return err
+		}
 	}
 
 	if runtimeContainerMetaSet != nil {
@@ -576,9 +638,19 @@ func defaultCheckPodNeedsBeUnready(pod *v1.Pod, spec *UpdateSpec) bool {
 		return containsReadinessGate(pod)
 	}
 
+	if getResizeRestartContainers(pod, spec.ContainerResources).Len() == 0 {
+		return false
+	}
+
+	return containsReadinessGate(pod)
+}
+
+// getResizeRestartContainers returns the names of containers that will be restarted by kubelet to resize
+// the given resources, for the resizePolicy of the changed resources is RestartContainer.
+func getResizeRestartContainers(pod *v1.Pod, containerResources map[string]v1.ResourceRequirements) sets.String {
 	// flag represents whether cpu or memory resource changed
 	resourceFlag := make(map[string]int)
-	for c, resizeResources := range spec.ContainerResources {
+	for c, resizeResources := range containerResources {
 		flag := 0
 		_, limitExist := resizeResources.Limits[v1.ResourceCPU]
 		_, reqExist := resizeResources.Requests[v1.ResourceCPU]
@@ -597,8 +669,7 @@ func defaultCheckPodNeedsBeUnready(pod *v1.Pod, spec *UpdateSpec) bool {
 	// For example:
 	// 		we should not restart the container
 	//		when only resize cpu in container with memory RestartContainer RestartPolicy,
-	needRestart := false
-OuterLoop:
+	restartContainers := sets.NewString()
 	for _, container := range pod.Spec.Containers {
 		if flag, exist := resourceFlag[container.Name]; exist {
 			for _, resizePolicy := range container.ResizePolicy {
@@ -607,15 +678,24 @@ OuterLoop:
 				}
 				if (resizePolicy.ResourceName == v1.ResourceCPU && (flag&cpuMask) != 0) ||
 					(resizePolicy.ResourceName == v1.ResourceMemory && (flag&memMask) != 0) {
-					needRestart = true
-					break OuterLoop
+					restartContainers.Insert(container.Name)
+					break
 				}
 			}
 		}
 	}
-	if !needRestart {
-		return false
-	}
+	return restartContainers
+}
 
-	return containsReadinessGate(pod)
+// checkContainersResizeRestarted checks the containers resized with RestartContainer policy have been restarted,
+// otherwise they may still run with the old resources even if kubelet has reported the new ones.
+func checkContainersResizeRestarted(pod *v1.Pod, inPlaceUpdateState *appspub.InPlaceUpdateState) error {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if oldStatus, ok := inPlaceUpdateState.LastContainerStatuses[cs.Name]; ok && oldStatus.ResizeRestart {
+			if cs.RestartCount <= oldStatus.RestartCount {
+				return fmt.Errorf("container %s has not been restarted for resize", cs.Name)
+			}
+		}
+	}
+	return nil
 }
@@ -2071,3 +2071,24 @@ func TestDefaultCheckPodNeedsBeUnready(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckContainersResizeRestarted(t *testing.T) {
+	state := &appspub.InPlaceUpdateState{
+		LastContainerStatuses: map[string]appspub.InPlaceUpdateContainerStatus{
+			"c1": {RestartCount: 1, ResizeRestart: true},
+			"c2": {ImageID: "img-id"},
+		},
+	}
+	pod := &v1.Pod{
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "c1", RestartCount: 1},
+				{Name: "c2", RestartCount: 0},
+			},
+		},
+	}
+	assert.Error(t, checkContainersResizeRestarted(pod, state))
+
+	pod.Status.ContainerStatuses[0].RestartCount = 2
+	assert.NoError(t, checkContainersResizeRestarted(pod, state))
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inplaceupdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/appscode/jsonpatch"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/features"
+	utilcontainermeta "github.com/openkruise/kruise/pkg/util/containermeta"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+)
+
+// projectedAnnotationSuffix is the suffix of the prefix of pod annotations that the values of containers are projected into.
+// The whole key looks like `<container-name>.inplace-update.apps.kruise.io/env.<ENV-NAME>`.
+const projectedAnnotationSuffix = ".inplace-update.apps.kruise.io"
+
+// IsRecreateContainerEnabled returns whether the values of containers in the pod template
+// should be projected into pod annotations, so that their changes can be updated in place.
+func IsRecreateContainerEnabled(meta *metav1.ObjectMeta) bool {
+	return utilfeature.DefaultFeatureGate.Enabled(features.InPlaceUpdateEnvFromMetadata) &&
+		meta.Annotations[appspub.InPlaceUpdateRecreateContainerKey] == "true"
+}
+
+// ProjectContainerValues projects the plain env values, command and args of containers into pod annotations,
+// and makes the containers read them by env from metadata, if InPlaceUpdateRecreateContainerKey is enabled.
+// The elements of command and args are replaced with $(VAR) references to the projected env.
+// Values that reference other variables are kept as they are, and changing them still needs to recreate the pod.
+// It should be called when a new pod is created from the template.
+func ProjectContainerValues(pod *v1.Pod) {
+	if !IsRecreateContainerEnabled(&pod.ObjectMeta) {
+		return
+	}
+
+	// DO NOT modify the containers and annotations in place, for they may be shared with the template
+	annotations := make(map[string]string, len(pod.Annotations))
+	for k, v := range pod.Annotations {
+		annotations[k] = v
+	}
+	containers := make([]v1.Container, len(pod.Spec.Containers))
+	for i := range pod.Spec.Containers {
+		c := pod.Spec.Containers[i].DeepCopy()
+		for j := range c.Env {
+			env := &c.Env[j]
+			if env.ValueFrom != nil || !isProjectableValue(env.Value) {
+				continue
+			}
+			key, ok := getProjectedAnnotationKey(c.Name, "env."+env.Name)
+			if !ok {
+				continue
+			}
+			annotations[key] = env.Value
+			env.Value = ""
+			env.ValueFrom = newAnnotationEnvSource(key)
+		}
+		projectContainerArgs(annotations, c, "command", c.Command)
+		projectContainerArgs(annotations, c, "args", c.Args)
+		containers[i] = *c
+	}
+	pod.Annotations = annotations
+	pod.Spec.Containers = containers
+}
+
+func projectContainerArgs(annotations map[string]string, c *v1.Container, field string, args []string) {
+	for k := range args {
+		if !isProjectableValue(args[k]) {
+			continue
+		}
+		key, ok := getProjectedAnnotationKey(c.Name, fmt.Sprintf("%s.%d", field, k))
+		envName := getProjectedArgEnvName(field, k)
+		if !ok || hasContainerEnv(c, envName) {
+			continue
+		}
+		annotations[key] = args[k]
+		c.Env = append(c.Env, v1.EnvVar{Name: envName, ValueFrom: newAnnotationEnvSource(key)})
+		args[k] = fmt.Sprintf("$(%s)", envName)
+	}
+}
+
+// projectPatchToMetadata converts the replace operation of a plain env value or an element of command and args
+// into the annotation that the value has been projected into, so that kruise-daemon will recreate the container.
+func projectPatchToMetadata(op *jsonpatch.Operation, oldTemp *v1.PodTemplateSpec, updateSpec *UpdateSpec) error {
+	// for example: /spec/containers/0/env/1/value, /spec/containers/0/args/2
+	words := strings.Split(op.Path, "/")
+	idx, _ := strconv.Atoi(words[3])
+	if len(oldTemp.Spec.Containers) <= idx {
+		return fmt.Errorf("container not found in old revision")
+	}
+	c := &oldTemp.Spec.Containers[idx]
+	newValue, ok := op.Value.(string)
+	if !ok {
+		return fmt.Errorf("value is not a string")
+	}
+
+	k, _ := strconv.Atoi(words[5])
+	var oldValue, name string
+	switch words[4] {
+	case "env":
+		if len(c.Env) <= k || c.Env[k].ValueFrom != nil {
+			return fmt.Errorf("env not found in old revision")
+		}
+		oldValue, name = c.Env[k].Value, "env."+c.Env[k].Name
+	case "command", "args":
+		values := c.Command
+		if words[4] == "args" {
+			values = c.Args
+		}
+		if len(values) <= k || hasContainerEnv(c, getProjectedArgEnvName(words[4], k)) {
+			return fmt.Errorf("%s not found in old revision", words[4])
+		}
+		oldValue, name = values[k], fmt.Sprintf("%s.%d", words[4], k)
+	}
+	if !isProjectableValue(oldValue) || !isProjectableValue(newValue) {
+		return fmt.Errorf("value that references variables can not be updated by recreating container")
+	}
+	key, ok := getProjectedAnnotationKey(c.Name, name)
+	if !ok {
+		return fmt.Errorf("value can not be projected into annotation %s", key)
+	}
+
+	objMeta := updateSpec.ContainerRefMetadata[c.Name]
+	if objMeta.Annotations == nil {
+		objMeta.Annotations = make(map[string]string)
+	}
+	objMeta.Annotations[key] = newValue
+	updateSpec.ContainerRefMetadata[c.Name] = objMeta
+	updateSpec.UpdateEnvFromMetadata = true
+	return nil
+}
+
+// checkProjectedMetadata checks that the container in pod actually reads the projected annotations to update,
+// in case the pod was created before InPlaceUpdateRecreateContainerKey enabled.
+func checkProjectedMetadata(pod *v1.Pod, containerName string, objMeta metav1.ObjectMeta) error {
+	for k := range objMeta.Annotations {
+		if !strings.HasPrefix(k, containerName+projectedAnnotationSuffix+"/") {
+			continue
+		}
+		for i := range pod.Spec.Containers {
+			c := &pod.Spec.Containers[i]
+			if c.Name == containerName && !utilcontainermeta.IsContainerReferenceToMeta(c, "metadata.annotations", k) {
+				return fmt.Errorf("container %s does not read annotation %s, the pod has to be recreated", containerName, k)
+			}
+		}
+	}
+	return nil
+}
+
+func getProjectedAnnotationKey(containerName, name string) (string, bool) {
+	key := containerName + projectedAnnotationSuffix + "/" + name
+	return key, len(validation.IsQualifiedName(key)) == 0
+}
+
+func getProjectedArgEnvName(field string, k int) string {
+	return fmt.Sprintf("KRUISE_INPLACE_%s_%d", strings.ToUpper(field), k)
+}
+
+// isProjectableValue returns false if the value may reference other variables,
+// which will not be expanded any more once it is read from annotation.
+func isProjectableValue(value string) bool {
+	return !strings.Contains(value, "$")
+}
+
+func hasContainerEnv(c *v1.Container, name string) bool {
+	for i := range c.Env {
+		if c.Env[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newAnnotationEnvSource(key string) *v1.EnvVarSource {
+	return &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: fmt.Sprintf("metadata.annotations['%s']", key)}}
+}
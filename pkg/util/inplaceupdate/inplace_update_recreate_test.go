@@ -0,0 +1,191 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inplaceupdate
+
+import (
+	"reflect"
+	"testing"
+
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/features"
+	utilcontainermeta "github.com/openkruise/kruise/pkg/util/containermeta"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+)
+
+func TestProjectContainerValues(t *testing.T) {
+	defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.InPlaceUpdateEnvFromMetadata, true)()
+
+	template := &v1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{appspub.InPlaceUpdateRecreateContainerKey: "true"}},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:    "main",
+				Command: []string{"/bin/app"},
+				Args:    []string{"--level=info", "--home=$(HOME)"},
+				Env: []v1.EnvVar{
+					{Name: "MODE", Value: "fast"},
+					{Name: "HOME", Value: "/home/$(USER)"},
+					{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+				},
+			}},
+		},
+	}
+	pod := &v1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+	ProjectContainerValues(pod)
+
+	if template.Spec.Containers[0].Args[0] != "--level=info" || template.Spec.Containers[0].Env[0].Value != "fast" || len(template.Annotations) != 1 {
+		t.Fatalf("expected template not modified, got %v", template)
+	}
+
+	expectedAnnotations := map[string]string{
+		appspub.InPlaceUpdateRecreateContainerKey:      "true",
+		"main.inplace-update.apps.kruise.io/env.MODE":  "fast",
+		"main.inplace-update.apps.kruise.io/command.0": "/bin/app",
+		"main.inplace-update.apps.kruise.io/args.0":    "--level=info",
+	}
+	if !reflect.DeepEqual(pod.Annotations, expectedAnnotations) {
+		t.Fatalf("expected annotations %v, got %v", expectedAnnotations, pod.Annotations)
+	}
+
+	c := &pod.Spec.Containers[0]
+	if !reflect.DeepEqual(c.Command, []string{"$(KRUISE_INPLACE_COMMAND_0)"}) {
+		t.Fatalf("unexpected command %v", c.Command)
+	}
+	if !reflect.DeepEqual(c.Args, []string{"$(KRUISE_INPLACE_ARGS_0)", "--home=$(HOME)"}) {
+		t.Fatalf("unexpected args %v", c.Args)
+	}
+	if c.Env[1].Value != "/home/$(USER)" || c.Env[1].ValueFrom != nil {
+		t.Fatalf("expected env referencing variables not projected, got %v", c.Env[1])
+	}
+	for key := range expectedAnnotations {
+		if key != appspub.InPlaceUpdateRecreateContainerKey && !utilcontainermeta.IsContainerReferenceToMeta(c, "metadata.annotations", key) {
+			t.Fatalf("expected container to read annotation %s, got env %v", key, c.Env)
+		}
+	}
+
+	// not projected without the annotation
+	pod = &v1.Pod{Spec: *template.Spec.DeepCopy()}
+	ProjectContainerValues(pod)
+	if !reflect.DeepEqual(pod.Spec, template.Spec) {
+		t.Fatalf("expected pod not projected, got %v", pod.Spec)
+	}
+}
+
+func TestCalculateInPlaceUpdateSpecWithRecreateContainer(t *testing.T) {
+	defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.InPlaceUpdateEnvFromMetadata, true)()
+
+	newRevision := func(name, annotations, containers string) *apps.ControllerRevision {
+		return &apps.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data: runtime.RawExtension{Raw: []byte(`{"spec":{"template":{"$patch":"replace","metadata":{"annotations":` + annotations +
+				`},"spec":{"containers":` + containers + `}}}}`)},
+		}
+	}
+	enabled := `{"apps.kruise.io/inplace-update-recreate-container":"true"}`
+
+	cases := []struct {
+		name             string
+		oldRevision      *apps.ControllerRevision
+		newRevision      *apps.ControllerRevision
+		expectedMetadata map[string]metav1.ObjectMeta
+		expectedReasons  []NotInPlaceUpdateReason
+	}{
+		{
+			name:        "env, command and args changed",
+			oldRevision: newRevision("old-revision", enabled, `[{"name":"c1","image":"foo","command":["a"],"args":["--x=1"],"env":[{"name":"E","value":"1"}]}]`),
+			newRevision: newRevision("new-revision", enabled, `[{"name":"c1","image":"foo","command":["b"],"args":["--x=2"],"env":[{"name":"E","value":"2"}]}]`),
+			expectedMetadata: map[string]metav1.ObjectMeta{"c1": {Annotations: map[string]string{
+				"c1.inplace-update.apps.kruise.io/command.0": "b",
+				"c1.inplace-update.apps.kruise.io/args.0":    "--x=2",
+				"c1.inplace-update.apps.kruise.io/env.E":     "2",
+			}}},
+		},
+		{
+			name:        "value references variables",
+			oldRevision: newRevision("old-revision", enabled, `[{"name":"c1","image":"foo","args":["--x=1"]}]`),
+			newRevision: newRevision("new-revision", enabled, `[{"name":"c1","image":"foo","args":["--x=$(X)"]}]`),
+			expectedReasons: []NotInPlaceUpdateReason{
+				{Path: "/spec/containers/0/args/0", Message: "value that references variables can not be updated by recreating container"},
+			},
+		},
+		{
+			name:        "args added",
+			oldRevision: newRevision("old-revision", enabled, `[{"name":"c1","image":"foo","args":["--x=1"]}]`),
+			newRevision: newRevision("new-revision", enabled, `[{"name":"c1","image":"foo","args":["--x=1","--y=1"]}]`),
+			expectedReasons: []NotInPlaceUpdateReason{
+				{Path: "/spec/containers/0/args/1", Message: "add operation is not supported"},
+			},
+		},
+		{
+			name:        "recreating container enabled",
+			oldRevision: newRevision("old-revision", `{}`, `[{"name":"c1","image":"foo","args":["--x=1"]}]`),
+			newRevision: newRevision("new-revision", enabled, `[{"name":"c1","image":"foo","args":["--x=2"]}]`),
+			expectedReasons: []NotInPlaceUpdateReason{
+				{Path: "/metadata/annotations/apps.kruise.io~1inplace-update-recreate-container", Message: "recreating container for in-place update enabled or disabled"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, reasons := calculateInPlaceUpdateSpec(tc.oldRevision, tc.newRevision, nil)
+			if !reflect.DeepEqual(reasons, tc.expectedReasons) {
+				t.Fatalf("expected reasons %v, got %v", tc.expectedReasons, reasons)
+			}
+			if tc.expectedMetadata == nil {
+				return
+			}
+			if !spec.UpdateEnvFromMetadata || !reflect.DeepEqual(spec.ContainerRefMetadata, tc.expectedMetadata) {
+				t.Fatalf("expected container metadata %v, got %v", tc.expectedMetadata, spec.ContainerRefMetadata)
+			}
+		})
+	}
+}
+
+func TestPatchUpdateSpecToPodWithProjectedValues(t *testing.T) {
+	defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.InPlaceUpdateEnvFromMetadata, true)()
+
+	spec := &UpdateSpec{
+		ContainerRefMetadata: map[string]metav1.ObjectMeta{
+			"c1": {Annotations: map[string]string{"c1.inplace-update.apps.kruise.io/env.E": "2"}},
+		},
+		UpdateEnvFromMetadata: true,
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{appspub.InPlaceUpdateRecreateContainerKey: "true"}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "c1", Env: []v1.EnvVar{{Name: "E", Value: "1"}}}}},
+	}
+
+	// the pod created before values projected
+	if _, err := defaultPatchUpdateSpecToPod(pod.DeepCopy(), spec, &appspub.InPlaceUpdateState{}); err == nil {
+		t.Fatalf("expected error for pod without projected values")
+	}
+
+	ProjectContainerValues(pod)
+	got, err := defaultPatchUpdateSpecToPod(pod, spec, &appspub.InPlaceUpdateState{})
+	if err != nil {
+		t.Fatalf("failed to patch update spec: %v", err)
+	}
+	if v := got.Annotations["c1.inplace-update.apps.kruise.io/env.E"]; v != "2" {
+		t.Fatalf("expected projected env updated to 2, got %s", v)
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -159,6 +160,63 @@ func TestCalculateInPlaceUpdateSpec(t *testing.T) {
 	}
 }
 
+func TestCheckInPlaceUpdate(t *testing.T) {
+	newRevision := func(name, containers string) *apps.ControllerRevision {
+		return &apps.ControllerRevision{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       runtime.RawExtension{Raw: []byte(`{"spec":{"template":{"$patch":"replace","spec":{"containers":` + containers + `}}}}`)},
+		}
+	}
+
+	cases := []struct {
+		name            string
+		oldRevision     *apps.ControllerRevision
+		newRevision     *apps.ControllerRevision
+		expectedCan     bool
+		expectedReasons []NotInPlaceUpdateReason
+	}{
+		{
+			name:        "image changed",
+			oldRevision: newRevision("old-revision", `[{"name":"c1","image":"foo1"}]`),
+			newRevision: newRevision("new-revision", `[{"name":"c1","image":"foo2"}]`),
+			expectedCan: true,
+		},
+		{
+			name:            "old revision not found",
+			newRevision:     newRevision("new-revision", `[{"name":"c1","image":"foo2"}]`),
+			expectedReasons: []NotInPlaceUpdateReason{{Message: "old or new revision not found"}},
+		},
+		{
+			name:        "image and command changed",
+			oldRevision: newRevision("old-revision", `[{"name":"c1","image":"foo1","command":["a"]}]`),
+			newRevision: newRevision("new-revision", `[{"name":"c1","image":"foo2","command":["b"]}]`),
+			expectedReasons: []NotInPlaceUpdateReason{
+				{Path: "/spec/containers/0/command/0", Message: "field is immutable in pod spec and requires recreating the pod"},
+			},
+		},
+		{
+			name:        "container added",
+			oldRevision: newRevision("old-revision", `[{"name":"c1","image":"foo1"}]`),
+			newRevision: newRevision("new-revision", `[{"name":"c1","image":"foo1"},{"name":"c2","image":"bar"}]`),
+			expectedReasons: []NotInPlaceUpdateReason{
+				{Path: "/spec/containers/1", Message: "add operation is not supported"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			can, reasons := CheckInPlaceUpdate(tc.oldRevision, tc.newRevision, nil)
+			if can != tc.expectedCan {
+				t.Fatalf("expected can update in place %v, got %v", tc.expectedCan, can)
+			}
+			if !reflect.DeepEqual(reasons, tc.expectedReasons) {
+				t.Fatalf("expected reasons %v, got %v", tc.expectedReasons, reasons)
+			}
+		})
+	}
+}
+
 func TestCheckInPlaceUpdateCompleted(t *testing.T) {
 	succeedPods := []*v1.Pod{
 		{
@@ -648,3 +706,181 @@ func TestRefresh(t *testing.T) {
 		}
 	}
 }
+
+func TestRefreshResizeTimeout(t *testing.T) {
+	defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.InPlaceWorkloadVerticalScaling, true)()
+
+	now := time.Now()
+	newPod := func(updateTime time.Time, resize v1.PodResizeStatus) *v1.Pod {
+		state := appspub.InPlaceUpdateState{Revision: "new-revision", UpdateTimestamp: metav1.NewTime(updateTime), UpdateResources: true}
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "pod-0",
+				Labels:      map[string]string{apps.StatefulSetRevisionLabel: "new-revision"},
+				Annotations: map[string]string{appspub.InPlaceUpdateStateKey: util.DumpJSON(state)},
+			},
+			Spec: v1.PodSpec{
+				ReadinessGates: []v1.PodReadinessGate{{ConditionType: appspub.InPlaceUpdateReady}},
+				Containers: []v1.Container{{
+					Name: "c1",
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")},
+					},
+				}},
+			},
+			Status: v1.PodStatus{
+				Resize: resize,
+				ContainerStatuses: []v1.ContainerStatus{{
+					Name: "c1",
+					Resources: &v1.ResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")},
+					},
+				}},
+			},
+		}
+	}
+
+	cases := []struct {
+		name              string
+		pod               *v1.Pod
+		timeoutSeconds    int32
+		expectedFailed    bool
+		expectedDelay     time.Duration
+		expectedCondition bool
+	}{
+		{
+			name:           "no timeout",
+			pod:            newPod(now.Add(-time.Hour), v1.PodResizeStatusInfeasible),
+			timeoutSeconds: 0,
+		},
+		{
+			name:           "infeasible but not timed out",
+			pod:            newPod(now.Add(-10*time.Second), v1.PodResizeStatusInfeasible),
+			timeoutSeconds: 60,
+			expectedDelay:  50 * time.Second,
+		},
+		{
+			name:           "still in progress",
+			pod:            newPod(now.Add(-time.Hour), v1.PodResizeStatusInProgress),
+			timeoutSeconds: 60,
+		},
+		{
+			name:              "deferred and timed out",
+			pod:               newPod(now.Add(-time.Hour), v1.PodResizeStatusDeferred),
+			timeoutSeconds:    60,
+			expectedFailed:    true,
+			expectedCondition: true,
+		},
+	}
+
+	Clock = testingclock.NewFakeClock(now)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := fake.NewClientBuilder().WithObjects(tc.pod).Build()
+			ctrl := New(cli, revisionadapter.NewDefaultImpl())
+			res := ctrl.Refresh(tc.pod, &UpdateOptions{ResizeTimeoutSeconds: tc.timeoutSeconds})
+			if res.RefreshErr != nil {
+				t.Fatalf("failed to refresh: %v", res.RefreshErr)
+			}
+			if res.ResizeFailed != tc.expectedFailed {
+				t.Fatalf("expected resize failed %v, got %v", tc.expectedFailed, res.ResizeFailed)
+			}
+			if res.DelayDuration != tc.expectedDelay {
+				t.Fatalf("expected delay %v, got %v", tc.expectedDelay, res.DelayDuration)
+			}
+
+			got := &v1.Pod{}
+			if err := cli.Get(context.TODO(), types.NamespacedName{Name: tc.pod.Name}, got); err != nil {
+				t.Fatalf("failed to get pod: %v", err)
+			}
+			condition := GetCondition(got)
+			if !tc.expectedCondition {
+				if condition != nil {
+					t.Fatalf("expected no condition, got %v", util.DumpJSON(condition))
+				}
+				return
+			}
+			if condition == nil || condition.Status != v1.ConditionFalse || condition.Reason != appspub.InPlaceUpdateResizeFailedReason {
+				t.Fatalf("expected ResizeFailed condition, got %v", util.DumpJSON(condition))
+			}
+
+			// the pod already failed should not be updated again
+			res = ctrl.Refresh(got, &UpdateOptions{ResizeTimeoutSeconds: tc.timeoutSeconds})
+			if res.RefreshErr != nil || !res.ResizeFailed {
+				t.Fatalf("expected resize failed again, got %v", res)
+			}
+			again := &v1.Pod{}
+			if err := cli.Get(context.TODO(), types.NamespacedName{Name: tc.pod.Name}, again); err != nil {
+				t.Fatalf("failed to get pod: %v", err)
+			}
+			if again.ResourceVersion != got.ResourceVersion {
+				t.Fatalf("expected pod not updated, got resourceVersion %s -> %s", got.ResourceVersion, again.ResourceVersion)
+			}
+		})
+	}
+}
+
+func TestRefreshPostUpdateHook(t *testing.T) {
+	now := time.Now()
+	// the timestamp in annotation is serialized in seconds
+	state := appspub.InPlaceUpdateState{Revision: "new-revision", UpdateTimestamp: metav1.NewTime(now.Add(-time.Minute).Truncate(time.Second))}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod-0",
+			Labels:      map[string]string{apps.StatefulSetRevisionLabel: "new-revision"},
+			Annotations: map[string]string{appspub.InPlaceUpdateStateKey: util.DumpJSON(state)},
+		},
+		Spec: v1.PodSpec{ReadinessGates: []v1.PodReadinessGate{{ConditionType: appspub.InPlaceUpdateReady}}},
+		Status: v1.PodStatus{Conditions: []v1.PodCondition{
+			{Type: appspub.InPlaceUpdateReady, Status: v1.ConditionFalse, Reason: "StartInPlaceUpdate"},
+		}},
+	}
+
+	cases := []struct {
+		name              string
+		hooked            bool
+		expectedCondition v1.ConditionStatus
+	}{
+		{
+			name:              "kept not ready by hook",
+			hooked:            true,
+			expectedCondition: v1.ConditionFalse,
+		},
+		{
+			name:              "hook released",
+			expectedCondition: v1.ConditionTrue,
+		},
+	}
+
+	Clock = testingclock.NewFakeClock(now)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := fake.NewClientBuilder().WithObjects(pod.DeepCopy()).Build()
+			ctrl := New(cli, revisionadapter.NewDefaultImpl())
+			var gotUpdateTime time.Time
+			opts := &UpdateOptions{CheckPostUpdateHook: func(_ *v1.Pod, updateTime time.Time) (bool, time.Duration) {
+				gotUpdateTime = updateTime
+				return tc.hooked, 5 * time.Second
+			}}
+
+			res := ctrl.Refresh(pod, opts)
+			if res.RefreshErr != nil {
+				t.Fatalf("failed to refresh: %v", res.RefreshErr)
+			}
+			if res.PostUpdateHooked != tc.hooked {
+				t.Fatalf("expected post update hooked %v, got %v", tc.hooked, res.PostUpdateHooked)
+			}
+			if !gotUpdateTime.Equal(state.UpdateTimestamp.Time) {
+				t.Fatalf("expected update time %v, got %v", state.UpdateTimestamp.Time, gotUpdateTime)
+			}
+
+			got := &v1.Pod{}
+			if err := cli.Get(context.TODO(), types.NamespacedName{Name: pod.Name}, got); err != nil {
+				t.Fatalf("failed to get pod: %v", err)
+			}
+			if condition := GetCondition(got); condition == nil || condition.Status != tc.expectedCondition {
+				t.Fatalf("expected condition %v, got %v", tc.expectedCondition, util.DumpJSON(condition))
+			}
+		})
+	}
+}
@@ -177,6 +177,9 @@ func (v *NativeVerticalUpdate) IsUpdateCompleted(pod *v1.Pod) (bool, error) {
 	if len(pod.Status.ContainerStatuses) != len(containers) {
 		return false, fmt.Errorf("some container status is not reported")
 	}
+	if pod.Status.Resize == v1.PodResizeStatusInfeasible || pod.Status.Resize == v1.PodResizeStatusDeferred {
+		return false, fmt.Errorf("resize is %s", pod.Status.Resize)
+	}
 	for _, cs := range pod.Status.ContainerStatuses {
 		if !v.isContainerUpdateCompleted(containers[cs.Name], &cs) {
 			return false, fmt.Errorf("container %s resources not changed", cs.Name)
@@ -0,0 +1,192 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+)
+
+const (
+	defaultHTTPHookTimeout = 3 * time.Second
+	defaultHTTPHookPeriod  = 10 * time.Second
+
+	// maxHTTPHookResults is the max number of pods whose last handler result is cached.
+	maxHTTPHookResults = 4096
+)
+
+// HookHandler calls the handler of a lifecycle hook for Pod in the given lifecycle state,
+// and returns true if Pod can go on to the next state.
+// The handler is called asynchronously, so it returns the last result of handler,
+// or false if there is no result yet.
+type HookHandler interface {
+	Handle(hook *appspub.LifecycleHook, pod *v1.Pod, state appspub.LifecycleStateType) (bool, error)
+}
+
+// HookRequest is the body of the POST request sent to the HTTP endpoint of a lifecycle hook.
+type HookRequest struct {
+	Namespace string                     `json:"namespace"`
+	Name      string                     `json:"name"`
+	UID       types.UID                  `json:"uid"`
+	State     appspub.LifecycleStateType `json:"state"`
+}
+
+type httpHookHandler struct {
+	client *http.Client
+	// results caches the last result of handler for each pod and state,
+	// and the endpoint is called again only if the result is older than periodSeconds,
+	// no matter how often pods are reconciled.
+	results *cache.LRUExpireCache
+
+	// calling is the keys of pod and state whose endpoint is being called,
+	// so that reconciling never waits for the endpoints.
+	callingLock sync.Mutex
+	calling     sets.String
+}
+
+type httpHookResult struct {
+	succeeded bool
+	err       error
+	time      time.Time
+}
+
+// NewHTTPHookHandler returns a HookHandler that calls the HTTPHandler of hooks with the given client.
+func NewHTTPHookHandler(client *http.Client) HookHandler {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &httpHookHandler{client: client, results: cache.NewLRUExpireCache(maxHTTPHookResults), calling: sets.NewString()}
+}
+
+func (h *httpHookHandler) Handle(hook *appspub.LifecycleHook, pod *v1.Pod, state appspub.LifecycleStateType) (bool, error) {
+	if hook == nil || hook.HTTPHandler == nil {
+		return false, nil
+	}
+	key := fmt.Sprintf("%s/%s", pod.UID, state)
+	period := getHTTPHookPeriod(hook.HTTPHandler)
+	var last *httpHookResult
+	if v, ok := h.results.Get(key); ok {
+		last = v.(*httpHookResult)
+		if time.Since(last.time) < period {
+			return last.succeeded, last.err
+		}
+	}
+
+	h.callAsync(key, hook.HTTPHandler, pod.DeepCopy(), state, period)
+	if last != nil {
+		return last.succeeded, last.err
+	}
+	return false, nil
+}
+
+func (h *httpHookHandler) callAsync(key string, handler *appspub.LifecycleHTTPHandler, pod *v1.Pod, state appspub.LifecycleStateType, period time.Duration) {
+	h.callingLock.Lock()
+	defer h.callingLock.Unlock()
+	if h.calling.Has(key) {
+		return
+	}
+	h.calling.Insert(key)
+
+	go func() {
+		defer func() {
+			h.callingLock.Lock()
+			defer h.callingLock.Unlock()
+			h.calling.Delete(key)
+		}()
+		succeeded, err := h.call(handler, pod, state)
+		// keep the result for another period, so that it can be returned while the endpoint is called again
+		h.results.Add(key, &httpHookResult{succeeded: succeeded, err: err, time: time.Now()}, 2*period)
+	}()
+}
+
+func (h *httpHookHandler) call(handler *appspub.LifecycleHTTPHandler, pod *v1.Pod, state appspub.LifecycleStateType) (bool, error) {
+	endpoint, err := getHTTPHookURL(handler, pod)
+	if err != nil {
+		return false, err
+	}
+	body, err := json.Marshal(HookRequest{Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID, State: state})
+	if err != nil {
+		return false, err
+	}
+
+	timeout := defaultHTTPHookTimeout
+	if handler.TimeoutSeconds > 0 {
+		timeout = time.Duration(handler.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices, nil
+}
+
+func getHTTPHookURL(handler *appspub.LifecycleHTTPHandler, pod *v1.Pod) (string, error) {
+	if handler.URL != "" {
+		return handler.URL, nil
+	}
+	if pod.Status.PodIP == "" {
+		return "", fmt.Errorf("pod %s/%s has no IP yet", pod.Namespace, pod.Name)
+	}
+	scheme := strings.ToLower(string(handler.Scheme))
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := handler.Path
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	u := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(handler.Port))),
+		Path:   path,
+	}
+	return u.String(), nil
+}
+
+func getHTTPHookPeriod(handler *appspub.LifecycleHTTPHandler) time.Duration {
+	if handler.PeriodSeconds > 0 {
+		return time.Duration(handler.PeriodSeconds) * time.Second
+	}
+	return defaultHTTPHookPeriod
+}
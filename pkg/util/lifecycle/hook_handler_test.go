@@ -0,0 +1,193 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/features"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+)
+
+func TestHTTPHookHandler(t *testing.T) {
+	requests := make(chan HookRequest, 10)
+	status := int32(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST request, got %s", r.Method)
+		}
+		var got HookRequest
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		requests <- got
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	handler := NewHTTPHookHandler(server.Client()).(*httpHookHandler)
+	hook := &appspub.LifecycleHook{HTTPHandler: &appspub.LifecycleHTTPHandler{URL: server.URL + "/hooks"}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", UID: "uid-foo"}}
+
+	expectRequest := func(expected HookRequest) {
+		select {
+		case got := <-requests:
+			if got != expected {
+				t.Fatalf("expected request %+v, got %+v", expected, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected request %+v, got none", expected)
+		}
+	}
+	waitForResult := func(state appspub.LifecycleStateType, since time.Time) {
+		key := fmt.Sprintf("%s/%s", pod.UID, state)
+		err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			v, ok := handler.results.Get(key)
+			return ok && v.(*httpHookResult).time.After(since), nil
+		})
+		if err != nil {
+			t.Fatalf("failed to wait for result of %s: %v", key, err)
+		}
+	}
+
+	// the endpoint is called asynchronously, and there is no result until it responds
+	start := time.Now()
+	if ok, err := handler.Handle(hook, pod, appspub.LifecycleStatePreparingDelete); err != nil || ok {
+		t.Fatalf("expected no result yet, got %v, %v", ok, err)
+	}
+	expected := HookRequest{Namespace: "default", Name: "foo", UID: "uid-foo", State: appspub.LifecycleStatePreparingDelete}
+	expectRequest(expected)
+	waitForResult(appspub.LifecycleStatePreparingDelete, start)
+	if ok, err := handler.Handle(hook, pod, appspub.LifecycleStatePreparingDelete); err != nil || !ok {
+		t.Fatalf("expected success, got %v, %v", ok, err)
+	}
+
+	// the result is reused within the period of handler
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	if ok, err := handler.Handle(hook, pod, appspub.LifecycleStatePreparingDelete); err != nil || !ok {
+		t.Fatalf("expected cached success, got %v, %v", ok, err)
+	}
+	select {
+	case got := <-requests:
+		t.Fatalf("expected no request within period, got %+v", got)
+	default:
+	}
+
+	// the last result is returned while the endpoint is called again after the period
+	v, _ := handler.results.Get(fmt.Sprintf("%s/%s", pod.UID, appspub.LifecycleStatePreparingDelete))
+	v.(*httpHookResult).time = time.Now().Add(-defaultHTTPHookPeriod)
+	start = time.Now()
+	if ok, err := handler.Handle(hook, pod, appspub.LifecycleStatePreparingDelete); err != nil || !ok {
+		t.Fatalf("expected last success, got %v, %v", ok, err)
+	}
+	expectRequest(expected)
+	waitForResult(appspub.LifecycleStatePreparingDelete, start)
+	if ok, err := handler.Handle(hook, pod, appspub.LifecycleStatePreparingDelete); err != nil || ok {
+		t.Fatalf("expected not succeeded, got %v, %v", ok, err)
+	}
+
+	start = time.Now()
+	if ok, err := handler.Handle(hook, pod, appspub.LifecycleStatePreparingNormal); err != nil || ok {
+		t.Fatalf("expected no result yet, got %v, %v", ok, err)
+	}
+	expected.State = appspub.LifecycleStatePreparingNormal
+	expectRequest(expected)
+	waitForResult(appspub.LifecycleStatePreparingNormal, start)
+	if ok, err := handler.Handle(hook, pod, appspub.LifecycleStatePreparingNormal); err != nil || ok {
+		t.Fatalf("expected not succeeded, got %v, %v", ok, err)
+	}
+}
+
+func TestGetHTTPHookURL(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo"}}
+	handler := &appspub.LifecycleHTTPHandler{Port: 8080, Path: "hooks"}
+	if _, err := getHTTPHookURL(handler, pod); err == nil {
+		t.Fatalf("expected error for pod without IP")
+	}
+
+	pod.Status.PodIP = "10.0.0.1"
+	if u, _ := getHTTPHookURL(handler, pod); u != "http://10.0.0.1:8080/hooks" {
+		t.Fatalf("unexpected url %s", u)
+	}
+	handler.Scheme = v1.URISchemeHTTPS
+	if u, _ := getHTTPHookURL(handler, pod); u != "https://10.0.0.1:8080/hooks" {
+		t.Fatalf("unexpected url %s", u)
+	}
+	pod.Status.PodIP = "fd00::1"
+	if u, _ := getHTTPHookURL(handler, pod); u != "https://[fd00::1]:8080/hooks" {
+		t.Fatalf("unexpected url %s", u)
+	}
+}
+
+type fakeHookHandler struct {
+	succeeded bool
+	calls     int
+}
+
+func (h *fakeHookHandler) Handle(_ *appspub.LifecycleHook, _ *v1.Pod, _ appspub.LifecycleStateType) (bool, error) {
+	h.calls++
+	return h.succeeded, nil
+}
+
+func TestCheckHookWithHTTPHandler(t *testing.T) {
+	fake := &fakeHookHandler{}
+	control := &realControl{hookHandler: fake}
+
+	hook := &appspub.LifecycleHook{HTTPHandler: &appspub.LifecycleHTTPHandler{URL: "http://foo", PeriodSeconds: 5}}
+	pod := newHookedPod("p", appspub.LifecycleStatePreparingNormal, time.Second)
+
+	// http handler takes no effect without the feature gate
+	if IsPodHooked(hook, pod) {
+		t.Fatalf("expected pod not to wait for the http handler when feature gate disabled")
+	}
+	if proceed, _ := control.CheckHook(record.NewFakeRecorder(10), hook, pod, appspub.LifecycleStatePreparingNormal); proceed || fake.calls != 0 {
+		t.Fatalf("expected not to call handler, got proceed %v and %d calls", proceed, fake.calls)
+	}
+
+	defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.LifecycleHookHTTPHandler, true)()
+	if !IsPodHooked(hook, pod) || IsPodAllHooked(hook, pod) {
+		t.Fatalf("expected pod to wait for the http handler")
+	}
+
+	proceed, left := control.CheckHook(record.NewFakeRecorder(10), hook, pod, appspub.LifecycleStatePreparingNormal)
+	if proceed || left != 5*time.Second {
+		t.Fatalf("expected to check again after period, got %v, %v", proceed, left)
+	}
+
+	fake.succeeded = true
+	if proceed, _ = control.CheckHook(record.NewFakeRecorder(10), hook, pod, appspub.LifecycleStatePreparingNormal); !proceed {
+		t.Fatalf("expected to proceed after http handler succeeded")
+	}
+
+	// not call the handler for pod in another state
+	fake.calls = 0
+	if proceed, _ = control.CheckHook(record.NewFakeRecorder(10), hook, pod, appspub.LifecycleStatePreparingDelete); proceed || fake.calls != 0 {
+		t.Fatalf("expected not to call handler, got proceed %v and %d calls", proceed, fake.calls)
+	}
+}
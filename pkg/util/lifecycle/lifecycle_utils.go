@@ -22,12 +22,17 @@ import (
 	"time"
 
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/features"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
 	"github.com/openkruise/kruise/pkg/util/podadapter"
 	"github.com/openkruise/kruise/pkg/util/podreadiness"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/cache"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -37,17 +42,30 @@ const (
 	// these keys for MarkPodNotReady Policy of pod lifecycle
 	preparingDeleteHookKey = "preDeleteHook"
 	preparingUpdateHookKey = "preUpdateHook"
+
+	// HookTimeoutReason is the reason of events recorded when a lifecycle hook has timed out.
+	HookTimeoutReason = "LifecycleHookTimeout"
+
+	// hookTimeoutWarningTTL is how long the warning of a hook timed out with Block failurePolicy is not recorded again.
+	hookTimeoutWarningTTL = 24 * time.Hour
 )
 
 // Interface for managing pods lifecycle.
 type Interface interface {
 	UpdatePodLifecycle(pod *v1.Pod, state appspub.LifecycleStateType, markPodNotReady bool) (bool, *v1.Pod, error)
 	UpdatePodLifecycleWithHandler(pod *v1.Pod, state appspub.LifecycleStateType, inPlaceUpdateHandler *appspub.LifecycleHook) (bool, *v1.Pod, error)
+	CheckHook(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, state appspub.LifecycleStateType) (bool, time.Duration)
+	CheckHookSince(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, since time.Time, hookDesc string) (bool, time.Duration)
+	CheckPostInPlaceUpdateHook(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, updateTime time.Time) (bool, time.Duration)
+	RemovePodHook(pod *v1.Pod, hook *appspub.LifecycleHook) (bool, *v1.Pod, error)
 }
 
 type realControl struct {
 	adp                 podadapter.Adapter
 	podReadinessControl podreadiness.Interface
+	hookHandler         HookHandler
+	// timeoutWarnings records the pods whose hooks have timed out with Block failurePolicy and been warned
+	timeoutWarnings *cache.LRUExpireCache
 }
 
 func New(c client.Client) Interface {
@@ -55,6 +73,8 @@ func New(c client.Client) Interface {
 	return &realControl{
 		adp:                 adp,
 		podReadinessControl: podreadiness.NewForAdapter(adp),
+		hookHandler:         NewHTTPHookHandler(nil),
+		timeoutWarnings:     cache.NewLRUExpireCache(4096),
 	}
 }
 
@@ -63,6 +83,8 @@ func NewForTypedClient(c clientset.Interface) Interface {
 	return &realControl{
 		adp:                 adp,
 		podReadinessControl: podreadiness.NewForAdapter(adp),
+		hookHandler:         NewHTTPHookHandler(nil),
+		timeoutWarnings:     cache.NewLRUExpireCache(4096),
 	}
 }
 
@@ -71,6 +93,8 @@ func NewForInformer(informer coreinformers.PodInformer) Interface {
 	return &realControl{
 		adp:                 adp,
 		podReadinessControl: podreadiness.NewForAdapter(adp),
+		hookHandler:         NewHTTPHookHandler(nil),
+		timeoutWarnings:     cache.NewLRUExpireCache(4096),
 	}
 }
 
@@ -207,6 +231,10 @@ func IsPodHooked(hook *appspub.LifecycleHook, pod *v1.Pod) bool {
 	if hook == nil || pod == nil {
 		return false
 	}
+	// pod always waits for the response of the http handler
+	if hasHTTPHandler(hook) {
+		return true
+	}
 	for _, f := range hook.FinalizersHandler {
 		if controllerutil.ContainsFinalizer(pod, f) {
 			return true
@@ -224,6 +252,10 @@ func IsPodAllHooked(hook *appspub.LifecycleHook, pod *v1.Pod) bool {
 	if hook == nil || pod == nil {
 		return false
 	}
+	// pod always waits for the response of the http handler
+	if hasHTTPHandler(hook) {
+		return false
+	}
 	for _, f := range hook.FinalizersHandler {
 		if !controllerutil.ContainsFinalizer(pod, f) {
 			return false
@@ -237,6 +269,230 @@ func IsPodAllHooked(hook *appspub.LifecycleHook, pod *v1.Pod) bool {
 	return true
 }
 
+// GetPodLifecycleTimestamp returns the time when the pod entered its current lifecycle state.
+func GetPodLifecycleTimestamp(pod *v1.Pod) (time.Time, bool) {
+	value, ok := pod.Annotations[appspub.LifecycleTimestampKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return timestamp, true
+}
+
+// IsHookTimedOut returns whether the pod has waited for the hook in the given lifecycle state longer than
+// the timeoutSeconds of hook. If not timed out yet, it also returns the duration left before the timeout.
+func IsHookTimedOut(hook *appspub.LifecycleHook, pod *v1.Pod, state appspub.LifecycleStateType) (bool, time.Duration) {
+	if hook == nil || pod == nil || hook.TimeoutSeconds <= 0 || GetPodLifecycleState(pod) != state {
+		return false, 0
+	}
+	timestamp, ok := GetPodLifecycleTimestamp(pod)
+	if !ok {
+		return false, 0
+	}
+	return isHookTimedOutSince(hook, timestamp)
+}
+
+func isHookTimedOutSince(hook *appspub.LifecycleHook, since time.Time) (bool, time.Duration) {
+	timeout := time.Duration(hook.TimeoutSeconds) * time.Second
+	if elapsed := time.Since(since); elapsed < timeout {
+		return false, timeout - elapsed
+	}
+	return true, 0
+}
+
+// checkHookTimeout returns whether the pod that is waiting for the hook in the given lifecycle state can proceed
+// without it, and the duration after which the hook will time out. A warning event is recorded for the pod
+// once the hook has timed out, no matter its failurePolicy is Proceed or Block.
+func (c *realControl) checkHookTimeout(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, state appspub.LifecycleStateType) (bool, time.Duration) {
+	timedOut, left := IsHookTimedOut(hook, pod, state)
+	if !timedOut {
+		return false, left
+	}
+	timestamp, _ := GetPodLifecycleTimestamp(pod)
+	return c.proceedOnHookTimeout(recorder, hook, pod, fmt.Sprintf("lifecycle hook in %s state", state), timestamp), 0
+}
+
+// proceedOnHookTimeout returns whether the pod can proceed without the hook that has timed out.
+// With Block failurePolicy, the warning event is only recorded once for the hook waited for since the given time.
+func (c *realControl) proceedOnHookTimeout(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, hookDesc string, since time.Time) bool {
+	if hook.FailurePolicy == appspub.LifecycleHookFailurePolicyProceed {
+		recorder.Eventf(pod, v1.EventTypeWarning, HookTimeoutReason,
+			"%s timed out after %d seconds, proceed without it", hookDesc, hook.TimeoutSeconds)
+		return true
+	}
+	if c.timeoutWarnings != nil {
+		key := fmt.Sprintf("%s/%s/%d", pod.UID, hookDesc, since.Unix())
+		if _, warned := c.timeoutWarnings.Get(key); warned {
+			return false
+		}
+		c.timeoutWarnings.Add(key, struct{}{}, hookTimeoutWarningTTL)
+	}
+	recorder.Eventf(pod, v1.EventTypeWarning, HookTimeoutReason,
+		"%s timed out after %d seconds, keep waiting for it", hookDesc, hook.TimeoutSeconds)
+	return false
+}
+
+// CheckHook returns whether the pod that is waiting for the hook in the given lifecycle state can proceed,
+// either because the HTTPHandler of hook has responded with success or because the hook has timed out
+// with Proceed failurePolicy. Otherwise, it returns the duration after which the hook should be checked again.
+func (c *realControl) CheckHook(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, state appspub.LifecycleStateType) (bool, time.Duration) {
+	if !hasHTTPHandler(hook) || GetPodLifecycleState(pod) != state {
+		return c.checkHookTimeout(recorder, hook, pod, state)
+	}
+
+	if c.callHTTPHandler(hook, pod, state) {
+		return true, 0
+	}
+
+	proceed, left := c.checkHookTimeout(recorder, hook, pod, state)
+	if period := getHTTPHookPeriod(hook.HTTPHandler); !proceed && (left == 0 || left > period) {
+		left = period
+	}
+	return proceed, left
+}
+
+// CheckPostInPlaceUpdateHook returns whether the pod, whose containers have been updated in-place since updateTime,
+// is still held by the PostInPlaceUpdate hook, and the duration after which the hook should be checked again.
+// The timeout of hook is counted from the time when the in-place update began.
+func (c *realControl) CheckPostInPlaceUpdateHook(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, updateTime time.Time) (bool, time.Duration) {
+	return c.CheckHookSince(recorder, hook, pod, updateTime, "PostInPlaceUpdate lifecycle hook")
+}
+
+// CheckHookSince returns whether the pod is still held by the hook which has been waited for since the given time,
+// and the duration after which the hook should be checked again. It works for the hooks that are not bound to
+// a lifecycle state, and hookDesc is used in the events recorded on timeout.
+func (c *realControl) CheckHookSince(recorder record.EventRecorder, hook *appspub.LifecycleHook, pod *v1.Pod, since time.Time, hookDesc string) (bool, time.Duration) {
+	if !IsPodHooked(hook, pod) {
+		return false, 0
+	}
+	httpHandled := hasHTTPHandler(hook)
+	if httpHandled && c.callHTTPHandler(hook, pod, GetPodLifecycleState(pod)) {
+		return false, 0
+	}
+
+	var left time.Duration
+	if hook.TimeoutSeconds > 0 {
+		var timedOut bool
+		if timedOut, left = isHookTimedOutSince(hook, since); timedOut && c.proceedOnHookTimeout(recorder, hook, pod, hookDesc, since) {
+			return false, 0
+		}
+	}
+	if httpHandled {
+		if period := getHTTPHookPeriod(hook.HTTPHandler); left == 0 || left > period {
+			left = period
+		}
+	}
+	return true, left
+}
+
+// RemovePodHook removes the labels and finalizers in handlers of the hook from pod, so that the pod proceeding
+// without the hook, such as deleted after its PreDelete hook has timed out with Proceed failurePolicy,
+// will not be held by them.
+func (c *realControl) RemovePodHook(pod *v1.Pod, hook *appspub.LifecycleHook) (bool, *v1.Pod, error) {
+	if hook == nil || pod == nil {
+		return false, pod, nil
+	}
+	clone := pod.DeepCopy()
+	var removed bool
+	for k, v := range hook.LabelsHandler {
+		if clone.Labels[k] == v {
+			delete(clone.Labels, k)
+			removed = true
+		}
+	}
+	for _, f := range hook.FinalizersHandler {
+		if controllerutil.RemoveFinalizer(clone, f) {
+			removed = true
+		}
+	}
+	if !removed {
+		return false, pod, nil
+	}
+	gotPod, err := c.adp.UpdatePod(clone)
+	return true, gotPod, err
+}
+
+// SetPodHook returns a function that adds the labels and finalizers in handlers of the hook to pod.
+func SetPodHook(hook *appspub.LifecycleHook) func(*v1.Pod) {
+	return func(pod *v1.Pod) {
+		if hook == nil {
+			return
+		}
+		if pod.Labels == nil && len(hook.LabelsHandler) > 0 {
+			pod.Labels = make(map[string]string, len(hook.LabelsHandler))
+		}
+		for k, v := range hook.LabelsHandler {
+			pod.Labels[k] = v
+		}
+		for _, f := range hook.FinalizersHandler {
+			controllerutil.AddFinalizer(pod, f)
+		}
+	}
+}
+
+// hasHTTPHandler returns whether the hook has an HTTPHandler that takes effect.
+func hasHTTPHandler(hook *appspub.LifecycleHook) bool {
+	return hook != nil && hook.HTTPHandler != nil && utilfeature.DefaultFeatureGate.Enabled(features.LifecycleHookHTTPHandler)
+}
+
+func (c *realControl) callHTTPHandler(hook *appspub.LifecycleHook, pod *v1.Pod, state appspub.LifecycleStateType) bool {
+	ok, err := c.hookHandler.Handle(hook, pod, state)
+	if err != nil {
+		klog.ErrorS(err, "Failed to call http handler of lifecycle hook", "pod", klog.KObj(pod), "state", state)
+		return false
+	}
+	if ok {
+		klog.V(3).InfoS("Http handler of lifecycle hook has succeeded", "pod", klog.KObj(pod), "state", state)
+	}
+	return ok
+}
+
+// CalculateLifecycleStates returns the status of pods in the lifecycle states that wait for hooks.
+func CalculateLifecycleStates(lifecycle *appspub.Lifecycle, pods []*v1.Pod) []appspub.LifecycleStateStatus {
+	if lifecycle == nil {
+		return nil
+	}
+	hookedStates := []struct {
+		state appspub.LifecycleStateType
+		hook  *appspub.LifecycleHook
+	}{
+		{state: appspub.LifecycleStatePreparingNormal, hook: lifecycle.PreNormal},
+		{state: appspub.LifecycleStatePreparingUpdate, hook: lifecycle.InPlaceUpdate},
+		{state: appspub.LifecycleStateUpdated, hook: lifecycle.InPlaceUpdate},
+		{state: appspub.LifecycleStatePreparingDelete, hook: lifecycle.PreDelete},
+	}
+
+	var states []appspub.LifecycleStateStatus
+	for _, hs := range hookedStates {
+		if hs.hook == nil {
+			continue
+		}
+		status := appspub.LifecycleStateStatus{State: hs.state}
+		for _, pod := range pods {
+			if GetPodLifecycleState(pod) != hs.state {
+				continue
+			}
+			status.Replicas++
+			if timedOut, _ := IsHookTimedOut(hs.hook, pod, hs.state); timedOut {
+				status.TimedOutReplicas++
+			}
+			if timestamp, ok := GetPodLifecycleTimestamp(pod); ok {
+				if status.OldestTimestamp == nil || timestamp.Before(status.OldestTimestamp.Time) {
+					// keep it in local time as decoded from the API, so that the status can be compared
+					status.OldestTimestamp = &metav1.Time{Time: timestamp.Local()}
+				}
+			}
+		}
+		if status.Replicas > 0 {
+			states = append(states, status)
+		}
+	}
+	return states
+}
+
 func getReadinessMessage(key string) podreadiness.Message {
 	return podreadiness.Message{UserAgent: "Lifecycle", Key: key}
 }
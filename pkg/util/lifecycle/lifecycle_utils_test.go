@@ -0,0 +1,201 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lifecycle
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+)
+
+func newHookedPod(name string, state appspub.LifecycleStateType, since time.Duration) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{appspub.LifecycleStateKey: string(state), "hook": "true"},
+			Annotations: map[string]string{appspub.LifecycleTimestampKey: time.Now().Add(-since).Format(time.RFC3339)},
+		},
+	}
+}
+
+func TestCheckHookTimeout(t *testing.T) {
+	cases := []struct {
+		name            string
+		hook            *appspub.LifecycleHook
+		pod             *v1.Pod
+		expectedProceed bool
+		expectedWaiting bool
+		expectedEvent   bool
+	}{
+		{
+			name: "no timeout",
+			hook: &appspub.LifecycleHook{LabelsHandler: map[string]string{"hook": "true"}},
+			pod:  newHookedPod("p", appspub.LifecycleStatePreparingDelete, time.Hour),
+		},
+		{
+			name:            "not timed out yet",
+			hook:            &appspub.LifecycleHook{LabelsHandler: map[string]string{"hook": "true"}, TimeoutSeconds: 60, FailurePolicy: appspub.LifecycleHookFailurePolicyProceed},
+			pod:             newHookedPod("p", appspub.LifecycleStatePreparingDelete, 10*time.Second),
+			expectedWaiting: true,
+		},
+		{
+			name: "timed out in another state",
+			hook: &appspub.LifecycleHook{LabelsHandler: map[string]string{"hook": "true"}, TimeoutSeconds: 60, FailurePolicy: appspub.LifecycleHookFailurePolicyProceed},
+			pod:  newHookedPod("p", appspub.LifecycleStateNormal, time.Hour),
+		},
+		{
+			name:            "timed out and proceed",
+			hook:            &appspub.LifecycleHook{LabelsHandler: map[string]string{"hook": "true"}, TimeoutSeconds: 60, FailurePolicy: appspub.LifecycleHookFailurePolicyProceed},
+			pod:             newHookedPod("p", appspub.LifecycleStatePreparingDelete, time.Hour),
+			expectedProceed: true,
+			expectedEvent:   true,
+		},
+		{
+			name:          "timed out and block",
+			hook:          &appspub.LifecycleHook{LabelsHandler: map[string]string{"hook": "true"}, TimeoutSeconds: 60, FailurePolicy: appspub.LifecycleHookFailurePolicyBlock},
+			pod:           newHookedPod("p", appspub.LifecycleStatePreparingDelete, time.Hour),
+			expectedEvent: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			control := &realControl{timeoutWarnings: cache.NewLRUExpireCache(10)}
+			proceed, left := control.checkHookTimeout(recorder, tc.hook, tc.pod, appspub.LifecycleStatePreparingDelete)
+			if proceed != tc.expectedProceed {
+				t.Fatalf("expected proceed %v, got %v", tc.expectedProceed, proceed)
+			}
+			if (left > 0) != tc.expectedWaiting {
+				t.Fatalf("expected waiting %v, got duration left %v", tc.expectedWaiting, left)
+			}
+			if gotEvent := len(recorder.Events) > 0; gotEvent != tc.expectedEvent {
+				t.Fatalf("expected event %v, got %v", tc.expectedEvent, gotEvent)
+			}
+
+			// the warning of a blocked hook is only recorded once
+			if tc.expectedEvent && !tc.expectedProceed {
+				<-recorder.Events
+				control.checkHookTimeout(recorder, tc.hook, tc.pod, appspub.LifecycleStatePreparingDelete)
+				if len(recorder.Events) > 0 {
+					t.Fatalf("expected no more event, got %v", <-recorder.Events)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateLifecycleStates(t *testing.T) {
+	lifecycle := &appspub.Lifecycle{
+		PreDelete: &appspub.LifecycleHook{LabelsHandler: map[string]string{"hook": "true"}, TimeoutSeconds: 60},
+	}
+	pods := []*v1.Pod{
+		newHookedPod("p0", appspub.LifecycleStatePreparingDelete, time.Hour),
+		newHookedPod("p1", appspub.LifecycleStatePreparingDelete, 10*time.Second),
+		newHookedPod("p2", appspub.LifecycleStateNormal, time.Hour),
+		newHookedPod("p3", appspub.LifecycleStatePreparingNormal, time.Hour),
+	}
+
+	states := CalculateLifecycleStates(lifecycle, pods)
+	if len(states) != 1 {
+		t.Fatalf("expected only PreparingDelete state, got %v", states)
+	}
+	state := states[0]
+	if state.State != appspub.LifecycleStatePreparingDelete || state.Replicas != 2 || state.TimedOutReplicas != 1 {
+		t.Fatalf("unexpected lifecycle state status: %+v", state)
+	}
+	expectedOldest, _ := GetPodLifecycleTimestamp(pods[0])
+	if state.OldestTimestamp == nil || !state.OldestTimestamp.Time.Equal(expectedOldest) {
+		t.Fatalf("expected oldest timestamp %v, got %v", expectedOldest, state.OldestTimestamp)
+	}
+
+	if states := CalculateLifecycleStates(nil, pods); states != nil {
+		t.Fatalf("expected no states without lifecycle, got %v", states)
+	}
+}
+
+func TestCheckPostInPlaceUpdateHook(t *testing.T) {
+	hook := &appspub.LifecycleHook{
+		LabelsHandler:     map[string]string{"post-hook": "true"},
+		FinalizersHandler: []string{"example.com/post-hook"},
+		TimeoutSeconds:    60,
+		FailurePolicy:     appspub.LifecycleHookFailurePolicyProceed,
+	}
+
+	control := &realControl{}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p"}}
+	if hooked, _ := control.CheckPostInPlaceUpdateHook(record.NewFakeRecorder(10), hook, pod, time.Now()); hooked {
+		t.Fatalf("expected pod without hook labels not hooked")
+	}
+
+	SetPodHook(hook)(pod)
+	if pod.Labels["post-hook"] != "true" || len(pod.Finalizers) != 1 {
+		t.Fatalf("expected hook labels and finalizers set, got %v, %v", pod.Labels, pod.Finalizers)
+	}
+
+	hooked, left := control.CheckPostInPlaceUpdateHook(record.NewFakeRecorder(10), hook, pod, time.Now().Add(-10*time.Second))
+	if !hooked || left <= 0 || left > 50*time.Second {
+		t.Fatalf("expected hooked and waiting for timeout, got %v, %v", hooked, left)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	if hooked, _ = control.CheckPostInPlaceUpdateHook(recorder, hook, pod, time.Now().Add(-time.Hour)); hooked {
+		t.Fatalf("expected to proceed after timeout")
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected timeout event, got %d", len(recorder.Events))
+	}
+}
+
+func TestRemovePodHook(t *testing.T) {
+	hook := &appspub.LifecycleHook{
+		LabelsHandler:     map[string]string{"hook": "true"},
+		FinalizersHandler: []string{"example.com/hook"},
+		TimeoutSeconds:    60,
+		FailurePolicy:     appspub.LifecycleHookFailurePolicyProceed,
+	}
+	pod := newHookedPod("p", appspub.LifecycleStatePreparingDelete, time.Hour)
+	pod.Namespace = "default"
+	pod.Finalizers = []string{"example.com/hook", "example.com/other"}
+	control := New(fake.NewClientBuilder().WithObjects(pod.DeepCopy()).Build())
+
+	updated, gotPod, err := control.RemovePodHook(pod, hook)
+	if err != nil || !updated {
+		t.Fatalf("expected pod hook removed, got %v, %v", updated, err)
+	}
+	if _, ok := gotPod.Labels["hook"]; ok || gotPod.Labels[appspub.LifecycleStateKey] != string(appspub.LifecycleStatePreparingDelete) {
+		t.Fatalf("expected only the hook label removed, got %v", gotPod.Labels)
+	}
+	if !reflect.DeepEqual(gotPod.Finalizers, []string{"example.com/other"}) {
+		t.Fatalf("expected only the hook finalizer removed, got %v", gotPod.Finalizers)
+	}
+	if IsPodHooked(hook, gotPod) {
+		t.Fatalf("expected pod not hooked any more")
+	}
+
+	if updated, _, err = control.RemovePodHook(gotPod, hook); err != nil || updated {
+		t.Fatalf("expected nothing to remove, got %v, %v", updated, err)
+	}
+}
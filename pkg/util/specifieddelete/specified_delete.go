@@ -32,6 +32,11 @@ func IsSpecifiedDelete(obj metav1.Object) bool {
 	return ok
 }
 
+func IsSpecifiedReplace(obj metav1.Object) bool {
+	_, ok := obj.GetLabels()[appsv1alpha1.SpecifiedReplaceKey]
+	return ok
+}
+
 func PatchPodSpecifiedDelete(c client.Client, pod *v1.Pod, value string) (bool, error) {
 	if _, ok := pod.Labels[appsv1alpha1.SpecifiedDeleteKey]; ok {
 		return false, nil
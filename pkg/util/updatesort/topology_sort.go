@@ -0,0 +1,173 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package updatesort
+
+import (
+	"sort"
+	"time"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/util/updatestage"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type topologySort struct {
+	strategy *appspub.UpdateTopologyStrategy
+}
+
+func NewTopologySorter(s *appspub.UpdateTopologyStrategy) Sorter {
+	return &topologySort{strategy: s}
+}
+
+// Sort helps sort the indexes of pods by the domain order of UpdateTopologyStrategy.
+// Pods in the same domain keep their original order.
+func (ts *topologySort) Sort(pods []*v1.Pod, indexes []int) []int {
+	if ts.strategy == nil || len(indexes) <= 1 {
+		return indexes
+	}
+
+	ranks := make(map[string]int)
+	for i, domain := range GetTopologyDomains(ts.strategy, pods) {
+		ranks[domain] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return ranks[getTopologyDomain(ts.strategy, pods[indexes[i]])] < ranks[getTopologyDomain(ts.strategy, pods[indexes[j]])]
+	})
+	return indexes
+}
+
+// GetTopologyDomains returns the domains of the pods in the order to update.
+// Domains in domainOrder come first, then the other domains in alphabetical order,
+// and the empty domain of pods without the topologyKey label comes last.
+func GetTopologyDomains(strategy *appspub.UpdateTopologyStrategy, pods []*v1.Pod) []string {
+	existing := sets.NewString()
+	for _, pod := range pods {
+		if pod == nil {
+			continue
+		}
+		existing.Insert(getTopologyDomain(strategy, pod))
+	}
+
+	domains := make([]string, 0, existing.Len())
+	for _, domain := range strategy.DomainOrder {
+		if domain != "" && existing.Has(domain) {
+			domains = append(domains, domain)
+			existing.Delete(domain)
+		}
+	}
+	hasEmpty := existing.Has("")
+	existing.Delete("")
+	domains = append(domains, existing.List()...)
+	if hasEmpty {
+		domains = append(domains, "")
+	}
+	return domains
+}
+
+// CalculateUpdateTopology calculates the status of the topology-batched update for the pods,
+// and returns the duration left to soak the current domain if it is soaking.
+func CalculateUpdateTopology(
+	strategy *appspub.UpdateTopologyStrategy,
+	oldStatus *appspub.UpdateTopologyStatus,
+	revision string,
+	pods []*v1.Pod,
+	isUpdated, isAvailable func(*v1.Pod) bool,
+	now metav1.Time,
+) (*appspub.UpdateTopologyStatus, time.Duration) {
+	if strategy == nil {
+		return nil, 0
+	}
+	if oldStatus != nil && oldStatus.Revision != revision {
+		oldStatus = nil
+	}
+
+	completed := sets.NewString()
+	if oldStatus != nil {
+		completed.Insert(oldStatus.CompletedDomains...)
+	}
+	domainPods := make(map[string][]*v1.Pod)
+	for _, pod := range pods {
+		if pod == nil {
+			continue
+		}
+		domain := getTopologyDomain(strategy, pod)
+		domainPods[domain] = append(domainPods[domain], pod)
+	}
+
+	domains := GetTopologyDomains(strategy, pods)
+	stageStatus, _ := updatestage.Calculate(len(domains), func(i int) (*updatestage.Stage, error) {
+		domain := domains[i]
+		stage := &updatestage.Stage{
+			AllUpdated:   true,
+			AllAvailable: true,
+			Passed:       completed.Has(domain),
+			SoakSeconds:  strategy.SoakSeconds,
+		}
+		for _, pod := range domainPods[domain] {
+			if !isUpdated(pod) {
+				stage.AllUpdated = false
+			} else if !isAvailable(pod) {
+				stage.AllAvailable = false
+			}
+		}
+		if oldStatus != nil && oldStatus.Phase == appspub.UpdateTopologyPhaseSoaking && oldStatus.CurrentDomain == domain {
+			stage.SoakStartTime = oldStatus.SoakStartTime
+		}
+		return stage, nil
+	}, now)
+
+	status := &appspub.UpdateTopologyStatus{
+		Revision:      revision,
+		Phase:         appspub.UpdateTopologyPhase(stageStatus.Phase),
+		SoakStartTime: stageStatus.SoakStartTime,
+	}
+	if stageStatus.Index > 0 {
+		status.CompletedDomains = domains[:stageStatus.Index]
+	}
+	if stageStatus.Index < len(domains) {
+		status.CurrentDomain = domains[stageStatus.Index]
+	}
+	return status, stageStatus.SoakLeft
+}
+
+// FilterUpdateTopology returns the indexes of pods that are allowed to update in the current domain.
+// No pod is allowed to update when the current domain is soaking.
+func FilterUpdateTopology(strategy *appspub.UpdateTopologyStrategy, status *appspub.UpdateTopologyStatus, pods []*v1.Pod, indexes []int) []int {
+	if strategy == nil || status == nil {
+		return indexes
+	}
+
+	switch status.Phase {
+	case appspub.UpdateTopologyPhaseSoaking:
+		return nil
+	case appspub.UpdateTopologyPhaseUpdating:
+		var filtered []int
+		for _, idx := range indexes {
+			if pods[idx] != nil && getTopologyDomain(strategy, pods[idx]) == status.CurrentDomain {
+				filtered = append(filtered, idx)
+			}
+		}
+		return filtered
+	}
+	return indexes
+}
+
+func getTopologyDomain(strategy *appspub.UpdateTopologyStrategy, pod *v1.Pod) string {
+	return pod.Labels[strategy.TopologyKey]
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package updatesort
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testTopologyKey = "example.com/zone"
+
+func newTopologyPod(zone string, updated, available bool) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{}, Annotations: map[string]string{}}}
+	if zone != "" {
+		pod.Labels[testTopologyKey] = zone
+	}
+	if updated {
+		pod.Annotations["updated"] = "true"
+	}
+	if available {
+		pod.Annotations["available"] = "true"
+	}
+	return pod
+}
+
+func TestTopologySort(t *testing.T) {
+	strategy := &appspub.UpdateTopologyStrategy{TopologyKey: testTopologyKey, DomainOrder: []string{"zone-c"}}
+	pods := []*v1.Pod{
+		newTopologyPod("zone-b", false, false),
+		newTopologyPod("", false, false),
+		newTopologyPod("zone-a", false, false),
+		nil,
+		newTopologyPod("zone-c", false, false),
+		newTopologyPod("zone-b", false, false),
+		newTopologyPod("zone-a", false, false),
+	}
+
+	if domains := GetTopologyDomains(strategy, pods); !reflect.DeepEqual(domains, []string{"zone-c", "zone-a", "zone-b", ""}) {
+		t.Fatalf("unexpected domains: %v", domains)
+	}
+	indexes := NewTopologySorter(strategy).Sort(pods, []int{0, 1, 2, 4, 5, 6})
+	if expected := []int{4, 2, 6, 0, 5, 1}; !reflect.DeepEqual(indexes, expected) {
+		t.Fatalf("expected %v, got %v", expected, indexes)
+	}
+}
+
+func TestCalculateUpdateTopology(t *testing.T) {
+	now := metav1.Now()
+	soakStart := metav1.NewTime(now.Add(-30 * time.Second))
+	strategy := &appspub.UpdateTopologyStrategy{TopologyKey: testTopologyKey, DomainOrder: []string{"zone-b"}, SoakSeconds: 60}
+	isUpdated := func(pod *v1.Pod) bool { return pod.Annotations["updated"] == "true" }
+	isAvailable := func(pod *v1.Pod) bool { return pod.Annotations["available"] == "true" }
+
+	cases := []struct {
+		name            string
+		pods            []*v1.Pod
+		oldStatus       *appspub.UpdateTopologyStatus
+		expectedStatus  *appspub.UpdateTopologyStatus
+		expectedSoak    time.Duration
+		expectedIndexes []int
+	}{
+		{
+			name:            "update the first domain in order",
+			pods:            []*v1.Pod{newTopologyPod("zone-a", false, true), newTopologyPod("zone-b", true, true), newTopologyPod("zone-b", false, true)},
+			expectedStatus:  &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseUpdating},
+			expectedIndexes: []int{2},
+		},
+		{
+			name:           "wait for updated pods to be available",
+			pods:           []*v1.Pod{newTopologyPod("zone-a", false, true), newTopologyPod("zone-b", true, true), newTopologyPod("zone-b", true, false)},
+			expectedStatus: &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseUpdating},
+		},
+		{
+			name:           "begin to soak",
+			pods:           []*v1.Pod{newTopologyPod("zone-a", false, true), newTopologyPod("zone-b", true, true), newTopologyPod("zone-b", true, true)},
+			expectedStatus: &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseSoaking, SoakStartTime: &now},
+			expectedSoak:   60 * time.Second,
+		},
+		{
+			name:           "keep soaking",
+			pods:           []*v1.Pod{newTopologyPod("zone-a", false, true), newTopologyPod("zone-b", true, true), newTopologyPod("zone-b", true, true)},
+			oldStatus:      &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseSoaking, SoakStartTime: &soakStart},
+			expectedStatus: &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseSoaking, SoakStartTime: &soakStart},
+			expectedSoak:   30 * time.Second,
+		},
+		{
+			name:           "soak again for a new revision",
+			pods:           []*v1.Pod{newTopologyPod("zone-a", false, true), newTopologyPod("zone-b", true, true), newTopologyPod("zone-b", true, true)},
+			oldStatus:      &appspub.UpdateTopologyStatus{Revision: "old", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseSoaking, SoakStartTime: &soakStart},
+			expectedStatus: &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseSoaking, SoakStartTime: &now},
+			expectedSoak:   60 * time.Second,
+		},
+		{
+			name:            "move to the next domain after soaking",
+			pods:            []*v1.Pod{newTopologyPod("zone-a", false, true), newTopologyPod("zone-b", true, true), newTopologyPod("", false, true)},
+			oldStatus:       &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-b", Phase: appspub.UpdateTopologyPhaseSoaking, SoakStartTime: &metav1.Time{Time: now.Add(-time.Minute)}},
+			expectedStatus:  &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "zone-a", Phase: appspub.UpdateTopologyPhaseUpdating, CompletedDomains: []string{"zone-b"}},
+			expectedIndexes: []int{0},
+		},
+		{
+			name:            "completed domain is not blocked by unavailable pods",
+			pods:            []*v1.Pod{newTopologyPod("zone-a", true, true), newTopologyPod("zone-b", true, false), newTopologyPod("", false, true)},
+			oldStatus:       &appspub.UpdateTopologyStatus{Revision: "rev", CompletedDomains: []string{"zone-b", "zone-a"}},
+			expectedStatus:  &appspub.UpdateTopologyStatus{Revision: "rev", CurrentDomain: "", Phase: appspub.UpdateTopologyPhaseUpdating, CompletedDomains: []string{"zone-b", "zone-a"}},
+			expectedIndexes: []int{2},
+		},
+		{
+			name:           "all domains completed",
+			pods:           []*v1.Pod{newTopologyPod("zone-a", true, true), newTopologyPod("zone-b", true, true)},
+			oldStatus:      &appspub.UpdateTopologyStatus{Revision: "rev", CompletedDomains: []string{"zone-b", "zone-a"}},
+			expectedStatus: &appspub.UpdateTopologyStatus{Revision: "rev", Phase: appspub.UpdateTopologyPhaseCompleted, CompletedDomains: []string{"zone-b", "zone-a"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, soak := CalculateUpdateTopology(strategy, tc.oldStatus, "rev", tc.pods, isUpdated, isAvailable, now)
+			if !reflect.DeepEqual(status, tc.expectedStatus) {
+				t.Fatalf("expected status %+v, got %+v", tc.expectedStatus, status)
+			}
+			if soak != tc.expectedSoak {
+				t.Fatalf("expected soak duration %v, got %v", tc.expectedSoak, soak)
+			}
+
+			var indexes []int
+			for i := range tc.pods {
+				if !isUpdated(tc.pods[i]) {
+					indexes = append(indexes, i)
+				}
+			}
+			if filtered := FilterUpdateTopology(strategy, status, tc.pods, indexes); len(filtered) != len(tc.expectedIndexes) ||
+				(len(filtered) > 0 && !reflect.DeepEqual(filtered, tc.expectedIndexes)) {
+				t.Fatalf("expected indexes %v, got %v", tc.expectedIndexes, filtered)
+			}
+		})
+	}
+}
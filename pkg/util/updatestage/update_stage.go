@@ -0,0 +1,97 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package updatestage
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Phase is the phase of the current stage of a staged rolling update.
+type Phase string
+
+const (
+	// PhaseUpdating means pods of the current stage are updating.
+	PhaseUpdating Phase = "Updating"
+	// PhaseSoaking means pods of the current stage have been updated and available,
+	// and it is waiting for soakSeconds before the next stage.
+	PhaseSoaking Phase = "Soaking"
+	// PhasePaused means the current stage has been completed and soaked, and it is waiting to be resumed.
+	PhasePaused Phase = "Paused"
+	// PhaseCompleted means all stages have been completed.
+	PhaseCompleted Phase = "Completed"
+)
+
+// Stage is the state of a stage in a staged rolling update.
+type Stage struct {
+	// AllUpdated is whether all pods of the stage have been updated.
+	AllUpdated bool
+	// AllAvailable is whether all updated pods of the stage are available.
+	AllAvailable bool
+	// Passed is whether the stage has been passed in the last status,
+	// so it is not soaked or paused again as long as all pods of it are still updated.
+	Passed bool
+	// SoakSeconds is how long to wait after all pods of the stage have been updated and available.
+	SoakSeconds int32
+	// SoakStartTime is the time when the stage began to soak in the last status, if it was soaking.
+	SoakStartTime *metav1.Time
+	// Paused is whether to pause the rolling update after the stage has been soaked.
+	Paused bool
+}
+
+// Status is the status of a staged rolling update.
+type Status struct {
+	// Index is the index of the current stage, which equals to the number of stages if all have been completed.
+	Index int
+	// Phase is the phase of the current stage.
+	Phase Phase
+	// SoakStartTime is the time when the current stage began to soak.
+	SoakStartTime *metav1.Time
+	// SoakLeft is the duration left to soak the current stage.
+	SoakLeft time.Duration
+}
+
+// Calculate goes through the stages in order, and returns the status of the first stage that has not been completed.
+// The state of each stage is got lazily, for the later stages are not needed once an earlier one is not completed.
+func Calculate(count int, getStage func(index int) (*Stage, error), now metav1.Time) (*Status, error) {
+	for i := 0; i < count; i++ {
+		stage, err := getStage(i)
+		if err != nil {
+			return nil, err
+		}
+		// the stage has been passed, no need to wait for it again
+		if stage.AllUpdated && stage.Passed {
+			continue
+		}
+		if !stage.AllUpdated || !stage.AllAvailable {
+			return &Status{Index: i, Phase: PhaseUpdating}, nil
+		}
+
+		soakStartTime := now
+		if stage.SoakStartTime != nil {
+			soakStartTime = *stage.SoakStartTime
+		}
+		if left := time.Duration(stage.SoakSeconds)*time.Second - now.Sub(soakStartTime.Time); left > 0 {
+			return &Status{Index: i, Phase: PhaseSoaking, SoakStartTime: &soakStartTime, SoakLeft: left}, nil
+		}
+		if stage.Paused {
+			return &Status{Index: i, Phase: PhasePaused, SoakStartTime: &soakStartTime}, nil
+		}
+	}
+	return &Status{Index: count, Phase: PhaseCompleted}, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package updatestage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCalculate(t *testing.T) {
+	now := metav1.Now()
+	soakStartTime := metav1.NewTime(now.Add(-time.Minute))
+
+	cases := []struct {
+		name           string
+		stages         []Stage
+		expectStatus   *Status
+		expectGetCount int
+	}{
+		{
+			name:           "update the first stage",
+			stages:         []Stage{{AllUpdated: false}, {AllUpdated: false}},
+			expectStatus:   &Status{Index: 0, Phase: PhaseUpdating},
+			expectGetCount: 1,
+		},
+		{
+			name:           "wait for updated pods to be available",
+			stages:         []Stage{{AllUpdated: true, AllAvailable: false}, {}},
+			expectStatus:   &Status{Index: 0, Phase: PhaseUpdating},
+			expectGetCount: 1,
+		},
+		{
+			name:           "start to soak",
+			stages:         []Stage{{AllUpdated: true, AllAvailable: true, SoakSeconds: 60}, {}},
+			expectStatus:   &Status{Index: 0, Phase: PhaseSoaking, SoakStartTime: &now, SoakLeft: time.Minute},
+			expectGetCount: 1,
+		},
+		{
+			name:           "pause after soaked",
+			stages:         []Stage{{AllUpdated: true, AllAvailable: true, SoakSeconds: 60, SoakStartTime: &soakStartTime, Paused: true}, {}},
+			expectStatus:   &Status{Index: 0, Phase: PhasePaused, SoakStartTime: &soakStartTime},
+			expectGetCount: 1,
+		},
+		{
+			name:           "skip the passed stage",
+			stages:         []Stage{{AllUpdated: true, Passed: true, SoakSeconds: 60, Paused: true}, {AllUpdated: false}},
+			expectStatus:   &Status{Index: 1, Phase: PhaseUpdating},
+			expectGetCount: 2,
+		},
+		{
+			name:           "all stages completed",
+			stages:         []Stage{{AllUpdated: true, AllAvailable: true}, {AllUpdated: true, AllAvailable: true}},
+			expectStatus:   &Status{Index: 2, Phase: PhaseCompleted},
+			expectGetCount: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var getCount int
+			status, err := Calculate(len(tc.stages), func(i int) (*Stage, error) {
+				getCount++
+				return &tc.stages[i], nil
+			}, now)
+			if err != nil {
+				t.Fatalf("failed to calculate: %v", err)
+			}
+			if !reflect.DeepEqual(status, tc.expectStatus) {
+				t.Fatalf("expected status %+v, got %+v", tc.expectStatus, status)
+			}
+			if getCount != tc.expectGetCount {
+				t.Fatalf("expected to get %d stages, got %d", tc.expectGetCount, getCount)
+			}
+		})
+	}
+}
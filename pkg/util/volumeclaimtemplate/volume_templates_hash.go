@@ -31,6 +31,9 @@ import (
 const (
 	// HashAnnotation represents the specs of volumeclaimtemplates hash
 	HashAnnotation = "kruise.io/cloneset-volumeclaimtemplate-hash"
+	// HashWithoutSizeAnnotation represents the specs of volumeclaimtemplates hash excluding the storage requests,
+	// which tells whether volumeclaimtemplates only differ in size
+	HashWithoutSizeAnnotation = "kruise.io/cloneset-volumeclaimtemplate-hash-without-size"
 )
 
 var (
@@ -69,6 +72,15 @@ func (h *volumeClaimTemplatesHasher) getExpectHash(templates []v1.PersistentVolu
 	return hashVolumeClaimTemplate(pvcs)
 }
 
+func getHashWithoutSize(templates []v1.PersistentVolumeClaim) uint64 {
+	sizeIgnored := make([]v1.PersistentVolumeClaim, len(templates))
+	for i := range templates {
+		templates[i].DeepCopyInto(&sizeIgnored[i])
+		delete(sizeIgnored[i].Spec.Resources.Requests, v1.ResourceStorage)
+	}
+	return defaultHasher.getExpectHash(sizeIgnored)
+}
+
 func hashVolumeClaimTemplate(templates []v1.PersistentVolumeClaim) uint64 {
 	hash := fnv.New32a()
 	envsJSON, _ := json.Marshal(templates)
@@ -92,8 +104,10 @@ func PatchVCTemplateHash(revision *apps.ControllerRevision, vcTemplates []v1.Per
 		// get hash of vct
 		vcTemplateHash := defaultHasher.getExpectHash(vcTemplates)
 		revision.Annotations[HashAnnotation] = strconv.FormatUint(vcTemplateHash, 10)
+		revision.Annotations[HashWithoutSizeAnnotation] = strconv.FormatUint(getHashWithoutSize(vcTemplates), 10)
 	} else {
 		revision.Annotations[HashAnnotation] = ""
+		revision.Annotations[HashWithoutSizeAnnotation] = ""
 	}
 }
 
@@ -117,7 +131,18 @@ func CanVCTemplateInplaceUpdate(oldRevision, newRevision *apps.ControllerRevisio
 	if !oldExist || !newExist {
 		return true
 	}
-	return newVCTemplatesHash == oldVCTemplatesHash
+	if newVCTemplatesHash == oldVCTemplatesHash {
+		return true
+	}
+	return false
+}
+
+// IsVCTemplateOnlySizeChanged returns whether the volumeclaimtemplates of the two revisions only differ in
+// storage requests. Whether the PVCs can be expanded online instead of recreated is up to the caller.
+func IsVCTemplateOnlySizeChanged(oldRevision, newRevision *apps.ControllerRevision) bool {
+	newHashWithoutSize, newExist := newRevision.Annotations[HashWithoutSizeAnnotation]
+	oldHashWithoutSize, oldExist := oldRevision.Annotations[HashWithoutSizeAnnotation]
+	return newExist && oldExist && newHashWithoutSize == oldHashWithoutSize
 }
 
 func init() {
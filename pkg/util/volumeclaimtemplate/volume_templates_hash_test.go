@@ -406,3 +406,44 @@ func TestCanVCTemplateInplaceUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestIsVCTemplateOnlySizeChanged(t *testing.T) {
+	newTemplates := func(size string) []v1.PersistentVolumeClaim {
+		return []v1.PersistentVolumeClaim{{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+			Spec: v1.PersistentVolumeClaimSpec{
+				AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Resources: v1.VolumeResourceRequirements{
+					Requests: map[v1.ResourceName]resource.Quantity{v1.ResourceStorage: resource.MustParse(size)},
+				},
+			},
+		}}
+	}
+	oldRevision := &apps.ControllerRevision{}
+	PatchVCTemplateHash(oldRevision, newTemplates("1Gi"))
+	sizeChanged := &apps.ControllerRevision{}
+	PatchVCTemplateHash(sizeChanged, newTemplates("2Gi"))
+	modeChanged := &apps.ControllerRevision{}
+	templates := newTemplates("2Gi")
+	templates[0].Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteMany}
+	PatchVCTemplateHash(modeChanged, templates)
+
+	noHash := &apps.ControllerRevision{}
+
+	tests := []struct {
+		name        string
+		newRevision *apps.ControllerRevision
+		want        bool
+	}{
+		{name: "size changed", newRevision: sizeChanged, want: true},
+		{name: "access modes changed", newRevision: modeChanged, want: false},
+		{name: "hash missing", newRevision: noHash, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsVCTemplateOnlySizeChanged(oldRevision, tt.newRevision); got != tt.want {
+				t.Errorf("IsVCTemplateOnlySizeChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,7 +17,9 @@ import (
 	"k8s.io/kubernetes/pkg/apis/core"
 	apivalidation "k8s.io/kubernetes/pkg/apis/core/validation"
 
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
 	clonesetcore "github.com/openkruise/kruise/pkg/controller/cloneset/core"
 	"github.com/openkruise/kruise/pkg/util"
 	webhookutil "github.com/openkruise/kruise/pkg/webhook/util"
@@ -85,6 +87,31 @@ func (h *CloneSetCreateUpdateHandler) validateCloneSetSpec(spec, oldSpec *appsv1
 		allErrs = append(allErrs, field.Forbidden(fldPath.Child("template", "spec", "activeDeadlineSeconds"), "activeDeadlineSeconds in cloneset is not Supported"))
 	}
 
+	if spec.ProgressDeadlineSeconds != nil {
+		allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(*spec.ProgressDeadlineSeconds), fldPath.Child("progressDeadlineSeconds"))...)
+		if *spec.ProgressDeadlineSeconds <= spec.MinReadySeconds {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("progressDeadlineSeconds"), *spec.ProgressDeadlineSeconds, "must be greater than minReadySeconds"))
+		}
+	}
+	switch spec.RollbackPolicy {
+	case "", appsv1alpha1.CloneSetRollbackPolicyNone:
+	case appsv1alpha1.CloneSetRollbackPolicyAuto:
+		if spec.ProgressDeadlineSeconds == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("progressDeadlineSeconds"), "progressDeadlineSeconds is required for Auto rollbackPolicy"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("rollbackPolicy"), spec.RollbackPolicy,
+			[]string{string(appsv1alpha1.CloneSetRollbackPolicyNone), string(appsv1alpha1.CloneSetRollbackPolicyAuto)}))
+	}
+
+	if policy := spec.PersistentVolumeClaimRetentionPolicy; policy != nil {
+		allErrs = append(allErrs, validatePersistentVolumeClaimRetentionPolicyType(policy.WhenDeleted, fldPath.Child("persistentVolumeClaimRetentionPolicy", "whenDeleted"))...)
+		allErrs = append(allErrs, validatePersistentVolumeClaimRetentionPolicyType(policy.WhenScaled, fldPath.Child("persistentVolumeClaimRetentionPolicy", "whenScaled"))...)
+		if policy.WhenScaled == appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType && spec.ScaleStrategy.DisablePVCReuse {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("persistentVolumeClaimRetentionPolicy", "whenScaled"), "Retain can not work with scaleStrategy.disablePVCReuse"))
+		}
+	}
+
 	var oldScaleStrategy *appsv1alpha1.CloneSetScaleStrategy
 	if oldSpec != nil {
 		oldScaleStrategy = &oldSpec.ScaleStrategy
@@ -92,32 +119,67 @@ func (h *CloneSetCreateUpdateHandler) validateCloneSetSpec(spec, oldSpec *appsv1
 
 	allErrs = append(allErrs, h.validateScaleStrategy(&spec.ScaleStrategy, oldScaleStrategy, metadata, fldPath.Child("scaleStrategy"))...)
 	allErrs = append(allErrs, h.validateUpdateStrategy(&spec.UpdateStrategy, int(*spec.Replicas), fldPath.Child("updateStrategy"))...)
+	if len(spec.ScaleStrategy.PodsToReplace) > 0 {
+		maxSurge, _ := intstrutil.GetValueFromIntOrPercent(intstrutil.ValueOrDefault(spec.UpdateStrategy.MaxSurge, intstrutil.FromInt(0)), int(*spec.Replicas), true)
+		if maxSurge <= 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("scaleStrategy").Child("podsToReplace"), "requires updateStrategy.maxSurge to be positive"))
+		}
+	}
+	var oldLifecycle *appspub.Lifecycle
+	if oldSpec != nil {
+		oldLifecycle = oldSpec.Lifecycle
+	}
+	allErrs = append(allErrs, webhookutil.ValidateLifecycle(spec.Lifecycle, oldLifecycle, fldPath.Child("lifecycle"))...)
 
 	return allErrs
 }
 
+func validatePersistentVolumeClaimRetentionPolicyType(policy appsv1beta1.PersistentVolumeClaimRetentionPolicyType, fldPath *field.Path) field.ErrorList {
+	switch policy {
+	case "", appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType, appsv1beta1.DeletePersistentVolumeClaimRetentionPolicyType:
+		return nil
+	default:
+		return field.ErrorList{field.NotSupported(fldPath, policy, []string{string(appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType), string(appsv1beta1.DeletePersistentVolumeClaimRetentionPolicyType)})}
+	}
+}
+
 func (h *CloneSetCreateUpdateHandler) validateScaleStrategy(strategy, oldStrategy *appsv1alpha1.CloneSetScaleStrategy, metadata *metav1.ObjectMeta, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
-	if list := util.CheckDuplicate(strategy.PodsToDelete); len(list) > 0 {
-		allErrs = append(allErrs, field.Invalid(fldPath.Child("podsToDelete"), strategy.PodsToDelete, fmt.Sprintf("duplicated items %v", list)))
-		return allErrs
+	var oldPodsToDelete, oldPodsToReplace []string
+	if oldStrategy != nil {
+		oldPodsToDelete, oldPodsToReplace = oldStrategy.PodsToDelete, oldStrategy.PodsToReplace
 	}
+	allErrs = append(allErrs, h.validateSpecifiedPods(strategy.PodsToDelete, oldPodsToDelete, metadata, fldPath.Child("podsToDelete"))...)
+	allErrs = append(allErrs, h.validateSpecifiedPods(strategy.PodsToReplace, oldPodsToReplace, metadata, fldPath.Child("podsToReplace"))...)
+
+	if list := sets.NewString(strategy.PodsToDelete...).Intersection(sets.NewString(strategy.PodsToReplace...)); list.Len() > 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("podsToReplace"), strategy.PodsToReplace, fmt.Sprintf("items %v already in podsToDelete", list.List())))
+	}
+	allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(strategy.StandbyReplicas), fldPath.Child("standbyReplicas"))...)
+
+	return allErrs
+}
 
-	podsToDeleteSet := sets.NewString(strategy.PodsToDelete...)
+func (h *CloneSetCreateUpdateHandler) validateSpecifiedPods(podNames, oldPodNames []string, metadata *metav1.ObjectMeta, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
 
-	if oldStrategy != nil && len(oldStrategy.PodsToDelete) > 0 {
-		podsToDeleteSet.Delete(oldStrategy.PodsToDelete...)
+	if list := util.CheckDuplicate(podNames); len(list) > 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath, podNames, fmt.Sprintf("duplicated items %v", list)))
+		return allErrs
 	}
 
-	for _, podName := range podsToDeleteSet.List() {
+	podNameSet := sets.NewString(podNames...)
+	podNameSet.Delete(oldPodNames...)
+
+	for _, podName := range podNameSet.List() {
 		pod := &v1.Pod{}
 		if err := h.Client.Get(context.TODO(), types.NamespacedName{Namespace: metadata.Namespace, Name: podName}, pod); err != nil {
-			allErrs = append(allErrs, field.Invalid(fldPath.Child("podsToDelete"), podName, fmt.Sprintf("find pod %s failed: %v", podName, err)))
+			allErrs = append(allErrs, field.Invalid(fldPath, podName, fmt.Sprintf("find pod %s failed: %v", podName, err)))
 		} else if pod.DeletionTimestamp != nil {
-			allErrs = append(allErrs, field.Invalid(fldPath.Child("podsToDelete"), podName, fmt.Sprintf("find pod %s already terminating", podName)))
+			allErrs = append(allErrs, field.Invalid(fldPath, podName, fmt.Sprintf("find pod %s already terminating", podName)))
 		} else if owner := metav1.GetControllerOf(pod); owner == nil || owner.UID != metadata.UID {
-			allErrs = append(allErrs, field.Invalid(fldPath.Child("podsToDelete"), podName, fmt.Sprintf("find pod %s owner is not this CloneSet", podName)))
+			allErrs = append(allErrs, field.Invalid(fldPath, podName, fmt.Sprintf("find pod %s owner is not this CloneSet", podName)))
 		}
 	}
 
@@ -154,6 +216,10 @@ func (h *CloneSetCreateUpdateHandler) validateUpdateStrategy(strategy *appsv1alp
 		allErrs = append(allErrs, field.Required(fldPath.Child("scatterStrategy"), err.Error()))
 	}
 
+	if err := strategy.TopologyStrategy.FieldsValidation(); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("topologyStrategy"), strategy.TopologyStrategy, err.Error()))
+	}
+
 	var maxUnavailable int
 	if strategy.MaxUnavailable != nil {
 		maxUnavailable, err = intstrutil.GetValueFromIntOrPercent(strategy.MaxUnavailable, replicas, true)
@@ -181,6 +247,57 @@ func (h *CloneSetCreateUpdateHandler) validateUpdateStrategy(strategy *appsv1alp
 			"maxUnavailable and maxSurge should not both be less than 1"))
 	}
 
+	allErrs = append(allErrs, validateUpdateSteps(strategy.Steps, replicas, partition, fldPath.Child("steps"))...)
+
+	return allErrs
+}
+
+func validateUpdateSteps(steps []appsv1alpha1.CloneSetUpdateStep, replicas, strategyPartition int, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	lastPartition := replicas
+	for i := range steps {
+		step := &steps[i]
+		stepPath := fldPath.Index(i)
+
+		var partition int
+		var err error
+		switch {
+		case step.Partition != nil && step.Replicas != nil:
+			allErrs = append(allErrs, field.Invalid(stepPath, step, "partition and replicas can not be set at the same time"))
+			continue
+		case step.Partition != nil:
+			if partition, err = util.GetScaledValueFromIntOrPercent(step.Partition, replicas, true); err != nil {
+				allErrs = append(allErrs, field.Invalid(stepPath.Child("partition"), step.Partition.String(),
+					fmt.Sprintf("failed getValueFromIntOrPercent for partition: %v", err)))
+				continue
+			}
+			allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(partition), stepPath.Child("partition"))...)
+		case step.Replicas != nil:
+			var updated int
+			if updated, err = util.GetScaledValueFromIntOrPercent(step.Replicas, replicas, true); err != nil {
+				allErrs = append(allErrs, field.Invalid(stepPath.Child("replicas"), step.Replicas.String(),
+					fmt.Sprintf("failed getValueFromIntOrPercent for replicas: %v", err)))
+				continue
+			}
+			allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(updated), stepPath.Child("replicas"))...)
+			partition = replicas - updated
+		default:
+			allErrs = append(allErrs, field.Required(stepPath, "one of partition and replicas must be set"))
+			continue
+		}
+		if partition > lastPartition {
+			allErrs = append(allErrs, field.Invalid(stepPath, step, "the number of pods to update should not decrease in later steps"))
+		}
+		lastPartition = partition
+
+		if step.Pause != nil && step.Pause.Duration != nil {
+			allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(*step.Pause.Duration), stepPath.Child("pause", "duration"))...)
+		}
+	}
+	// the last step could never be finished if updateStrategy.partition holds more pods in old revisions
+	if len(steps) > 0 && strategyPartition > lastPartition {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Index(len(steps)-1), "updateStrategy.partition should not be larger than the partition of the last step"))
+	}
 	return allErrs
 }
 
@@ -196,8 +313,11 @@ func (h *CloneSetCreateUpdateHandler) validateCloneSetUpdate(cloneSet, oldCloneS
 	clone.Spec.Lifecycle = oldCloneSet.Spec.Lifecycle
 	clone.Spec.RevisionHistoryLimit = oldCloneSet.Spec.RevisionHistoryLimit
 	clone.Spec.VolumeClaimTemplates = oldCloneSet.Spec.VolumeClaimTemplates
+	clone.Spec.ProgressDeadlineSeconds = oldCloneSet.Spec.ProgressDeadlineSeconds
+	clone.Spec.RollbackPolicy = oldCloneSet.Spec.RollbackPolicy
+	clone.Spec.PersistentVolumeClaimRetentionPolicy = oldCloneSet.Spec.PersistentVolumeClaimRetentionPolicy
 	if !apiequality.Semantic.DeepEqual(clone.Spec, oldCloneSet.Spec) {
-		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "updates to cloneset spec for fields other than 'replicas', 'template', 'lifecycle', 'scaleStrategy', 'updateStrategy', 'minReadySeconds', 'volumeClaimTemplates' and 'revisionHistoryLimit' are forbidden"))
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "updates to cloneset spec for fields other than 'replicas', 'template', 'lifecycle', 'scaleStrategy', 'updateStrategy', 'minReadySeconds', 'volumeClaimTemplates', 'revisionHistoryLimit', 'progressDeadlineSeconds', 'rollbackPolicy' and 'persistentVolumeClaimRetentionPolicy' are forbidden"))
 	}
 
 	coreControl := clonesetcore.New(cloneSet)
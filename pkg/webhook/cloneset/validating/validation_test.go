@@ -16,6 +16,7 @@ import (
 	"github.com/openkruise/kruise/apis/apps/defaults"
 	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	appsv1beta1 "github.com/openkruise/kruise/apis/apps/v1beta1"
 	"github.com/openkruise/kruise/pkg/util"
 )
 
@@ -124,6 +125,22 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		{
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val1,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(2)),
+					MaxUnavailable: &intOrStr1,
+					MaxSurge:       &intOrStr1,
+				},
+				ScaleStrategy: appsv1alpha1.CloneSetScaleStrategy{
+					PodsToReplace: []string{"p0"},
+				},
+			},
+		},
 		{
 			spec: &appsv1alpha1.CloneSetSpec{
 				Replicas: &val1,
@@ -179,6 +196,19 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		{
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val1,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:             appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:        util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable:   &intOrStr1,
+					TopologyStrategy: &appspub.UpdateTopologyStrategy{TopologyKey: "example.com/zone"},
+				},
+			},
+		},
 		{
 			// test for all acceptable CloneSetSpec changes
 			spec: &appsv1alpha1.CloneSetSpec{
@@ -244,6 +274,46 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		{
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val2,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+					Steps: []appsv1alpha1.CloneSetUpdateStep{
+						{Replicas: &intOrStr1, Pause: &appsv1alpha1.CloneSetUpdateStepPause{}},
+						{Partition: &intOrStr0, Pause: &appsv1alpha1.CloneSetUpdateStepPause{Duration: utilpointer.Int32(60)}},
+					},
+				},
+			},
+		},
+		{
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas:                &val2,
+				Selector:                &metav1.LabelSelector{MatchLabels: validLabels},
+				Template:                validPodTemplate.Template,
+				ProgressDeadlineSeconds: utilpointer.Int32(600),
+				RollbackPolicy:          appsv1alpha1.CloneSetRollbackPolicyAuto,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+				},
+			},
+			oldSpec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val2,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+				},
+			},
+		},
 	}
 
 	for i, successCase := range successCases {
@@ -406,6 +476,142 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		"invalid-podsToReplace": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val1,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(2)),
+					MaxUnavailable: &intOrStr1,
+				},
+				ScaleStrategy: appsv1alpha1.CloneSetScaleStrategy{
+					PodsToDelete:  []string{"p0"},
+					PodsToReplace: []string{"p0"},
+				},
+			},
+		},
+		"invalid-podsToReplace-maxSurge": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val1,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(2)),
+					MaxUnavailable: &intOrStr1,
+				},
+				ScaleStrategy: appsv1alpha1.CloneSetScaleStrategy{
+					PodsToReplace: []string{"p0"},
+				},
+			},
+		},
+		"invalid-steps-1": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val2,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+					Steps: []appsv1alpha1.CloneSetUpdateStep{
+						{Replicas: &intOrStr1, Partition: &intOrStr1},
+					},
+				},
+			},
+		},
+		"invalid-steps-2": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val2,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+					Steps: []appsv1alpha1.CloneSetUpdateStep{
+						{Partition: &intOrStr0},
+						{Partition: &intOrStr1},
+					},
+				},
+			},
+		},
+		"invalid-steps-partition": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val2,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(1)),
+					MaxUnavailable: &intOrStr1,
+					Steps: []appsv1alpha1.CloneSetUpdateStep{
+						{Replicas: &intOrStr1},
+						{Partition: &intOrStr0},
+					},
+				},
+			},
+		},
+		"invalid-progress-deadline": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas:                &val2,
+				Selector:                &metav1.LabelSelector{MatchLabels: validLabels},
+				Template:                validPodTemplate.Template,
+				MinReadySeconds:         30,
+				ProgressDeadlineSeconds: utilpointer.Int32(30),
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+				},
+			},
+		},
+		"invalid-rollback-policy": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas:       &val2,
+				Selector:       &metav1.LabelSelector{MatchLabels: validLabels},
+				Template:       validPodTemplate.Template,
+				RollbackPolicy: appsv1alpha1.CloneSetRollbackPolicyAuto,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+				},
+			},
+		},
+		"invalid-pvc-retention-policy": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val2,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				ScaleStrategy: appsv1alpha1.CloneSetScaleStrategy{
+					DisablePVCReuse: true,
+				},
+				PersistentVolumeClaimRetentionPolicy: &appsv1alpha1.CloneSetPersistentVolumeClaimRetentionPolicy{
+					WhenScaled: appsv1beta1.RetainPersistentVolumeClaimRetentionPolicyType,
+				},
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:           appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:      util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable: &intOrStr1,
+				},
+			},
+		},
+		"invalid-topology-key": {
+			spec: &appsv1alpha1.CloneSetSpec{
+				Replicas: &val2,
+				Selector: &metav1.LabelSelector{MatchLabels: validLabels},
+				Template: validPodTemplate.Template,
+				UpdateStrategy: appsv1alpha1.CloneSetUpdateStrategy{
+					Type:             appsv1alpha1.InPlaceIfPossibleCloneSetUpdateStrategyType,
+					Partition:        util.GetIntOrStrPointer(intstr.FromInt(0)),
+					MaxUnavailable:   &intOrStr1,
+					TopologyStrategy: &appspub.UpdateTopologyStrategy{TopologyKey: v1.LabelTopologyZone},
+				},
+			},
+		},
 		"invalid-cloneset-update-1": {
 			spec: &appsv1alpha1.CloneSetSpec{
 				Replicas: &val1,
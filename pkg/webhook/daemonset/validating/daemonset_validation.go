@@ -2,6 +2,7 @@ package validating
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -11,11 +12,13 @@ import (
 	metavalidation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	appsvalidation "k8s.io/kubernetes/pkg/apis/apps/validation"
 	corevalidation "k8s.io/kubernetes/pkg/apis/core/validation"
 
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
 	webhookutil "github.com/openkruise/kruise/pkg/webhook/util"
 	"github.com/openkruise/kruise/pkg/webhook/util/convertor"
@@ -31,12 +34,12 @@ func validatingDaemonSetFn(ctx context.Context, obj *appsv1alpha1.DaemonSet) (bo
 
 func validateDaemonSet(ds *appsv1alpha1.DaemonSet) field.ErrorList {
 	allErrs := genericvalidation.ValidateObjectMeta(&ds.ObjectMeta, true, ValidateDaemonSetName, field.NewPath("metadata"))
-	allErrs = append(allErrs, validateDaemonSetSpec(&ds.Spec, field.NewPath("spec"))...)
+	allErrs = append(allErrs, validateDaemonSetSpec(&ds.Spec, nil, field.NewPath("spec"))...)
 	return allErrs
 }
 
 // ValidateDaemonSetSpec tests if required fields in the DaemonSetSpec are set.
-func validateDaemonSetSpec(spec *appsv1alpha1.DaemonSetSpec, fldPath *field.Path) field.ErrorList {
+func validateDaemonSetSpec(spec, oldSpec *appsv1alpha1.DaemonSetSpec, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	allErrs = append(allErrs, metavalidation.ValidateLabelSelector(spec.Selector, metavalidation.LabelSelectorValidationOptions{}, fldPath.Child("selector"))...)
@@ -71,6 +74,7 @@ func validateDaemonSetSpec(spec *appsv1alpha1.DaemonSetSpec, fldPath *field.Path
 	if spec.Template.Spec.ActiveDeadlineSeconds != nil {
 		allErrs = append(allErrs, field.Forbidden(fldPath.Child("template", "spec", "activeDeadlineSeconds"), "activeDeadlineSeconds in DaemonSet is not Supported"))
 	}
+	allErrs = append(allErrs, validateNodePoolPatches(spec, fldPath.Child("nodePoolPatches"))...)
 	allErrs = append(allErrs, corevalidation.ValidateNonnegativeField(int64(spec.MinReadySeconds), fldPath.Child("minReadySeconds"))...)
 
 	allErrs = append(allErrs, validateDaemonSetUpdateStrategy(&spec.UpdateStrategy, fldPath.Child("updateStrategy"))...)
@@ -83,6 +87,11 @@ func validateDaemonSetSpec(spec *appsv1alpha1.DaemonSetSpec, fldPath *field.Path
 		if spec.Lifecycle.InPlaceUpdate != nil {
 			allErrs = append(allErrs, field.Forbidden(fldPath.Child("lifecycle", "inPlaceUpdate"), "inPlaceUpdate hook has not supported yet"))
 		}
+		var oldLifecycle *appspub.Lifecycle
+		if oldSpec != nil {
+			oldLifecycle = oldSpec.Lifecycle
+		}
+		allErrs = append(allErrs, webhookutil.ValidateLifecycle(spec.Lifecycle, oldLifecycle, fldPath.Child("lifecycle"))...)
 	}
 	return allErrs
 }
@@ -144,7 +153,86 @@ func validateRollingUpdateDaemonSet(rollingUpdate *appsv1alpha1.RollingUpdateDae
 	if rollingUpdate.Partition != nil {
 		allErrs = append(allErrs, corevalidation.ValidateNonnegativeField(int64(*rollingUpdate.Partition), fldPath.Child("rollingUpdate").Child("partition"))...)
 	}
+	allErrs = append(allErrs, validateUpdateStages(rollingUpdate.Stages, fldPath.Child("stages"))...)
+
+	return allErrs
+}
+
+func validateUpdateStages(stages []appsv1alpha1.DaemonSetUpdateStage, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := make(map[string]struct{}, len(stages))
+	for i := range stages {
+		stage := &stages[i]
+		idxPath := fldPath.Index(i)
+		if stage.Name != "" {
+			if _, ok := names[stage.Name]; ok {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), stage.Name))
+			}
+			names[stage.Name] = struct{}{}
+		}
+		if stage.NodeSelector != nil {
+			allErrs = append(allErrs, metavalidation.ValidateLabelSelector(stage.NodeSelector, metavalidation.LabelSelectorValidationOptions{}, idxPath.Child("nodeSelector"))...)
+		}
+		if stage.Replicas != nil {
+			allErrs = append(allErrs, appsvalidation.ValidatePositiveIntOrPercent(*stage.Replicas, idxPath.Child("replicas"))...)
+			allErrs = append(allErrs, appsvalidation.IsNotMoreThan100Percent(*stage.Replicas, idxPath.Child("replicas"))...)
+		}
+		allErrs = append(allErrs, corevalidation.ValidateNonnegativeField(int64(stage.SoakSeconds), idxPath.Child("soakSeconds"))...)
+	}
+	return allErrs
+}
 
+func validateNodePoolPatches(spec *appsv1alpha1.DaemonSetSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := make(map[string]struct{}, len(spec.NodePoolPatches))
+	for i := range spec.NodePoolPatches {
+		patch := &spec.NodePoolPatches[i]
+		idxPath := fldPath.Index(i)
+		if patch.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		} else {
+			if _, ok := names[patch.Name]; ok {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), patch.Name))
+			}
+			names[patch.Name] = struct{}{}
+		}
+		if patch.NodeSelector == nil {
+			allErrs = append(allErrs, field.Required(idxPath.Child("nodeSelector"), ""))
+		} else {
+			allErrs = append(allErrs, metavalidation.ValidateLabelSelector(patch.NodeSelector, metavalidation.LabelSelectorValidationOptions{}, idxPath.Child("nodeSelector"))...)
+		}
+		if len(patch.Patch.Raw) == 0 {
+			allErrs = append(allErrs, field.Required(idxPath.Child("patch"), ""))
+			continue
+		}
+
+		// the patched template should be valid as well
+		templateBytes, err := json.Marshal(spec.Template)
+		if err != nil {
+			allErrs = append(allErrs, field.InternalError(idxPath.Child("patch"), err))
+			continue
+		}
+		modified, err := strategicpatch.StrategicMergePatch(templateBytes, patch.Patch.Raw, &corev1.PodTemplateSpec{})
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("patch"), string(patch.Patch.Raw), fmt.Sprintf("failed to patch template: %v", err)))
+			continue
+		}
+		template := &corev1.PodTemplateSpec{}
+		if err = json.Unmarshal(modified, template); err != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("patch"), string(patch.Patch.Raw), fmt.Sprintf("failed to unmarshal patched template: %v", err)))
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(spec.Selector)
+		if err == nil && !selector.Matches(labels.Set(template.Labels)) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("patch"), string(patch.Patch.Raw), "`selector` does not match patched template `labels`"))
+		}
+		coreTemplate, err := convertor.ConvertPodTemplateSpec(template)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("patch"), string(patch.Patch.Raw), fmt.Sprintf("Convert_v1_PodTemplateSpec_To_core_PodTemplateSpec failed: %v", err)))
+			continue
+		}
+		allErrs = append(allErrs, corevalidation.ValidatePodTemplateSpec(coreTemplate, idxPath.Child("patch"), webhookutil.DefaultPodValidationOptions)...)
+	}
 	return allErrs
 }
 
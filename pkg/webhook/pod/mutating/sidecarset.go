@@ -21,7 +21,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
-	"sort"
 	"strings"
 
 	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
@@ -151,22 +150,7 @@ func (h *PodCreateHandler) sidecarsetMutatingPod(ctx context.Context, req admiss
 		"volumesInSidecar", volumesInSidecar, "injectedAnnotations", injectedAnnotations,
 		"namespace", pod.Namespace, "name", pod.Name)
 	klog.V(4).InfoS("before mutating", "func", "sidecar inject", "pod", klog.KObj(pod))
-	// apply sidecar set info into pod
-	// 1. inject init containers, sort by their name, after the original init containers
-	sort.SliceStable(sidecarInitContainers, func(i, j int) bool {
-		return sidecarInitContainers[i].Name < sidecarInitContainers[j].Name
-	})
-	pod.Spec.InitContainers = mergeSidecarContainers(pod.Spec.InitContainers, sidecarInitContainers)
-	// 2. inject containers
-	pod.Spec.Containers = mergeSidecarContainers(pod.Spec.Containers, sidecarContainers)
-	// 3. inject volumes
-	pod.Spec.Volumes = util.MergeVolumes(pod.Spec.Volumes, volumesInSidecar)
-	// 4. inject imagePullSecrets
-	pod.Spec.ImagePullSecrets = mergeSidecarSecrets(pod.Spec.ImagePullSecrets, sidecarSecrets)
-	// 5. apply annotations
-	for k, v := range injectedAnnotations {
-		pod.Annotations[k] = v
-	}
+	sidecarcontrol.InjectSidecars(pod, sidecarContainers, sidecarInitContainers, sidecarSecrets, volumesInSidecar, injectedAnnotations)
 	klog.V(4).InfoS("after mutating", "func", "sidecar inject", "pod", klog.KObj(pod))
 	return false, nil
 }
@@ -203,6 +187,17 @@ func (h *PodCreateHandler) getSuitableRevisionSidecarSet(sidecarSet *appsv1alpha
 		return sidecarSet.DeepCopy(), nil
 
 	default:
+		// the newly created pods are injected with the revision which the matched pods are rolled back to
+		if sidecarSet.Spec.UpdateStrategy.RollbackTo != nil {
+			rollbackSidecarSet, err := sidecarcontrol.NewHistoryControl(h.Client).GetRollbackSidecarSet(sidecarSet)
+			if err != nil {
+				klog.ErrorS(err, "Failed to restore rollback revision for SidecarSet", "sidecarSet", klog.KObj(sidecarSet),
+					"rollbackTo", sidecarSet.Spec.UpdateStrategy.RollbackTo)
+				return nil, err
+			}
+			return rollbackSidecarSet, nil
+		}
+
 		revisionInfo := sidecarSet.Spec.InjectionStrategy.Revision
 		if revisionInfo == nil || (revisionInfo.RevisionName == nil && revisionInfo.CustomVersion == nil) {
 			return sidecarSet.DeepCopy(), nil
@@ -289,53 +284,6 @@ func (h *PodCreateHandler) getSpecificHistorySidecarSet(sidecarSet *appsv1alpha1
 	return historySidecarSet, nil
 }
 
-func mergeSidecarSecrets(secretsInPod, secretsInSidecar []corev1.LocalObjectReference) (allSecrets []corev1.LocalObjectReference) {
-	secretFilter := make(map[string]bool)
-	for _, podSecret := range secretsInPod {
-		if _, ok := secretFilter[podSecret.Name]; !ok {
-			secretFilter[podSecret.Name] = true
-			allSecrets = append(allSecrets, podSecret)
-		}
-	}
-	for _, sidecarSecret := range secretsInSidecar {
-		if _, ok := secretFilter[sidecarSecret.Name]; !ok {
-			secretFilter[sidecarSecret.Name] = true
-			allSecrets = append(allSecrets, sidecarSecret)
-		}
-	}
-	return allSecrets
-}
-
-func mergeSidecarContainers(origins []corev1.Container, injected []*appsv1alpha1.SidecarContainer) []corev1.Container {
-	//format: pod.spec.containers[index].name -> index(the index of container in pod)
-	containersInPod := make(map[string]int)
-	for index, container := range origins {
-		containersInPod[container.Name] = index
-	}
-	var beforeAppContainers []corev1.Container
-	var afterAppContainers []corev1.Container
-	for _, sidecar := range injected {
-		//sidecar container already exist in pod
-		//keep the order of pod's original containers unchanged
-		if index, ok := containersInPod[sidecar.Name]; ok {
-			origins[index] = sidecar.Container
-			continue
-		}
-
-		switch sidecar.PodInjectPolicy {
-		case appsv1alpha1.BeforeAppContainerType:
-			beforeAppContainers = append(beforeAppContainers, sidecar.Container)
-		case appsv1alpha1.AfterAppContainerType:
-			afterAppContainers = append(afterAppContainers, sidecar.Container)
-		default:
-			afterAppContainers = append(afterAppContainers, sidecar.Container)
-		}
-	}
-	origins = append(beforeAppContainers, origins...)
-	origins = append(origins, afterAppContainers...)
-	return origins
-}
-
 func buildSidecars(isUpdated bool, pod *corev1.Pod, oldPod *corev1.Pod, matchedSidecarSets []sidecarcontrol.SidecarControl) (
 	sidecarContainers, sidecarInitContainers []*appsv1alpha1.SidecarContainer, sidecarSecrets []corev1.LocalObjectReference,
 	volumesInSidecars []corev1.Volume, injectedAnnotations map[string]string, err error) {
@@ -439,6 +387,8 @@ func buildSidecars(isUpdated bool, pod *corev1.Pod, oldPod *corev1.Pod, matchedS
 				initContainer.Env = append(initContainer.Env, corev1.EnvVar{Name: sidecarcontrol.SidecarEnvKey, Value: "true"})
 				// merged Env from sidecar.Env and transfer envs
 				initContainer.Env = util.MergeEnvVar(initContainer.Env, transferEnvs)
+				// derive resources from the app containers
+				initContainer.Resources = sidecarcontrol.GetSidecarResources(initContainer, pod)
 				isInjecting = true
 
 				// when sidecar container UpgradeStrategy is HotUpgrade
@@ -471,6 +421,10 @@ func buildSidecars(isUpdated bool, pod *corev1.Pod, oldPod *corev1.Pod, matchedS
 				"containerName", sidecarContainer.Name, "namespace", pod.Namespace, "podName", pod.Name, "envs", transferEnvs, "volumeMounts", injectedMounts)
 			//when update pod object
 			if isUpdated {
+				// native sidecar containers are in pod.spec.initContainers, which can't be injected into the existing pod
+				if sidecarcontrol.IsNativeSidecarSet(sidecarSet) {
+					continue
+				}
 				// judge whether inject sidecar container into pod
 				needInject, existSidecars, existVolumes := control.NeedToInjectInUpdatedPod(pod, oldPod, sidecarContainer, transferEnvs, injectedMounts)
 				if !needInject {
@@ -497,9 +451,16 @@ func buildSidecars(isUpdated bool, pod *corev1.Pod, oldPod *corev1.Pod, matchedS
 			sidecarContainer.Env = append(sidecarContainer.Env, corev1.EnvVar{Name: sidecarcontrol.SidecarEnvKey, Value: "true"})
 			// merged Env from sidecar.Env and transfer envs
 			sidecarContainer.Env = util.MergeEnvVar(sidecarContainer.Env, transferEnvs)
-
-			// when sidecar container UpgradeStrategy is HotUpgrade
-			if sidecarcontrol.IsHotUpgradeContainer(sidecarContainer) {
+			// derive resources from the app containers
+			sidecarContainer.Resources = sidecarcontrol.GetSidecarResources(sidecarContainer, pod)
+
+			if sidecarcontrol.IsNativeSidecarSet(sidecarSet) {
+				// inject the sidecar container into pod.spec.initContainers as native sidecar container
+				restartPolicy := corev1.ContainerRestartPolicyAlways
+				sidecarContainer.RestartPolicy = &restartPolicy
+				sidecarInitContainers = append(sidecarInitContainers, sidecarContainer)
+			} else if sidecarcontrol.IsHotUpgradeContainer(sidecarContainer) {
+				// when sidecar container UpgradeStrategy is HotUpgrade
 				hotContainers, annotations := injectHotUpgradeContainers(hotUpgradeWorkInfo, sidecarContainer)
 				sidecarContainers = append(sidecarContainers, hotContainers...)
 				for k, v := range annotations {
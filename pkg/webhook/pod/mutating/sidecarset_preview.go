@@ -0,0 +1,51 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mutating
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/control/sidecarcontrol"
+)
+
+// PreviewSidecarSetInjection returns a copy of the pod injected with the SidecarSet in the same way as it is created,
+// and the conflicts which would make the injection rejected or broken. The others are the other SidecarSets matched
+// by the pod, which are checked for conflicts with the SidecarSet. Neither the pod nor the SidecarSets are modified.
+func PreviewSidecarSetInjection(sidecarSet *appsv1alpha1.SidecarSet, others []*appsv1alpha1.SidecarSet, pod *corev1.Pod) (*corev1.Pod, []string) {
+	conflicts := sidecarcontrol.GetSidecarSetInjectionConflicts(sidecarSet, others, pod)
+
+	podOut := pod.DeepCopy()
+	if podOut.Annotations == nil {
+		podOut.Annotations = make(map[string]string)
+	}
+	if _, err := sidecarcontrol.PatchPodMetadata(&podOut.ObjectMeta, sidecarSet.Spec.PatchPodMetadata); err != nil {
+		conflicts = append(conflicts, fmt.Sprintf("failed to patch pod metadata: %v", err))
+	}
+	// buildSidecars modifies the containers of SidecarSet, so a copy is used here
+	control := sidecarcontrol.New(sidecarSet.DeepCopy())
+	sidecarContainers, sidecarInitContainers, sidecarSecrets, volumesInSidecar, injectedAnnotations, err :=
+		buildSidecars(false, podOut, nil, []sidecarcontrol.SidecarControl{control})
+	if err != nil {
+		// the pod creation would be rejected by webhook
+		return podOut, append(conflicts, fmt.Sprintf("failed to build sidecars: %v", err))
+	}
+	sidecarcontrol.InjectSidecars(podOut, sidecarContainers, sidecarInitContainers, sidecarSecrets, volumesInSidecar, injectedAnnotations)
+	return podOut, conflicts
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mutating
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	appsv1alpha1 "github.com/openkruise/kruise/apis/apps/v1alpha1"
+	"github.com/openkruise/kruise/pkg/control/sidecarcontrol"
+)
+
+func TestPreviewSidecarSetInjection(t *testing.T) {
+	cases := []struct {
+		name             string
+		getSidecarSet    func() *appsv1alpha1.SidecarSet
+		getOthers        func() []*appsv1alpha1.SidecarSet
+		getPod           func() *corev1.Pod
+		expectContainers []string
+		expectVolumes    []string
+		expectConflicts  []string
+	}{
+		{
+			name: "inject without conflicts",
+			getSidecarSet: func() *appsv1alpha1.SidecarSet {
+				return sidecarSet1.DeepCopy()
+			},
+			getPod: func() *corev1.Pod {
+				return pod1.DeepCopy()
+			},
+			expectContainers: []string{"dns-f", "nginx", "log-agent"},
+			expectVolumes:    []string{"volume-a", "volume-b"},
+		},
+		{
+			name: "duplicate container name",
+			getSidecarSet: func() *appsv1alpha1.SidecarSet {
+				return sidecarSet1.DeepCopy()
+			},
+			getPod: func() *corev1.Pod {
+				pod := pod1.DeepCopy()
+				pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "log-agent", Image: "log-agent:app"})
+				return pod
+			},
+			expectContainers: []string{"dns-f", "nginx", "log-agent"},
+			expectVolumes:    []string{"volume-a", "volume-b"},
+			expectConflicts:  []string{"container log-agent already exists in pod"},
+		},
+		{
+			name: "volume clash",
+			getSidecarSet: func() *appsv1alpha1.SidecarSet {
+				sidecarSet := sidecarSet1.DeepCopy()
+				sidecarSet.Spec.Volumes = []corev1.Volume{
+					{
+						Name:         "volume-a",
+						VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+					},
+				}
+				sidecarSet.Spec.Containers[1].VolumeMounts = []corev1.VolumeMount{{Name: "volume-a", MountPath: "/a"}}
+				return sidecarSet
+			},
+			getPod: func() *corev1.Pod {
+				return pod1.DeepCopy()
+			},
+			expectContainers: []string{"dns-f", "nginx", "log-agent"},
+			expectVolumes:    []string{"volume-a", "volume-b"},
+			expectConflicts:  []string{"volume volume-a already exists in pod with a different source"},
+		},
+		{
+			name: "transferEnv source not found",
+			getSidecarSet: func() *appsv1alpha1.SidecarSet {
+				sidecarSet := sidecarSet1.DeepCopy()
+				sidecarSet.Spec.Containers[1].TransferEnv = []appsv1alpha1.TransferEnvVar{
+					{SourceContainerName: "nginx", EnvNames: []string{"hello1", "hello3"}},
+					{SourceContainerName: "main", EnvName: "hello1"},
+				}
+				return sidecarSet
+			},
+			getPod: func() *corev1.Pod {
+				return pod1.DeepCopy()
+			},
+			expectContainers: []string{"dns-f", "nginx", "log-agent"},
+			expectVolumes:    []string{"volume-a", "volume-b"},
+			expectConflicts: []string{
+				"transferEnv hello3 of container log-agent not found in source container nginx",
+				`transferEnv source container "main" of container log-agent not found in pod`,
+			},
+		},
+		{
+			name: "conflict with other sidecarSet",
+			getSidecarSet: func() *appsv1alpha1.SidecarSet {
+				return sidecarSet1.DeepCopy()
+			},
+			getOthers: func() []*appsv1alpha1.SidecarSet {
+				other := sidecarSet1.DeepCopy()
+				other.Name = "other"
+				other.Spec.InitContainers = nil
+				other.Spec.Containers = other.Spec.Containers[1:]
+				return []*appsv1alpha1.SidecarSet{other}
+			},
+			getPod: func() *corev1.Pod {
+				return pod1.DeepCopy()
+			},
+			expectContainers: []string{"dns-f", "nginx", "log-agent"},
+			expectVolumes:    []string{"volume-a", "volume-b"},
+			expectConflicts:  []string{"container log-agent already exist in other"},
+		},
+		{
+			name: "failed to build sidecars",
+			getSidecarSet: func() *appsv1alpha1.SidecarSet {
+				return sidecarSet1.DeepCopy()
+			},
+			getPod: func() *corev1.Pod {
+				pod := pod1.DeepCopy()
+				pod.Annotations = map[string]string{sidecarcontrol.SidecarSetHashAnnotation: "invalid"}
+				return pod
+			},
+			expectContainers: []string{"nginx"},
+			expectVolumes:    []string{"volume-a", "volume-b"},
+			expectConflicts: []string{
+				fmt.Sprintf("failed to build sidecars: pod(%s/test-pod) invalid annotations[%s] value invalid, unmarshal failed: "+
+					"invalid character 'i' looking for beginning of value", defaultNs, sidecarcontrol.SidecarSetHashAnnotation),
+			},
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			sidecarSet := cs.getSidecarSet()
+			pod := cs.getPod()
+			podIn := pod.DeepCopy()
+			var others []*appsv1alpha1.SidecarSet
+			if cs.getOthers != nil {
+				others = cs.getOthers()
+			}
+			podOut, conflicts := PreviewSidecarSetInjection(sidecarSet, others, pod)
+			if !reflect.DeepEqual(pod, podIn) {
+				t.Fatalf("expect pod not modified")
+			}
+			if !reflect.DeepEqual(conflicts, cs.expectConflicts) {
+				t.Fatalf("expect conflicts %v, but got %v", cs.expectConflicts, conflicts)
+			}
+			var containers, volumes []string
+			for _, c := range podOut.Spec.Containers {
+				containers = append(containers, c.Name)
+			}
+			for _, v := range podOut.Spec.Volumes {
+				volumes = append(volumes, v.Name)
+			}
+			if !reflect.DeepEqual(containers, cs.expectContainers) {
+				t.Fatalf("expect containers %v, but got %v", cs.expectContainers, containers)
+			}
+			if !reflect.DeepEqual(volumes, cs.expectVolumes) {
+				t.Fatalf("expect volumes %v, but got %v", cs.expectVolumes, volumes)
+			}
+		})
+	}
+}
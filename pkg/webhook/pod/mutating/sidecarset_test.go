@@ -544,6 +544,50 @@ func testInjectionStrategyPaused(t *testing.T, sidecarIn *appsv1alpha1.SidecarSe
 	}
 }
 
+func TestInjectionStrategyNativeSidecar(t *testing.T) {
+	sidecarSetIn := sidecarSet1.DeepCopy()
+	sidecarSetIn.Spec.InjectionStrategy.NativeSidecar = true
+	podIn := pod1.DeepCopy()
+	podOut := podIn.DeepCopy()
+	decoder := admission.NewDecoder(scheme.Scheme)
+	c := fake.NewClientBuilder().WithObjects(sidecarSetIn).WithIndex(
+		&appsv1alpha1.SidecarSet{}, fieldindex.IndexNameForSidecarSetNamespace, fieldindex.IndexSidecarSet,
+	).Build()
+	podHandler := &PodCreateHandler{Decoder: decoder, Client: c}
+	req := newAdmission(admissionv1.Create, runtime.RawExtension{}, runtime.RawExtension{}, "")
+	if _, err := podHandler.sidecarsetMutatingPod(context.Background(), req, podOut); err != nil {
+		t.Fatalf("inject sidecar into pod failed, err: %v", err)
+	}
+
+	if len(podOut.Spec.Containers) != len(podIn.Spec.Containers) {
+		t.Fatalf("expect %v containers but got %v", len(podIn.Spec.Containers), len(podOut.Spec.Containers))
+	}
+	expectInitContainers := len(podIn.Spec.InitContainers) + len(sidecarSetIn.Spec.InitContainers) + len(sidecarSetIn.Spec.Containers)
+	if len(podOut.Spec.InitContainers) != expectInitContainers {
+		t.Fatalf("expect %v initContainers but got %v", expectInitContainers, len(podOut.Spec.InitContainers))
+	}
+	for _, sidecar := range sidecarSetIn.Spec.Containers {
+		container := util.GetContainer(sidecar.Name, podOut)
+		if container == nil || !sidecarcontrol.IsSidecarContainer(*container) {
+			t.Fatalf("expect container %s injected as native sidecar container, but got %v", sidecar.Name, container)
+		}
+	}
+	// dns-f is injected before app containers, and log-agent after them
+	if podOut.Spec.InitContainers[0].Name != "dns-f" || podOut.Spec.InitContainers[expectInitContainers-1].Name != "log-agent" {
+		t.Fatalf("expect native sidecar containers injected by podInjectPolicy, but got %v", podOut.Spec.InitContainers)
+	}
+
+	// native sidecar containers without podInjectPolicy are injected before app init containers
+	restartPolicy := corev1.ContainerRestartPolicyAlways
+	initContainers := sidecarcontrol.MergeSidecarContainers([]corev1.Container{{Name: "init-0"}}, []*appsv1alpha1.SidecarContainer{
+		{Container: corev1.Container{Name: "init-1"}},
+		{Container: corev1.Container{Name: "native-sidecar", RestartPolicy: &restartPolicy}},
+	})
+	if len(initContainers) != 3 || initContainers[0].Name != "native-sidecar" || initContainers[2].Name != "init-1" {
+		t.Fatalf("expect native sidecar container injected before app init containers, but got %v", initContainers)
+	}
+}
+
 func TestInjectMetadata(t *testing.T) {
 	podIn := pod1.DeepCopy()
 	demo1 := sidecarSet1.DeepCopy()
@@ -660,6 +704,64 @@ func testInjectionStrategyRevision(t *testing.T, env []client.Object) {
 	}
 }
 
+func TestUpdateStrategyRollbackTo(t *testing.T) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"$patch": "replace",
+			"containers": []appsv1alpha1.SidecarContainer{
+				{
+					Container: corev1.Container{
+						Name:  "dns-f",
+						Image: "dns-f-image:0.9",
+					},
+					PodInjectPolicy: appsv1alpha1.BeforeAppContainerType,
+					ShareVolumePolicy: appsv1alpha1.ShareVolumePolicy{
+						Type: appsv1alpha1.ShareVolumePolicyDisabled,
+					},
+				},
+			},
+		},
+	})
+	revisionName := fmt.Sprintf("%s-12345", sidecarSet1.Name)
+	sidecarSetIn := sidecarSet1.DeepCopy()
+	sidecarSetIn.Spec.UpdateStrategy.RollbackTo = &appsv1alpha1.SidecarSetRollbackTo{
+		RevisionName: &revisionName,
+	}
+	revision := &apps.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: webhookutil.GetNamespace(),
+			Name:      revisionName,
+			Labels: map[string]string{
+				sidecarcontrol.SidecarSetKindName: sidecarSet1.GetName(),
+			},
+		},
+		Data: runtime.RawExtension{
+			Raw: raw,
+		},
+	}
+
+	podIn := pod1.DeepCopy()
+	decoder := admission.NewDecoder(scheme.Scheme)
+	c := fake.NewClientBuilder().WithObjects(sidecarSetIn, revision).WithIndex(
+		&appsv1alpha1.SidecarSet{}, fieldindex.IndexNameForSidecarSetNamespace, fieldindex.IndexSidecarSet,
+	).Build()
+	podHandler := &PodCreateHandler{Decoder: decoder, Client: c}
+	req := newAdmission(admissionv1.Create, runtime.RawExtension{}, runtime.RawExtension{}, "")
+	if _, err := podHandler.sidecarsetMutatingPod(context.Background(), req, podIn); err != nil {
+		t.Fatalf("failed to mutating pod, err: %v", err)
+	}
+
+	if len(podIn.Spec.Containers) != len(pod1.Spec.Containers)+1 {
+		t.Fatalf("expect %v containers but got %v", len(pod1.Spec.Containers)+1, len(podIn.Spec.Containers))
+	}
+	if podIn.Spec.Containers[0].Name != "dns-f" || podIn.Spec.Containers[0].Image != "dns-f-image:0.9" {
+		t.Fatalf("expect container dns-f of the rollback revision injected, but got %s(%s)", podIn.Spec.Containers[0].Name, podIn.Spec.Containers[0].Image)
+	}
+	if revisionInfo := sidecarcontrol.GetPodSidecarSetControllerRevision(sidecarSet1.Name, podIn); revisionInfo != revisionName {
+		t.Fatalf("expect controllerRevision %s in pod annotations, but got %s", revisionName, revisionInfo)
+	}
+}
+
 func TestSidecarSetPodInjectPolicy(t *testing.T) {
 	sidecarSetIn := sidecarSet1.DeepCopy()
 	testSidecarSetPodInjectPolicy(t, sidecarSetIn)
@@ -1371,7 +1473,7 @@ func TestMergeSidecarContainers(t *testing.T) {
 		t.Run(cs.name, func(t *testing.T) {
 			origins := cs.getOrigins()
 			injected := cs.getInjected()
-			finals := mergeSidecarContainers(origins, injected)
+			finals := sidecarcontrol.MergeSidecarContainers(origins, injected)
 			if len(finals) != cs.expectContainerLen {
 				t.Fatalf("expect %d containers but got %v", cs.expectContainerLen, len(finals))
 			}
@@ -1606,7 +1708,7 @@ func TestInjectInitContainerSort(t *testing.T) {
 			sort.SliceStable(injected, func(i, j int) bool {
 				return injected[i].Name < injected[j].Name
 			})
-			finals := mergeSidecarContainers(origins, injected)
+			finals := sidecarcontrol.MergeSidecarContainers(origins, injected)
 			if len(finals) != cs.expectContainerLen {
 				t.Fatalf("expect %d containers but got %v", cs.expectContainerLen, len(finals))
 			}
@@ -20,7 +20,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"reflect"
 	"regexp"
 	"strings"
 
@@ -138,6 +137,7 @@ func (h *SidecarSetCreateUpdateHandler) validateSidecarSetSpec(obj *appsv1alpha1
 	allErrs = append(allErrs, h.validateSidecarSetInjectionStrategy(obj, fldPath.Child("injectionStrategy"))...)
 	//validating SidecarSetUpdateStrategy
 	allErrs = append(allErrs, validateSidecarSetUpdateStrategy(&spec.UpdateStrategy, fldPath.Child("updateStrategy"))...)
+	allErrs = append(allErrs, h.validateSidecarSetRollbackTo(obj, fldPath.Child("updateStrategy").Child("rollbackTo"))...)
 	//validating volumes
 	vols, vErrs := getCoreVolumes(spec.Volumes, fldPath.Child("volumes"))
 	allErrs = append(allErrs, vErrs...)
@@ -211,6 +211,16 @@ func (h *SidecarSetCreateUpdateHandler) validateSidecarSetInjectionStrategy(obj
 				revisionInfo.Policy, appsv1alpha1.AlwaysSidecarSetInjectRevisionPolicy, appsv1alpha1.PartialSidecarSetInjectRevisionPolicy)))
 		}
 	}
+
+	if obj.Spec.InjectionStrategy.NativeSidecar {
+		for i := range obj.Spec.Containers {
+			container := &obj.Spec.Containers[i]
+			if sidecarcontrol.IsHotUpgradeContainer(container) {
+				errList = append(errList, field.Invalid(field.NewPath("spec", "containers").Index(i), container.Name,
+					"hotUpgrade is not supported when spec.injectionStrategy.nativeSidecar is true"))
+			}
+		}
+	}
 	return errList
 }
 
@@ -225,6 +235,24 @@ func intStrIsSet(i *intstr.IntOrString) bool {
 	return i.IntVal != 0
 }
 
+func (h *SidecarSetCreateUpdateHandler) validateSidecarSetRollbackTo(obj *appsv1alpha1.SidecarSet, fldPath *field.Path) field.ErrorList {
+	errList := field.ErrorList{}
+	rollbackTo := obj.Spec.UpdateStrategy.RollbackTo
+	if rollbackTo == nil {
+		return errList
+	}
+
+	if rollbackTo.RevisionName == nil && rollbackTo.CustomVersion == nil {
+		errList = append(errList, field.Invalid(fldPath, rollbackTo, "revisionName and customVersion cannot be empty simultaneously"))
+		return errList
+	}
+	revision, err := sidecarcontrol.NewHistoryControl(h.Client).GetRollbackSidecarSet(obj)
+	if err != nil || revision == nil {
+		errList = append(errList, field.Invalid(fldPath, rollbackTo, fmt.Sprintf("Cannot find specific ControllerRevision, err: %v", err)))
+	}
+	return errList
+}
+
 func validateSidecarSetUpdateStrategy(strategy *appsv1alpha1.SidecarSetUpdateStrategy, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 	// if SidecarSet update strategy is RollingUpdate
@@ -249,6 +277,33 @@ func validateSidecarSetUpdateStrategy(strategy *appsv1alpha1.SidecarSetUpdateStr
 				allErrs = append(allErrs, field.Required(fldPath.Child("scatterStrategy"), err.Error()))
 			}
 		}
+		allErrs = append(allErrs, validateUpdateStages(strategy.Stages, fldPath.Child("stages"))...)
+	}
+	return allErrs
+}
+
+func validateUpdateStages(stages []appsv1alpha1.SidecarSetUpdateStage, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := sets.NewString()
+	for i := range stages {
+		stage := &stages[i]
+		idxPath := fldPath.Index(i)
+		if stage.Name != "" {
+			if names.Has(stage.Name) {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), stage.Name))
+			}
+			names.Insert(stage.Name)
+		}
+		if stage.NamespaceSelector != nil {
+			allErrs = append(allErrs, metavalidation.ValidateLabelSelector(stage.NamespaceSelector, metavalidation.LabelSelectorValidationOptions{}, idxPath.Child("namespaceSelector"))...)
+		}
+		if stage.Selector != nil {
+			allErrs = append(allErrs, metavalidation.ValidateLabelSelector(stage.Selector, metavalidation.LabelSelectorValidationOptions{}, idxPath.Child("selector"))...)
+		}
+		if stage.MaxUnavailable != nil {
+			allErrs = append(allErrs, appsvalidation.ValidatePositiveIntOrPercent(*stage.MaxUnavailable, idxPath.Child("maxUnavailable"))...)
+		}
+		allErrs = append(allErrs, corevalidation.ValidateNonnegativeField(int64(stage.SoakSeconds), idxPath.Child("soakSeconds"))...)
 	}
 	return allErrs
 }
@@ -260,7 +315,8 @@ func validateContainersForSidecarSet(
 	allErrs := field.ErrorList{}
 	//validating initContainer
 	var coreInitContainers []core.Container
-	for _, container := range initContainers {
+	for i, container := range initContainers {
+		allErrs = append(allErrs, validateResourcesPolicy(container.ResourcesPolicy, container.Resources, fldPath.Child("initContainers").Index(i).Child("resourcesPolicy"))...)
 		coreContainer := core.Container{}
 		if err := corev1.Convert_v1_Container_To_core_Container(&container.Container, &coreContainer, nil); err != nil {
 			allErrs = append(allErrs, field.Invalid(fldPath.Child("initContainer"), container.Container, fmt.Sprintf("Convert_v1_Container_To_core_Container failed: %v", err)))
@@ -280,6 +336,7 @@ func validateContainersForSidecarSet(
 			allErrs = append(allErrs, field.Invalid(fldPath.Child("container").Child("shareVolumePolicy"), container.ShareVolumePolicy, "unsupported share volume policy"))
 		}
 		allErrs = append(allErrs, validateDownwardAPI(container.TransferEnv, idxPath.Child("transferEnv"))...)
+		allErrs = append(allErrs, validateResourcesPolicy(container.ResourcesPolicy, container.Resources, idxPath.Child("resourcesPolicy"))...)
 		coreContainer := core.Container{}
 		if err := corev1.Convert_v1_Container_To_core_Container(&container.Container, &coreContainer, nil); err != nil {
 			allErrs = append(allErrs, field.Invalid(fldPath.Child("container"), container.Container, fmt.Sprintf("Convert_v1_Container_To_core_Container failed: %v", err)))
@@ -343,18 +400,6 @@ func validateSidecarContainerConflict(newContainers, oldContainers []appsv1alpha
 
 // validate the sidecarset spec.container.name, spec.initContainer.name, volume.name conflicts with others in cluster
 func validateSidecarConflict(c client.Client, sidecarSets *appsv1alpha1.SidecarSetList, sidecarSet *appsv1alpha1.SidecarSet, fldPath *field.Path) field.ErrorList {
-	allErrs := field.ErrorList{}
-
-	// record initContainer, container, volume name of other sidecarsets in cluster
-	// container name -> sidecarset
-	containerInOthers := make(map[string]*appsv1alpha1.SidecarSet)
-	// volume name -> sidecarset
-	volumeInOthers := make(map[string]*appsv1alpha1.SidecarSet)
-	// init container name -> sidecarset
-	initContainerInOthers := make(map[string]*appsv1alpha1.SidecarSet)
-	// patch pod annotation key -> sidecarset.Name#patchPolicy
-	annotationsInOthers := make(map[string]string)
-
 	matchedList := make([]*appsv1alpha1.SidecarSet, 0)
 	for i := range sidecarSets.Items {
 		obj := &sidecarSets.Items[i]
@@ -362,84 +407,53 @@ func validateSidecarConflict(c client.Client, sidecarSets *appsv1alpha1.SidecarS
 			matchedList = append(matchedList, obj)
 		}
 	}
-	for _, set := range matchedList {
-		//ignore this sidecarset
-		if set.Name == sidecarSet.Name {
-			continue
-		}
-		for _, container := range set.Spec.InitContainers {
-			initContainerInOthers[container.Name] = set
-		}
-		for _, container := range set.Spec.Containers {
-			containerInOthers[container.Name] = set
-		}
-		for _, volume := range set.Spec.Volumes {
-			volumeInOthers[volume.Name] = set
-		}
-		for _, patch := range set.Spec.PatchPodMetadata {
-			if patch.PatchPolicy == appsv1alpha1.SidecarSetRetainPatchPolicy {
-				continue
-			}
-			for key := range patch.Annotations {
-				annotationsInOthers[key] = fmt.Sprintf("%s#%s", set.Name, patch.PatchPolicy)
-			}
-		}
-	}
+	return sidecarcontrol.ValidateSidecarSetConflict(sidecarSet, matchedList, fldPath)
+}
 
-	// whether initContainers conflict
-	for _, container := range sidecarSet.Spec.InitContainers {
-		if other, ok := initContainerInOthers[container.Name]; ok {
-			allErrs = append(allErrs, field.Invalid(fldPath.Child("containers"), container.Name, fmt.Sprintf(
-				"container %v already exist in %v", container.Name, other.Name)))
-		}
+func validateResourcesPolicy(policy *appsv1alpha1.SidecarResourcesPolicy, resources v1.ResourceRequirements, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if policy == nil {
+		return allErrs
 	}
-
-	// whether containers conflict
-	for _, container := range sidecarSet.Spec.Containers {
-		if other, ok := containerInOthers[container.Name]; ok {
-			allErrs = append(allErrs, field.Invalid(fldPath.Child("containers"), container.Name, fmt.Sprintf(
-				"container %v already exist in %v", container.Name, other.Name)))
+	validateExpressions := func(expressions map[v1.ResourceName]appsv1alpha1.SidecarResourceExpression, fldPath *field.Path) {
+		for name, expr := range expressions {
+			exprPath := fldPath.Key(string(name))
+			if expr.Percent <= 0 {
+				allErrs = append(allErrs, field.Invalid(exprPath.Child("percent"), expr.Percent, "must be greater than 0"))
+			}
+			if expr.Min != nil && expr.Max != nil && expr.Min.Cmp(*expr.Max) > 0 {
+				allErrs = append(allErrs, field.Invalid(exprPath.Child("min"), expr.Min.String(), "must not be greater than max"))
+			}
 		}
 	}
+	validateExpressions(policy.Requests, fldPath.Child("requests"))
+	validateExpressions(policy.Limits, fldPath.Child("limits"))
 
-	// whether volumes conflict
-	for _, volume := range sidecarSet.Spec.Volumes {
-		if other, ok := volumeInOthers[volume.Name]; ok {
-			if !reflect.DeepEqual(&volume, getSidecarsetVolume(volume.Name, other)) {
-				allErrs = append(allErrs, field.Invalid(fldPath.Child("volumes"), volume.Name, fmt.Sprintf(
-					"volume %s is in conflict with sidecarset %s", volume.Name, other.Name)))
+	// the derived requests will be clamped to limits, reject the combination that can never be satisfied
+	for name, reqExpr := range policy.Requests {
+		reqPath := fldPath.Child("requests").Key(string(name))
+		if limExpr, ok := policy.Limits[name]; ok {
+			if reqExpr.Percent > limExpr.Percent {
+				allErrs = append(allErrs, field.Invalid(reqPath.Child("percent"), reqExpr.Percent, "must not be greater than the percent of limits"))
+			}
+			if reqExpr.Min != nil && limExpr.Max != nil && reqExpr.Min.Cmp(*limExpr.Max) > 0 {
+				allErrs = append(allErrs, field.Invalid(reqPath.Child("min"), reqExpr.Min.String(), "must not be greater than the max of limits"))
 			}
+		} else if limit, ok := resources.Limits[name]; ok && reqExpr.Min != nil && reqExpr.Min.Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Invalid(reqPath.Child("min"), reqExpr.Min.String(), "must not be greater than the limits of container"))
 		}
 	}
-
-	// whether pod metadata conflict
-	for _, patch := range sidecarSet.Spec.PatchPodMetadata {
-		if patch.PatchPolicy == appsv1alpha1.SidecarSetRetainPatchPolicy {
+	for name, limExpr := range policy.Limits {
+		if _, ok := policy.Requests[name]; ok {
 			continue
 		}
-		for key := range patch.Annotations {
-			other, ok := annotationsInOthers[key]
-			if !ok {
-				continue
-			}
-			slice := strings.Split(other, "#")
-			if patch.PatchPolicy == appsv1alpha1.SidecarSetOverwritePatchPolicy || appsv1alpha1.SidecarSetPatchPolicyType(slice[1]) == appsv1alpha1.SidecarSetOverwritePatchPolicy {
-				allErrs = append(allErrs, field.Invalid(fldPath.Child("patchPodMetadata"), key, fmt.Sprintf("annotation %s is in conflict with sidecarset %s", key, slice[0])))
-			}
+		if request, ok := resources.Requests[name]; ok && limExpr.Max != nil && request.Cmp(*limExpr.Max) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("limits").Key(string(name)).Child("max"), limExpr.Max.String(), "must not be less than the requests of container"))
 		}
 	}
 	return allErrs
 }
 
-func getSidecarsetVolume(volumeName string, sidecarset *appsv1alpha1.SidecarSet) *v1.Volume {
-	for _, volume := range sidecarset.Spec.Volumes {
-		if volume.Name == volumeName {
-			return &volume
-		}
-	}
-	return nil
-}
-
 func validateDownwardAPI(envs []appsv1alpha1.TransferEnvVar, fldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 	for _, tEnv := range envs {
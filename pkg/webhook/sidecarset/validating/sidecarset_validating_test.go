@@ -14,6 +14,7 @@ import (
 
 	apps "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -1108,3 +1109,83 @@ func TestSidecarSetVolumeConflict(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateResourcesPolicy(t *testing.T) {
+	quantity := func(s string) *resource.Quantity {
+		q := resource.MustParse(s)
+		return &q
+	}
+	cases := []struct {
+		name      string
+		policy    *appsv1alpha1.SidecarResourcesPolicy
+		resources corev1.ResourceRequirements
+		expectErr bool
+	}{
+		{
+			name: "valid policy",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceCPU: {Percent: 10, Min: quantity("100m")}},
+				Limits:   map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceCPU: {Percent: 50, Max: quantity("1")}},
+			},
+		},
+		{
+			name: "percent of requests greater than limits",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceCPU: {Percent: 60}},
+				Limits:   map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceCPU: {Percent: 50}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "min of requests greater than max of limits",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceCPU: {Percent: 10, Min: quantity("2")}},
+				Limits:   map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceCPU: {Percent: 50, Max: quantity("1")}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "min of requests greater than static limits",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				Requests: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceMemory: {Percent: 10, Min: quantity("2Gi")}},
+			},
+			resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}},
+			expectErr: true,
+		},
+		{
+			name: "max of limits less than static requests",
+			policy: &appsv1alpha1.SidecarResourcesPolicy{
+				Limits: map[corev1.ResourceName]appsv1alpha1.SidecarResourceExpression{corev1.ResourceMemory: {Percent: 10, Max: quantity("512Mi")}},
+			},
+			resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}},
+			expectErr: true,
+		},
+	}
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			errs := validateResourcesPolicy(cs.policy, cs.resources, field.NewPath("resourcesPolicy"))
+			if cs.expectErr != (len(errs) > 0) {
+				t.Fatalf("expect error %v, but got %v", cs.expectErr, errs)
+			}
+		})
+	}
+}
+
+func TestValidateNativeSidecarHotUpgrade(t *testing.T) {
+	sidecarSet := &appsv1alpha1.SidecarSet{
+		Spec: appsv1alpha1.SidecarSetSpec{
+			InjectionStrategy: appsv1alpha1.SidecarSetInjectionStrategy{NativeSidecar: true},
+			Containers: []appsv1alpha1.SidecarContainer{
+				{
+					Container:       corev1.Container{Name: "proxy"},
+					UpgradeStrategy: appsv1alpha1.SidecarContainerUpgradeStrategy{UpgradeType: appsv1alpha1.SidecarContainerHotUpgrade},
+				},
+			},
+		},
+	}
+	handler := SidecarSetCreateUpdateHandler{}
+	errs := handler.validateSidecarSetInjectionStrategy(sidecarSet, field.NewPath("spec", "injectionStrategy"))
+	if len(errs) != 1 || errs[0].Field != "spec.containers[0]" {
+		t.Fatalf("expect error of spec.containers[0], but got %v", errs)
+	}
+}
@@ -58,7 +58,7 @@ func (h *StatefulSetCreateUpdateHandler) Handle(ctx context.Context, req admissi
 		if err := h.decodeObject(req, obj); err != nil {
 			return admission.Errored(http.StatusBadRequest, err)
 		}
-		if allErrs := validateStatefulSet(obj); len(allErrs) > 0 {
+		if allErrs := validateStatefulSet(obj, nil); len(allErrs) > 0 {
 			return admission.Errored(http.StatusUnprocessableEntity, allErrs.ToAggregate())
 		}
 	case admissionv1.Update:
@@ -69,7 +69,7 @@ func (h *StatefulSetCreateUpdateHandler) Handle(ctx context.Context, req admissi
 			return admission.Errored(http.StatusBadRequest, err)
 		}
 
-		validationErrorList := validateStatefulSet(obj)
+		validationErrorList := validateStatefulSet(obj, oldObj)
 		updateErrorList := ValidateStatefulSetUpdate(obj, oldObj)
 		if allErrs := append(validationErrorList, updateErrorList...); len(allErrs) > 0 {
 			return admission.Errored(http.StatusUnprocessableEntity, allErrs.ToAggregate())
@@ -66,6 +66,26 @@ func validateReserveOrdinals(spec *appsv1beta1.StatefulSetSpec, fldPath *field.P
 	return allErrs
 }
 
+func validateUpdateOrdinals(ordinals []intstr.IntOrString, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, elem := range ordinals {
+		if elem.Type == intstr.String {
+			if !reserveOrdinalRangeRexp.MatchString(elem.StrVal) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i), elem.StrVal, "not a valid range"))
+			} else if start, end, err := apiutil.ParseRange(elem.StrVal); err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i), elem.StrVal, err.Error()))
+			} else if end-start >= apiutil.MaxOrdinalRangeSize {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i), elem.StrVal,
+					fmt.Sprintf("must not contain more than %d ordinals", apiutil.MaxOrdinalRangeSize)))
+			}
+		}
+		if elem.Type == intstr.Int && elem.IntVal < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), elem.IntVal, "must be non-negative"))
+		}
+	}
+	return allErrs
+}
+
 func validateScaleStrategy(spec *appsv1beta1.StatefulSetSpec, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
 
@@ -125,6 +145,11 @@ func validateRollingUpdateStatefulSetStrategyTypeUnorderedUpdate(spec *appsv1bet
 				Child("rollingUpdate").Child("unorderedUpdate").Child("priorityStrategy"),
 				err.Error()))
 		}
+		if err := spec.UpdateStrategy.RollingUpdate.UnorderedUpdate.TopologyStrategy.FieldsValidation(); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("updateStrategy").
+				Child("rollingUpdate").Child("unorderedUpdate").Child("topologyStrategy"),
+				spec.UpdateStrategy.RollingUpdate.UnorderedUpdate.TopologyStrategy, err.Error()))
+		}
 	}
 	return allErrs
 }
@@ -155,6 +180,19 @@ func validateRollingUpdateStatefulSetStrategyType(spec *appsv1beta1.StatefulSetS
 			allErrs = append(allErrs, validateMaxUnavailableField(maxUnavailable, spec, fldPath.Child("updateStrategy").Child("rollingUpdate").Child("maxUnavailable"))...)
 		}
 
+		// validate the `ordinals` field
+		allErrs = append(allErrs, validateUpdateOrdinals(spec.UpdateStrategy.RollingUpdate.Ordinals, fldPath.Child("updateStrategy").Child("rollingUpdate").Child("ordinals"))...)
+
+		// validate the `maxSurge` field
+		if maxSurge := spec.UpdateStrategy.RollingUpdate.MaxSurge; maxSurge != nil {
+			allErrs = append(allErrs, validateMaxSurgeField(maxSurge, spec, fldPath.Child("updateStrategy").Child("rollingUpdate").Child("maxSurge"))...)
+		}
+
+		// validate the `volumeSnapshotBeforeUpdate` field
+		if strategy := spec.UpdateStrategy.RollingUpdate.VolumeSnapshotBeforeUpdate; strategy != nil {
+			allErrs = append(allErrs, validateVolumeSnapshotStrategy(strategy, fldPath.Child("updateStrategy").Child("rollingUpdate").Child("volumeSnapshotBeforeUpdate"))...)
+		}
+
 		// validate the `PodUpdatePolicy` related fields
 		allErrs = append(allErrs, validatePodUpdatePolicy(spec, fldPath)...)
 
@@ -231,8 +269,11 @@ func validateActiveDeadlineSeconds(spec *appsv1beta1.StatefulSetSpec, fldPath *f
 }
 
 // ValidateStatefulSetSpec tests if required fields in the StatefulSet spec are set.
-func validateStatefulSetSpec(spec *appsv1beta1.StatefulSetSpec, fldPath *field.Path) field.ErrorList {
+func validateStatefulSetSpec(spec, oldSpec *appsv1beta1.StatefulSetSpec, fldPath *field.Path) field.ErrorList {
 	var allErrs field.ErrorList
+	if oldSpec == nil {
+		oldSpec = &appsv1beta1.StatefulSetSpec{}
+	}
 
 	allErrs = append(allErrs, validatePodManagementPolicy(spec, fldPath)...)
 	allErrs = append(allErrs, validateReserveOrdinals(spec, fldPath)...)
@@ -251,6 +292,32 @@ func validateStatefulSetSpec(spec *appsv1beta1.StatefulSetSpec, fldPath *field.P
 	// validate `spec.Template.Spec.ActiveDeadlineSeconds`
 	allErrs = append(allErrs, validateActiveDeadlineSeconds(spec, fldPath)...)
 
+	// validate `spec.Lifecycle`
+	allErrs = append(allErrs, webhookutil.ValidateLifecycle(spec.Lifecycle, oldSpec.Lifecycle, fldPath.Child("lifecycle"))...)
+
+	// validate `spec.VolumeClaimUpdateStrategy`
+	allErrs = append(allErrs, validateVolumeClaimUpdateStrategy(&spec.VolumeClaimUpdateStrategy, &oldSpec.VolumeClaimUpdateStrategy, fldPath.Child("volumeClaimUpdateStrategy"))...)
+
+	return allErrs
+}
+
+func validateVolumeClaimUpdateStrategy(strategy, oldStrategy *appsv1beta1.VolumeClaimUpdateStrategy, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch strategy.Type {
+	case "", appsv1beta1.OnPodRollingUpdateVolumeClaimUpdateStrategyType, appsv1beta1.OnPVCDeleteVolumeClaimUpdateStrategyType:
+		if strategy.PreRecreateHook != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("preRecreateHook"),
+				fmt.Sprintf("can only be set with %s type", appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType)))
+		}
+	case appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType:
+		allErrs = append(allErrs, webhookutil.ValidateLifecycleHook(strategy.PreRecreateHook, oldStrategy.PreRecreateHook, fldPath.Child("preRecreateHook"))...)
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), strategy.Type, []string{
+			string(appsv1beta1.OnPodRollingUpdateVolumeClaimUpdateStrategyType),
+			string(appsv1beta1.OnPVCDeleteVolumeClaimUpdateStrategyType),
+			string(appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType),
+		}))
+	}
 	return allErrs
 }
 
@@ -302,10 +369,43 @@ func validateMaxUnavailableField(maxUnavailable *intstr.IntOrString, spec *appsv
 	return allErrs
 }
 
-// ValidateStatefulSet validates a StatefulSet.
-func validateStatefulSet(statefulSet *appsv1beta1.StatefulSet) field.ErrorList {
+func validateMaxSurgeField(maxSurge *intstr.IntOrString, spec *appsv1beta1.StatefulSetSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := appsvalidation.ValidatePositiveIntOrPercent(*maxSurge, fldPath)
+	if _, err := intstr.GetValueFromIntOrPercent(maxSurge, 1, true); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath, maxSurge, fmt.Sprintf("getValueFromIntOrPercent error: %v", err)))
+	}
+	if apps.ParallelPodManagement != spec.PodManagementPolicy &&
+		(maxSurge.Type != intstr.Int || maxSurge.IntVal != 0) {
+		allErrs = append(allErrs, field.Invalid(fldPath, maxSurge, "can only work with Parallel PodManagementPolicyType"))
+	}
+	return allErrs
+}
+
+func validateVolumeSnapshotStrategy(strategy *appsv1beta1.VolumeSnapshotStrategy, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if strategy.TimeoutSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("timeoutSeconds"), strategy.TimeoutSeconds, "must be non-negative"))
+	}
+	if strategy.RetentionLimit < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("retentionLimit"), strategy.RetentionLimit, "must be non-negative"))
+	}
+	switch strategy.FailurePolicy {
+	case "", appsv1beta1.VolumeSnapshotFailurePolicyProceed, appsv1beta1.VolumeSnapshotFailurePolicyBlock:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("failurePolicy"), strategy.FailurePolicy,
+			[]string{string(appsv1beta1.VolumeSnapshotFailurePolicyProceed), string(appsv1beta1.VolumeSnapshotFailurePolicyBlock)}))
+	}
+	return allErrs
+}
+
+// ValidateStatefulSet validates a StatefulSet, and oldStatefulSet is nil on creation.
+func validateStatefulSet(statefulSet, oldStatefulSet *appsv1beta1.StatefulSet) field.ErrorList {
 	allErrs := apivalidation.ValidateObjectMeta(&statefulSet.ObjectMeta, true, appsvalidation.ValidateStatefulSetName, field.NewPath("metadata"))
-	allErrs = append(allErrs, validateStatefulSetSpec(&statefulSet.Spec, field.NewPath("spec"))...)
+	var oldSpec *appsv1beta1.StatefulSetSpec
+	if oldStatefulSet != nil {
+		oldSpec = &oldStatefulSet.Spec
+	}
+	allErrs = append(allErrs, validateStatefulSetSpec(&statefulSet.Spec, oldSpec, field.NewPath("spec"))...)
 	return allErrs
 }
 
@@ -399,6 +499,13 @@ func ValidateVolumeClaimTemplateUpdate(c client.Client, sts, oldSts *appsv1beta1
 			continue
 		}
 		if !resizeOnly {
+			// the pvcs will be recreated if they cannot be updated in place
+			if sts.Spec.VolumeClaimUpdateStrategy.Type == appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType {
+				if errs := validateVolumeClaimTemplateRecreate(c, oldTemplate, &template, field.NewPath("spec", templateIdStr)); len(errs) > 0 {
+					return errs
+				}
+				continue
+			}
 			return field.ErrorList{field.Invalid(field.NewPath("spec", templateIdStr), template, "volumeClaimTemplate can not be modified when OnRollingUpdate")}
 		}
 		// check if sc allow volume expand
@@ -450,6 +557,29 @@ func GetDefaultStorageClass(c client.Client) (*storagev1.StorageClass, error) {
 	return defaultSC, nil
 }
 
+// validateVolumeClaimTemplateRecreate tests if only the fields that make the claims recreated are changed,
+// which are storage class and access modes, together with the storage size.
+func validateVolumeClaimTemplateRecreate(c client.Client, oldTemplate, template *v1.PersistentVolumeClaim, fldPath *field.Path) field.ErrorList {
+	oldSpec := oldTemplate.Spec.DeepCopy()
+	oldSpec.StorageClassName = template.Spec.StorageClassName
+	oldSpec.AccessModes = template.Spec.AccessModes
+	oldSpec.Resources = template.Spec.Resources
+	if !apiequality.Semantic.DeepEqual(oldSpec, &template.Spec) {
+		return field.ErrorList{field.Forbidden(fldPath.Child("spec"),
+			fmt.Sprintf("only storageClassName, accessModes and resources can be modified when %s", appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType))}
+	}
+
+	scName := template.Spec.StorageClassName
+	if scName == nil || (oldTemplate.Spec.StorageClassName != nil && *oldTemplate.Spec.StorageClassName == *scName) {
+		return nil
+	}
+	sc := &storagev1.StorageClass{}
+	if err := c.Get(context.TODO(), client.ObjectKey{Name: *scName}, sc); err != nil {
+		return field.ErrorList{field.Invalid(fldPath.Child("spec", "storageClassName"), *scName, "can not get sc")}
+	}
+	return nil
+}
+
 func isPVCResize(claim, template *v1.PersistentVolumeClaim) bool {
 	if claim.Spec.Resources.Requests.Storage().Cmp(*template.Spec.Resources.Requests.Storage()) != 0 ||
 		claim.Spec.Resources.Limits.Storage().Cmp(*template.Spec.Resources.Limits.Storage()) != 0 {
@@ -152,7 +152,7 @@ func TestValidateStatefulSet(t *testing.T) {
 	for i, successCase := range successCases {
 		t.Run("success case "+strconv.Itoa(i), func(t *testing.T) {
 			setTestDefault(&successCase)
-			if errs := validateStatefulSet(&successCase); len(errs) != 0 {
+			if errs := validateStatefulSet(&successCase, nil); len(errs) != 0 {
 				t.Errorf("expected success: %v", errs)
 			}
 		})
@@ -457,7 +457,7 @@ func TestValidateStatefulSet(t *testing.T) {
 	for k, v := range errorCases {
 		t.Run(k, func(t *testing.T) {
 			setTestDefault(&v)
-			errs := validateStatefulSet(&v)
+			errs := validateStatefulSet(&v, nil)
 			if len(errs) == 0 {
 				t.Errorf("expected failure for %s", k)
 			}
@@ -760,6 +760,7 @@ func newFakeStorageClass(name string, allowExpansion, isDefault bool) *storagev1
 func TestValidateVolumeClaimTemplateUpdate(t *testing.T) {
 	allowExpandSC := newFakeStorageClass("allowExpand", true, false)
 	disallowExpandSC := newFakeStorageClass("disallowExpand", false, false)
+	blockVolumeMode := v1.PersistentVolumeBlock
 	fakeClient := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(allowExpandSC, disallowExpandSC).Build()
 
 	tests := []struct {
@@ -929,6 +930,104 @@ func TestValidateVolumeClaimTemplateUpdate(t *testing.T) {
 			},
 			expectedErrors: true,
 		},
+		{
+			name: "recreate on pod rolling update strategy and change sc",
+			sts: &appsv1beta1.StatefulSet{
+				Spec: appsv1beta1.StatefulSetSpec{
+					VolumeClaimUpdateStrategy: appsv1beta1.VolumeClaimUpdateStrategy{
+						Type: appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType,
+					},
+					VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+							Spec: v1.PersistentVolumeClaimSpec{
+								StorageClassName: &disallowExpandSC.Name,
+								Resources: v1.VolumeResourceRequirements{
+									Requests: map[v1.ResourceName]resource.Quantity{
+										v1.ResourceStorage: resource.MustParse("3Gi"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			oldSts: &appsv1beta1.StatefulSet{
+				Spec: appsv1beta1.StatefulSetSpec{
+					VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+							Spec: v1.PersistentVolumeClaimSpec{
+								StorageClassName: &allowExpandSC.Name,
+								Resources: v1.VolumeResourceRequirements{
+									Requests: map[v1.ResourceName]resource.Quantity{
+										v1.ResourceStorage: resource.MustParse("2Gi"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErrors: false,
+		},
+		{
+			name: "recreate on pod rolling update strategy and change sc to a non-existing one",
+			sts: &appsv1beta1.StatefulSet{
+				Spec: appsv1beta1.StatefulSetSpec{
+					VolumeClaimUpdateStrategy: appsv1beta1.VolumeClaimUpdateStrategy{
+						Type: appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType,
+					},
+					VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+							Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: utilpointer.String("not-found")},
+						},
+					},
+				},
+			},
+			oldSts: &appsv1beta1.StatefulSet{
+				Spec: appsv1beta1.StatefulSetSpec{
+					VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+							Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &allowExpandSC.Name},
+						},
+					},
+				},
+			},
+			expectedErrors: true,
+		},
+		{
+			name: "recreate on pod rolling update strategy and change sc with volume mode",
+			sts: &appsv1beta1.StatefulSet{
+				Spec: appsv1beta1.StatefulSetSpec{
+					VolumeClaimUpdateStrategy: appsv1beta1.VolumeClaimUpdateStrategy{
+						Type: appsv1beta1.RecreateOnPodRollingUpdateVolumeClaimUpdateStrategyType,
+					},
+					VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+							Spec: v1.PersistentVolumeClaimSpec{
+								StorageClassName: &disallowExpandSC.Name,
+								VolumeMode:       &blockVolumeMode,
+							},
+						},
+					},
+				},
+			},
+			oldSts: &appsv1beta1.StatefulSet{
+				Spec: appsv1beta1.StatefulSetSpec{
+					VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+						{
+							ObjectMeta: metav1.ObjectMeta{Name: "pvc-1"},
+							Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &allowExpandSC.Name},
+						},
+					},
+				},
+			},
+			expectedErrors: true,
+		},
 		{
 			name: "onDelete update strategy and expand size",
 			sts: &appsv1beta1.StatefulSet{
@@ -1193,3 +1292,39 @@ func TestValidateReserveOrdinals(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateUpdateOrdinals(t *testing.T) {
+	tests := []struct {
+		name      string
+		ordinals  []intstr.IntOrString
+		expectErr bool
+	}{
+		{
+			name:     "valid ordinals",
+			ordinals: []intstr.IntOrString{intstr.FromInt32(3), intstr.FromString("0-2")},
+		},
+		{
+			name:      "negative ordinal",
+			ordinals:  []intstr.IntOrString{intstr.FromInt32(-1)},
+			expectErr: true,
+		},
+		{
+			name:      "invalid range",
+			ordinals:  []intstr.IntOrString{intstr.FromString("3-1")},
+			expectErr: true,
+		},
+		{
+			name:      "unbounded range",
+			ordinals:  []intstr.IntOrString{intstr.FromString("0-2147483647")},
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := validateUpdateOrdinals(test.ordinals, field.NewPath("ordinals"))
+			if test.expectErr != (len(errs) > 0) {
+				t.Fatalf("expect error %v, but got %v", test.expectErr, errs)
+			}
+		})
+	}
+}
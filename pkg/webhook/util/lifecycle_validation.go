@@ -0,0 +1,108 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"net/url"
+
+	v1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/features"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+)
+
+// ValidateLifecycle validates the timeout and failure policy of the lifecycle hooks.
+// The oldLifecycle is nil on creation.
+func ValidateLifecycle(lifecycle, oldLifecycle *appspub.Lifecycle, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if lifecycle == nil {
+		return allErrs
+	}
+	if oldLifecycle == nil {
+		oldLifecycle = &appspub.Lifecycle{}
+	}
+	allErrs = append(allErrs, ValidateLifecycleHook(lifecycle.PreDelete, oldLifecycle.PreDelete, fldPath.Child("preDelete"))...)
+	allErrs = append(allErrs, ValidateLifecycleHook(lifecycle.InPlaceUpdate, oldLifecycle.InPlaceUpdate, fldPath.Child("inPlaceUpdate"))...)
+	allErrs = append(allErrs, ValidateLifecycleHook(lifecycle.PreNormal, oldLifecycle.PreNormal, fldPath.Child("preNormal"))...)
+	allErrs = append(allErrs, ValidateLifecycleHook(lifecycle.PostInPlaceUpdate, oldLifecycle.PostInPlaceUpdate, fldPath.Child("postInPlaceUpdate"))...)
+	return allErrs
+}
+
+// ValidateLifecycleHook validates the timeout, failure policy and http handler of a lifecycle hook.
+// The http handler can only be added with the feature gate enabled, but the existing one is kept
+// after the feature gate is disabled. The oldHook is nil on creation.
+func ValidateLifecycleHook(hook, oldHook *appspub.LifecycleHook, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if hook == nil {
+		return allErrs
+	}
+	allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(hook.TimeoutSeconds), fldPath.Child("timeoutSeconds"))...)
+	switch hook.FailurePolicy {
+	case "", appspub.LifecycleHookFailurePolicyProceed, appspub.LifecycleHookFailurePolicyBlock:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("failurePolicy"), hook.FailurePolicy,
+			[]string{string(appspub.LifecycleHookFailurePolicyProceed), string(appspub.LifecycleHookFailurePolicyBlock)}))
+	}
+	if hook.HTTPHandler != nil {
+		if !utilfeature.DefaultFeatureGate.Enabled(features.LifecycleHookHTTPHandler) && (oldHook == nil || oldHook.HTTPHandler == nil) {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("httpHandler"),
+				fmt.Sprintf("httpHandler requires the %s feature gate to be enabled", features.LifecycleHookHTTPHandler)))
+			return allErrs
+		}
+		allErrs = append(allErrs, validateLifecycleHTTPHandler(hook.HTTPHandler, fldPath.Child("httpHandler"))...)
+	}
+	return allErrs
+}
+
+func validateLifecycleHTTPHandler(handler *appspub.LifecycleHTTPHandler, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	switch {
+	case handler.URL != "" && handler.Port != 0:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("port"), "only one of url and port can be set"))
+	case handler.URL != "":
+		if u, err := url.Parse(handler.URL); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("url"), handler.URL, err.Error()))
+		} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("url"), handler.URL, "must be an absolute http or https url"))
+		}
+		if handler.Path != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("path"), "path can only be set with port"))
+		}
+		if handler.Scheme != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("scheme"), "scheme can only be set with port"))
+		}
+	case handler.Port != 0:
+		if handler.Port < 1 || handler.Port > 65535 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), handler.Port, "must be between 1 and 65535"))
+		}
+		switch handler.Scheme {
+		case "", v1.URISchemeHTTP, v1.URISchemeHTTPS:
+		default:
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("scheme"), handler.Scheme,
+				[]string{string(v1.URISchemeHTTP), string(v1.URISchemeHTTPS)}))
+		}
+	default:
+		allErrs = append(allErrs, field.Required(fldPath, "one of url and port must be set"))
+	}
+	allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(handler.TimeoutSeconds), fldPath.Child("timeoutSeconds"))...)
+	allErrs = append(allErrs, apivalidation.ValidateNonnegativeField(int64(handler.PeriodSeconds), fldPath.Child("periodSeconds"))...)
+	return allErrs
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 The Kruise Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	appspub "github.com/openkruise/kruise/apis/apps/pub"
+	"github.com/openkruise/kruise/pkg/features"
+	utilfeature "github.com/openkruise/kruise/pkg/util/feature"
+)
+
+func TestValidateLifecycleHTTPHandler(t *testing.T) {
+	withHTTPHandler := &appspub.Lifecycle{
+		PreDelete: &appspub.LifecycleHook{HTTPHandler: &appspub.LifecycleHTTPHandler{URL: "http://foo/hooks"}},
+	}
+	withoutHTTPHandler := &appspub.Lifecycle{
+		PreDelete: &appspub.LifecycleHook{FinalizersHandler: []string{"example.com/hook"}},
+	}
+
+	cases := []struct {
+		name         string
+		gateEnabled  bool
+		lifecycle    *appspub.Lifecycle
+		oldLifecycle *appspub.Lifecycle
+		expectedErr  bool
+	}{
+		{
+			name:        "create with feature gate enabled",
+			gateEnabled: true,
+			lifecycle:   withHTTPHandler,
+		},
+		{
+			name:        "create with feature gate disabled",
+			lifecycle:   withHTTPHandler,
+			expectedErr: true,
+		},
+		{
+			name:         "add on update with feature gate disabled",
+			lifecycle:    withHTTPHandler,
+			oldLifecycle: withoutHTTPHandler,
+			expectedErr:  true,
+		},
+		{
+			name:         "keep on update with feature gate disabled",
+			lifecycle:    withHTTPHandler,
+			oldLifecycle: withHTTPHandler,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			defer utilfeature.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, features.LifecycleHookHTTPHandler, tc.gateEnabled)()
+			errs := ValidateLifecycle(tc.lifecycle, tc.oldLifecycle, field.NewPath("lifecycle"))
+			if tc.expectedErr != (len(errs) > 0) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, errs)
+			}
+		})
+	}
+}